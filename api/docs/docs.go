@@ -24,107 +24,234 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/messages": {
-            "post": {
-                "description": "Send a message to the message broker",
-                "consumes": [
-                    "application/json"
+        "/api/v1/admin/products": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "List soft deleted products one page at a time",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "messages"
+                    "admin"
                 ],
-                "summary": "Send a message",
+                "summary": "List deleted products",
                 "parameters": [
                     {
-                        "description": "Message content",
-                        "name": "message",
-                        "in": "body",
-                        "required": true,
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (1-100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "-id",
+                            "name",
+                            "-name",
+                            "price",
+                            "-price",
+                            "stock",
+                            "-stock",
+                            "created_at",
+                            "-created_at"
+                        ],
+                        "type": "string",
+                        "description": "Sort field, prefix with - for descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return, e.g. id,name,price",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_pagination.Page-github_com_Napat_golang-testcontainers-demo_pkg_model_ProductResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     }
+                }
+            }
+        },
+        "/api/v1/admin/products/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Permanently remove a soft deleted product. Live products must be deleted first.",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Purge a deleted product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Accepted",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Product is not deleted",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/orders": {
+        "/api/v1/admin/products/{id}/restore": {
             "post": {
-                "description": "Create a new order in the system",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "orders"
+                    "admin"
                 ],
-                "summary": "Create a new order",
+                "summary": "Restore a deleted product",
                 "parameters": [
                     {
-                        "description": "Order object",
-                        "name": "order",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Order"
-                        }
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Order"
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.ProductResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the product"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Product is not deleted",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/orders/search": {
+        "/api/v1/admin/users": {
             "get": {
-                "description": "Search orders using customer ID",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "List soft deleted users one page at a time",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "orders"
+                    "admin"
                 ],
-                "summary": "Search orders",
+                "summary": "List deleted users",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (1-100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "description": "Customer ID to search for",
-                        "name": "customer_id",
+                        "description": "Opaque cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "-id",
+                            "username",
+                            "-username",
+                            "email",
+                            "-email",
+                            "created_at",
+                            "-created_at"
+                        ],
+                        "type": "string",
+                        "description": "Sort field, prefix with - for descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return, e.g. id,username,email",
+                        "name": "fields",
                         "in": "query"
                     }
                 ],
@@ -132,34 +259,97 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Order"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_pagination.Page-github_com_Napat_golang-testcontainers-demo_pkg_model_UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/orders/simple-search": {
-            "get": {
-                "description": "Search orders using a simple query string",
-                "consumes": [
-                    "application/json"
+        "/api/v1/admin/users/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Permanently remove a soft deleted user and its role assignments. Live users must be deleted first.",
+                "tags": [
+                    "admin"
+                ],
+                "summary": "Purge a deleted user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "User is not deleted",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/admin/users/{id}/restore": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Bring a soft deleted user back. The user is restored as inactive and has to be reactivated explicitly.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "orders"
+                    "admin"
                 ],
-                "summary": "Simple search orders",
+                "summary": "Restore a deleted user",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Search query",
-                        "name": "q",
-                        "in": "query",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     }
                 ],
@@ -167,18 +357,45 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Order"
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the user"
                             }
                         }
+                    },
+                    "400": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "User is not deleted",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
                     }
                 }
             }
         },
-        "/api/v1/products": {
-            "get": {
-                "description": "Get a list of all products",
+        "/api/v1/auth/login": {
+            "post": {
+                "description": "Verify username and password and issue an access and refresh token pair",
                 "consumes": [
                     "application/json"
                 ],
@@ -186,23 +403,1020 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "products"
+                    "auth"
+                ],
+                "summary": "Log in",
+                "parameters": [
+                    {
+                        "description": "Login credentials",
+                        "name": "credentials",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.LoginRequest"
+                        }
+                    }
                 ],
-                "summary": "Get all products",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Product"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_auth.TokenPair"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/refresh": {
+            "post": {
+                "description": "Exchange a refresh token for a new token pair. The refresh token can only be used once.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Refresh tokens",
+                "parameters": [
+                    {
+                        "description": "Refresh token",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.TokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_auth.TokenPair"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/auth/revoke": {
+            "post": {
+                "description": "Revoke an access or refresh token until it expires",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Revoke a token",
+                "parameters": [
+                    {
+                        "description": "Token to revoke",
+                        "name": "token",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.TokenRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Send a message to the message broker",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Send a message",
+                "parameters": [
+                    {
+                        "description": "Message content",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.MessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orders": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List orders one page at a time. Pass next_cursor from the previous page as cursor to continue.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "List orders",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (1-100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "created_at",
+                            "-created_at",
+                            "total",
+                            "-total",
+                            "id",
+                            "-id"
+                        ],
+                        "type": "string",
+                        "description": "Sort field, prefix with - for descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by customer ID",
+                        "name": "customer_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return, e.g. id,status,total",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_pagination.Page-github_com_Napat_golang-testcontainers-demo_pkg_model_OrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new order in the system",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Create a new order",
+                "parameters": [
+                    {
+                        "description": "Order object",
+                        "name": "order",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Order"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.OrderResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orders/search": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Search orders using customer ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Search orders",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Customer ID to search for",
+                        "name": "customer_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return, e.g. id,status,total",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.OrderResponse"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/orders/simple-search": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Search orders using a simple query string",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "orders"
+                ],
+                "summary": "Simple search orders",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Search query",
+                        "name": "q",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return, e.g. id,status,total",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.OrderResponse"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/products": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List products one page at a time. Pass next_cursor from the previous page as cursor to continue.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "List products",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (1-100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "-id",
+                            "name",
+                            "-name",
+                            "price",
+                            "-price",
+                            "stock",
+                            "-stock",
+                            "created_at",
+                            "-created_at"
+                        ],
+                        "type": "string",
+                        "description": "Sort field, prefix with - for descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimum price",
+                        "name": "min_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Maximum price",
+                        "name": "max_price",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum stock, 1 lists only products in stock",
+                        "name": "min_stock",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return, e.g. id,name,price",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_pagination.Page-github_com_Napat_golang-testcontainers-demo_pkg_model_ProductResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new product in the system",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Create a new product",
+                "parameters": [
+                    {
+                        "description": "Product creation request",
+                        "name": "product",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.ProductCreate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.ProductResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "SKU already exists",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Database unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/products/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a product by its ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Get product by ID",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return, e.g. id,name,price",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.ProductResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the product"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace a product. If-Match must carry the ETag returned by GET.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "products"
+                ],
+                "summary": "Update a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being modified",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Product update request",
+                        "name": "product",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.ProductUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.ProductResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the product"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Soft delete a product. It can be restored by an administrator until the retention window purges it. If-Match must carry the ETag returned by GET.",
+                "tags": [
+                    "products"
+                ],
+                "summary": "Delete a product",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Product ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being deleted",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Precondition Failed",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Precondition Required",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List users one page at a time. Pass next_cursor from the previous page as cursor to continue.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "List users",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (1-100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Opaque cursor from the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "id",
+                            "-id",
+                            "username",
+                            "-username",
+                            "email",
+                            "-email",
+                            "created_at",
+                            "-created_at"
+                        ],
+                        "type": "string",
+                        "description": "Sort field, prefix with - for descending",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "active",
+                            "inactive",
+                            "suspended",
+                            "deleted"
+                        ],
+                        "type": "string",
+                        "description": "Filter by status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Filter by exact email domain, e.g. example.com",
+                        "name": "email_domain",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return, e.g. id,username,email",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_pagination.Page-github_com_Napat_golang-testcontainers-demo_pkg_model_UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Create a new user with the provided details",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Create a new user",
+                "parameters": [
+                    {
+                        "description": "User creation request",
+                        "name": "user",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserCreate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Username or email already taken",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "503": {
+                        "description": "Database unavailable",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/users/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get user details by their ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "users"
+                ],
+                "summary": "Get user by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated fields to return, e.g. id,username,email",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the user"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     }
                 }
             },
-            "post": {
-                "description": "Create a new product in the system",
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace the email and full name of a user",
                 "consumes": [
                     "application/json"
                 ],
@@ -210,98 +1424,161 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "products"
+                    "users"
                 ],
-                "summary": "Create a new product",
+                "summary": "Update a user",
                 "parameters": [
                     {
-                        "description": "Product object",
-                        "name": "product",
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being modified",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "User update request",
+                        "name": "user",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Product"
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserUpdate"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the user"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Error response",
                         "schema": {
-                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Product"
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     }
                 }
-            }
-        },
-        "/api/v1/products/{id}": {
-            "get": {
-                "description": "Get a product by its ID",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "Soft delete a user. Deleted users disappear from every endpoint except the admin ones and can be restored by an administrator until the retention window purges them.",
                 "tags": [
-                    "products"
+                    "users"
                 ],
-                "summary": "Get product by ID",
+                "summary": "Delete a user",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Product ID",
+                        "type": "string",
+                        "description": "User ID",
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being modified",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Error response",
                         "schema": {
-                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Product"
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
-                    }
-                }
-            }
-        },
-        "/api/v1/users": {
-            "get": {
-                "description": "Get a list of all users in the system",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "users"
-                ],
-                "summary": "Get all users",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "404": {
+                        "description": "Error response",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.User"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     },
                     "500": {
                         "description": "Error response",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     }
                 }
             },
-            "post": {
-                "description": "Create a new user with the provided details",
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update only the fields present in the request body",
                 "consumes": [
                     "application/json"
                 ],
@@ -311,49 +1588,98 @@ const docTemplate = `{
                 "tags": [
                     "users"
                 ],
-                "summary": "Create a new user",
+                "summary": "Partially update a user",
                 "parameters": [
                     {
-                        "description": "User creation request",
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being modified",
+                        "name": "If-Match",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "User patch request",
                         "name": "user",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserCreate"
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserPatch"
                         }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.User"
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the user"
+                            }
                         }
                     },
                     "400": {
                         "description": "Error response",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "428": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     },
                     "500": {
                         "description": "Error response",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     }
                 }
             }
         },
-        "/api/v1/users/{id}": {
-            "get": {
-                "description": "Get user details by their ID",
+        "/api/v1/users/{id}/status": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Move a user to another status. Allowed transitions: active, inactive and suspended can move between each other or to deleted. Moving to deleted soft deletes the user.",
                 "consumes": [
                     "application/json"
                 ],
@@ -363,7 +1689,7 @@ const docTemplate = `{
                 "tags": [
                     "users"
                 ],
-                "summary": "Get user by ID",
+                "summary": "Change user status",
                 "parameters": [
                     {
                         "type": "string",
@@ -371,31 +1697,70 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "ETag of the version being modified",
+                        "name": "If-Match",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Status change request",
+                        "name": "status",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserStatusChange"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.User"
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserResponse"
+                        },
+                        "headers": {
+                            "ETag": {
+                                "type": "string",
+                                "description": "Current version of the user"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     },
                     "404": {
                         "description": "Error response",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "412": {
+                        "description": "Error response",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid fields, all listed in errors",
+                        "schema": {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     },
                     "500": {
                         "description": "Error response",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_response.Problem"
                         }
                     }
                 }
@@ -411,49 +1776,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "health"
-                ],
-                "summary": "Get health status",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "$ref": "#/definitions/internal_handler_health.HealthStatus"
-                        }
-                    }
-                }
-            }
-        },
-        "/orders": {
-            "get": {
-                "description": "Get a list of all orders",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "orders"
-                ],
-                "summary": "List all orders",
-                "responses": {
-                    "200": {
-                        "description": "OK",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Order"
-                            }
-                        }
-                    },
-                    "204": {
-                        "description": "No Content"
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "health"
+                ],
+                "summary": "Get health status",
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_errors.Error"
+                            "$ref": "#/definitions/internal_handler_health.HealthStatus"
                         }
                     }
                 }
@@ -461,18 +1791,37 @@ const docTemplate = `{
         }
     },
     "definitions": {
-        "github_com_Napat_golang-testcontainers-demo_pkg_errors.Error": {
+        "github_com_Napat_golang-testcontainers-demo_pkg_auth.TokenPair": {
+            "description": "Access and refresh token pair",
             "type": "object",
             "properties": {
-                "code": {
-                    "type": "integer"
-                },
-                "message": {
+                "access_token": {
                     "type": "string"
                 },
-                "op": {
-                    "description": "Operation that failed",
+                "expires_in": {
+                    "description": "access token lifetime in seconds",
+                    "type": "integer",
+                    "example": 900
+                },
+                "refresh_token": {
                     "type": "string"
+                },
+                "token_type": {
+                    "type": "string",
+                    "example": "Bearer"
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_errors.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "email"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "must be a valid email address"
                 }
             }
         },
@@ -496,6 +1845,34 @@ const docTemplate = `{
                 }
             }
         },
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.LoginRequest": {
+            "description": "Login request body",
+            "type": "object",
+            "required": [
+                "password",
+                "username"
+            ],
+            "properties": {
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.MessageRequest": {
+            "description": "Message sending request body",
+            "type": "object",
+            "required": [
+                "content"
+            ],
+            "properties": {
+                "content": {
+                    "type": "string"
+                }
+            }
+        },
         "github_com_Napat_golang-testcontainers-demo_pkg_model.Order": {
             "type": "object",
             "properties": {
@@ -528,74 +1905,141 @@ const docTemplate = `{
                 }
             }
         },
-        "github_com_Napat_golang-testcontainers-demo_pkg_model.Product": {
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.OrderResponse": {
+            "description": "Order information",
             "type": "object",
             "properties": {
                 "created_at": {
                     "type": "string"
                 },
-                "description": {
+                "customer_id": {
                     "type": "string"
                 },
                 "id": {
-                    "type": "integer"
+                    "type": "string"
                 },
-                "name": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.Item"
+                    }
+                },
+                "payment_method": {
                     "type": "string"
                 },
-                "price": {
+                "status": {
+                    "type": "string"
+                },
+                "total": {
                     "type": "number"
                 },
-                "sku": {
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.ProductCreate": {
+            "description": "Product creation request body",
+            "type": "object",
+            "required": [
+                "name",
+                "sku"
+            ],
+            "properties": {
+                "description": {
                     "type": "string"
                 },
-                "stock": {
-                    "type": "integer"
+                "name": {
+                    "type": "string"
                 },
-                "updated_at": {
+                "price": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "sku": {
                     "type": "string"
                 },
-                "version": {
-                    "type": "integer"
+                "stock": {
+                    "type": "integer",
+                    "minimum": 0
                 }
             }
         },
-        "github_com_Napat_golang-testcontainers-demo_pkg_model.User": {
-            "description": "User account information",
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.ProductResponse": {
+            "description": "Product information",
             "type": "object",
             "properties": {
                 "created_at": {
                     "type": "string"
                 },
-                "email": {
+                "deleted_at": {
                     "type": "string"
                 },
-                "full_name": {
+                "description": {
                     "type": "string"
                 },
                 "id": {
+                    "type": "integer"
+                },
+                "name": {
                     "type": "string"
                 },
-                "password": {
+                "price": {
+                    "type": "number"
+                },
+                "sku": {
                     "type": "string"
                 },
-                "row_number": {
+                "stock": {
                     "type": "integer"
                 },
-                "status": {
-                    "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserStatus"
-                },
                 "updated_at": {
                     "type": "string"
                 },
-                "username": {
-                    "type": "string"
-                },
                 "version": {
                     "type": "integer"
                 }
             }
         },
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.ProductUpdate": {
+            "description": "Product update request body",
+            "type": "object",
+            "required": [
+                "name",
+                "sku"
+            ],
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "price": {
+                    "type": "number",
+                    "minimum": 0
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "stock": {
+                    "type": "integer",
+                    "minimum": 0
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.TokenRequest": {
+            "description": "Refresh or revoke request body",
+            "type": "object",
+            "required": [
+                "token"
+            ],
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
         "github_com_Napat_golang-testcontainers-demo_pkg_model.UserCreate": {
             "description": "User creation request body",
             "type": "object",
@@ -621,19 +2065,205 @@ const docTemplate = `{
                 }
             }
         },
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.UserPatch": {
+            "description": "Partial user update request body",
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "full_name": {
+                    "type": "string",
+                    "minLength": 1
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.UserResponse": {
+            "description": "Public user information",
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "deleted_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "full_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserStatus"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                },
+                "version": {
+                    "type": "integer"
+                }
+            }
+        },
         "github_com_Napat_golang-testcontainers-demo_pkg_model.UserStatus": {
             "type": "string",
             "enum": [
                 "active",
                 "inactive",
-                "suspended"
+                "suspended",
+                "deleted"
             ],
             "x-enum-varnames": [
                 "StatusActive",
                 "StatusInactive",
-                "StatusSuspended"
+                "StatusSuspended",
+                "StatusDeleted"
             ]
         },
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.UserStatusChange": {
+            "description": "User status change request body",
+            "type": "object",
+            "required": [
+                "status"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string"
+                },
+                "status": {
+                    "enum": [
+                        "active",
+                        "inactive",
+                        "suspended",
+                        "deleted"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserStatus"
+                        }
+                    ]
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_model.UserUpdate": {
+            "description": "User update request body",
+            "type": "object",
+            "required": [
+                "email",
+                "full_name"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "full_name": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "active",
+                        "inactive",
+                        "suspended",
+                        "deleted"
+                    ]
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_pagination.Page-github_com_Napat_golang-testcontainers-demo_pkg_model_OrderResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.OrderResponse"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_pagination.Page-github_com_Napat_golang-testcontainers-demo_pkg_model_ProductResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.ProductResponse"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_pagination.Page-github_com_Napat_golang-testcontainers-demo_pkg_model_UserResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_model.UserResponse"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "github_com_Napat_golang-testcontainers-demo_pkg_response.Problem": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string",
+                    "example": "user with this email already exists"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/github_com_Napat_golang-testcontainers-demo_pkg_errors.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/v1/users"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 409
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Conflict"
+                },
+                "trace_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "about:blank"
+                }
+            }
+        },
         "internal_handler_health.HealthStatus": {
             "type": "object",
             "properties": {
@@ -667,8 +2297,11 @@ const docTemplate = `{
         }
     },
     "securityDefinitions": {
-        "BasicAuth": {
-            "type": "basic"
+        "BearerAuth": {
+            "description": "Type \"Bearer\" followed by a space and the access token from /auth/login.",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     }
 }`
@@ -3,8 +3,9 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -18,14 +19,32 @@ import (
 	"github.com/IBM/sarama"
 	_ "github.com/Napat/golang-testcontainers-demo/api/docs"
 	"github.com/Napat/golang-testcontainers-demo/internal/config"
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus"
 	"github.com/Napat/golang-testcontainers-demo/internal/handler"
 	"github.com/Napat/golang-testcontainers-demo/internal/handler/health"
+	"github.com/Napat/golang-testcontainers-demo/internal/message"
+	"github.com/Napat/golang-testcontainers-demo/internal/outbox"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_cache"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_event"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_message"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_order"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_outbox"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_product"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_role"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_token"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_user"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_webhook"
+	"github.com/Napat/golang-testcontainers-demo/internal/retention"
 	"github.com/Napat/golang-testcontainers-demo/internal/router"
+	"github.com/Napat/golang-testcontainers-demo/internal/stream"
+	"github.com/Napat/golang-testcontainers-demo/internal/webhook"
+	"github.com/Napat/golang-testcontainers-demo/pkg/auth"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
+	"github.com/Napat/golang-testcontainers-demo/pkg/password"
+	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
 	"github.com/Napat/golang-testcontainers-demo/pkg/shutdown"
 	"github.com/Napat/golang-testcontainers-demo/pkg/tracing"
 	"github.com/elastic/go-elasticsearch/v8"
@@ -56,94 +75,80 @@ var (
 // @host      localhost:8080
 // @BasePath  /api/v1
 
-// @securityDefinitions.basic  BasicAuth
-
-// printEnvironmentInfo แสดงข้อมูล environment และ configuration
-func printEnvironmentInfo(configPath string, cfg *config.Config) {
-	log.Printf("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
-	log.Printf("📦 Testcontainers Demo API")
-	log.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
-
-	env := getEnvOrDefault("GO_ENV", "development")
-	isProd := env == "production"
-
-	log.Printf("📚 Environment:")
-	log.Printf("   ├── Mode: %s %s", env, getEnvironmentIcon(isProd))
-	log.Printf("   ├── Config: %s", configPath)
-	log.Printf("   └── Version: %s\n", "1.0.0")
+// @securityDefinitions.apikey  BearerAuth
+// @in                          header
+// @name                        Authorization
+// @description                 Type "Bearer" followed by a space and the access token from /auth/login.
+
+// logEnvironmentInfo บันทึกข้อมูล environment และ configuration
+func logEnvironmentInfo(configPath string) {
+	slog.Info("starting service",
+		"service", "testcontainers-demo-api",
+		"environment", getEnvOrDefault("GO_ENV", "development"),
+		"config", configPath,
+	)
 }
 
-// printSystemInfo แสดงข้อมูลระบบ
-func printSystemInfo() {
+// logSystemInfo บันทึกข้อมูลระบบ
+func logSystemInfo() {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
 
-	log.Printf("\n💻 System Information:")
-	log.Printf("   ├── Go Version: %s", runtime.Version())
-	log.Printf("   ├── GOOS/GOARCH: %s/%s", runtime.GOOS, runtime.GOARCH)
-	log.Printf("   ├── CPU Cores: %d", runtime.NumCPU())
-	log.Printf("   ├── Goroutines: %d", runtime.NumGoroutine())
-	log.Printf("   └── Memory:")
-	log.Printf("       ├── Alloc: %.2f MB", float64(m.Alloc)/1024/1024)
-	log.Printf("       ├── TotalAlloc: %.2f MB", float64(m.TotalAlloc)/1024/1024)
-	log.Printf("       └── Sys: %.2f MB", float64(m.Sys)/1024/1024)
-}
-
-// printServerInfo แสดงข้อมูลเซิร์ฟเวอร์
-func printServerInfo(cfg *config.Config, addr string, startTime time.Time) {
-	log.Printf("\n🚀 Server Details:")
-	log.Printf("   ├── Environment: %s", cfg.Environment)
-	log.Printf("   ├── Port: %s", cfg.Server.Port)
-	log.Printf("   ├── Startup Time: %v", time.Since(startTime).Round(time.Millisecond))
-	log.Printf("   └── PID: %d", os.Getpid())
-
-	log.Printf("\n🌐 Access URLs:")
-	log.Printf("   ├── Local API: http://localhost%s", addr)
-	log.Printf("   ├── Health Check: http://localhost%s/health", addr)
-	log.Printf("   ├── Metrics: http://localhost%s/metrics", addr)
-	log.Printf("   ├── Swagger UI: http://localhost%s/swagger/", addr)
-	log.Printf("   └── Swagger JSON: http://localhost%s/swagger.json", addr)
+	slog.Info("system",
+		"go_version", runtime.Version(),
+		"os", runtime.GOOS,
+		"arch", runtime.GOARCH,
+		"cpus", runtime.NumCPU(),
+		"goroutines", runtime.NumGoroutine(),
+		slog.Group("memory",
+			"alloc_bytes", m.Alloc,
+			"total_alloc_bytes", m.TotalAlloc,
+			"sys_bytes", m.Sys,
+		),
+	)
 }
 
-// printEndpoints แสดงรายการ endpoints ที่มี
-func printEndpoints() {
-	log.Printf("\n📝 API Endpoints:")
-	log.Printf("   ├── Health:")
-	log.Printf("   │   └── GET    /health                - Health check")
-	log.Printf("   ├── Users:")
-	log.Printf("   │   ├── GET    /api/v1/users         - List users")
-	log.Printf("   │   └── POST   /api/v1/users         - Create user")
-	log.Printf("   ├── Products:")
-	log.Printf("   │   └── GET    /api/v1/products      - List products")
-	log.Printf("   ├── Orders:")
-	log.Printf("   │   ├── POST   /api/v1/orders        - Create order")
-	log.Printf("   │   └── GET    /api/v1/orders/search - Search orders")
-	log.Printf("   └── Messages:")
-	log.Printf("       └── POST   /api/v1/messages      - Send message")
+// logServerInfo บันทึกข้อมูลเซิร์ฟเวอร์
+func logServerInfo(cfg *config.Config, addr string, startTime time.Time) {
+	base := "http://localhost" + addr
+	slog.Info("server",
+		"environment", cfg.Environment,
+		"port", cfg.Server.Port,
+		"startup_time", time.Since(startTime).Round(time.Millisecond).String(),
+		"pid", os.Getpid(),
+		slog.Group("urls",
+			"api", base,
+			"health", base+"/health",
+			"metrics", base+"/metrics",
+			"swagger_ui", base+"/swagger/",
+			"swagger_json", base+"/swagger.json",
+		),
+	)
 }
 
-// printDevTools แสดงรายการเครื่องมือสำหรับ development
-func printDevTools(cfg *config.Config, addr string) {
-	if cfg.Tracing.Enabled {
-		log.Printf("\n🔍 Debug Tools:")
-		log.Printf("   ├── pprof: http://localhost%s/debug/pprof", addr)
-		log.Printf("   ├── Heap: http://localhost%s/debug/pprof/heap", addr)
-		log.Printf("   └── Goroutines: http://localhost%s/debug/pprof/goroutine", addr)
+// logRoutes บันทึกรายการ endpoints ที่มี at debug level. The same table is
+// served at /api/v1/debug/routes.
+func logRoutes(health routes.Handler, handlers ...routes.Handler) {
+	var all []routes.Route
+	for _, h := range handlers {
+		all = append(all, h.GetRoutes()...)
+	}
+	infos := append(routes.Describe("", health.GetRoutes()), routes.Describe(routes.Prefix, all)...)
+	for _, info := range infos {
+		slog.Debug("route", "name", info.Name, "method", info.Method, "path", info.Path, "public", info.Public)
 	}
-
-	log.Printf("\n💡 Helper Commands:")
-	log.Printf("   ├── make test          - Run tests")
-	log.Printf("   ├── make coverage      - Generate test coverage")
-	log.Printf("   ├── make migrate       - Run database migrations")
-	log.Printf("   └── make docs          - Generate API documentation")
 }
 
-// getEnvironmentIcon returns emoji based on environment
-func getEnvironmentIcon(isProd bool) string {
-	if isProd {
-		return "🔒"
+// logDevTools บันทึกเครื่องมือสำหรับ development
+func logDevTools(cfg *config.Config, addr string) {
+	if cfg.Tracing.Enabled {
+		base := "http://localhost" + addr + "/debug/pprof"
+		slog.Info("profiling",
+			"pprof", base,
+			"heap", base+"/heap",
+			"goroutines", base+"/goroutine",
+		)
 	}
-	return "🔧"
 }
 
 // initializeServices ทำการเชื่อมต่อกับ services ต่างๆ
@@ -167,30 +172,28 @@ func initializeServices(cfg *config.Config) (*sql.DB, *sql.DB, *redis.Client, sa
 	// Redis - ไม่ถือว่าเป็น critical error
 	redisClient, err := initializeRedisConnection(cfg)
 	if err != nil {
-		log.Printf("⚠️  Redis initialization warning: %v", err)
+		slog.Warn("service unavailable, continuing without it", "service", "redis", "error", err)
 	}
 
 	// Kafka - ไม่ถือว่าเป็น critical error
 	kafkaClient, kafkaProducer, err := initializeKafkaConnection(cfg)
 	if err != nil {
-		log.Printf("⚠️  Kafka initialization warning: %v", err)
+		slog.Warn("service unavailable, continuing without it", "service", "kafka", "error", err)
 	}
 
 	// Elasticsearch - ไม่ถือว่าเป็น critical error
 	esClient, err := initializeElasticsearchConnection(cfg)
 	if err != nil {
-		log.Printf("⚠️  Elasticsearch initialization warning: %v", err)
+		slog.Warn("service unavailable, continuing without it", "service", "elasticsearch", "error", err)
 	}
 
 	return mysqlDB, postgresDB, redisClient, kafkaClient, kafkaProducer, esClient, nil
 }
 
 func initializeMySQLConnection(cfg *config.Config) (*sql.DB, error) {
-	log.Printf("   ├── MySQL:")
 	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true",
 		cfg.MySQL.User, cfg.MySQL.Password, cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.Database))
 	if err != nil {
-		log.Printf("   │   └── ❌ Connection failed: %v", err)
 		return nil, err
 	}
 
@@ -201,24 +204,25 @@ func initializeMySQLConnection(cfg *config.Config) (*sql.DB, error) {
 	db.SetConnMaxIdleTime(time.Duration(cfg.MySQL.MaxIdleTime) * time.Minute)
 
 	if err := db.Ping(); err != nil {
-		log.Printf("   │   └── ⚠️  Connected but ping failed: %v", err)
 		return db, err
 	}
 
-	log.Printf("   │   ├── ✅ Connected successfully to %s:%s/%s",
-		cfg.MySQL.Host, cfg.MySQL.Port, cfg.MySQL.Database)
-	log.Printf("   │   └── 🔄 Max lifetime: 5m, Max idle: 5m")
+	slog.Info("connected",
+		"service", "mysql",
+		"host", cfg.MySQL.Host,
+		"port", cfg.MySQL.Port,
+		"database", cfg.MySQL.Database,
+		"max_open_conns", cfg.MySQL.MaxOpenConns,
+	)
 	return db, nil
 }
 
 func initializePostgreSQLConnection(cfg *config.Config) (*sql.DB, error) {
-	log.Printf("   ├── PostgreSQL:")
 	db, err := sql.Open("postgres", fmt.Sprintf(
 		"host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		cfg.PostgreSQL.Host, cfg.PostgreSQL.Port, cfg.PostgreSQL.User,
 		cfg.PostgreSQL.Password, cfg.PostgreSQL.Database))
 	if err != nil {
-		log.Printf("   │   └── ❌ Connection failed: %v", err)
 		return nil, err
 	}
 
@@ -229,82 +233,312 @@ func initializePostgreSQLConnection(cfg *config.Config) (*sql.DB, error) {
 	db.SetConnMaxIdleTime(time.Duration(cfg.PostgreSQL.MaxIdleTime) * time.Minute)
 
 	if err := db.Ping(); err != nil {
-		log.Printf("   │   └── ⚠️  Connected but ping failed: %v", err)
 		return db, err
 	}
 
-	log.Printf("   │   ├── ✅ Connected successfully to %s:%s/%s",
-		cfg.PostgreSQL.Host, cfg.PostgreSQL.Port, cfg.PostgreSQL.Database)
-	log.Printf("   │   └── 🔄 Max lifetime: 5m, Max idle: 5m")
+	slog.Info("connected",
+		"service", "postgresql",
+		"host", cfg.PostgreSQL.Host,
+		"port", cfg.PostgreSQL.Port,
+		"database", cfg.PostgreSQL.Database,
+		"max_open_conns", cfg.PostgreSQL.MaxOpenConns,
+	)
 	return db, nil
 }
 
 func initializeRedisConnection(cfg *config.Config) (*redis.Client, error) {
-	log.Printf("   ├── Redis:")
 	client := redis.NewClient(&redis.Options{
 		Addr: fmt.Sprintf("%s:%s", cfg.Redis.Host, cfg.Redis.Port),
 	})
 
 	if err := client.Ping(context.Background()).Err(); err != nil {
-		log.Printf("   │   └── ❌ Connection failed: %v", err)
 		return client, err
 	}
 
-	log.Printf("   │   └── ✅ Connected successfully to %s:%s",
-		cfg.Redis.Host, cfg.Redis.Port)
+	slog.Info("connected", "service", "redis", "host", cfg.Redis.Host, "port", cfg.Redis.Port)
 	return client, nil
 }
 
 func initializeKafkaConnection(cfg *config.Config) (sarama.Client, sarama.SyncProducer, error) {
-	log.Printf("   ├── Kafka:")
 	config := sarama.NewConfig()
 	config.Producer.Return.Successes = true
+	// The broker gives up on a write no later than the publish does
+	if cfg.Kafka.PublishTimeout > 0 {
+		config.Producer.Timeout = time.Duration(cfg.Kafka.PublishTimeout) * time.Millisecond
+	}
 
 	client, err := sarama.NewClient(cfg.Kafka.Brokers, config)
 	if err != nil {
-		log.Printf("   │   ├── ❌ Connection failed: %v", err)
-		log.Printf("   │   └── Brokers: %v", cfg.Kafka.Brokers)
 		return nil, nil, err
 	}
 
 	producer, err := sarama.NewSyncProducer(cfg.Kafka.Brokers, config)
 	if err != nil {
-		log.Printf("   │   ├── ⚠️  Connected but producer creation failed: %v", err)
-		log.Printf("   │   ├── Brokers: %v", cfg.Kafka.Brokers)
-		log.Printf("   │   └── Topic: %s", cfg.Kafka.Topic)
-		return client, nil, err
+		return client, nil, fmt.Errorf("create producer: %w", err)
 	}
 
-	log.Printf("   │   ├── ✅ Connected successfully")
-	log.Printf("   │   ├── Brokers: %v", cfg.Kafka.Brokers)
-	log.Printf("   │   └── Topic: %s", cfg.Kafka.Topic)
+	slog.Info("connected", "service", "kafka", "brokers", cfg.Kafka.Brokers, "topic", cfg.Kafka.Topic)
 	return client, producer, nil
 }
 
 func initializeElasticsearchConnection(cfg *config.Config) (*elasticsearch.Client, error) {
-	log.Printf("   └── Elasticsearch:")
 	client, err := elasticsearch.NewClient(elasticsearch.Config{
 		Addresses: []string{cfg.Elasticsearch.URL},
 	})
 	if err != nil {
-		log.Printf("       └── ❌ Client creation failed: %v", err)
 		return nil, err
 	}
 
 	info, err := client.Info()
 	if err != nil {
-		log.Printf("       └── ⚠️  Client created but ping failed: %v", err)
 		return client, err
 	}
 	defer info.Body.Close()
 
-	log.Printf("       └── ✅ Connected successfully to %s", cfg.Elasticsearch.URL)
+	slog.Info("connected", "service", "elasticsearch", "url", cfg.Elasticsearch.URL)
 	return client, nil
 }
 
+// initializePasswordHasher ตั้งค่า password hasher ตาม configuration
+func initializePasswordHasher(cfg *config.Config) {
+	password.SetDefault(cfg.Security.Password.Manager())
+}
+
+// initializeTokenManager สร้าง JWT token manager โดยเก็บ revocation list ไว้ใน Redis
+func initializeTokenManager(cfg *config.Config, redisClient *redis.Client) *auth.TokenManager {
+	secret := getEnvOrDefault("JWT_SECRET", cfg.Security.JWT.Secret)
+	if secret == "" {
+		fatal("JWT secret is not configured, set security.jwt.secret or JWT_SECRET")
+	}
+
+	accessTTL := 15 * time.Minute
+	if cfg.Security.JWT.AccessTokenTTL > 0 {
+		accessTTL = time.Duration(cfg.Security.JWT.AccessTokenTTL) * time.Minute
+	}
+	refreshTTL := 7 * 24 * time.Hour
+	if cfg.Security.JWT.RefreshTokenTTL > 0 {
+		refreshTTL = time.Duration(cfg.Security.JWT.RefreshTokenTTL) * time.Minute
+	}
+
+	return auth.NewTokenManager(auth.Config{
+		Secret:     []byte(secret),
+		Issuer:     cfg.Security.JWT.Issuer,
+		AccessTTL:  accessTTL,
+		RefreshTTL: refreshTTL,
+	}, repository_token.NewRevocationRepository(redisClient))
+}
+
+// stopFunc stops a background worker and waits until it has returned, or
+// until ctx ends. It has the signature of a shutdown.Manager handler.
+type stopFunc func(ctx context.Context) error
+
+// stopped is the stopFunc of a worker that was not started
+func stopped(context.Context) error { return nil }
+
+// runWorker runs run in the background until the returned stopFunc is
+// called. The stopFunc cancels the context of run and waits for it to
+// return, so that what run uses can be closed safely afterwards.
+func runWorker(run func(ctx context.Context)) stopFunc {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+
+	return func(stopCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-stopCtx.Done():
+			return stopCtx.Err()
+		}
+	}
+}
+
+// startRetentionWorker ลบ record ที่ถูก soft delete เกินระยะเวลาที่กำหนดออกอย่างถาวร
+func startRetentionWorker(cfg *config.Config, purgers map[string]retention.Purger) stopFunc {
+	if !cfg.Retention.Enabled || cfg.Retention.DeletedRecordsDays <= 0 {
+		return stopped
+	}
+
+	keep := time.Duration(cfg.Retention.DeletedRecordsDays) * 24 * time.Hour
+	interval := time.Duration(cfg.Retention.PurgeInterval) * time.Minute
+	worker := retention.NewWorker(purgers, keep, interval, cfg.Retention.BatchSize)
+
+	slog.Info("retention worker started",
+		"deleted_records_days", cfg.Retention.DeletedRecordsDays,
+		"purge_interval", interval.String(),
+	)
+
+	return runWorker(worker.Run)
+}
+
+// startOutboxRelay ส่ง event ที่บันทึกไว้ในตาราง outbox เข้า Kafka
+func startOutboxRelay(cfg *config.Config, store outbox.Store, publisher outbox.Publisher) stopFunc {
+	if !cfg.Outbox.Enabled {
+		slog.Warn("outbox relay disabled, outbox events are not published")
+		return stopped
+	}
+
+	interval := time.Duration(cfg.Outbox.PollInterval) * time.Millisecond
+	maxBackoff := time.Duration(cfg.Outbox.MaxBackoff) * time.Second
+	relay := outbox.NewRelay(store, publisher, interval, cfg.Outbox.BatchSize, maxBackoff, cfg.Outbox.MaxAttempts)
+
+	slog.Info("outbox relay started",
+		"poll_interval", interval.String(),
+		"batch_size", cfg.Outbox.BatchSize,
+		"max_attempts", cfg.Outbox.MaxAttempts,
+	)
+
+	return runWorker(relay.Run)
+}
+
+// startWebhookDispatcher ส่ง event ที่อยู่ในคิวไปยัง endpoint ของ webhook subscriptions
+func startWebhookDispatcher(cfg *config.Config, store webhook.Store) stopFunc {
+	if !cfg.Webhooks.Enabled {
+		slog.Warn("webhook dispatcher disabled, webhook deliveries are not sent")
+		return stopped
+	}
+
+	dispatcher := webhook.NewDispatcher(store, webhook.NewClient(cfg.Webhooks.AllowPrivateNetworks), webhook.Config{
+		Interval:     time.Duration(cfg.Webhooks.PollInterval) * time.Millisecond,
+		Timeout:      time.Duration(cfg.Webhooks.Timeout) * time.Millisecond,
+		Workers:      cfg.Webhooks.Workers,
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		MinBackoff:   time.Duration(cfg.Webhooks.MinBackoff) * time.Second,
+		MaxBackoff:   time.Duration(cfg.Webhooks.MaxBackoff) * time.Second,
+		DisableAfter: cfg.Webhooks.DisableAfter,
+	})
+
+	slog.Info("webhook dispatcher started",
+		"poll_interval", cfg.Webhooks.PollInterval,
+		"workers", cfg.Webhooks.Workers,
+		"max_attempts", cfg.Webhooks.MaxAttempts,
+	)
+
+	return runWorker(dispatcher.Run)
+}
+
+// startMessageDispatcher ส่งข้อความที่ตั้งเวลาไว้เมื่อถึงเวลาส่ง
+func startMessageDispatcher(cfg *config.Config, schedule message.ScheduleQueue, store message.DispatchStore, bus eventbus.Bus) stopFunc {
+	if !cfg.Messages.Enabled {
+		slog.Warn("message dispatcher disabled, scheduled messages are not published")
+		return stopped
+	}
+
+	dispatcher := message.NewDispatcher(schedule, store, bus, message.Config{
+		Interval:    time.Duration(cfg.Messages.PollInterval) * time.Millisecond,
+		BatchSize:   cfg.Messages.BatchSize,
+		Lease:       time.Duration(cfg.Messages.Lease) * time.Second,
+		MaxAttempts: cfg.Messages.MaxAttempts,
+		MinBackoff:  time.Duration(cfg.Messages.MinBackoff) * time.Second,
+		MaxBackoff:  time.Duration(cfg.Messages.MaxBackoff) * time.Second,
+	})
+
+	slog.Info("message dispatcher started",
+		"poll_interval", cfg.Messages.PollInterval,
+		"batch_size", cfg.Messages.BatchSize,
+		"max_attempts", cfg.Messages.MaxAttempts,
+	)
+
+	return runWorker(dispatcher.Run)
+}
+
+// consumerGroups คืนรายชื่อ consumer group ที่ subscribe event bus.
+// Each group has retry and dead-letter topics of its own.
+func consumerGroups(cfg *config.Config) handler.ConsumerGroups {
+	return handler.ConsumerGroups{
+		Groups:   []string{cfg.Kafka.Consumer.GroupID, cfg.Webhooks.GroupID},
+		Prefixes: []string{streamGroupPrefix(cfg)},
+	}
+}
+
+// streamGroupPrefix is the start of the consumer group of the event stream
+// of every instance, which adds its host name
+func streamGroupPrefix(cfg *config.Config) string {
+	return cfg.Stream.GroupID + "-"
+}
+
+// startEventStream สร้าง broker ที่ส่ง event ให้ client ผ่าน Server-Sent Events.
+// Every instance subscribes in a group of its own, so each one sees every
+// event whichever instance a client is connected to.
+func startEventStream(cfg *config.Config, bus eventbus.Bus) *stream.Broker {
+	if !cfg.Stream.Enabled {
+		slog.Warn("event stream disabled, /api/v1/events/stream answers 503")
+		return nil
+	}
+
+	broker := stream.NewBroker(stream.Config{
+		ReplaySize:   cfg.Stream.ReplaySize,
+		ClientBuffer: cfg.Stream.ClientBuffer,
+		Heartbeat:    time.Duration(cfg.Stream.Heartbeat) * time.Second,
+		WriteTimeout: time.Duration(cfg.Stream.WriteTimeout) * time.Second,
+	})
+
+	host, _ := os.Hostname()
+	group := streamGroupPrefix(cfg) + host
+	registry := consumer.NewRegistry()
+	stream.Register(registry, broker)
+	if err := bus.Subscribe(group, registry); err != nil {
+		slog.Warn("service unavailable, continuing without it", "service", "event stream consumer", "error", err)
+	}
+
+	slog.Info("event stream started", "group", group, "replay_size", broker.Config().ReplaySize)
+	return broker
+}
+
+// initializeEventBus สร้าง event bus ตาม backend ที่ตั้งค่าไว้.
+// The Kafka bus moves messages that keep failing through the retry topics to
+// the dead-letter topic when dead_letter is enabled.
+func initializeEventBus(cfg *config.Config, redisClient *redis.Client, kafkaProducer sarama.SyncProducer) (eventbus.Bus, error) {
+	backend, err := eventbus.ParseBackend(cfg.EventBus.Backend)
+	if err != nil {
+		return nil, err
+	}
+	retry := eventbus.Retry{
+		MaxAttempts: cfg.Kafka.Consumer.MaxAttempts,
+		Backoff:     time.Duration(cfg.Kafka.Consumer.RetryBackoff) * time.Millisecond,
+	}
+
+	switch backend {
+	case eventbus.Memory:
+		slog.Warn("in-memory event bus, events stay within this process and are lost on restart")
+		return eventbus.NewMemoryBus(retry), nil
+	case eventbus.Redis:
+		stream := cfg.EventBus.Stream
+		if stream == "" {
+			stream = cfg.Kafka.Topic
+		}
+		claimIdle := time.Duration(cfg.EventBus.ClaimIdle) * time.Second
+		slog.Info("redis event bus", "stream", stream, "max_len", cfg.EventBus.MaxLen)
+		return eventbus.NewRedisBus(redisClient, stream, cfg.EventBus.MaxLen, claimIdle, retry), nil
+	}
+
+	eventMode, err := event.ParseMode(cfg.Kafka.EventMode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kafka configuration: %w", err)
+	}
+	producer := repository_event.NewProducerRepository(kafkaProducer, cfg.Kafka.Topic, eventMode, time.Duration(cfg.Kafka.PublishTimeout)*time.Millisecond)
+
+	consumerConfig := consumer.Config{
+		MaxAttempts:  retry.MaxAttempts,
+		RetryBackoff: retry.Backoff,
+	}
+	if cfg.Kafka.Consumer.DeadLetter && kafkaProducer != nil {
+		consumerConfig.Forwarder = repository_event.NewFailureRepository(kafkaProducer)
+		for _, delay := range cfg.Kafka.Consumer.RetryDelays {
+			consumerConfig.RetryDelays = append(consumerConfig.RetryDelays, time.Duration(delay)*time.Millisecond)
+		}
+	}
+	return eventbus.NewKafkaBus(cfg.Kafka.Brokers, producer, cfg.Kafka.Topic, cfg.Kafka.Consumer.InitialOffset, consumerConfig), nil
+}
+
 // setupGracefulShutdown จัดการการปิดระบบอย่างสมบูรณ์
 func setupGracefulShutdown(
 	srv *http.Server,
+	workers *shutdown.Manager,
 	mysqlDB *sql.DB,
 	postgresDB *sql.DB,
 	redisClient *redis.Client,
@@ -316,7 +550,7 @@ func setupGracefulShutdown(
 
 	go func() {
 		<-quit
-		log.Println("\n🛑 Initiating graceful shutdown...")
+		slog.Info("shutting down")
 
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -327,19 +561,26 @@ func setupGracefulShutdown(
 		if err := srv.Shutdown(ctx); err != nil {
 			shutdownErrs = append(shutdownErrs, fmt.Errorf("server shutdown: %w", err))
 		} else {
-			log.Println("✅ HTTP server stopped")
+			slog.Info("http server stopped")
+		}
+
+		// 2. Stop background workers and consumers while their connections are open
+		if err := workers.Shutdown(ctx); err != nil {
+			shutdownErrs = append(shutdownErrs, fmt.Errorf("background workers: %w", err))
+		} else {
+			slog.Info("background workers stopped")
 		}
 
-		// 2. Then close Redis (non-critical)
+		// 3. Then close Redis (non-critical)
 		if redisClient != nil {
 			if err := redisClient.Close(); err != nil {
 				shutdownErrs = append(shutdownErrs, fmt.Errorf("redis cleanup: %w", err))
 			} else {
-				log.Println("✅ Redis connection closed")
+				slog.Info("redis connection closed")
 			}
 		}
 
-		// 3. Close Kafka producer first, then client
+		// 4. Close Kafka producer first, then client
 		if kafkaProducer != nil {
 			// Use a sync.Once to ensure we only close once
 			var once sync.Once
@@ -347,7 +588,7 @@ func setupGracefulShutdown(
 				if err := kafkaProducer.Close(); err != nil {
 					shutdownErrs = append(shutdownErrs, fmt.Errorf("kafka producer cleanup: %w", err))
 				} else {
-					log.Println("✅ Kafka producer closed")
+					slog.Info("kafka producer closed")
 				}
 			})
 			// Wait a bit before closing the client
@@ -358,16 +599,16 @@ func setupGracefulShutdown(
 			if err := kafkaClient.Close(); err != nil {
 				shutdownErrs = append(shutdownErrs, fmt.Errorf("kafka client cleanup: %w", err))
 			} else {
-				log.Println("✅ Kafka client closed")
+				slog.Info("kafka client closed")
 			}
 		}
 
-		// 4. Finally close databases
+		// 5. Finally close databases
 		if postgresDB != nil {
 			if err := postgresDB.Close(); err != nil {
 				shutdownErrs = append(shutdownErrs, fmt.Errorf("postgres cleanup: %w", err))
 			} else {
-				log.Println("✅ PostgreSQL connection closed")
+				slog.Info("postgresql connection closed")
 			}
 		}
 
@@ -375,53 +616,52 @@ func setupGracefulShutdown(
 			if err := mysqlDB.Close(); err != nil {
 				shutdownErrs = append(shutdownErrs, fmt.Errorf("mysql cleanup: %w", err))
 			} else {
-				log.Println("✅ MySQL connection closed")
+				slog.Info("mysql connection closed")
 			}
 		}
 
 		// Report any shutdown errors
-		if len(shutdownErrs) > 0 {
-			log.Println("\n⚠️  Shutdown completed with errors:")
-			for _, err := range shutdownErrs {
-				log.Printf("   - %v", err)
-			}
-		} else {
-			log.Println("\n✅ All connections closed successfully")
+		for _, err := range shutdownErrs {
+			slog.Error("shutdown step failed", "error", err)
 		}
-
-		log.Println("👋 Server shutdown complete")
+		slog.Info("shutdown complete", "errors", len(shutdownErrs))
 		os.Exit(0)
 	}()
 
+	// The server stops serving as soon as the shutdown begins; main waits
+	// here until the shutdown above has finished and exits the process
 	return func() {
-		log.Println("\n🔄 Running cleanup handlers...")
+		slog.Info("running cleanup handlers")
 		quit <- syscall.SIGTERM
+		select {}
 	}
 }
 
-func printConnectionPoolInfo(mysqlDB *sql.DB, postgresDB *sql.DB, redisClient *redis.Client) {
-	log.Printf("\n📊 Connection Pools:")
-
-	// MySQL pool stats
-	log.Printf("   ├── MySQL:")
-	log.Printf("   │   ├── Max Open: %d", mysqlDB.Stats().MaxOpenConnections)
-	log.Printf("   │   ├── Open: %d", mysqlDB.Stats().OpenConnections)
-	log.Printf("   │   ├── In Use: %d", mysqlDB.Stats().InUse)
-	log.Printf("   │   └── Idle: %d", mysqlDB.Stats().Idle)
-
-	// PostgreSQL pool stats
-	log.Printf("   ├── PostgreSQL:")
-	log.Printf("   │   ├── Max Open: %d", postgresDB.Stats().MaxOpenConnections)
-	log.Printf("   │   ├── Open: %d", postgresDB.Stats().OpenConnections)
-	log.Printf("   │   ├── In Use: %d", postgresDB.Stats().InUse)
-	log.Printf("   │   └── Idle: %d", postgresDB.Stats().Idle)
-
-	// Redis pool stats
-	log.Printf("   └── Redis:")
-	poolStats := redisClient.PoolStats()
-	log.Printf("       ├── Total Conns: %d", poolStats.TotalConns)
-	log.Printf("       ├── Idle Conns: %d", poolStats.IdleConns)
-	log.Printf("       └── Stale Conns: %d", poolStats.StaleConns)
+// logConnectionPoolInfo บันทึกสถานะ connection pools
+func logConnectionPoolInfo(mysqlDB *sql.DB, postgresDB *sql.DB, redisClient *redis.Client) {
+	mysqlStats := mysqlDB.Stats()
+	postgresStats := postgresDB.Stats()
+	redisStats := redisClient.PoolStats()
+
+	slog.Info("connection pools",
+		slog.Group("mysql",
+			"max_open", mysqlStats.MaxOpenConnections,
+			"open", mysqlStats.OpenConnections,
+			"in_use", mysqlStats.InUse,
+			"idle", mysqlStats.Idle,
+		),
+		slog.Group("postgresql",
+			"max_open", postgresStats.MaxOpenConnections,
+			"open", postgresStats.OpenConnections,
+			"in_use", postgresStats.InUse,
+			"idle", postgresStats.Idle,
+		),
+		slog.Group("redis",
+			"total", redisStats.TotalConns,
+			"idle", redisStats.IdleConns,
+			"stale", redisStats.StaleConns,
+		),
+	)
 }
 
 // getAppVersion returns application version from build info
@@ -454,55 +694,55 @@ func getDependencyVersions() map[string]string {
 	return versions
 }
 
-// printVersionInfo แสดงข้อมูลเวอร์ชันและการ build
-func printVersionInfo() {
+// logVersionInfo บันทึกข้อมูลเวอร์ชันและการ build
+func logVersionInfo() {
 	versions := getDependencyVersions()
 
-	log.Printf("\n📦 Build Information:")
-	log.Printf("   ├── Version: %s", getAppVersion())
-	if buildTime != "" {
-		log.Printf("   ├── Build Time: %s", buildTime)
-	}
-	if gitCommitSHA != "" {
-		log.Printf("   ├── Git Commit: %s", gitCommitSHA)
-	}
-	log.Printf("   └── Dependencies:")
-	log.Printf("       ├── MySQL Driver: %s", versions["mysql"])
-	log.Printf("       ├── PostgreSQL Driver: %s", versions["postgres"])
-	log.Printf("       ├── Redis Client: %s", versions["redis"])
-	log.Printf("       ├── Kafka Client: %s", versions["kafka"])
-	log.Printf("       └── Elasticsearch Client: %s", versions["elasticsearch"])
+	slog.Info("build",
+		"version", getAppVersion(),
+		"build_time", buildTime,
+		"git_commit", gitCommitSHA,
+		slog.Group("dependencies",
+			"mysql", versions["mysql"],
+			"postgres", versions["postgres"],
+			"redis", versions["redis"],
+			"kafka", versions["kafka"],
+			"elasticsearch", versions["elasticsearch"],
+		),
+	)
 }
 
-// printActiveConfiguration แสดงการตั้งค่าที่ใช้งานอยู่
-func printActiveConfiguration(cfg *config.Config, srv *http.Server) {
-	log.Printf("\n⚙️  Active Configuration:")
-
-	// Server timeouts
-	log.Printf("   ├── Timeouts:")
-	log.Printf("   │   ├── Read: %v", srv.ReadTimeout)
-	log.Printf("   │   ├── Write: %v", srv.WriteTimeout)
-	log.Printf("   │   └── Idle: %v", srv.IdleTimeout)
-
-	// Connection pools
-	log.Printf("   ├── Connection Pools:")
-	log.Printf("   │   ├── MySQL Max Conn: %d", cfg.MySQL.MaxOpenConns)
-	log.Printf("   │   ├── PostgreSQL Max Conn: %d", cfg.PostgreSQL.MaxOpenConns)
-	log.Printf("   │   └── Redis Pool Size: %d", cfg.Redis.PoolSize)
-
-	// Tracing settings if enabled
+// logActiveConfiguration บันทึกการตั้งค่าที่ใช้งานอยู่
+func logActiveConfiguration(cfg *config.Config, srv *http.Server) {
+	attrs := []any{
+		slog.Group("timeouts",
+			"read", srv.ReadTimeout.String(),
+			"write", srv.WriteTimeout.String(),
+			"idle", srv.IdleTimeout.String(),
+		),
+		slog.Group("pools",
+			"mysql_max_open", cfg.MySQL.MaxOpenConns,
+			"postgresql_max_open", cfg.PostgreSQL.MaxOpenConns,
+			"redis_pool_size", cfg.Redis.PoolSize,
+		),
+		slog.Group("logging",
+			"level", cfg.Logging.Level,
+			"access_log", cfg.Logging.AccessLog,
+		),
+		slog.Group("env",
+			"GO_ENV", getEnvOrDefault("GO_ENV", "development"),
+			"CONFIG_PATH", getEnvOrDefault("CONFIG_PATH", "configs/dev.yaml"),
+			"PORT", cfg.Server.Port,
+		),
+	}
 	if cfg.Tracing.Enabled {
-		log.Printf("   ├── Tracing:")
-		log.Printf("   │   ├── Service: %s", cfg.Tracing.ServiceName)
-		log.Printf("   │   ├── Collector: %s", cfg.Tracing.CollectorURL)
-		log.Printf("   │   └── Sampling Ratio: %.2f", cfg.Tracing.SamplingRatio)
+		attrs = append(attrs, slog.Group("tracing",
+			"service", cfg.Tracing.ServiceName,
+			"collector", cfg.Tracing.CollectorURL,
+			"sampling_ratio", cfg.Tracing.SamplingRatio,
+		))
 	}
-
-	// Environment variables
-	log.Printf("   └── Environment Variables:")
-	log.Printf("       ├── GO_ENV: %s", getEnvOrDefault("GO_ENV", "development"))
-	log.Printf("       ├── CONFIG_PATH: %s", getEnvOrDefault("CONFIG_PATH", "configs/dev.yaml"))
-	log.Printf("       └── PORT: %s", cfg.Server.Port)
+	slog.Info("configuration", attrs...)
 }
 
 func main() {
@@ -512,27 +752,45 @@ func main() {
 	configPath := getEnvOrDefault("CONFIG_PATH", "configs/dev.yaml")
 	cfg, err := config.Load(configPath)
 	if err != nil {
-		log.Fatalf("❌ Failed to load config: %v", err)
+		fatal("failed to load config", "path", configPath, "error", err)
 	}
 
-	// Print initial information
-	printEnvironmentInfo(configPath, cfg)
-	printVersionInfo()
+	// Every record, including those of libraries using the log package, is
+	// written as JSON from here on
+	logger, err := logging.New(os.Stdout, cfg.Logging.Level)
+	if err != nil {
+		fatal("invalid logging configuration", "error", err)
+	}
+	slog.SetDefault(logger)
+
+	initializePasswordHasher(cfg)
 
-	log.Printf("\n🔌 Services Status:")
+	// Log initial information
+	logEnvironmentInfo(configPath)
+	logVersionInfo()
 
 	// Initialize services
 	mysqlDB, postgresDB, redisClient, kafkaClient, kafkaProducer, esClient, err := initializeServices(cfg)
 	if err != nil {
-		log.Fatalf("❌ Critical service initialization failed: %v", err)
+		fatal("critical service initialization failed", "error", err)
 	}
 
 	// Initialize repositories and handlers
 	userRepo := repository_user.NewUserRepository(mysqlDB)
+	outboxRepo := repository_outbox.NewOutboxRepository(mysqlDB)
+	webhookRepo := repository_webhook.NewWebhookRepository(mysqlDB)
+	messageRepo := repository_message.NewMessageRepository(mysqlDB)
 	productRepo := repository_product.NewProductRepository(postgresDB)
 	cacheRepo := repository_cache.NewCacheRepository(redisClient)
-	eventRepo := repository_event.NewProducerRepository(kafkaProducer, cfg.Kafka.Topic)
+	bus, err := initializeEventBus(cfg, redisClient, kafkaProducer)
+	if err != nil {
+		fatal("invalid event bus configuration", "error", err)
+	}
+	messageSchedule := repository_message.NewScheduleRepository(redisClient)
+	messageSender := message.NewSender(messageRepo, bus, messageSchedule, time.Duration(cfg.Messages.RecoverAfter)*time.Second)
 	orderRepo := repository_order.NewOrderRepository(esClient)
+	tokenManager := initializeTokenManager(cfg, redisClient)
+	policyEngine := authz.NewEngine(repository_role.NewRoleRepository(mysqlDB), cfg.Security.RBAC.DefaultRole)
 	healthHandler := health.NewHealthHandler(mysqlDB, postgresDB, redisClient, kafkaClient, esClient)
 
 	// Setup deferred cleanup
@@ -551,48 +809,82 @@ func main() {
 		}
 	}()
 
-	// Print system information
-	printSystemInfo()
+	// Log system information
+	logSystemInfo()
 
 	addr := fmt.Sprintf(":%s", cfg.Server.Port)
-	printServerInfo(cfg, addr, startTime)
-	printEndpoints()
-	printDevTools(cfg, addr)
+	logServerInfo(cfg, addr, startTime)
+	logDevTools(cfg, addr)
 
-	// Print connection pools info
-	printConnectionPoolInfo(mysqlDB, postgresDB, redisClient)
+	// Log connection pools info
+	logConnectionPoolInfo(mysqlDB, postgresDB, redisClient)
 
 	// Initialize shutdown manager
 	shutdownManager := shutdown.NewManager(30 * time.Second)
 
-	// Add shutdown handlers
-	shutdownManager.AddHandler(func(ctx context.Context) error {
-		return mysqlDB.Close()
-	})
-	shutdownManager.AddHandler(func(ctx context.Context) error {
-		return postgresDB.Close()
-	})
-	shutdownManager.AddHandler(func(ctx context.Context) error {
-		return redisClient.Close()
+	// Background workers register here and are stopped on shutdown, after the
+	// HTTP server and before the connections they use are closed
+	// Purge soft deleted records past the retention window
+	stopRetention := startRetentionWorker(cfg, map[string]retention.Purger{
+		"users":    userRepo,
+		"products": productRepo,
+		"outbox":   outboxRepo,
+		"webhooks": webhookRepo,
+		"messages": messageRepo,
 	})
+	shutdownManager.AddHandler(stopRetention)
+
+	// Publish events committed to the outbox
+	stopOutbox := startOutboxRelay(cfg, outboxRepo, bus)
+
+	// Consume events to keep the user cache in line across instances
+	registry := consumer.NewRegistry()
+	consumer.RegisterUserCache(registry, cacheRepo)
+	if cfg.Kafka.Consumer.Enabled {
+		if err := bus.Subscribe(cfg.Kafka.Consumer.GroupID, registry); err != nil {
+			slog.Warn("service unavailable, continuing without it", "service", "event consumer", "error", err)
+		}
+	}
+
+	// Queue events for webhook subscriptions in a group of their own, so every
+	// event reaches them whichever instance handles it for the cache
+	stopWebhooks := startWebhookDispatcher(cfg, webhookRepo)
+	shutdownManager.AddHandler(stopWebhooks)
+	if cfg.Webhooks.Enabled {
+		webhookRegistry := consumer.NewRegistry()
+		webhook.Register(webhookRegistry, webhookRepo)
+		if err := bus.Subscribe(cfg.Webhooks.GroupID, webhookRegistry); err != nil {
+			slog.Warn("service unavailable, continuing without it", "service", "webhook consumer", "error", err)
+		}
+	}
+
+	// Push events to UIs over Server-Sent Events
+	broker := startEventStream(cfg, bus)
+
+	// Publish scheduled messages of the message API once they are due
+	stopMessages := startMessageDispatcher(cfg, messageSchedule, messageRepo, bus)
+
+	// Shutdown handlers run concurrently, so everything that publishes to the
+	// bus stops in this one handler, and has returned, before the bus closes
 	shutdownManager.AddHandler(func(ctx context.Context) error {
-		return kafkaClient.Close()
+		if err := errors.Join(stopOutbox(ctx), stopMessages(ctx)); err != nil {
+			return err
+		}
+		if err := messageSender.Close(ctx); err != nil {
+			return err
+		}
+		return bus.Close(ctx)
 	})
 
 	// Initialize tracer if enabled
 	if cfg.Tracing.Enabled {
-		log.Printf("\n🔍 Tracing:")
-		log.Printf("   ├── Service: %s", cfg.Tracing.ServiceName)
-		log.Printf("   ├── Collector: %s", cfg.Tracing.CollectorURL)
-		log.Printf("   └── Sampling Ratio: %.2f", cfg.Tracing.SamplingRatio)
-
 		cleanup, err := tracing.InitTracer(
 			cfg.Tracing.ServiceName,
 			cfg.Tracing.CollectorURL,
 			tracing.WithSamplingRatio(cfg.Tracing.SamplingRatio),
 		)
 		if err != nil {
-			log.Fatalf("❌ Failed to initialize tracer: %v", err)
+			fatal("failed to initialize tracer", "error", err)
 		}
 		defer cleanup()
 
@@ -603,20 +895,37 @@ func main() {
 	}
 
 	// Initialize handlers
-	userHandler := handler.NewUserHandler(userRepo, cacheRepo, eventRepo)
-	productHandler := handler.NewProductHandler(productRepo)
-	orderHandler := handler.NewOrderHandler(orderRepo)
-	messageHandler := handler.NewMessageHandler(eventRepo)
+	userHandler := handler.NewUserHandler(userRepo, cacheRepo, bus)
+	productHandler := handler.NewProductHandler(productRepo, bus)
+	orderHandler := handler.NewOrderHandler(orderRepo, bus)
+	messageHandler := handler.NewMessageHandler(messageSender, messageRepo, policyEngine)
+	authHandler := handler.NewAuthHandler(userRepo, tokenManager)
+	deadLetterHandler := handler.NewDeadLetterHandler(
+		repository_event.NewDeadLetterRepository(kafkaClient, kafkaProducer, cfg.Kafka.Topic),
+		consumerGroups(cfg),
+	)
+	webhookHandler := handler.NewWebhookHandler(webhookRepo)
+	streamHandler := handler.NewStreamHandler(broker)
 
 	// Setup router using the router package
-	routerHandler := router.Setup(
+	routerHandler, err := router.Setup(
 		userHandler,
 		productHandler,
 		orderHandler,
 		messageHandler,
+		authHandler,
+		deadLetterHandler,
+		webhookHandler,
+		streamHandler,
 		healthHandler,
+		tokenManager,
+		policyEngine,
 		cfg,
 	)
+	if err != nil {
+		fatal("failed to set up router", "error", err)
+	}
+	logRoutes(healthHandler, userHandler, productHandler, orderHandler, messageHandler, authHandler, deadLetterHandler, webhookHandler, streamHandler)
 
 	// Setup HTTP server
 	rootMux := http.NewServeMux()
@@ -645,22 +954,33 @@ func main() {
 		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
 	}
 
+	// Event streams never go idle, they are ended when shutdown begins so
+	// that the server can drain
+	if broker != nil {
+		srv.RegisterOnShutdown(broker.Close)
+	}
+
 	// Setup graceful shutdown
-	cleanup := setupGracefulShutdown(srv, mysqlDB, postgresDB, redisClient, kafkaProducer, kafkaClient)
+	cleanup := setupGracefulShutdown(srv, shutdownManager, mysqlDB, postgresDB, redisClient, kafkaProducer, kafkaClient)
 	defer cleanup()
 
 	// Add configuration details
-	printActiveConfiguration(cfg, srv)
+	logActiveConfiguration(cfg, srv)
 
-	log.Printf("\n✨ Server is ready to accept connections")
-	log.Printf("━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━\n")
+	slog.Info("server ready", "addr", addr)
 
 	// Start server
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		log.Fatalf("❌ Server error: %v", err)
+		fatal("server error", "error", err)
 	}
 }
 
+// fatal logs msg with args at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
 // getEnvOrDefault returns environment variable value or default if not set
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
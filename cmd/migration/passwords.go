@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/password"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+type plaintextRow struct {
+	id       string
+	password string
+}
+
+// runPasswordHashMigration แปลง password ที่ยังเก็บเป็น plaintext ในตาราง users ให้เป็น hash
+//
+// Passwords are hashed with hasher, built from the same configuration as the
+// API so that they are not rehashed on the next login. Only values that parse
+// as a complete hash are skipped, a plaintext password that merely starts
+// like one is hashed too.
+//
+// The update only succeeds while the row still holds the plaintext value it
+// read, so running it concurrently with the API never overwrites a password
+// that was changed in the meantime. Running it again is a no-op.
+func runPasswordHashMigration(ctx context.Context, dsn string, batchSize int, hasher *password.Manager) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.PingContext(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		lastID  string
+		scanned int
+		hashed  int
+	)
+
+	for {
+		batch, err := loadPasswordBatch(ctx, db, lastID, batchSize)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, row := range batch {
+			scanned++
+			lastID = row.id
+			if hasher.IsHashed(row.password) {
+				continue
+			}
+
+			hash, err := hasher.Hash(row.password)
+			if err != nil {
+				log.Fatalf("Error hashing password for user %s: %v", row.id, err)
+			}
+
+			result, err := db.ExecContext(ctx,
+				"UPDATE users SET password = ? WHERE id = ? AND password = ?",
+				hash, row.id, row.password)
+			if err != nil {
+				log.Fatalf("Error updating password for user %s: %v", row.id, err)
+			}
+			if n, _ := result.RowsAffected(); n > 0 {
+				hashed++
+			}
+		}
+
+		if len(batch) < batchSize {
+			break
+		}
+	}
+
+	log.Printf("Password migration completed: %d users scanned, %d plaintext passwords hashed", scanned, hashed)
+}
+
+func loadPasswordBatch(ctx context.Context, db *sql.DB, afterID string, limit int) ([]plaintextRow, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT id, password FROM users WHERE id > ? ORDER BY id LIMIT ?",
+		afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []plaintextRow
+	for rows.Next() {
+		var row plaintextRow
+		if err := rows.Scan(&row.id, &row.password); err != nil {
+			return nil, err
+		}
+		batch = append(batch, row)
+	}
+	return batch, rows.Err()
+}
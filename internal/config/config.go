@@ -42,15 +42,34 @@ type Config struct {
 	} `yaml:"redis"`
 
 	Kafka struct {
-		Brokers []string `yaml:"brokers"`
-		Topic   string   `yaml:"topic"`
+		Brokers        []string            `yaml:"brokers"`
+		Topic          string              `yaml:"topic"`
+		EventMode      string              `yaml:"event_mode"`      // binary or structured CloudEvents encoding
+		PublishTimeout int                 `yaml:"publish_timeout"` // in milliseconds, longest wait for the broker per publish
+		Consumer       KafkaConsumerConfig `yaml:"consumer"`
 	} `yaml:"kafka"`
 
 	Elasticsearch struct {
 		URL string `yaml:"url"`
 	} `yaml:"elasticsearch"`
 
+	Logging LoggingConfig `yaml:"logging"`
+
 	Tracing TracingConfig `yaml:"tracing"`
+
+	Security SecurityConfig `yaml:"security"`
+
+	Retention RetentionConfig `yaml:"retention"`
+
+	Outbox OutboxConfig `yaml:"outbox"`
+
+	EventBus EventBusConfig `yaml:"event_bus"`
+
+	Webhooks WebhooksConfig `yaml:"webhooks"`
+
+	Stream StreamConfig `yaml:"stream"`
+
+	Messages MessagesConfig `yaml:"messages"`
 }
 
 type Server struct {
@@ -66,9 +85,15 @@ type CORSConfig struct {
 	AllowedOrigins []string `yaml:"allowed_origins"`
 	AllowedMethods []string `yaml:"allowed_methods"`
 	AllowedHeaders []string `yaml:"allowed_headers"`
+	ExposedHeaders []string `yaml:"exposed_headers"`
 	MaxAge         int      `yaml:"max_age"`
 }
 
+type LoggingConfig struct {
+	Level     string `yaml:"level"`      // debug, info, warn or error; info when empty
+	AccessLog bool   `yaml:"access_log"` // one record per HTTP request
+}
+
 type TracingConfig struct {
 	Enabled       bool    `yaml:"enabled"`
 	ServiceName   string  `yaml:"serviceName"`
@@ -76,6 +101,100 @@ type TracingConfig struct {
 	SamplingRatio float64 `yaml:"samplingRatio"`
 }
 
+type SecurityConfig struct {
+	Password PasswordConfig `yaml:"password"`
+	JWT      JWTConfig      `yaml:"jwt"`
+	RBAC     RBACConfig     `yaml:"rbac"`
+}
+
+type PasswordConfig struct {
+	Algorithm  string       `yaml:"algorithm"` // argon2id or bcrypt
+	Argon2     Argon2Config `yaml:"argon2"`
+	BcryptCost int          `yaml:"bcrypt_cost"`
+}
+
+type Argon2Config struct {
+	Memory      uint32 `yaml:"memory"` // in KiB
+	Iterations  uint32 `yaml:"iterations"`
+	Parallelism uint8  `yaml:"parallelism"`
+}
+
+type JWTConfig struct {
+	Secret          string `yaml:"secret"`
+	Issuer          string `yaml:"issuer"`
+	AccessTokenTTL  int    `yaml:"access_token_ttl"`  // in minutes
+	RefreshTokenTTL int    `yaml:"refresh_token_ttl"` // in minutes
+}
+
+type RBACConfig struct {
+	DefaultRole string `yaml:"default_role"` // role applied to users without an assigned role
+}
+
+type RetentionConfig struct {
+	Enabled            bool `yaml:"enabled"`
+	DeletedRecordsDays int  `yaml:"deleted_records_days"` // soft deleted rows older than this are purged
+	PurgeInterval      int  `yaml:"purge_interval"`       // in minutes
+	BatchSize          int  `yaml:"batch_size"`           // rows removed per purge statement
+}
+
+type KafkaConsumerConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	GroupID       string `yaml:"group_id"`
+	InitialOffset string `yaml:"initial_offset"` // oldest or newest, where a new group starts
+	MaxAttempts   int    `yaml:"max_attempts"`   // tries per message before it is skipped
+	RetryBackoff  int    `yaml:"retry_backoff"`  // in milliseconds, doubled after every retry
+	DeadLetter    bool   `yaml:"dead_letter"`    // forward failed messages to retry and dead-letter topics
+	RetryDelays   []int  `yaml:"retry_delays"`   // in milliseconds, one per retry topic
+}
+
+type EventBusConfig struct {
+	Backend   string `yaml:"backend"`    // kafka, redis or memory
+	Stream    string `yaml:"stream"`     // redis stream, kafka.topic when empty
+	MaxLen    int64  `yaml:"max_len"`    // approximate number of entries the redis stream keeps, 0 for no limit
+	ClaimIdle int    `yaml:"claim_idle"` // in seconds, redis entries pending longer are taken over by another member
+}
+
+type OutboxConfig struct {
+	Enabled      bool `yaml:"enabled"`
+	PollInterval int  `yaml:"poll_interval"` // in milliseconds
+	BatchSize    int  `yaml:"batch_size"`    // messages read per poll
+	MaxBackoff   int  `yaml:"max_backoff"`   // in seconds, cap on the delay between retries
+	MaxAttempts  int  `yaml:"max_attempts"`  // attempts before a message is parked
+}
+
+type WebhooksConfig struct {
+	Enabled              bool   `yaml:"enabled"`
+	GroupID              string `yaml:"group_id"`               // consumer group that queues events for subscriptions
+	PollInterval         int    `yaml:"poll_interval"`          // in milliseconds
+	Timeout              int    `yaml:"timeout"`                // in milliseconds, per request
+	Workers              int    `yaml:"workers"`                // requests in flight at once
+	MaxAttempts          int    `yaml:"max_attempts"`           // requests made for one delivery
+	MinBackoff           int    `yaml:"min_backoff"`            // in seconds, delay after the first failed attempt
+	MaxBackoff           int    `yaml:"max_backoff"`            // in seconds, cap on the delay between attempts
+	DisableAfter         int    `yaml:"disable_after"`          // failed attempts in a row that disable a subscription, -1 never
+	AllowPrivateNetworks bool   `yaml:"allow_private_networks"` // let endpoints resolve to loopback, private and link-local addresses, development only
+}
+
+type StreamConfig struct {
+	Enabled      bool   `yaml:"enabled"`
+	GroupID      string `yaml:"group_id"`      // prefix of the consumer group, each instance adds its host name
+	ReplaySize   int    `yaml:"replay_size"`   // events kept for clients resuming with Last-Event-ID
+	ClientBuffer int    `yaml:"client_buffer"` // events queued per connection before it is dropped as too slow
+	Heartbeat    int    `yaml:"heartbeat"`     // in seconds
+	WriteTimeout int    `yaml:"write_timeout"` // in seconds, per event
+}
+
+type MessagesConfig struct {
+	Enabled      bool `yaml:"enabled"`       // publish scheduled messages from this instance
+	PollInterval int  `yaml:"poll_interval"` // in milliseconds
+	BatchSize    int  `yaml:"batch_size"`    // messages claimed, and published, at once
+	Lease        int  `yaml:"lease"`         // in seconds, before a claimed message is claimed again
+	MaxAttempts  int  `yaml:"max_attempts"`  // publishes tried for one message
+	MinBackoff   int  `yaml:"min_backoff"`   // in seconds, delay after the first failed publish
+	MaxBackoff   int  `yaml:"max_backoff"`   // in seconds, cap on the delay between publishes
+	RecoverAfter int  `yaml:"recover_after"` // in seconds, before a message sent now and still queued is published by a dispatcher
+}
+
 func Load(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
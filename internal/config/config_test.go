@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -57,3 +58,13 @@ func TestLoad_InvalidYAML(t *testing.T) {
 	_, err = Load(tmpfile.Name())
 	assert.Error(t, err)
 }
+
+func TestPasswordConfig_Manager(t *testing.T) {
+	argon, err := PasswordConfig{Argon2: Argon2Config{Memory: 1024, Iterations: 1, Parallelism: 1}}.Manager().Hash("password123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(argon, "$argon2id$v=19$m=1024,t=1,p=1$"), argon)
+
+	bcrypt, err := PasswordConfig{Algorithm: "bcrypt", BcryptCost: 4}.Manager().Hash("password123")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(bcrypt, "$2a$04$"), bcrypt)
+}
@@ -0,0 +1,33 @@
+package config
+
+import "github.com/Napat/golang-testcontainers-demo/pkg/password"
+
+// Manager returns the password manager the configuration describes: new
+// passwords are hashed with Algorithm, and hashes produced by the other
+// algorithm are still verified and upgraded on login. Unset parameters take
+// the package defaults.
+func (c PasswordConfig) Manager() *password.Manager {
+	params := password.DefaultArgon2Params
+	if c.Argon2.Memory > 0 {
+		params.Memory = c.Argon2.Memory
+	}
+	if c.Argon2.Iterations > 0 {
+		params.Iterations = c.Argon2.Iterations
+	}
+	if c.Argon2.Parallelism > 0 {
+		params.Parallelism = c.Argon2.Parallelism
+	}
+
+	cost := password.DefaultBcryptCost
+	if c.BcryptCost > 0 {
+		cost = c.BcryptCost
+	}
+
+	argon := password.NewArgon2id(params)
+	bcryptHasher := password.NewBcrypt(cost)
+
+	if c.Algorithm == "bcrypt" {
+		return password.NewManager(bcryptHasher, argon)
+	}
+	return password.NewManager(argon, bcryptHasher)
+}
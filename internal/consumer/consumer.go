@@ -0,0 +1,384 @@
+package consumer
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/Napat/golang-testcontainers-demo/pkg/tracing"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	// DefaultMaxAttempts is how often a failing message is tried when no limit is configured
+	DefaultMaxAttempts = 3
+	// DefaultRetryBackoff is the delay before the first retry, doubled after every further one
+	DefaultRetryBackoff = 500 * time.Millisecond
+
+	// rejoinDelay paces attempts to rejoin the group after an error
+	rejoinDelay = time.Second
+	// maxForwardBackoff caps the delay between attempts to forward a failed
+	// message while Kafka is unavailable
+	maxForwardBackoff = 30 * time.Second
+)
+
+// Forwarder publishes a failed message to a retry or dead-letter topic
+type Forwarder interface {
+	Forward(ctx context.Context, topic string, msg *sarama.ConsumerMessage, failure repository_event.Failure) error
+}
+
+// Config describes the consumer group and how failures are retried
+type Config struct {
+	GroupID      string
+	Topics       []string
+	MaxAttempts  int
+	RetryBackoff time.Duration
+
+	// Forwarder moves messages that failed every attempt out of the way, so
+	// they do not hold back their partition. Without it they are logged and
+	// skipped.
+	Forwarder Forwarder
+	// RetryDelays lists the delay of each retry tier. A failed message moves
+	// to the next tier, after the last one or when it failed permanently to
+	// the dead-letter topic.
+	RetryDelays []time.Duration
+}
+
+// FailureTopics returns the retry and dead-letter topics of every consumed
+// topic, empty without a Forwarder
+func (c Config) FailureTopics() []string {
+	if c.Forwarder == nil {
+		return nil
+	}
+	var topics []string
+	for _, topic := range c.Topics {
+		for n := range c.RetryDelays {
+			topics = append(topics, repository_event.RetryTopic(topic, c.GroupID, n+1))
+		}
+		topics = append(topics, repository_event.DeadLetterTopic(topic, c.GroupID))
+	}
+	return topics
+}
+
+// tier places a consumed topic: the topic its messages were first published
+// to and the retry tier it holds, 0 for the topic itself
+type tier struct {
+	topic string
+	n     int
+}
+
+// SaramaConfig returns the client configuration the consumer expects:
+// offsets are committed by the consumer after each processed message, and a
+// new group starts from initialOffset, "oldest" or "newest" (the default).
+func SaramaConfig(initialOffset string) *sarama.Config {
+	config := sarama.NewConfig()
+	config.Consumer.Return.Errors = true
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	if initialOffset == "oldest" {
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	}
+	return config
+}
+
+// Consumer reads the configured topics as a member of a consumer group and
+// dispatches the event of every message to the handler registered for its
+// type.
+//
+// Partitions are processed concurrently, one goroutine per claimed
+// partition, and messages within a partition strictly in order. A message is
+// committed once its handler succeeded, once it failed permanently or ran out
+// of attempts and was forwarded to a retry or dead-letter topic, or when no
+// handler is registered for its type. Retry topics are consumed like the
+// others, each message once its tier's delay since it failed has passed.
+// Delivery is at least once: a message interrupted by a rebalance or
+// shutdown is delivered again to the next owner of its partition.
+type Consumer struct {
+	group    sarama.ConsumerGroup
+	registry *Registry
+	config   Config
+	tiers    map[string]tier
+	metrics  *metrics.ConsumerMetrics
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New creates a consumer for group. Call Start to join the group and Stop to
+// leave it.
+func New(group sarama.ConsumerGroup, registry *Registry, config Config) *Consumer {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = DefaultMaxAttempts
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = DefaultRetryBackoff
+	}
+	tiers := make(map[string]tier)
+	for _, topic := range config.Topics {
+		tiers[topic] = tier{topic: topic}
+		if config.Forwarder == nil {
+			continue
+		}
+		for n := range config.RetryDelays {
+			tiers[repository_event.RetryTopic(topic, config.GroupID, n+1)] = tier{topic: topic, n: n + 1}
+		}
+	}
+	return &Consumer{
+		group:    group,
+		registry: registry,
+		config:   config,
+		tiers:    tiers,
+		metrics:  metrics.NewConsumerMetrics(),
+	}
+}
+
+// Start joins the group in the background and keeps consuming, across
+// rebalances, until Stop is called
+func (c *Consumer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.done = make(chan struct{})
+
+	// The channel is closed by group.Close
+	go func() {
+		for err := range c.group.Errors() {
+			slog.Error("kafka consumer error", "group", c.config.GroupID, "error", err)
+		}
+	}()
+
+	topics := make([]string, 0, len(c.tiers))
+	for topic := range c.tiers {
+		topics = append(topics, topic)
+	}
+
+	go func() {
+		defer close(c.done)
+		for ctx.Err() == nil {
+			// Consume returns at every rebalance and is called again to
+			// join the new generation
+			err := c.group.Consume(ctx, topics, &groupHandler{consumer: c})
+			if stderrors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			if err != nil {
+				slog.Error("kafka consumer session failed", "group", c.config.GroupID, "error", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(rejoinDelay):
+				}
+			}
+		}
+	}()
+}
+
+// Stop leaves the group. Messages in flight are finished or left uncommitted,
+// then the group is closed so its partitions are handed to the remaining
+// members at once. It has the signature of a shutdown.Manager handler.
+func (c *Consumer) Stop(ctx context.Context) error {
+	if c.cancel == nil {
+		return c.group.Close()
+	}
+	c.cancel()
+
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		c.group.Close()
+		return ctx.Err()
+	}
+	return c.group.Close()
+}
+
+// groupHandler implements sarama.ConsumerGroupHandler for one generation of
+// the group
+type groupHandler struct {
+	consumer *Consumer
+}
+
+func (h *groupHandler) Setup(session sarama.ConsumerGroupSession) error {
+	slog.Info("kafka consumer joined group",
+		"group", h.consumer.config.GroupID,
+		"member", session.MemberID(),
+		"generation", session.GenerationID(),
+		"claims", session.Claims(),
+	)
+	return nil
+}
+
+// Cleanup runs once every partition goroutine returned, before the
+// partitions are revoked, and commits what they marked
+func (h *groupHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+	return nil
+}
+
+func (h *groupHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	c := h.consumer
+	lag := c.metrics.Lag.WithLabelValues(c.config.GroupID, claim.Topic(), strconv.Itoa(int(claim.Partition())))
+	ctx := session.Context()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			if !c.process(ctx, msg) {
+				// Interrupted by a rebalance or shutdown, the message stays
+				// uncommitted for the next owner of the partition
+				return nil
+			}
+			session.MarkMessage(msg, "")
+			session.Commit()
+			lag.Set(float64(max(claim.HighWaterMarkOffset()-msg.Offset-1, 0)))
+		}
+	}
+}
+
+// process runs the handler of msg, retrying failures with backoff, and
+// forwards it when every attempt failed. It returns false when ctx ended
+// before the message was settled.
+func (c *Consumer) process(ctx context.Context, msg *sarama.ConsumerMessage) bool {
+	logger := slog.Default().With(
+		"group", c.config.GroupID,
+		"topic", msg.Topic,
+		"partition", msg.Partition,
+		"offset", msg.Offset,
+	)
+
+	// The tier the message was read from and its failures so far
+	t := c.tiers[msg.Topic]
+	previous := repository_event.FailureOf(msg)
+
+	// A message that is not an event never will be, it goes straight to the
+	// dead-letter topic where it can be inspected
+	envelope, err := event.FromMessage(msg)
+	if err != nil {
+		return c.fail(ctx, logger, msg, t, "", repository_event.Failure{
+			Reason:   fmt.Sprintf("message is not an event: %v", err),
+			Attempts: previous.Attempts + 1,
+			FailedAt: time.Now(),
+		}, true)
+	}
+	eventType := envelope.Type
+	logger = logger.With("event_type", eventType, "event_id", envelope.ID)
+
+	id := envelope.CorrelationID
+	if id == "" {
+		id = header(msg, requestid.Header)
+	}
+	if id != "" {
+		ctx = requestid.NewContext(ctx, id)
+		logger = logger.With("request_id", id)
+	}
+
+	// Messages of a retry tier wait for the tier's delay since they failed
+	if t.n > 0 {
+		if wait := time.Until(previous.FailedAt.Add(c.config.RetryDelays[t.n-1])); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-time.After(wait):
+			}
+		}
+	}
+
+	handle := c.registry.lookup(eventType)
+	if handle == nil {
+		logger.Debug("no handler for message")
+		c.metrics.MessagesTotal.WithLabelValues(c.config.GroupID, msg.Topic, eventType, "unhandled").Inc()
+		return true
+	}
+
+	// The span continues the trace of the request that published the event
+	ctx, span := tracing.StartConsumerSpan(ctx, c.config.GroupID, msg, envelope.ID)
+	defer span.End()
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		logger = logger.With("trace_id", sc.TraceID().String())
+	}
+	ctx = logging.NewContext(ctx, logger)
+
+	start := time.Now()
+	defer func() {
+		c.metrics.ProcessingDuration.WithLabelValues(c.config.GroupID, msg.Topic, eventType).Observe(time.Since(start).Seconds())
+	}()
+
+	backoff := c.config.RetryBackoff
+	for attempt := 1; ; attempt++ {
+		err := handle(ctx, envelope)
+		if err == nil {
+			c.metrics.MessagesTotal.WithLabelValues(c.config.GroupID, msg.Topic, eventType, "success").Inc()
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		span.RecordError(err)
+		if IsPermanent(err) || attempt >= c.config.MaxAttempts {
+			span.SetStatus(codes.Error, err.Error())
+			return c.fail(ctx, logger, msg, t, eventType, repository_event.Failure{
+				Reason:   err.Error(),
+				Attempts: previous.Attempts + attempt,
+				FailedAt: time.Now(),
+			}, IsPermanent(err))
+		}
+
+		logger.Warn("failed to process message, retrying", "attempt", attempt, "retry_in", backoff.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// fail forwards a message that failed every attempt to the next retry tier,
+// or to the dead-letter topic after the last tier or a permanent failure.
+// Forwarding is retried until it succeeds, holding back the partition while
+// Kafka is unavailable, or until ctx ends and false is returned.
+func (c *Consumer) fail(ctx context.Context, logger *slog.Logger, msg *sarama.ConsumerMessage, t tier, eventType string, failure repository_event.Failure, permanent bool) bool {
+	if c.config.Forwarder == nil {
+		logger.Error("failed to process message, skipping it", "attempts", failure.Attempts, "error", failure.Reason)
+		c.metrics.MessagesTotal.WithLabelValues(c.config.GroupID, msg.Topic, eventType, "failed").Inc()
+		return true
+	}
+
+	topic, status := repository_event.DeadLetterTopic(t.topic, c.config.GroupID), "dead_lettered"
+	if !permanent && t.n < len(c.config.RetryDelays) {
+		topic, status = repository_event.RetryTopic(t.topic, c.config.GroupID, t.n+1), "retried"
+	}
+
+	backoff := c.config.RetryBackoff
+	for {
+		err := c.config.Forwarder.Forward(ctx, topic, msg, failure)
+		if err == nil {
+			logger.Warn("failed to process message, forwarded it",
+				"to", topic,
+				"attempts", failure.Attempts,
+				"error", failure.Reason,
+			)
+			c.metrics.MessagesTotal.WithLabelValues(c.config.GroupID, msg.Topic, eventType, status).Inc()
+			return true
+		}
+
+		logger.Error("failed to forward message", "to", topic, "retry_in", backoff.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxForwardBackoff)
+	}
+}
@@ -0,0 +1,206 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSession struct {
+	ctx     context.Context
+	marked  []int64
+	commits int
+}
+
+func (s *fakeSession) Claims() map[string][]int32                           { return nil }
+func (s *fakeSession) MemberID() string                                     { return "member" }
+func (s *fakeSession) GenerationID() int32                                  { return 1 }
+func (s *fakeSession) MarkOffset(topic string, p int32, o int64, m string)  {}
+func (s *fakeSession) ResetOffset(topic string, p int32, o int64, m string) {}
+func (s *fakeSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+func (s *fakeSession) Commit()                  { s.commits++ }
+func (s *fakeSession) Context() context.Context { return s.ctx }
+
+type fakeClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *fakeClaim) Topic() string                            { return "events" }
+func (c *fakeClaim) Partition() int32                         { return 0 }
+func (c *fakeClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeClaim) HighWaterMarkOffset() int64               { return 10 }
+func (c *fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func typedMessage(offset int64, eventType string) *sarama.ConsumerMessage {
+	envelope, _ := event.New(context.Background(), eventType, 1, "key", struct{}{})
+	headers, value, _ := envelope.Encode(event.Binary)
+
+	msg := &sarama.ConsumerMessage{Topic: "events", Offset: offset, Value: value}
+	for _, h := range headers {
+		msg.Headers = append(msg.Headers, &h)
+	}
+	return msg
+}
+
+func claimOf(msgs ...*sarama.ConsumerMessage) *fakeClaim {
+	claim := &fakeClaim{messages: make(chan *sarama.ConsumerMessage, len(msgs))}
+	for _, msg := range msgs {
+		claim.messages <- msg
+	}
+	close(claim.messages)
+	return claim
+}
+
+func TestConsumeClaim(t *testing.T) {
+	var handled []string
+	calls := map[string]int{}
+	r := NewRegistry()
+	r.Handle("ok", func(ctx context.Context, e *event.Envelope) error {
+		handled = append(handled, "ok:"+requestid.FromContext(ctx))
+		return nil
+	})
+	r.Handle("flaky", func(ctx context.Context, e *event.Envelope) error {
+		calls["flaky"]++
+		if calls["flaky"] < 2 {
+			return errors.New("temporarily unavailable")
+		}
+		handled = append(handled, "flaky")
+		return nil
+	})
+	r.Handle("broken", func(ctx context.Context, e *event.Envelope) error {
+		calls["broken"]++
+		return errors.New("always fails")
+	})
+	r.Handle("poison", func(ctx context.Context, e *event.Envelope) error {
+		calls["poison"]++
+		return Permanent(errors.New("malformed"))
+	})
+
+	c := New(nil, r, Config{GroupID: "test", MaxAttempts: 3, RetryBackoff: time.Millisecond})
+
+	withID := typedMessage(0, "ok")
+	withID.Headers = append(withID.Headers, &sarama.RecordHeader{Key: []byte(requestid.Header), Value: []byte("req-1")})
+	session := &fakeSession{ctx: context.Background()}
+	claim := claimOf(
+		withID,
+		typedMessage(1, "flaky"),
+		typedMessage(2, "broken"),
+		typedMessage(3, "poison"),
+		typedMessage(4, "unknown"),
+		&sarama.ConsumerMessage{Topic: "events", Offset: 5, Value: []byte(`{"content":"hello"}`)},
+	)
+
+	assert.NoError(t, (&groupHandler{consumer: c}).ConsumeClaim(session, claim))
+
+	assert.Equal(t, []string{"ok:req-1", "flaky"}, handled)
+	assert.Equal(t, map[string]int{"flaky": 2, "broken": 3, "poison": 1}, calls)
+	// Every message is settled and committed, in partition order
+	assert.Equal(t, []int64{0, 1, 2, 3, 4, 5}, session.marked)
+	assert.Equal(t, 6, session.commits)
+}
+
+func TestConsumeClaim_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewRegistry()
+	r.Handle("slow", func(ctx context.Context, e *event.Envelope) error {
+		cancel()
+		return ctx.Err()
+	})
+
+	c := New(nil, r, Config{GroupID: "test"})
+	session := &fakeSession{ctx: ctx}
+
+	assert.NoError(t, (&groupHandler{consumer: c}).ConsumeClaim(session, claimOf(typedMessage(0, "slow"), typedMessage(1, "slow"))))
+
+	// The interrupted message is left for the next owner of the partition
+	assert.Empty(t, session.marked)
+	assert.Zero(t, session.commits)
+}
+
+type forwarded struct {
+	topic    string
+	offset   int64
+	attempts int
+}
+
+type fakeForwarder struct {
+	forwarded []forwarded
+	failures  int
+}
+
+func (f *fakeForwarder) Forward(ctx context.Context, topic string, msg *sarama.ConsumerMessage, failure repository_event.Failure) error {
+	if f.failures > 0 {
+		f.failures--
+		return errors.New("broker unavailable")
+	}
+	f.forwarded = append(f.forwarded, forwarded{topic: topic, offset: msg.Offset, attempts: failure.Attempts})
+	return nil
+}
+
+func TestConsumeClaim_ForwardsFailures(t *testing.T) {
+	r := NewRegistry()
+	r.Handle("broken", func(ctx context.Context, e *event.Envelope) error {
+		return errors.New("always fails")
+	})
+	r.Handle("poison", func(ctx context.Context, e *event.Envelope) error {
+		return Permanent(errors.New("malformed"))
+	})
+
+	forwarder := &fakeForwarder{failures: 1}
+	c := New(nil, r, Config{
+		GroupID:      "test",
+		Topics:       []string{"events"},
+		MaxAttempts:  2,
+		RetryBackoff: time.Millisecond,
+		Forwarder:    forwarder,
+		RetryDelays:  []time.Duration{10 * time.Millisecond, 50 * time.Millisecond},
+	})
+	assert.Equal(t, []string{"events.test.retry.1", "events.test.retry.2", "events.test.dlq"}, c.config.FailureTopics())
+
+	// A message on the last tier that failed 4 times just now
+	retried := typedMessage(2, "broken")
+	retried.Topic = "events.test.retry.2"
+	retried.Headers = append(retried.Headers,
+		&sarama.RecordHeader{Key: []byte(repository_event.AttemptsHeader), Value: []byte("4")},
+		&sarama.RecordHeader{Key: []byte(repository_event.FailedAtHeader), Value: []byte(time.Now().UTC().Format(time.RFC3339Nano))},
+	)
+
+	session := &fakeSession{ctx: context.Background()}
+	start := time.Now()
+	assert.NoError(t, (&groupHandler{consumer: c}).ConsumeClaim(session, claimOf(typedMessage(0, "broken"), typedMessage(1, "poison"), retried)))
+
+	assert.Equal(t, []forwarded{
+		{topic: "events.test.retry.1", offset: 0, attempts: 2},
+		{topic: "events.test.dlq", offset: 1, attempts: 1},
+		{topic: "events.test.dlq", offset: 2, attempts: 6},
+	}, forwarder.forwarded)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, []int64{0, 1, 2}, session.marked)
+}
+
+func TestConsumeClaim_DeadLettersInvalidMessages(t *testing.T) {
+	forwarder := &fakeForwarder{}
+	c := New(nil, NewRegistry(), Config{
+		GroupID:     "test",
+		Topics:      []string{"events"},
+		Forwarder:   forwarder,
+		RetryDelays: []time.Duration{time.Millisecond},
+	})
+
+	session := &fakeSession{ctx: context.Background()}
+	invalid := &sarama.ConsumerMessage{Topic: "events", Offset: 0, Value: []byte(`{"content":"hello"}`)}
+	assert.NoError(t, (&groupHandler{consumer: c}).ConsumeClaim(session, claimOf(invalid)))
+
+	// Retrying cannot turn it into an event, it skips the retry tiers
+	assert.Equal(t, []forwarded{{topic: "events.test.dlq", offset: 0, attempts: 1}}, forwarder.forwarded)
+	assert.Equal(t, []int64{0}, session.marked)
+}
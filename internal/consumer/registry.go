@@ -0,0 +1,110 @@
+package consumer
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+)
+
+// HandlerFunc processes the event of one message. A nil error commits the
+// message, any other error is retried unless it is marked with Permanent.
+type HandlerFunc func(ctx context.Context, e *event.Envelope) error
+
+// Registry maps event types to the handlers that process them
+type Registry struct {
+	handlers map[string]HandlerFunc
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle registers h for eventType. Registering a type twice is a
+// programming error and panics, like http.ServeMux does for patterns.
+func (r *Registry) Handle(eventType string, h HandlerFunc) {
+	if eventType == "" {
+		panic("consumer: empty event type")
+	}
+	if _, ok := r.handlers[eventType]; ok {
+		panic(fmt.Sprintf("consumer: handler for %q already registered", eventType))
+	}
+	r.handlers[eventType] = h
+}
+
+// Types returns the registered event types
+func (r *Registry) Types() []string {
+	types := make([]string, 0, len(r.handlers))
+	for eventType := range r.handlers {
+		types = append(types, eventType)
+	}
+	return types
+}
+
+// Handles reports whether a handler is registered for eventType
+func (r *Registry) Handles(eventType string) bool {
+	return r.lookup(eventType) != nil
+}
+
+// Dispatch runs the handler registered for the type of e. Events without a
+// handler are ignored.
+func (r *Registry) Dispatch(ctx context.Context, e *event.Envelope) error {
+	h := r.lookup(e.Type)
+	if h == nil {
+		return nil
+	}
+	return h(ctx, e)
+}
+
+func (r *Registry) lookup(eventType string) HandlerFunc {
+	return r.handlers[eventType]
+}
+
+// Typed adapts fn to a HandlerFunc that decodes the event data into T, the
+// payload registered for the event type in event.Types. Data that does not
+// decode, or a schema version newer than the registered one, is a permanent
+// failure.
+func Typed[T any](fn func(ctx context.Context, payload T) error) HandlerFunc {
+	return func(ctx context.Context, e *event.Envelope) error {
+		payload, err := event.Decode[T](event.Types, e)
+		if err != nil {
+			return Permanent(err)
+		}
+		return fn(ctx, payload)
+	}
+}
+
+func header(msg *sarama.ConsumerMessage, key string) string {
+	for _, h := range msg.Headers {
+		if h != nil && string(h.Key) == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. a malformed message. The
+// message is logged and committed.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return stderrors.As(err, &p)
+}
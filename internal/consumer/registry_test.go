@@ -0,0 +1,108 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Handle(t *testing.T) {
+	r := NewRegistry()
+	r.Handle("user.created", func(ctx context.Context, e *event.Envelope) error { return nil })
+
+	assert.NotNil(t, r.lookup("user.created"))
+	assert.Nil(t, r.lookup("user.deleted"))
+	assert.Equal(t, []string{"user.created"}, r.Types())
+
+	assert.True(t, r.Handles("user.created"))
+	assert.False(t, r.Handles("user.deleted"))
+
+	failing := errors.New("cache unavailable")
+	r.Handle("user.updated", func(ctx context.Context, e *event.Envelope) error { return failing })
+	assert.ErrorIs(t, r.Dispatch(context.Background(), &event.Envelope{Type: "user.updated"}), failing)
+	assert.NoError(t, r.Dispatch(context.Background(), &event.Envelope{Type: "user.deleted"}))
+
+	assert.Panics(t, func() {
+		r.Handle("user.created", func(ctx context.Context, e *event.Envelope) error { return nil })
+	})
+	assert.Panics(t, func() {
+		r.Handle("", func(ctx context.Context, e *event.Envelope) error { return nil })
+	})
+}
+
+func TestTyped(t *testing.T) {
+	var got model.UserChangedEvent
+	h := Typed(func(ctx context.Context, payload model.UserChangedEvent) error {
+		got = payload
+		return nil
+	})
+
+	envelope, err := event.Types.New(context.Background(), model.UserEventUpdated, "user:1", model.UserChangedEvent{Type: model.UserEventUpdated, Status: model.StatusActive})
+	require.NoError(t, err)
+	require.NoError(t, h(context.Background(), envelope))
+	assert.Equal(t, model.UserEventUpdated, got.Type)
+	assert.Equal(t, model.StatusActive, got.Status)
+
+	envelope.Data = []byte(`not json`)
+	assert.True(t, IsPermanent(h(context.Background(), envelope)))
+
+	envelope.Data = []byte(`{}`)
+	envelope.SchemaVersion = 2
+	assert.True(t, IsPermanent(h(context.Background(), envelope)))
+}
+
+func TestPermanent(t *testing.T) {
+	assert.Nil(t, Permanent(nil))
+	assert.False(t, IsPermanent(errors.New("timeout")))
+
+	cause := errors.New("bad payload")
+	err := Permanent(cause)
+	assert.True(t, IsPermanent(err))
+	assert.ErrorIs(t, err, cause)
+}
+
+type fakeCache struct {
+	values map[string]interface{}
+}
+
+func (f *fakeCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	f.values[key] = value
+	return nil
+}
+
+func (f *fakeCache) Delete(ctx context.Context, key string) error {
+	delete(f.values, key)
+	return nil
+}
+
+func TestRegisterUserCache(t *testing.T) {
+	id := uuid.Must(uuid.NewV7())
+	key := "user:" + id.String()
+	cache := &fakeCache{values: map[string]interface{}{}}
+	r := NewRegistry()
+	RegisterUserCache(r, cache)
+
+	send := func(eventType, value string) {
+		t.Helper()
+		envelope, err := event.Types.New(context.Background(), eventType, key, json.RawMessage(value))
+		require.NoError(t, err)
+		require.NoError(t, r.lookup(eventType)(context.Background(), envelope))
+	}
+
+	send(model.UserEventCreated, `{"id":"`+id.String()+`","username":"alice"}`)
+	assert.Equal(t, "alice", cache.values[key].(model.UserResponse).Username)
+
+	send(model.UserEventUpdated, `{"type":"user.updated","user_id":"`+id.String()+`","user":{"id":"`+id.String()+`","username":"bob"}}`)
+	assert.Equal(t, "bob", cache.values[key].(model.UserResponse).Username)
+
+	send(model.UserEventDeleted, `{"type":"user.deleted","user_id":"`+id.String()+`"}`)
+	assert.NotContains(t, cache.values, key)
+}
@@ -0,0 +1,45 @@
+package consumer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+)
+
+// userCacheTTL matches the expiry the user handler caches with
+const userCacheTTL = time.Hour
+
+// Cache is the part of the cache repository the user cache handlers need
+type Cache interface {
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// RegisterUserCache registers handlers that keep the user:<id> cache entries
+// in line with user events, so every API instance sees changes made through
+// the others
+func RegisterUserCache(r *Registry, cache Cache) {
+	r.Handle(model.UserEventCreated, Typed(func(ctx context.Context, user model.UserResponse) error {
+		return cache.Set(ctx, userCacheKey(user.ID.String()), user, userCacheTTL)
+	}))
+
+	refresh := Typed(func(ctx context.Context, event model.UserChangedEvent) error {
+		if event.User == nil {
+			return cache.Delete(ctx, userCacheKey(event.UserID.String()))
+		}
+		return cache.Set(ctx, userCacheKey(event.UserID.String()), *event.User, userCacheTTL)
+	})
+	r.Handle(model.UserEventUpdated, refresh)
+	r.Handle(model.UserEventStatusChanged, refresh)
+	r.Handle(model.UserEventRestored, refresh)
+
+	r.Handle(model.UserEventDeleted, Typed(func(ctx context.Context, event model.UserChangedEvent) error {
+		return cache.Delete(ctx, userCacheKey(event.UserID.String()))
+	}))
+}
+
+func userCacheKey(id string) string {
+	return fmt.Sprintf("user:%s", id)
+}
@@ -0,0 +1,162 @@
+// Package eventbus publishes events and delivers them to consumers through
+// Kafka, Redis Streams or an in-process queue, chosen by configuration.
+package eventbus
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/Napat/golang-testcontainers-demo/pkg/tracing"
+)
+
+// Backends selectable in configuration
+const (
+	Kafka  = "kafka"
+	Redis  = "redis"
+	Memory = "memory"
+)
+
+// ErrClosed is returned by a bus that was closed
+var ErrClosed = stderrors.New("eventbus: bus closed")
+
+// Bus publishes events and delivers them to the handlers of a registry.
+//
+// Every backend gives the same guarantees: values are wrapped in an event
+// envelope like ProducerRepository.SendMessage does, and refused when their
+// type is not registered in event.Types. Each subscribed group receives
+// every event published after it subscribed, at least once, and events
+// published under the same key in order as long as the group has a single
+// member.
+type Bus interface {
+	// SendMessage publishes value under key. It returns once the event is
+	// stored by the backend, or fails when ctx ends first.
+	SendMessage(ctx context.Context, key string, value interface{}) error
+	// Subscribe delivers events to registry as a member of group until the
+	// bus is closed
+	Subscribe(group string, registry *consumer.Registry) error
+	// Close stops the subscriptions, waiting for handlers in flight until ctx
+	// ends. It has the signature of a shutdown.Manager handler.
+	Close(ctx context.Context) error
+}
+
+// Retry configures how the memory and Redis buses retry a failing handler.
+// Kafka subscriptions use the consumer configuration instead.
+type Retry struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+func (r Retry) withDefaults() Retry {
+	if r.MaxAttempts <= 0 {
+		r.MaxAttempts = consumer.DefaultMaxAttempts
+	}
+	if r.Backoff <= 0 {
+		r.Backoff = consumer.DefaultRetryBackoff
+	}
+	return r
+}
+
+// ParseBackend validates a configured backend name, empty meaning Kafka
+func ParseBackend(s string) (string, error) {
+	switch s {
+	case "", Kafka:
+		return Kafka, nil
+	case Redis, Memory:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown event bus backend %q, want kafka, redis or memory", s)
+	}
+}
+
+// contextError classifies a publish that ctx ended: a missed deadline is a
+// timeout, a cancelled caller gets its own error back
+func contextError(op string, err error) error {
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return errors.NewTimeout(op, err)
+	}
+	return err
+}
+
+// deliverer runs the handlers of one subscription of the memory or Redis
+// bus, retrying failures like the Kafka consumer does. Events that fail
+// every attempt are logged, counted as dead letters and dropped.
+type deliverer struct {
+	group    string
+	source   string // stream or queue name, the topic label of the metrics
+	registry *consumer.Registry
+	retry    Retry
+	metrics  *metrics.ConsumerMetrics
+}
+
+func newDeliverer(group, source string, registry *consumer.Registry, retry Retry) *deliverer {
+	return &deliverer{
+		group:    group,
+		source:   source,
+		registry: registry,
+		retry:    retry.withDefaults(),
+		metrics:  metrics.NewConsumerMetrics(),
+	}
+}
+
+// deliver runs the handler of e under the request id and trace it was
+// published with. It returns false when ctx ended before the event was
+// settled, so the event must be delivered again.
+func (d *deliverer) deliver(ctx context.Context, e *event.Envelope, traceContext map[string]string) bool {
+	logger := slog.Default().With(
+		"group", d.group,
+		"source", d.source,
+		"event_type", e.Type,
+		"event_id", e.ID,
+	)
+	if !d.registry.Handles(e.Type) {
+		d.metrics.MessagesTotal.WithLabelValues(d.group, d.source, e.Type, "unhandled").Inc()
+		return true
+	}
+
+	if e.CorrelationID != "" {
+		ctx = requestid.NewContext(ctx, e.CorrelationID)
+		logger = logger.With("request_id", e.CorrelationID)
+	}
+	ctx = tracing.Extract(ctx, traceContext)
+	ctx = logging.NewContext(ctx, logger)
+
+	start := time.Now()
+	defer func() {
+		d.metrics.ProcessingDuration.WithLabelValues(d.group, d.source, e.Type).Observe(time.Since(start).Seconds())
+	}()
+
+	backoff := d.retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := d.registry.Dispatch(ctx, e)
+		if err == nil {
+			d.metrics.MessagesTotal.WithLabelValues(d.group, d.source, e.Type, "success").Inc()
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		if consumer.IsPermanent(err) || attempt >= d.retry.MaxAttempts {
+			logger.Error("failed to process event, skipping it", "attempts", attempt, "error", err)
+			d.metrics.MessagesTotal.WithLabelValues(d.group, d.source, e.Type, "failed").Inc()
+			d.metrics.DeadLettersTotal.WithLabelValues(d.group, d.source, "failed").Inc()
+			return true
+		}
+
+		logger.Warn("failed to process event, retrying", "attempt", attempt, "retry_in", backoff.String(), "error", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
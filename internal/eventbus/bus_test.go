@@ -0,0 +1,28 @@
+package eventbus_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus"
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus/eventbustest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryBus(t *testing.T) {
+	eventbustest.Run(t, func(t *testing.T) eventbus.Bus {
+		return eventbus.NewMemoryBus(eventbus.Retry{MaxAttempts: 3, Backoff: time.Millisecond})
+	})
+}
+
+func TestParseBackend(t *testing.T) {
+	for input, want := range map[string]string{"": eventbus.Kafka, "kafka": eventbus.Kafka, "redis": eventbus.Redis, "memory": eventbus.Memory} {
+		got, err := eventbus.ParseBackend(input)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err := eventbus.ParseBackend("nats")
+	assert.Error(t, err)
+}
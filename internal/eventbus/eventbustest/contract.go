@@ -0,0 +1,216 @@
+// Package eventbustest holds the contract every eventbus.Bus backend must
+// pass.
+package eventbustest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deliveryTimeout bounds the wait for an event, generous for consumer group
+// rebalances
+const deliveryTimeout = 30 * time.Second
+
+// Run runs the contract against the buses returned by newBus. Every call
+// must return a bus of its own, on a topic or stream no other bus uses, that
+// retries a failing handler at least twice with a short backoff. Run closes
+// the buses.
+func Run(t *testing.T, newBus func(t *testing.T) eventbus.Bus) {
+	open := func(t *testing.T) eventbus.Bus {
+		bus := newBus(t)
+		t.Cleanup(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+			defer cancel()
+			assert.NoError(t, bus.Close(ctx))
+		})
+		return bus
+	}
+
+	t.Run("delivers events in order with their request id", func(t *testing.T) {
+		bus := open(t)
+		received := newRecorder()
+		require.NoError(t, bus.Subscribe(group(), received.registry(nil)))
+
+		userID := uuid.Must(uuid.NewV7())
+		for i := range 5 {
+			ctx := requestid.NewContext(context.Background(), fmt.Sprintf("req-%d", i))
+			require.NoError(t, bus.SendMessage(ctx, "user:"+userID.String(), changed(userID, i)))
+		}
+
+		got := received.wait(t, 5)
+		for i, d := range got {
+			assert.Equal(t, userID, d.payload.UserID)
+			assert.Equal(t, fmt.Sprintf("reason-%d", i), d.payload.Reason)
+			assert.Equal(t, fmt.Sprintf("req-%d", i), d.requestID)
+			assert.Equal(t, model.UserEventUpdated, d.envelope.Type)
+			assert.Equal(t, "user:"+userID.String(), d.envelope.Subject)
+		}
+	})
+
+	t.Run("delivers every event to every group", func(t *testing.T) {
+		bus := open(t)
+		first, second := newRecorder(), newRecorder()
+		require.NoError(t, bus.Subscribe(group(), first.registry(nil)))
+		require.NoError(t, bus.Subscribe(group(), second.registry(nil)))
+
+		userID := uuid.Must(uuid.NewV7())
+		for i := range 3 {
+			require.NoError(t, bus.SendMessage(context.Background(), "user:"+userID.String(), changed(userID, i)))
+		}
+
+		assert.Len(t, first.wait(t, 3), 3)
+		assert.Len(t, second.wait(t, 3), 3)
+	})
+
+	t.Run("retries a failed handler", func(t *testing.T) {
+		bus := open(t)
+		failures := 1
+		received := newRecorder()
+		require.NoError(t, bus.Subscribe(group(), received.registry(func(model.UserChangedEvent) error {
+			if failures > 0 {
+				failures--
+				return fmt.Errorf("cache unavailable")
+			}
+			return nil
+		})))
+
+		userID := uuid.Must(uuid.NewV7())
+		require.NoError(t, bus.SendMessage(context.Background(), "user:"+userID.String(), changed(userID, 0)))
+
+		got := received.wait(t, 2)
+		assert.Equal(t, got[0].envelope.ID, got[1].envelope.ID)
+	})
+
+	t.Run("skips a permanent failure", func(t *testing.T) {
+		bus := open(t)
+		received := newRecorder()
+		require.NoError(t, bus.Subscribe(group(), received.registry(func(payload model.UserChangedEvent) error {
+			if payload.Reason == "reason-0" {
+				return consumer.Permanent(fmt.Errorf("malformed"))
+			}
+			return nil
+		})))
+
+		userID := uuid.Must(uuid.NewV7())
+		require.NoError(t, bus.SendMessage(context.Background(), "user:"+userID.String(), changed(userID, 0)))
+		require.NoError(t, bus.SendMessage(context.Background(), "user:"+userID.String(), changed(userID, 1)))
+
+		got := received.wait(t, 2)
+		assert.Equal(t, "reason-0", got[0].payload.Reason)
+		assert.Equal(t, "reason-1", got[1].payload.Reason)
+	})
+
+	t.Run("refuses unregistered values", func(t *testing.T) {
+		bus := open(t)
+		err := bus.SendMessage(context.Background(), "user:1", &model.User{Username: "alice", Password: "secret"})
+		assert.ErrorIs(t, err, event.ErrUnknownType)
+	})
+
+	t.Run("fails a publish whose context ended", func(t *testing.T) {
+		bus := open(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := bus.SendMessage(ctx, "user:1", changed(uuid.Must(uuid.NewV7()), 0))
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("refuses subscriptions once closed", func(t *testing.T) {
+		bus := newBus(t)
+		ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+		defer cancel()
+		require.NoError(t, bus.Close(ctx))
+		assert.ErrorIs(t, bus.Subscribe(group(), newRecorder().registry(nil)), eventbus.ErrClosed)
+	})
+}
+
+func group() string {
+	return "contract-" + uuid.NewString()
+}
+
+func changed(userID uuid.UUID, i int) model.UserChangedEvent {
+	return model.UserChangedEvent{
+		Type:   model.UserEventUpdated,
+		UserID: userID,
+		Status: model.StatusActive,
+		Reason: fmt.Sprintf("reason-%d", i),
+	}
+}
+
+// delivery is one call of a handler
+type delivery struct {
+	envelope  *event.Envelope
+	payload   model.UserChangedEvent
+	requestID string
+}
+
+// recorder records the deliveries to the handler of one group
+type recorder struct {
+	mu         sync.Mutex
+	deliveries []delivery
+	signal     chan struct{}
+}
+
+func newRecorder() *recorder {
+	return &recorder{signal: make(chan struct{}, 1)}
+}
+
+// registry handles user.updated events by recording them, then returning
+// the result of fn when it is not nil
+func (r *recorder) registry(fn func(model.UserChangedEvent) error) *consumer.Registry {
+	registry := consumer.NewRegistry()
+	registry.Handle(model.UserEventUpdated, func(ctx context.Context, e *event.Envelope) error {
+		payload, err := event.Decode[model.UserChangedEvent](event.Types, e)
+		if err != nil {
+			return consumer.Permanent(err)
+		}
+
+		r.mu.Lock()
+		r.deliveries = append(r.deliveries, delivery{envelope: e, payload: payload, requestID: requestid.FromContext(ctx)})
+		r.mu.Unlock()
+		select {
+		case r.signal <- struct{}{}:
+		default:
+		}
+
+		if fn != nil {
+			return fn(payload)
+		}
+		return nil
+	})
+	return registry
+}
+
+// wait returns the first n deliveries once they arrived
+func (r *recorder) wait(t *testing.T, n int) []delivery {
+	t.Helper()
+	deadline := time.After(deliveryTimeout)
+	for {
+		r.mu.Lock()
+		if len(r.deliveries) >= n {
+			got := append([]delivery(nil), r.deliveries[:n]...)
+			r.mu.Unlock()
+			return got
+		}
+		r.mu.Unlock()
+
+		select {
+		case <-r.signal:
+		case <-deadline:
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			require.FailNow(t, "timed out waiting for events", "got %d of %d", len(r.deliveries), n)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_event"
+)
+
+// KafkaBus publishes events to a Kafka topic through a ProducerRepository
+// and delivers them with a consumer group per subscription, retry and
+// dead-letter topics included when the consumer configuration has a
+// Forwarder.
+type KafkaBus struct {
+	*repository_event.ProducerRepository
+
+	brokers       []string
+	topic         string
+	initialOffset string
+	config        consumer.Config
+
+	mu        sync.Mutex
+	consumers []*consumer.Consumer
+	closed    bool
+}
+
+// NewKafkaBus creates a bus on topic. config applies to every subscription,
+// its GroupID and Topics are set by Subscribe. initialOffset is where a new
+// group starts, see consumer.SaramaConfig.
+func NewKafkaBus(brokers []string, producer *repository_event.ProducerRepository, topic, initialOffset string, config consumer.Config) *KafkaBus {
+	return &KafkaBus{
+		ProducerRepository: producer,
+		brokers:            brokers,
+		topic:              topic,
+		initialOffset:      initialOffset,
+		config:             config,
+	}
+}
+
+// Subscribe joins group, creating its retry and dead-letter topics first
+func (b *KafkaBus) Subscribe(group string, registry *consumer.Registry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+
+	config := b.config
+	config.GroupID = group
+	config.Topics = []string{b.topic}
+	if topics := config.FailureTopics(); len(topics) > 0 {
+		admin, err := sarama.NewClusterAdmin(b.brokers, sarama.NewConfig())
+		if err != nil {
+			return fmt.Errorf("create cluster admin: %w", err)
+		}
+		err = repository_event.CreateTopics(admin, b.topic, topics)
+		admin.Close()
+		if err != nil {
+			return fmt.Errorf("create retry topics: %w", err)
+		}
+	}
+
+	consumerGroup, err := sarama.NewConsumerGroup(b.brokers, group, consumer.SaramaConfig(b.initialOffset))
+	if err != nil {
+		return fmt.Errorf("create consumer group: %w", err)
+	}
+	c := consumer.New(consumerGroup, registry, config)
+	c.Start()
+	b.consumers = append(b.consumers, c)
+
+	slog.Info("kafka consumer started",
+		"group", group,
+		"topic", b.topic,
+		"event_types", registry.Types(),
+		"failure_topics", config.FailureTopics(),
+	)
+	return nil
+}
+
+// Close stops every consumer. The producer belongs to the caller and stays
+// open.
+func (b *KafkaBus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	b.closed = true
+	consumers := b.consumers
+	b.consumers = nil
+	b.mu.Unlock()
+
+	var firstErr error
+	for _, c := range consumers {
+		if err := c.Stop(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,135 @@
+package eventbus
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/tracing"
+)
+
+// memoryQueueSize bounds the events waiting for one group. A publish waits
+// for room while the group is behind.
+const memoryQueueSize = 1024
+
+// MemoryBus delivers events within the process, for development without a
+// broker and for tests. Events are lost when the process stops, and only the
+// subscriptions of the same process see them.
+type MemoryBus struct {
+	retry Retry
+
+	mu     sync.RWMutex
+	groups map[string]*memoryGroup
+	closed bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// memoryGroup is one subscribed group. Members of a group share its queue.
+type memoryGroup struct {
+	queue chan memoryEvent
+}
+
+type memoryEvent struct {
+	envelope     *event.Envelope
+	traceContext map[string]string
+}
+
+func NewMemoryBus(retry Retry) *MemoryBus {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MemoryBus{
+		retry:  retry,
+		groups: make(map[string]*memoryGroup),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// SendMessage queues the event for every subscribed group. Without a
+// subscriber the event is dropped.
+func (b *MemoryBus) SendMessage(ctx context.Context, key string, value interface{}) error {
+	const op = "MemoryBus.SendMessage"
+	envelope, err := event.Types.Wrap(ctx, key, value)
+	if err != nil {
+		return err
+	}
+	traceContext := tracing.Inject(ctx)
+	if err := ctx.Err(); err != nil {
+		return contextError(op, err)
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return errors.NewUnavailable(op, ErrClosed)
+	}
+	for _, g := range b.groups {
+		// Each group gets its own copy, a handler cannot change the event
+		// another group sees
+		copied := *envelope
+		select {
+		case g.queue <- memoryEvent{envelope: &copied, traceContext: traceContext}:
+		case <-ctx.Done():
+			return contextError(op, ctx.Err())
+		case <-b.ctx.Done():
+			return errors.NewUnavailable(op, ErrClosed)
+		}
+	}
+	return nil
+}
+
+// Subscribe starts a member of group. The first member creates the group's
+// queue, so events published before it are not delivered.
+func (b *MemoryBus) Subscribe(group string, registry *consumer.Registry) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return ErrClosed
+	}
+
+	g, ok := b.groups[group]
+	if !ok {
+		g = &memoryGroup{queue: make(chan memoryEvent, memoryQueueSize)}
+		b.groups[group] = g
+	}
+	d := newDeliverer(group, Memory, registry, b.retry)
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			select {
+			case <-b.ctx.Done():
+				return
+			case e := <-g.queue:
+				d.deliver(b.ctx, e.envelope, e.traceContext)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close stops delivering. Events still queued are dropped.
+func (b *MemoryBus) Close(ctx context.Context) error {
+	// Cancel first, a publish waiting for room holds the read lock
+	b.cancel()
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
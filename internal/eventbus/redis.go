@@ -0,0 +1,289 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/tracing"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultClaimIdle is how long an entry stays pending with a member
+	// before another member of its group takes it over
+	DefaultClaimIdle = time.Minute
+
+	// redisBlock bounds the wait of one read for new entries, and so how long
+	// Close waits for an idle subscription
+	redisBlock = time.Second
+	// redisBatchSize caps the entries read at once
+	redisBatchSize = 16
+	// redisAckTimeout bounds acknowledging an entry after its handler ran
+	redisAckTimeout = 5 * time.Second
+	// redisRetryDelay paces reads after Redis failed
+	redisRetryDelay = time.Second
+)
+
+// Fields of a stream entry
+const (
+	keyField   = "key"
+	eventField = "event" // the envelope as JSON
+	traceField = "trace" // the trace context as JSON
+)
+
+// RedisBus publishes events to a Redis stream and delivers them through
+// consumer groups of the stream.
+//
+// Entries are acknowledged once their handler settled them. An entry left
+// pending, because its member stopped in between, is taken over by a member
+// of the same group once it was idle for claimIdle, so delivery is at least
+// once. The stream is trimmed to about maxLen entries.
+type RedisBus struct {
+	client    *redis.Client
+	stream    string
+	maxLen    int64
+	claimIdle time.Duration
+	retry     Retry
+	member    string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRedisBus(client *redis.Client, stream string, maxLen int64, claimIdle time.Duration, retry Retry) *RedisBus {
+	if claimIdle <= 0 {
+		claimIdle = DefaultClaimIdle
+	}
+	host, _ := os.Hostname()
+	ctx, cancel := context.WithCancel(context.Background())
+	return &RedisBus{
+		client:    client,
+		stream:    stream,
+		maxLen:    maxLen,
+		claimIdle: claimIdle,
+		retry:     retry,
+		member:    fmt.Sprintf("%s-%s", host, uuid.NewString()),
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+// SendMessage appends the event to the stream
+func (b *RedisBus) SendMessage(ctx context.Context, key string, value interface{}) error {
+	const op = "RedisBus.SendMessage"
+	envelope, err := event.Types.Wrap(ctx, key, value)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return contextError(op, err)
+	}
+	if b.ctx.Err() != nil {
+		return errors.NewUnavailable(op, ErrClosed)
+	}
+
+	values := map[string]interface{}{
+		keyField:   key,
+		eventField: data,
+	}
+	if traceContext := tracing.Inject(ctx); traceContext != nil {
+		encoded, err := json.Marshal(traceContext)
+		if err != nil {
+			return err
+		}
+		values[traceField] = encoded
+	}
+
+	err = b.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: b.stream,
+		MaxLen: b.maxLen,
+		Approx: b.maxLen > 0,
+		Values: values,
+	}).Err()
+	return repository.FromRedis(op, err)
+}
+
+// Subscribe creates group on the stream, unless it exists, and starts
+// reading it. A new group starts at the end of the stream.
+func (b *RedisBus) Subscribe(group string, registry *consumer.Registry) error {
+	const op = "RedisBus.Subscribe"
+	if b.ctx.Err() != nil {
+		return ErrClosed
+	}
+
+	err := b.client.XGroupCreateMkStream(b.ctx, b.stream, group, "$").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return repository.FromRedis(op, err)
+	}
+
+	d := newDeliverer(group, b.stream, registry, b.retry)
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.read(group, d)
+	}()
+	return nil
+}
+
+// read delivers new entries, and entries abandoned by other members, until
+// the bus is closed
+func (b *RedisBus) read(group string, d *deliverer) {
+	logger := slog.Default().With("group", group, "stream", b.stream, "member", b.member)
+	var lastClaim time.Time
+
+	for b.ctx.Err() == nil {
+		if time.Since(lastClaim) >= b.claimIdle/2 {
+			lastClaim = time.Now()
+			if !b.claim(group, d, logger) {
+				return
+			}
+		}
+
+		streams, err := b.client.XReadGroup(b.ctx, &redis.XReadGroupArgs{
+			Group:    group,
+			Consumer: b.member,
+			Streams:  []string{b.stream, ">"},
+			Count:    redisBatchSize,
+			Block:    redisBlock,
+		}).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			if b.ctx.Err() != nil {
+				return
+			}
+			logger.Error("failed to read event stream", "error", err)
+			b.pause()
+			continue
+		}
+
+		for _, stream := range streams {
+			for _, msg := range stream.Messages {
+				if !b.process(group, d, msg, logger) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// claim takes over the entries of group that were pending with any member
+// for longer than claimIdle and processes them. It returns false when the
+// bus was closed meanwhile.
+func (b *RedisBus) claim(group string, d *deliverer, logger *slog.Logger) bool {
+	start := "0-0"
+	for {
+		messages, next, err := b.client.XAutoClaim(b.ctx, &redis.XAutoClaimArgs{
+			Stream:   b.stream,
+			Group:    group,
+			Consumer: b.member,
+			MinIdle:  b.claimIdle,
+			Start:    start,
+			Count:    redisBatchSize,
+		}).Result()
+		if err != nil {
+			if b.ctx.Err() != nil {
+				return false
+			}
+			logger.Error("failed to claim pending events", "error", err)
+			return true
+		}
+
+		for _, msg := range messages {
+			if !b.process(group, d, msg, logger) {
+				return false
+			}
+		}
+		if next == "0-0" || next == "" {
+			return true
+		}
+		start = next
+	}
+}
+
+// process delivers the event of msg and acknowledges it once settled. It
+// returns false when the bus was closed before, the entry then stays pending.
+func (b *RedisBus) process(group string, d *deliverer, msg redis.XMessage, logger *slog.Logger) bool {
+	envelope, traceContext, err := decodeEntry(msg)
+	if err != nil {
+		// Delivering it again cannot help, it stays in the stream until
+		// trimmed and is counted so it does not go unnoticed
+		logger.Error("entry is not an event, skipping it", "entry_id", msg.ID, "error", err)
+		d.metrics.MessagesTotal.WithLabelValues(group, b.stream, "", "invalid").Inc()
+		d.metrics.DeadLettersTotal.WithLabelValues(group, b.stream, "invalid").Inc()
+	} else if !d.deliver(b.ctx, envelope, traceContext) {
+		return false
+	}
+
+	// A failed ack delivers the entry again once it is claimed
+	ctx, cancel := context.WithTimeout(context.Background(), redisAckTimeout)
+	defer cancel()
+	if err := b.client.XAck(ctx, b.stream, group, msg.ID).Err(); err != nil {
+		logger.Error("failed to acknowledge event", "entry_id", msg.ID, "error", err)
+	}
+	return true
+}
+
+func (b *RedisBus) pause() {
+	select {
+	case <-b.ctx.Done():
+	case <-time.After(redisRetryDelay):
+	}
+}
+
+// decodeEntry reads the envelope and trace context of a stream entry
+func decodeEntry(msg redis.XMessage) (*event.Envelope, map[string]string, error) {
+	data, ok := msg.Values[eventField].(string)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: no %s field", event.ErrNotEvent, eventField)
+	}
+	envelope := &event.Envelope{}
+	if err := json.Unmarshal([]byte(data), envelope); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", event.ErrNotEvent, err)
+	}
+	if err := envelope.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	var traceContext map[string]string
+	if encoded, ok := msg.Values[traceField].(string); ok {
+		// A context that cannot be read only costs the link to the publisher
+		_ = json.Unmarshal([]byte(encoded), &traceContext)
+	}
+	return envelope, traceContext, nil
+}
+
+// Close stops reading. Handlers in flight finish, or leave their entry
+// pending for another member, before it returns.
+func (b *RedisBus) Close(ctx context.Context) error {
+	b.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	repository_user "github.com/Napat/golang-testcontainers-demo/internal/repository/repository_user"
+	"github.com/Napat/golang-testcontainers-demo/pkg/auth"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/password"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
+	"github.com/google/uuid"
+)
+
+type CredentialRepository interface {
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
+	UpdatePassword(ctx context.Context, id uuid.UUID, hash string) error
+}
+
+type TokenService interface {
+	Issue(userID uuid.UUID, username string) (*auth.TokenPair, error)
+	Redeem(ctx context.Context, refreshToken string) (*auth.Identity, error)
+	Revoke(ctx context.Context, token string) error
+}
+
+type AuthHandler struct {
+	users  CredentialRepository
+	tokens TokenService
+	routes []routes.Route
+
+	// dummyHash is verified when the username does not exist so that the
+	// response time does not reveal which usernames are registered
+	dummyHash     string
+	dummyHashOnce sync.Once
+}
+
+func NewAuthHandler(users CredentialRepository, tokens TokenService) *AuthHandler {
+	h := &AuthHandler{
+		users:  users,
+		tokens: tokens,
+	}
+
+	h.routes = []routes.Route{
+		{
+			Name:    "auth.login",
+			Method:  http.MethodPost,
+			Pattern: "/auth/login",
+			Handler: h.login,
+			Public:  true,
+		},
+		{
+			Name:    "auth.refresh",
+			Method:  http.MethodPost,
+			Pattern: "/auth/refresh",
+			Handler: h.refresh,
+			Public:  true,
+		},
+		{
+			Name:    "auth.revoke",
+			Method:  http.MethodPost,
+			Pattern: "/auth/revoke",
+			Handler: h.revoke,
+			Public:  true,
+		},
+	}
+
+	return h
+}
+
+// GetRoutes returns all routes for this handler
+func (h *AuthHandler) GetRoutes() []routes.Route {
+	return h.routes
+}
+
+// @Summary Log in
+// @Description Verify username and password and issue an access and refresh token pair
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body model.LoginRequest true "Login credentials"
+// @Success 200 {object} auth.TokenPair
+// @Failure 400 {object} response.Problem
+// @Failure 401 {object} response.Problem
+// @Failure 403 {object} response.Problem
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Router /api/v1/auth/login [post]
+func (h *AuthHandler) login(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.LoginRequest
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	user, err := h.users.GetByUsername(ctx, req.Username)
+	if errors.Is(err, repository_user.ErrUserNotFound) {
+		h.verifyDummy(req.Password)
+		response.RespondWithError(w, r, http.StatusUnauthorized, auth.ErrInvalidCredentials.Error())
+		return
+	}
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	ok, needsRehash := user.VerifyPassword(req.Password)
+	if !ok {
+		response.RespondWithError(w, r, http.StatusUnauthorized, auth.ErrInvalidCredentials.Error())
+		return
+	}
+
+	if !user.IsActive() {
+		response.RespondWithError(w, r, http.StatusForbidden, "User account is not active")
+		return
+	}
+
+	if needsRehash {
+		h.rehash(ctx, user, req.Password)
+	}
+
+	tokens, err := h.tokens.Issue(user.ID, user.Username)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, tokens)
+}
+
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new token pair. The refresh token can only be used once, and only while the account is still active.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body model.TokenRequest true "Refresh token"
+// @Success 200 {object} auth.TokenPair
+// @Failure 400 {object} response.Problem
+// @Failure 401 {object} response.Problem
+// @Failure 403 {object} response.Problem
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Router /api/v1/auth/refresh [post]
+func (h *AuthHandler) refresh(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var req model.TokenRequest
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	identity, err := h.tokens.Redeem(ctx, req.Token)
+	if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, auth.ErrTokenRevoked) {
+		response.RespondWithError(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	// The account may have changed since login, a deleted user is gone and
+	// an inactive one is refused as on login
+	user, err := h.users.GetByID(ctx, identity.UserID)
+	if errors.Is(err, repository_user.ErrUserNotFound) {
+		response.RespondWithError(w, r, http.StatusUnauthorized, auth.ErrInvalidToken.Error())
+		return
+	}
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	if !user.IsActive() {
+		response.RespondWithError(w, r, http.StatusForbidden, "User account is not active")
+		return
+	}
+
+	tokens, err := h.tokens.Issue(user.ID, user.Username)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, tokens)
+}
+
+// @Summary Revoke a token
+// @Description Revoke an access or refresh token until it expires
+// @Tags auth
+// @Accept json
+// @Param token body model.TokenRequest true "Token to revoke"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Problem
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Router /api/v1/auth/revoke [post]
+func (h *AuthHandler) revoke(w http.ResponseWriter, r *http.Request) {
+	var req model.TokenRequest
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.tokens.Revoke(r.Context(), req.Token); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// rehash upgrades a password hash produced with outdated parameters. Failures
+// are logged only, the user has already been authenticated.
+func (h *AuthHandler) rehash(ctx context.Context, user *model.User, plain string) {
+	hash, err := password.Default().Hash(plain)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to rehash password", "user_id", user.ID, "error", err)
+		return
+	}
+	if err := h.users.UpdatePassword(ctx, user.ID, hash); err != nil {
+		logging.FromContext(ctx).Error("failed to store rehashed password", "user_id", user.ID, "error", err)
+	}
+}
+
+func (h *AuthHandler) verifyDummy(plain string) {
+	h.dummyHashOnce.Do(func() {
+		h.dummyHash, _ = password.Default().Hash("dummy-password-for-timing")
+	})
+	password.Default().Verify(plain, h.dummyHash)
+}
@@ -0,0 +1,240 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/handler"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_user"
+	"github.com/Napat/golang-testcontainers-demo/pkg/auth"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/password"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockCredentialRepo struct {
+	mock.Mock
+}
+
+func (m *MockCredentialRepo) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	args := m.Called(ctx, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockCredentialRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.User), args.Error(1)
+}
+
+func (m *MockCredentialRepo) UpdatePassword(ctx context.Context, id uuid.UUID, hash string) error {
+	args := m.Called(ctx, id, hash)
+	return args.Error(0)
+}
+
+type MockTokenService struct {
+	mock.Mock
+}
+
+func (m *MockTokenService) Issue(userID uuid.UUID, username string) (*auth.TokenPair, error) {
+	args := m.Called(userID, username)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.TokenPair), args.Error(1)
+}
+
+func (m *MockTokenService) Redeem(ctx context.Context, refreshToken string) (*auth.Identity, error) {
+	args := m.Called(ctx, refreshToken)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*auth.Identity), args.Error(1)
+}
+
+func (m *MockTokenService) Revoke(ctx context.Context, token string) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func TestAuthHandler_Login(t *testing.T) {
+	// Cheap hashing keeps the test fast; the legacy bcrypt hasher exercises rehash-on-login
+	fast := password.NewArgon2id(password.Argon2Params{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32})
+	legacy := password.NewBcrypt(4)
+	password.SetDefault(password.NewManager(fast, legacy))
+
+	currentHash, err := fast.Hash("password123")
+	require.NoError(t, err)
+	legacyHash, err := legacy.Hash("password123")
+	require.NoError(t, err)
+
+	newUser := func(hash string, status model.UserStatus) *model.User {
+		return &model.User{ID: uuid.Must(uuid.NewV7()), Username: "testuser", Password: hash, Status: status}
+	}
+
+	tests := []struct {
+		name           string
+		request        model.LoginRequest
+		user           *model.User
+		repoError      error
+		expectRehash   bool
+		expectedStatus int
+	}{
+		{
+			name:           "success",
+			request:        model.LoginRequest{Username: "testuser", Password: "password123"},
+			user:           newUser(currentHash, model.StatusActive),
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "legacy hash is upgraded",
+			request:        model.LoginRequest{Username: "testuser", Password: "password123"},
+			user:           newUser(legacyHash, model.StatusActive),
+			expectRehash:   true,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "wrong password",
+			request:        model.LoginRequest{Username: "testuser", Password: "wrong-password"},
+			user:           newUser(currentHash, model.StatusActive),
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "unknown user",
+			request:        model.LoginRequest{Username: "nobody", Password: "password123"},
+			repoError:      repository_user.ErrUserNotFound,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "inactive user",
+			request:        model.LoginRequest{Username: "testuser", Password: "password123"},
+			user:           newUser(currentHash, model.StatusSuspended),
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "missing password",
+			request:        model.LoginRequest{Username: "testuser"},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockCredentialRepo)
+			mockTokens := new(MockTokenService)
+
+			if tt.user != nil || tt.repoError != nil {
+				mockRepo.On("GetByUsername", mock.Anything, tt.request.Username).Return(tt.user, tt.repoError)
+			}
+			if tt.expectRehash {
+				mockRepo.On("UpdatePassword", mock.Anything, tt.user.ID, mock.MatchedBy(fast.Matches)).Return(nil)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				mockTokens.On("Issue", tt.user.ID, tt.user.Username).Return(&auth.TokenPair{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer"}, nil)
+			}
+
+			h := handler.NewAuthHandler(mockRepo, mockTokens)
+
+			var login http.HandlerFunc
+			for _, route := range h.GetRoutes() {
+				if route.Pattern == "/auth/login" {
+					login = route.Handler
+					assert.True(t, route.Public)
+				}
+			}
+			require.NotNil(t, login)
+
+			body, _ := json.Marshal(tt.request)
+			req := httptest.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(body))
+			rec := httptest.NewRecorder()
+
+			login(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			mockRepo.AssertExpectations(t)
+			mockTokens.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthHandler_Refresh(t *testing.T) {
+	userID := uuid.Must(uuid.NewV7())
+	identity := &auth.Identity{UserID: userID, Username: "testuser"}
+
+	tests := []struct {
+		name           string
+		redeemError    error
+		user           *model.User
+		repoError      error
+		expectedStatus int
+	}{
+		{
+			name:           "success",
+			user:           &model.User{ID: userID, Username: "renamed", Status: model.StatusActive},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "replayed token",
+			redeemError:    auth.ErrTokenRevoked,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "deleted user",
+			repoError:      repository_user.ErrUserNotFound,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "inactive user",
+			user:           &model.User{ID: userID, Username: "testuser", Status: model.StatusInactive},
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockCredentialRepo)
+			mockTokens := new(MockTokenService)
+
+			if tt.redeemError != nil {
+				mockTokens.On("Redeem", mock.Anything, "refresh-token").Return(nil, tt.redeemError)
+			} else {
+				mockTokens.On("Redeem", mock.Anything, "refresh-token").Return(identity, nil)
+				mockRepo.On("GetByID", mock.Anything, userID).Return(tt.user, tt.repoError)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				// Issued for the user as stored now, not as in the token
+				mockTokens.On("Issue", userID, "renamed").Return(&auth.TokenPair{AccessToken: "access", RefreshToken: "refresh", TokenType: "Bearer"}, nil)
+			}
+
+			h := handler.NewAuthHandler(mockRepo, mockTokens)
+
+			var refresh http.HandlerFunc
+			for _, route := range h.GetRoutes() {
+				if route.Pattern == "/auth/refresh" {
+					refresh = route.Handler
+				}
+			}
+			require.NotNil(t, refresh)
+
+			body, _ := json.Marshal(model.TokenRequest{Token: "refresh-token"})
+			rec := httptest.NewRecorder()
+			refresh(rec, httptest.NewRequest(http.MethodPost, "/auth/refresh", bytes.NewBuffer(body)))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			mockRepo.AssertExpectations(t)
+			mockTokens.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+)
+
+type DeadLetterRepository interface {
+	List(ctx context.Context, group string, limit int) ([]*model.DeadLetter, error)
+	Get(ctx context.Context, group, id string) (*model.DeadLetter, error)
+	Replay(ctx context.Context, group, id string) error
+	Discard(ctx context.Context, group, id string) error
+}
+
+// ConsumerGroups names the consumer groups whose dead letters can be
+// managed: the listed groups, and the groups named after one of the
+// prefixes, such as the event stream group of every instance
+type ConsumerGroups struct {
+	Groups   []string
+	Prefixes []string
+}
+
+// Has reports whether group is one of the consumer groups
+func (g ConsumerGroups) Has(group string) bool {
+	if group == "" {
+		return false
+	}
+	if slices.Contains(g.Groups, group) {
+		return true
+	}
+	for _, prefix := range g.Prefixes {
+		if prefix != "" && strings.HasPrefix(group, prefix) && len(group) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type DeadLetterHandler struct {
+	repo   DeadLetterRepository
+	groups ConsumerGroups
+	routes []routes.Route
+	mux    http.Handler
+}
+
+// NewDeadLetterHandler manages the dead letters of groups. Other group names
+// answer 404, so that no topic is read, or created, for them.
+func NewDeadLetterHandler(repo DeadLetterRepository, groups ConsumerGroups) *DeadLetterHandler {
+	h := &DeadLetterHandler{
+		repo:   repo,
+		groups: groups,
+	}
+
+	h.routes = []routes.Route{
+		{
+			Name:        "admin.dead_letters.list",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/dead-letters/{group}",
+			Handler:     h.listDeadLetters,
+			Permissions: []string{authz.EventsAdmin},
+		},
+		{
+			Name:        "admin.dead_letters.get",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/dead-letters/{group}/{id}",
+			Handler:     h.getDeadLetter,
+			Permissions: []string{authz.EventsAdmin},
+		},
+		{
+			Name:        "admin.dead_letters.replay",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/dead-letters/{group}/{id}/replay",
+			Handler:     h.replayDeadLetter,
+			Permissions: []string{authz.EventsAdmin},
+		},
+		{
+			Name:        "admin.dead_letters.discard",
+			Method:      http.MethodDelete,
+			Pattern:     "/admin/dead-letters/{group}/{id}",
+			Handler:     h.discardDeadLetter,
+			Permissions: []string{authz.EventsAdmin},
+		},
+	}
+	h.mux = routes.NewHandler(h.routes)
+
+	return h
+}
+
+// GetRoutes implements routes.Handler interface
+func (h *DeadLetterHandler) GetRoutes() []routes.Route {
+	return h.routes
+}
+
+// @Summary List dead letters
+// @Description List events that failed processing on every retry tier and were not replayed or discarded yet
+// @Tags admin
+// @Produce json
+// @Param group path string true "Consumer group" example(golang-testcontainers-demo-webhooks)
+// @Param limit query int false "Maximum number of messages (1-100)" default(20)
+// @Success 200 {array} model.DeadLetter
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 404 {object} response.Problem "Unknown consumer group"
+// @Failure 503 {object} response.Problem "Kafka unavailable"
+// @Security BearerAuth
+// @Router /api/v1/admin/dead-letters/{group} [get]
+func (h *DeadLetterHandler) listDeadLetters(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.consumerGroup(w, r)
+	if !ok {
+		return
+	}
+
+	limit := pagination.DefaultLimit
+	requested, err := queryInt(r, "limit")
+	if err != nil || (requested != nil && (*requested < 1 || *requested > pagination.MaxLimit)) {
+		response.RespondWithError(w, r, http.StatusBadRequest, pagination.ErrInvalidLimit.Error())
+		return
+	}
+	if requested != nil {
+		limit = *requested
+	}
+
+	letters, err := h.repo.List(r.Context(), group, limit)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.RespondWithJSON(w, http.StatusOK, letters)
+}
+
+// @Summary Inspect a dead letter
+// @Description Get a dead-lettered event with its headers and value
+// @Tags admin
+// @Produce json
+// @Param group path string true "Consumer group"
+// @Param id path string true "Dead letter ID, <partition>-<offset>"
+// @Success 200 {object} model.DeadLetter
+// @Failure 404 {object} response.Problem "Unknown consumer group, or unknown, replayed or discarded dead letter"
+// @Failure 503 {object} response.Problem "Kafka unavailable"
+// @Security BearerAuth
+// @Router /api/v1/admin/dead-letters/{group}/{id} [get]
+func (h *DeadLetterHandler) getDeadLetter(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.consumerGroup(w, r)
+	if !ok {
+		return
+	}
+
+	letter, err := h.repo.Get(r.Context(), group, r.PathValue("id"))
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.RespondWithJSON(w, http.StatusOK, letter)
+}
+
+// @Summary Replay a dead letter
+// @Description Publish a dead-lettered event to its original topic, where it is processed again from the first attempt
+// @Tags admin
+// @Param group path string true "Consumer group"
+// @Param id path string true "Dead letter ID, <partition>-<offset>"
+// @Success 204 "No Content"
+// @Failure 404 {object} response.Problem "Unknown consumer group, or unknown, replayed or discarded dead letter"
+// @Failure 503 {object} response.Problem "Kafka unavailable"
+// @Security BearerAuth
+// @Router /api/v1/admin/dead-letters/{group}/{id}/replay [post]
+func (h *DeadLetterHandler) replayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.consumerGroup(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Replay(r.Context(), group, r.PathValue("id")); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Discard a dead letter
+// @Description Drop a dead-lettered event without processing it
+// @Tags admin
+// @Param group path string true "Consumer group"
+// @Param id path string true "Dead letter ID, <partition>-<offset>"
+// @Success 204 "No Content"
+// @Failure 404 {object} response.Problem "Unknown consumer group, or unknown, replayed or discarded dead letter"
+// @Failure 503 {object} response.Problem "Kafka unavailable"
+// @Security BearerAuth
+// @Router /api/v1/admin/dead-letters/{group}/{id} [delete]
+func (h *DeadLetterHandler) discardDeadLetter(w http.ResponseWriter, r *http.Request) {
+	group, ok := h.consumerGroup(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Discard(r.Context(), group, r.PathValue("id")); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// consumerGroup returns the consumer group of the request, answering 404
+// when it is not one of the groups
+func (h *DeadLetterHandler) consumerGroup(w http.ResponseWriter, r *http.Request) (string, bool) {
+	group := r.PathValue("group")
+	if !h.groups.Has(group) {
+		response.WriteError(w, r, errors.NewNotFound("consumerGroup", "consumer group", group))
+		return "", false
+	}
+	return group, true
+}
+
+// ServeHTTP implements http.Handler interface
+func (h *DeadLetterHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
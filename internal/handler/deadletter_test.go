@@ -0,0 +1,204 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/handler"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockDeadLetterRepo struct {
+	mock.Mock
+}
+
+func (m *MockDeadLetterRepo) List(ctx context.Context, group string, limit int) ([]*model.DeadLetter, error) {
+	args := m.Called(ctx, group, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*model.DeadLetter), args.Error(1)
+}
+
+func (m *MockDeadLetterRepo) Get(ctx context.Context, group, id string) (*model.DeadLetter, error) {
+	args := m.Called(ctx, group, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.DeadLetter), args.Error(1)
+}
+
+func (m *MockDeadLetterRepo) Replay(ctx context.Context, group, id string) error {
+	return m.Called(ctx, group, id).Error(0)
+}
+
+func (m *MockDeadLetterRepo) Discard(ctx context.Context, group, id string) error {
+	return m.Called(ctx, group, id).Error(0)
+}
+
+var deadLetterGroups = handler.ConsumerGroups{
+	Groups:   []string{"cache", "webhooks"},
+	Prefixes: []string{"stream-"},
+}
+
+func TestDeadLetterHandler(t *testing.T) {
+	letter := &model.DeadLetter{ID: "0-42", EventType: model.UserEventUpdated, OriginalTopic: "events", Attempts: 7, Reason: "timeout"}
+	notFound := errors.NewNotFound("DeadLetterRepository.Get", "dead letter", "0-1")
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		setup          func(*MockDeadLetterRepo)
+		expectedStatus int
+	}{
+		{
+			name:   "list with default limit",
+			method: http.MethodGet,
+			path:   "/admin/dead-letters/cache",
+			setup: func(m *MockDeadLetterRepo) {
+				m.On("List", mock.Anything, "cache", 20).Return([]*model.DeadLetter{letter}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "list of the webhook group",
+			method: http.MethodGet,
+			path:   "/admin/dead-letters/webhooks",
+			setup: func(m *MockDeadLetterRepo) {
+				m.On("List", mock.Anything, "webhooks", 20).Return([]*model.DeadLetter{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "list of the stream group of an instance",
+			method: http.MethodGet,
+			path:   "/admin/dead-letters/stream-api-1",
+			setup: func(m *MockDeadLetterRepo) {
+				m.On("List", mock.Anything, "stream-api-1", 20).Return([]*model.DeadLetter{}, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "list of an unknown group",
+			method:         http.MethodGet,
+			path:           "/admin/dead-letters/billing",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "list of the bare stream prefix",
+			method:         http.MethodGet,
+			path:           "/admin/dead-letters/stream-",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "list with invalid limit",
+			method:         http.MethodGet,
+			path:           "/admin/dead-letters/cache?limit=500",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:   "inspect",
+			method: http.MethodGet,
+			path:   "/admin/dead-letters/cache/0-42",
+			setup: func(m *MockDeadLetterRepo) {
+				m.On("Get", mock.Anything, "cache", "0-42").Return(letter, nil)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:   "inspect unknown",
+			method: http.MethodGet,
+			path:   "/admin/dead-letters/cache/0-1",
+			setup: func(m *MockDeadLetterRepo) {
+				m.On("Get", mock.Anything, "cache", "0-1").Return(nil, notFound)
+			},
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "inspect in an unknown group",
+			method:         http.MethodGet,
+			path:           "/admin/dead-letters/billing/0-42",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "replay",
+			method: http.MethodPost,
+			path:   "/admin/dead-letters/webhooks/0-42/replay",
+			setup: func(m *MockDeadLetterRepo) {
+				m.On("Replay", mock.Anything, "webhooks", "0-42").Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "replay in an unknown group",
+			method:         http.MethodPost,
+			path:           "/admin/dead-letters/billing/0-42/replay",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:   "replay while kafka is down",
+			method: http.MethodPost,
+			path:   "/admin/dead-letters/cache/0-42/replay",
+			setup: func(m *MockDeadLetterRepo) {
+				m.On("Replay", mock.Anything, "cache", "0-42").Return(errors.NewUnavailable("DeadLetterRepository.Replay", assert.AnError))
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:   "discard",
+			method: http.MethodDelete,
+			path:   "/admin/dead-letters/cache/0-42",
+			setup: func(m *MockDeadLetterRepo) {
+				m.On("Discard", mock.Anything, "cache", "0-42").Return(nil)
+			},
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "discard in an unknown group",
+			method:         http.MethodDelete,
+			path:           "/admin/dead-letters/billing/0-42",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockDeadLetterRepo)
+			if tt.setup != nil {
+				tt.setup(repo)
+			}
+			h := handler.NewDeadLetterHandler(repo, deadLetterGroups)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			repo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("list body", func(t *testing.T) {
+		repo := new(MockDeadLetterRepo)
+		repo.On("List", mock.Anything, "cache", 5).Return([]*model.DeadLetter{letter}, nil)
+		h := handler.NewDeadLetterHandler(repo, deadLetterGroups)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/dead-letters/cache?limit=5", nil))
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var got []model.DeadLetter
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+		require.Len(t, got, 1)
+		assert.Equal(t, "0-42", got[0].ID)
+		assert.Equal(t, 7, got[0].Attempts)
+	})
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/projection"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+)
+
+// parseFields reads the ?fields= sparse fieldset for responses shaped like
+// dto. It writes 400 on unknown fields and reports whether the request may
+// proceed.
+func parseFields(w http.ResponseWriter, r *http.Request, dto interface{}) ([]string, bool) {
+	fields, err := projection.Fields(r.URL.Query(), dto)
+	if err != nil {
+		response.RespondWithError(w, r, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+	return fields, true
+}
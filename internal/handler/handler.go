@@ -15,12 +15,15 @@ func New(
 	orderRepo OrderRepository,
 	producer MessageProducer,
 	cache CacheRepository,
+	sender MessageSender,
+	messageRepo MessageRepository,
+	authorizer Authorizer,
 ) *Handler {
 	return &Handler{
 		userHandler:    NewUserHandler(userRepo, cache, producer),
-		productHandler: NewProductHandler(productRepo),
-		orderHandler:   NewOrderHandler(orderRepo),
-		messageHandler: NewMessageHandler(producer),
+		productHandler: NewProductHandler(productRepo, producer),
+		orderHandler:   NewOrderHandler(orderRepo, producer),
+		messageHandler: NewMessageHandler(sender, messageRepo, authorizer),
 	}
 }
 
@@ -9,6 +9,7 @@ import (
 
 	"github.com/Napat/golang-testcontainers-demo/internal/handler/health"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/mock"
 )
@@ -30,6 +31,14 @@ func (m *MockOrderRepo) SearchOrders(ctx context.Context, params map[string]inte
 	return args.Get(0).([]model.Order), args.Error(1)
 }
 
+func (m *MockOrderRepo) ListOrders(ctx context.Context, page pagination.Request, filter model.OrderFilter) (*pagination.Page[model.Order], error) {
+	args := m.Called(ctx, page, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pagination.Page[model.Order]), args.Error(1)
+}
+
 type MockProductRepo struct {
 	mock.Mock
 }
@@ -47,9 +56,12 @@ func (m *MockProductRepo) GetByID(ctx context.Context, id int64) (*model.Product
 	return args.Get(0).(*model.Product), args.Error(1)
 }
 
-func (m *MockProductRepo) GetAll(ctx context.Context) ([]*model.Product, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*model.Product), args.Error(1)
+func (m *MockProductRepo) List(ctx context.Context, page pagination.Request, filter model.ProductFilter) (*pagination.Page[*model.Product], error) {
+	args := m.Called(ctx, page, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pagination.Page[*model.Product]), args.Error(1)
 }
 
 func (m *MockProductRepo) Update(ctx context.Context, product *model.Product) error {
@@ -57,7 +69,17 @@ func (m *MockProductRepo) Update(ctx context.Context, product *model.Product) er
 	return args.Error(0)
 }
 
-func (m *MockProductRepo) Delete(ctx context.Context, id int64) error {
+func (m *MockProductRepo) Delete(ctx context.Context, id int64, version int) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockProductRepo) Restore(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockProductRepo) Purge(ctx context.Context, id int64) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
@@ -79,9 +101,27 @@ func (m *MockUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.User,
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func (m *MockUserRepo) GetAll(ctx context.Context) ([]*model.User, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*model.User), args.Error(1)
+func (m *MockUserRepo) List(ctx context.Context, page pagination.Request, filter model.UserFilter) (*pagination.Page[*model.User], error) {
+	args := m.Called(ctx, page, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pagination.Page[*model.User]), args.Error(1)
+}
+
+func (m *MockUserRepo) Update(ctx context.Context, user *model.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) Restore(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) Purge(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
 }
 
 type MockCache struct {
@@ -98,36 +138,41 @@ func (m *MockCache) Set(ctx context.Context, key string, value interface{}, expi
 	return args.Error(0)
 }
 
+func (m *MockCache) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
 type MockMessageProducer struct {
 	mock.Mock
 }
 
-func (m *MockMessageProducer) SendMessage(key string, value interface{}) error {
-	args := m.Called(key, value)
+func (m *MockMessageProducer) SendMessage(ctx context.Context, key string, value interface{}) error {
+	args := m.Called(ctx, key, value)
 	return args.Error(0)
 }
 
 func TestOrderHandler(t *testing.T) {
 	mockRepo := new(MockOrderRepo)
-	mockRepo.On("SearchOrders", mock.Anything, mock.Anything).Return([]model.Order{}, nil)
+	mockRepo.On("ListOrders", mock.Anything, mock.Anything, mock.Anything).Return(&pagination.Page[model.Order]{Data: []model.Order{}}, nil)
 
-	handler := NewOrderHandler(mockRepo)
+	handler := NewOrderHandler(mockRepo, new(MockMessageProducer))
 	req := httptest.NewRequest("GET", "/orders", nil)
 	w := httptest.NewRecorder()
 
 	handler.ListOrders(w, req)
 
-	if w.Code != http.StatusNoContent {
-		t.Errorf("expected status NoContent; got %v", w.Code)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status OK; got %v", w.Code)
 	}
 	mockRepo.AssertExpectations(t)
 }
 
 func TestProductHandler(t *testing.T) {
 	mockRepo := new(MockProductRepo)
-	mockRepo.On("GetAll", mock.Anything).Return([]*model.Product{}, nil)
+	mockRepo.On("List", mock.Anything, mock.Anything, mock.Anything).Return(&pagination.Page[*model.Product]{Data: []*model.Product{}}, nil)
 
-	handler := NewProductHandler(mockRepo)
+	handler := NewProductHandler(mockRepo, new(MockMessageProducer))
 	req := httptest.NewRequest("GET", "/products", nil)
 	w := httptest.NewRecorder()
 
@@ -144,7 +189,7 @@ func TestUserHandler(t *testing.T) {
 	mockCache := new(MockCache)
 	mockProducer := new(MockMessageProducer)
 
-	mockRepo.On("GetAll", mock.Anything).Return([]*model.User{}, nil)
+	mockRepo.On("List", mock.Anything, mock.Anything, mock.Anything).Return(&pagination.Page[*model.User]{Data: []*model.User{}}, nil)
 
 	handler := NewUserHandler(mockRepo, mockCache, mockProducer)
 	req := httptest.NewRequest("GET", "/users", nil)
@@ -10,6 +10,9 @@ import (
 	"github.com/IBM/sarama"
 	"github.com/elastic/go-elasticsearch/v8"
 	"github.com/go-redis/redis/v8"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
 )
 
 type HealthHandler struct {
@@ -42,45 +45,14 @@ func NewHealthHandler(mysqlDB, postgresDB *sql.DB, redisClient *redis.Client, ka
 	}
 }
 
-// RegisterRoutes registers all health check endpoints
-func (h *HealthHandler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/health", h.handleHealth)
-	mux.HandleFunc("/health/live", h.handleLive)
-	mux.HandleFunc("/health/ready", h.handleReady)
-}
-
-func (h *HealthHandler) handleHealth(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	h.Health(w, r)
-}
-
-func (h *HealthHandler) handleLive(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	// Clean up path to handle both with and without trailing slash
-	if r.URL.Path != "/health/live" && r.URL.Path != "/health/live/" {
-		http.NotFound(w, r)
-		return
-	}
-	h.Live(w, r)
-}
-
-func (h *HealthHandler) handleReady(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	// Clean up path to handle both with and without trailing slash
-	if r.URL.Path != "/health/ready" && r.URL.Path != "/health/ready/" {
-		http.NotFound(w, r)
-		return
+// GetRoutes returns the health check endpoints. They are public and served
+// outside the API prefix so probes need no token.
+func (h *HealthHandler) GetRoutes() []routes.Route {
+	return []routes.Route{
+		{Name: "health", Method: http.MethodGet, Pattern: "/health", Handler: h.Health, Public: true},
+		{Name: "health.live", Method: http.MethodGet, Pattern: "/health/live", Handler: h.Live, Public: true},
+		{Name: "health.ready", Method: http.MethodGet, Pattern: "/health/ready", Handler: h.Ready, Public: true},
 	}
-	h.Ready(w, r)
 }
 
 func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
@@ -102,7 +74,7 @@ func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 // @Router /health [get]
 func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		response.RespondWithError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
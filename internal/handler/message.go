@@ -1,38 +1,94 @@
 package handler
 
 import (
-	"encoding/json"
-	"log"
+	"context"
+	stderrors "errors"
+	"fmt"
 	"net/http"
-	"strings"
 
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_message"
+	"github.com/Napat/golang-testcontainers-demo/pkg/auth"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
 	"github.com/Napat/golang-testcontainers-demo/pkg/response"
 	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
+	"github.com/google/uuid"
 )
 
 type MessageProducer interface {
-	SendMessage(key string, value interface{}) error
+	SendMessage(ctx context.Context, key string, value interface{}) error
 }
 
+// MessageSender stores a message and publishes it in the background, or
+// schedules it for later
+type MessageSender interface {
+	Send(ctx context.Context, m *model.Message) error
+	Cancel(ctx context.Context, id string) (*model.Message, error)
+}
+
+type MessageRepository interface {
+	GetByID(ctx context.Context, id string) (*model.Message, error)
+	List(ctx context.Context, page pagination.Request, filter model.MessageFilter) (*pagination.Page[*model.Message], error)
+}
+
+// Authorizer tells whether a user holds permissions beyond those its route
+// requires, the policy engine in production
+type Authorizer interface {
+	Authorize(ctx context.Context, userID uuid.UUID, required ...string) error
+}
+
+// MessageHandler serves the message API. Messages belong to the user who
+// sent them: callers without messages:* only see their own.
 type MessageHandler struct {
-	producer MessageProducer
-	routes   []routes.Route
+	sender     MessageSender
+	repo       MessageRepository
+	authorizer Authorizer
+	routes     []routes.Route
+	mux        http.Handler
 }
 
-func NewMessageHandler(producer MessageProducer) *MessageHandler {
+func NewMessageHandler(sender MessageSender, repo MessageRepository, authorizer Authorizer) *MessageHandler {
 	h := &MessageHandler{
-		producer: producer,
+		sender:     sender,
+		repo:       repo,
+		authorizer: authorizer,
 	}
 
 	// Prepare routes
 	h.routes = []routes.Route{
 		{
-			Method:  http.MethodPost,
-			Pattern: "/messages",
-			Handler: h.sendMessage,
+			Name:        "messages.list",
+			Method:      http.MethodGet,
+			Pattern:     "/messages",
+			Handler:     h.listMessages,
+			Permissions: []string{authz.MessagesRead},
+		},
+		{
+			Name:        "messages.send",
+			Method:      http.MethodPost,
+			Pattern:     "/messages",
+			Handler:     h.sendMessage,
+			Permissions: []string{authz.MessagesWrite},
+		},
+		{
+			Name:        "messages.get",
+			Method:      http.MethodGet,
+			Pattern:     "/messages/{id}",
+			Handler:     h.getMessage,
+			Permissions: []string{authz.MessagesRead},
+		},
+		{
+			Name:        "messages.cancel",
+			Method:      http.MethodPost,
+			Pattern:     "/messages/{id}/cancel",
+			Handler:     h.cancelMessage,
+			Permissions: []string{authz.MessagesWrite},
 		},
 	}
+	h.mux = routes.NewHandler(h.routes)
 
 	return h
 }
@@ -43,45 +99,175 @@ func (h *MessageHandler) GetRoutes() []routes.Route {
 }
 
 // @Summary Send a message
-// @Description Send a message to the message broker
+// @Description Store a message and publish it to the message broker in the background. The message is returned queued; follow Location to see it move to published, or to failed with the broker error. With deliver_at or delay, not both, the message is returned scheduled and published once due.
 // @Tags messages
 // @Accept json
 // @Produce json
-// @Param message body map[string]string true "Message content"
-// @Success 202 {object} map[string]string
+// @Param message body model.MessageRequest true "Message content"
+// @Success 202 {object} model.Message
+// @Header 202 {string} Location "URL of the message status"
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Failure 503 {object} response.Problem "Database or schedule unavailable, or shutting down"
+// @Security BearerAuth
 // @Router /api/v1/messages [post]
 func (h *MessageHandler) sendMessage(w http.ResponseWriter, r *http.Request) {
 	var req model.MessageRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		response.RespondWithError(w, http.StatusBadRequest, "Invalid request payload", "sendMessage")
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
 
-	if err := h.producer.SendMessage("message", req); err != nil {
-		log.Printf("Error sending message: %v", err)
-		response.RespondWithError(w, http.StatusInternalServerError, "Failed to send message", "sendMessage")
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		response.WriteError(w, r, errors.NewUnauthorized("sendMessage", "authentication required"))
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status":  "success",
-		"message": "Message sent successfully",
-	})
+	message := req.Message()
+	message.SenderID = identity.UserID.String()
+	if err := h.sender.Send(r.Context(), message); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", routes.Prefix+"/messages/"+message.ID)
+	response.RespondWithJSON(w, http.StatusAccepted, message)
 }
 
-func (h *MessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
-
-	if path == "/messages" {
-		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		h.sendMessage(w, r)
+// @Summary Get a message
+// @Description Get a message and its delivery status: scheduled, queued, published, failed or cancelled. Only the sender, or a caller with messages:*, sees a message.
+// @Tags messages
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {object} model.Message
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/messages/{id} [get]
+func (h *MessageHandler) getMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.UUIDParam(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	message, err := h.ownMessage(r, id.String())
+	if err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
+	response.RespondWithJSON(w, http.StatusOK, message)
+}
 
-	http.NotFound(w, r)
+// @Summary Cancel a scheduled message
+// @Description Cancel a message that is still scheduled. A message that is due is already on its way and cannot be cancelled. Only the sender, or a caller with messages:*, can cancel a message.
+// @Tags messages
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {object} model.Message
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Failure 409 {object} response.Problem "Message is no longer scheduled"
+// @Security BearerAuth
+// @Router /api/v1/messages/{id}/cancel [post]
+func (h *MessageHandler) cancelMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.UUIDParam(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	if _, err := h.ownMessage(r, id.String()); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	message, err := h.sender.Cancel(r.Context(), id.String())
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.RespondWithJSON(w, http.StatusOK, message)
+}
+
+// @Summary List messages
+// @Description List messages one page at a time, oldest first unless sorted by -id. Callers without messages:* only see the messages they sent.
+// @Tags messages
+// @Produce json
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "Opaque cursor from the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(id, -id)
+// @Param status query string false "Filter by status" Enums(scheduled, queued, published, failed, cancelled)
+// @Success 200 {object} pagination.Page[model.Message]
+// @Failure 400 {object} response.Problem
+// @Failure 500 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/messages [get]
+func (h *MessageHandler) listMessages(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePage(w, r, model.MessageSortFields...)
+	if !ok {
+		return
+	}
+
+	filter := model.MessageFilter{Status: r.URL.Query().Get("status")}
+	if filter.Status != "" && !model.ValidMessageStatus(filter.Status) {
+		response.RespondWithError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid status %q", filter.Status))
+		return
+	}
+
+	sender, err := h.senderScope(r)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	filter.SenderID = sender
+
+	messages, err := h.repo.List(r.Context(), page, filter)
+	if err != nil {
+		writePageError(w, r, err)
+		return
+	}
+	response.RespondWithJSON(w, http.StatusOK, messages)
+}
+
+// senderScope returns the sender whose messages the caller is limited to,
+// empty when it holds messages:* and sees every message
+func (h *MessageHandler) senderScope(r *http.Request) (string, error) {
+	identity, ok := auth.IdentityFromContext(r.Context())
+	if !ok {
+		return "", errors.NewUnauthorized("senderScope", "authentication required")
+	}
+
+	err := h.authorizer.Authorize(r.Context(), identity.UserID, authz.MessagesAll)
+	switch {
+	case err == nil:
+		return "", nil
+	case stderrors.Is(err, authz.ErrForbidden):
+		return identity.UserID.String(), nil
+	default:
+		return "", errors.NewUnavailable("authorize", err)
+	}
+}
+
+// ownMessage returns the message with id if the caller may see it. Another
+// sender's message is reported as not found, so ids cannot be probed.
+func (h *MessageHandler) ownMessage(r *http.Request, id string) (*model.Message, error) {
+	sender, err := h.senderScope(r)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		return nil, err
+	}
+	if sender != "" && message.SenderID != sender {
+		return nil, repository_message.ErrMessageNotFound
+	}
+	return message, nil
+}
+
+// ServeHTTP implements http.Handler interface
+func (h *MessageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
 }
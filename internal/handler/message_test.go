@@ -2,74 +2,431 @@ package handler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Napat/golang-testcontainers-demo/internal/handler"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_message"
+	"github.com/Napat/golang-testcontainers-demo/pkg/auth"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
-type MockProducer struct {
+type ctxKey struct{}
+
+var (
+	messageSender = uuid.Must(uuid.NewV7())
+	messageAdmin  = uuid.Must(uuid.NewV7())
+)
+
+// messageAdmins grants messages:* to the users in it and nothing else
+type messageAdmins map[uuid.UUID]bool
+
+func (a messageAdmins) Authorize(ctx context.Context, userID uuid.UUID, required ...string) error {
+	if a[userID] {
+		return nil
+	}
+	return authz.ErrForbidden
+}
+
+// newMessageHandler creates a message handler where messageAdmin holds messages:*
+func newMessageHandler(sender handler.MessageSender, repo handler.MessageRepository) http.Handler {
+	return handler.NewMessageHandler(sender, repo, messageAdmins{messageAdmin: true})
+}
+
+// asUser returns req as sent by userID
+func asUser(req *http.Request, userID uuid.UUID) *http.Request {
+	return req.WithContext(auth.WithIdentity(req.Context(), &auth.Identity{UserID: userID, Username: "user"}))
+}
+
+type MockMessageSender struct {
 	mock.Mock
 }
 
-func (m *MockProducer) SendMessage(topic string, message interface{}) error {
-	args := m.Called(topic, message)
+func (m *MockMessageSender) Send(ctx context.Context, message *model.Message) error {
+	args := m.Called(ctx, message)
 	return args.Error(0)
 }
 
-func TestMessageHandler_ServeHTTP(t *testing.T) {
+func (m *MockMessageSender) Cancel(ctx context.Context, id string) (*model.Message, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Message), args.Error(1)
+}
+
+type MockMessageRepo struct {
+	mock.Mock
+}
+
+func (m *MockMessageRepo) GetByID(ctx context.Context, id string) (*model.Message, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.Message), args.Error(1)
+}
+
+func (m *MockMessageRepo) List(ctx context.Context, page pagination.Request, filter model.MessageFilter) (*pagination.Page[*model.Message], error) {
+	args := m.Called(ctx, page, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pagination.Page[*model.Message]), args.Error(1)
+}
+
+func TestMessageHandler_SendMessage(t *testing.T) {
 	tests := []struct {
 		name           string
 		method         string
-		requestBody    model.MessageRequest
-		mockError      error
+		body           string
+		sendError      error
 		expectedStatus int
 	}{
 		{
-			name:           "success",
+			name:           "accepted",
 			method:         http.MethodPost,
-			requestBody:    model.MessageRequest{Content: "test message"},
-			mockError:      nil,
+			body:           `{"content":"test message"}`,
 			expectedStatus: http.StatusAccepted,
 		},
 		{
 			name:           "method not allowed",
-			method:         http.MethodGet,
+			method:         http.MethodDelete,
 			expectedStatus: http.StatusMethodNotAllowed,
 		},
 		{
-			name:           "producer error",
+			name:           "missing content",
+			method:         http.MethodPost,
+			body:           `{}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "delay and deliver_at",
+			method:         http.MethodPost,
+			body:           `{"content":"test message","delay":"1h","deliver_at":"2030-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "invalid delay",
+			method:         http.MethodPost,
+			body:           `{"content":"test message","delay":"soon"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "delay too long",
+			method:         http.MethodPost,
+			body:           `{"content":"test message","delay":"1000h"}`,
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "database unavailable",
 			method:         http.MethodPost,
-			requestBody:    model.MessageRequest{Content: "test message"},
-			mockError:      assert.AnError,
-			expectedStatus: http.StatusInternalServerError,
+			body:           `{"content":"test message"}`,
+			sendError:      errors.NewUnavailable("MessageRepository.Create", assert.AnError),
+			expectedStatus: http.StatusServiceUnavailable,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockProducer := new(MockProducer)
-			if tt.method == http.MethodPost && tt.mockError != nil {
-				mockProducer.On("SendMessage", "message", tt.requestBody).Return(tt.mockError)
-			} else if tt.method == http.MethodPost {
-				mockProducer.On("SendMessage", "message", tt.requestBody).Return(nil)
+			sender := new(MockMessageSender)
+			if tt.expectedStatus == http.StatusAccepted || tt.sendError != nil {
+				sender.On("Send", mock.Anything, mock.MatchedBy(func(m *model.Message) bool {
+					return m.Content == "test message" && m.Status == model.MessageQueued && m.SenderID == messageSender.String()
+				})).Return(tt.sendError)
 			}
 
-			h := handler.NewMessageHandler(mockProducer)
+			req := asUser(httptest.NewRequest(tt.method, "/messages", strings.NewReader(tt.body)), messageSender)
+			rec := httptest.NewRecorder()
+			newMessageHandler(sender, new(MockMessageRepo)).ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			sender.AssertExpectations(t)
+			if rec.Code != http.StatusAccepted {
+				return
+			}
+
+			var message model.Message
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &message))
+			id, err := uuid.Parse(message.ID)
+			require.NoError(t, err)
+			assert.Equal(t, uuid.Version(7), id.Version())
+			assert.Equal(t, model.MessageQueued, message.Status)
+			assert.Equal(t, "/api/v1/messages/"+message.ID, rec.Header().Get("Location"))
+		})
+	}
+}
+
+func TestMessageHandler_SendMessage_PassesRequestContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), ctxKey{}, "request")
+
+	var sent context.Context
+	sender := new(MockMessageSender)
+	sender.On("Send", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(0).(context.Context)
+	}).Return(nil)
+
+	req := asUser(httptest.NewRequest(http.MethodPost, "/messages", bytes.NewBufferString(`{"content":"test message"}`)).WithContext(ctx), messageSender)
+	rec := httptest.NewRecorder()
+	newMessageHandler(sender, new(MockMessageRepo)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "request", sent.Value(ctxKey{}))
+}
+
+func TestMessageHandler_SendMessage_Scheduled(t *testing.T) {
+	sender := new(MockMessageSender)
+	sender.On("Send", mock.Anything, mock.MatchedBy(func(m *model.Message) bool {
+		return m.Status == model.MessageScheduled && m.DeliverAt != nil
+	})).Return(nil)
+
+	start := time.Now()
+	req := asUser(httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(`{"content":"reminder","delay":"15m"}`)), messageSender)
+	rec := httptest.NewRecorder()
+	newMessageHandler(sender, new(MockMessageRepo)).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	sender.AssertExpectations(t)
+
+	var message model.Message
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &message))
+	assert.Equal(t, model.MessageScheduled, message.Status)
+	require.NotNil(t, message.DeliverAt)
+	assert.WithinDuration(t, start.Add(15*time.Minute), *message.DeliverAt, time.Minute)
+}
+
+func TestMessageHandler_CancelMessage(t *testing.T) {
+	id := uuid.Must(uuid.NewV7()).String()
+
+	scheduled := &model.Message{ID: id, SenderID: messageSender.String(), Content: "reminder", Status: model.MessageScheduled}
+	cancelled := &model.Message{ID: id, SenderID: messageSender.String(), Content: "reminder", Status: model.MessageCancelled}
+
+	tests := []struct {
+		name           string
+		path           string
+		caller         uuid.UUID
+		stored         *model.Message
+		repoError      error
+		message        *model.Message
+		cancelError    error
+		expectedStatus int
+	}{
+		{
+			name:           "own message",
+			path:           "/messages/" + id + "/cancel",
+			caller:         messageSender,
+			stored:         scheduled,
+			message:        cancelled,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "another sender's message",
+			path:           "/messages/" + id + "/cancel",
+			caller:         uuid.Must(uuid.NewV7()),
+			stored:         scheduled,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "any message with messages:*",
+			path:           "/messages/" + id + "/cancel",
+			caller:         messageAdmin,
+			stored:         scheduled,
+			message:        cancelled,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "not found",
+			path:           "/messages/" + id + "/cancel",
+			caller:         messageSender,
+			repoError:      repository_message.ErrMessageNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "no longer scheduled",
+			path:           "/messages/" + id + "/cancel",
+			caller:         messageSender,
+			stored:         scheduled,
+			cancelError:    errors.NewConflict("Sender.Cancel", "message is published, only scheduled messages can be cancelled", nil),
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "malformed id",
+			path:           "/messages/42/cancel",
+			caller:         messageSender,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockMessageRepo)
+			if tt.stored != nil || tt.repoError != nil {
+				repo.On("GetByID", mock.Anything, id).Return(tt.stored, tt.repoError)
+			}
+			sender := new(MockMessageSender)
+			if tt.message != nil || tt.cancelError != nil {
+				sender.On("Cancel", mock.Anything, id).Return(tt.message, tt.cancelError)
+			}
+
+			rec := httptest.NewRecorder()
+			newMessageHandler(sender, repo).ServeHTTP(rec, asUser(httptest.NewRequest(http.MethodPost, tt.path, nil), tt.caller))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			repo.AssertExpectations(t)
+			sender.AssertExpectations(t)
+			if tt.message != nil {
+				var message model.Message
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &message))
+				assert.Equal(t, *tt.message, message)
+			}
+		})
+	}
+}
+
+func TestMessageHandler_GetMessage(t *testing.T) {
+	id := uuid.Must(uuid.NewV7()).String()
+
+	own := &model.Message{ID: id, SenderID: messageSender.String(), Content: "test message", Status: model.MessageFailed, Error: "broker unavailable"}
+
+	tests := []struct {
+		name           string
+		path           string
+		caller         uuid.UUID
+		message        *model.Message
+		repoError      error
+		expectedStatus int
+	}{
+		{
+			name:           "own message",
+			path:           "/messages/" + id,
+			caller:         messageSender,
+			message:        own,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "another sender's message",
+			path:           "/messages/" + id,
+			caller:         uuid.Must(uuid.NewV7()),
+			message:        own,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "any message with messages:*",
+			path:           "/messages/" + id,
+			caller:         messageAdmin,
+			message:        own,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "not found",
+			path:           "/messages/" + id,
+			caller:         messageSender,
+			repoError:      repository_message.ErrMessageNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "malformed id",
+			path:           "/messages/42",
+			caller:         messageSender,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockMessageRepo)
+			if tt.message != nil || tt.repoError != nil {
+				repo.On("GetByID", mock.Anything, id).Return(tt.message, tt.repoError)
+			}
 
-			body, _ := json.Marshal(tt.requestBody)
-			req := httptest.NewRequest(tt.method, "/messages", bytes.NewBuffer(body))
 			rec := httptest.NewRecorder()
+			newMessageHandler(new(MockMessageSender), repo).ServeHTTP(rec, asUser(httptest.NewRequest(http.MethodGet, tt.path, nil), tt.caller))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			repo.AssertExpectations(t)
+			if rec.Code == http.StatusOK {
+				var message model.Message
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &message))
+				assert.Equal(t, *tt.message, message)
+			}
+		})
+	}
+}
+
+func TestMessageHandler_ListMessages(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		caller         uuid.UUID
+		filter         model.MessageFilter
+		repoError      error
+		expectedStatus int
+	}{
+		{
+			name:           "own messages",
+			caller:         messageSender,
+			filter:         model.MessageFilter{SenderID: messageSender.String()},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "every message with messages:*",
+			caller:         messageAdmin,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "by status",
+			query:          "?status=failed",
+			caller:         messageSender,
+			filter:         model.MessageFilter{Status: model.MessageFailed, SenderID: messageSender.String()},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown status",
+			query:          "?status=lost",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unknown sort",
+			query:          "?sort=content",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid cursor",
+			caller:         messageAdmin,
+			repoError:      pagination.ErrInvalidCursor,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := new(MockMessageRepo)
+			if tt.expectedStatus == http.StatusOK {
+				repo.On("List", mock.Anything, mock.Anything, tt.filter).Return(&pagination.Page[*model.Message]{
+					Data:  []*model.Message{{ID: "1", Status: model.MessagePublished}},
+					Total: 1,
+				}, nil)
+			} else if tt.repoError != nil {
+				repo.On("List", mock.Anything, mock.Anything, tt.filter).Return(nil, tt.repoError)
+			}
 
-			h.ServeHTTP(rec, req)
+			rec := httptest.NewRecorder()
+			newMessageHandler(new(MockMessageSender), repo).ServeHTTP(rec, asUser(httptest.NewRequest(http.MethodGet, "/messages"+tt.query, nil), tt.caller))
 
 			assert.Equal(t, tt.expectedStatus, rec.Code)
-			mockProducer.AssertExpectations(t)
+			repo.AssertExpectations(t)
 		})
 	}
 }
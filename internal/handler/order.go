@@ -2,55 +2,68 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
-	"log"
 	"net/http"
-	"strings"
 
-	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
-	"github.com/Napat/golang-testcontainers-demo/pkg/middleware"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/projection"
 	"github.com/Napat/golang-testcontainers-demo/pkg/response"
 	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
 )
 
 type OrderRepository interface {
 	CreateOrder(ctx context.Context, order *model.Order) error
 	SearchOrders(ctx context.Context, params map[string]interface{}) ([]model.Order, error)
+	ListOrders(ctx context.Context, page pagination.Request, filter model.OrderFilter) (*pagination.Page[model.Order], error)
 }
 
 type OrderHandler struct {
 	orderRepo OrderRepository
+	producer  MessageProducer
 	routes    []routes.Route
+	mux       http.Handler
 }
 
-func NewOrderHandler(repo OrderRepository) *OrderHandler {
+func NewOrderHandler(repo OrderRepository, producer MessageProducer) *OrderHandler {
 	h := &OrderHandler{
 		orderRepo: repo,
+		producer:  producer,
 	}
 
 	h.routes = []routes.Route{
 		{
-			Method:  http.MethodGet,
-			Pattern: "/orders",
-			Handler: h.ListOrders,
+			Name:        "orders.list",
+			Method:      http.MethodGet,
+			Pattern:     "/orders",
+			Handler:     h.ListOrders,
+			Permissions: []string{authz.OrdersRead},
 		},
 		{
-			Method:  http.MethodPost,
-			Pattern: "/orders",
-			Handler: h.createOrder,
+			Name:        "orders.create",
+			Method:      http.MethodPost,
+			Pattern:     "/orders",
+			Handler:     h.createOrder,
+			Permissions: []string{authz.OrdersWrite},
 		},
 		{
-			Method:  http.MethodGet,
-			Pattern: "/orders/search",
-			Handler: h.searchOrders,
+			Name:        "orders.search",
+			Method:      http.MethodGet,
+			Pattern:     "/orders/search",
+			Handler:     h.searchOrders,
+			Permissions: []string{authz.OrdersRead},
 		},
 		{
-			Method:  http.MethodGet,
-			Pattern: "/orders/simple-search",
-			Handler: h.simpleSearch,
+			Name:        "orders.simple_search",
+			Method:      http.MethodGet,
+			Pattern:     "/orders/simple-search",
+			Handler:     h.simpleSearch,
+			Permissions: []string{authz.OrdersRead},
 		},
 	}
+	h.mux = routes.NewHandler(h.routes)
 
 	return h
 }
@@ -66,25 +79,31 @@ func (h *OrderHandler) GetRoutes() []routes.Route {
 // @Accept json
 // @Produce json
 // @Param order body model.Order true "Order object"
-// @Success 201 {object} model.Order
-// @Failure 400 {object} map[string]string
+// @Success 201 {object} model.OrderResponse
+// @Failure 400 {object} response.Problem
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Security BearerAuth
 // @Router /api/v1/orders [post]
 func (h *OrderHandler) createOrder(w http.ResponseWriter, r *http.Request) {
 	var order model.Order
-	if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-		response.RespondWithError(w, http.StatusBadRequest, "Invalid request payload", "createOrder")
+	if err := validate.Decode(r, &order); err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
 
-	if err := h.orderRepo.CreateOrder(r.Context(), &order); err != nil {
-		log.Printf("Error creating order: %v", err)
-		response.RespondWithError(w, http.StatusInternalServerError, "Failed to create order", "createOrder")
+	ctx := r.Context()
+	if err := h.orderRepo.CreateOrder(ctx, &order); err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(order)
+	// Publishing is best effort, the order is already stored. A client that
+	// hangs up must not cancel the event.
+	if err := h.producer.SendMessage(context.WithoutCancel(ctx), "order:"+order.ID, order.ToResponse()); err != nil {
+		logging.FromContext(ctx).Warn("failed to publish order event", "order_id", order.ID, "event", model.OrderEventCreated, "error", err)
+	}
+
+	response.RespondWithJSON(w, http.StatusCreated, order.ToResponse())
 }
 
 // @Summary Search orders
@@ -93,9 +112,16 @@ func (h *OrderHandler) createOrder(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param customer_id query string false "Customer ID to search for"
-// @Success 200 {array} model.Order
+// @Param fields query string false "Comma separated fields to return, e.g. id,status,total"
+// @Success 200 {array} model.OrderResponse
+// @Security BearerAuth
 // @Router /api/v1/orders/search [get]
 func (h *OrderHandler) searchOrders(w http.ResponseWriter, r *http.Request) {
+	fields, ok := parseFields(w, r, model.OrderResponse{})
+	if !ok {
+		return
+	}
+
 	params := make(map[string]interface{})
 	if customerID := r.URL.Query().Get("customer_id"); customerID != "" {
 		params["customer_id"] = customerID
@@ -103,13 +129,11 @@ func (h *OrderHandler) searchOrders(w http.ResponseWriter, r *http.Request) {
 
 	orders, err := h.orderRepo.SearchOrders(r.Context(), params)
 	if err != nil {
-		log.Printf("Error searching orders: %v", err)
-		response.RespondWithError(w, http.StatusInternalServerError, "Failed to search orders", "searchOrders")
+		response.WriteError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(orders)
+	response.RespondWithJSON(w, http.StatusOK, projection.Select(orderResponses(orders), fields))
 }
 
 // @Summary Simple search orders
@@ -118,12 +142,18 @@ func (h *OrderHandler) searchOrders(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param q query string true "Search query"
-// @Success 200 {array} model.Order
+// @Param fields query string false "Comma separated fields to return, e.g. id,status,total"
+// @Success 200 {array} model.OrderResponse
+// @Security BearerAuth
 // @Router /api/v1/orders/simple-search [get]
 func (h *OrderHandler) simpleSearch(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
 	if query == "" {
-		response.RespondWithError(w, http.StatusBadRequest, "Search query is required", "simpleSearch")
+		response.RespondWithError(w, r, http.StatusBadRequest, "Search query is required")
+		return
+	}
+	fields, ok := parseFields(w, r, model.OrderResponse{})
+	if !ok {
 		return
 	}
 
@@ -133,55 +163,64 @@ func (h *OrderHandler) simpleSearch(w http.ResponseWriter, r *http.Request) {
 
 	orders, err := h.orderRepo.SearchOrders(r.Context(), params)
 	if err != nil {
-		log.Printf("Error performing simple search: %v", err)
-		response.RespondWithError(w, http.StatusInternalServerError, "Failed to search orders", "simpleSearch")
+		response.WriteError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(orders)
+	response.RespondWithJSON(w, http.StatusOK, projection.Select(orderResponses(orders), fields))
 }
 
-// @Summary List all orders
-// @Description Get a list of all orders
+// @Summary List orders
+// @Description List orders one page at a time. Pass next_cursor from the previous page as cursor to continue.
 // @Tags orders
 // @Accept json
 // @Produce json
-// @Success 200 {array} model.Order
-// @Success 204 "No Content"
-// @Failure 500 {object} errors.Error
-// @Router /orders [get]
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "Opaque cursor from the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(created_at, -created_at, total, -total, id, -id)
+// @Param status query string false "Filter by status"
+// @Param customer_id query string false "Filter by customer ID"
+// @Param fields query string false "Comma separated fields to return, e.g. id,status,total"
+// @Success 200 {object} pagination.Page[model.OrderResponse]
+// @Failure 400 {object} response.Problem
+// @Failure 500 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/orders [get]
 func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
-	orders, err := h.orderRepo.SearchOrders(r.Context(), map[string]interface{}{
-		"query": map[string]interface{}{
-			"match_all": map[string]interface{}{},
-		},
-	})
-	if err != nil {
-		middleware.WriteError(w, errors.NewInternalError("listOrders", err))
+	page, ok := parsePage(w, r, model.OrderSortFields...)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, model.OrderResponse{})
+	if !ok {
 		return
 	}
 
-	if len(orders) == 0 {
-		w.WriteHeader(http.StatusNoContent)
+	filter := model.OrderFilter{
+		Status:     r.URL.Query().Get("status"),
+		CustomerID: r.URL.Query().Get("customer_id"),
+	}
+
+	orders, err := h.orderRepo.ListOrders(r.Context(), page, filter)
+	if err != nil {
+		writePageError(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(orders)
+	public := pagination.MapPage(orders, func(o model.Order) model.OrderResponse { return o.ToResponse() })
+	response.RespondWithJSON(w, http.StatusOK, projection.SelectPage(public, fields))
 }
 
-// ServeHTTP implements http.Handler interface
-func (h *OrderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Remove /api/v1 prefix if present for both test and production compatibility
-	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
-
-	// Find matching route
-	for _, route := range h.routes {
-		if strings.TrimSuffix(path, "/") == strings.TrimSuffix(route.Pattern, "/") && r.Method == route.Method {
-			route.Handler(w, r)
-			return
-		}
+// orderResponses returns the public representation of every order
+func orderResponses(orders []model.Order) []model.OrderResponse {
+	out := make([]model.OrderResponse, len(orders))
+	for i := range orders {
+		out[i] = orders[i].ToResponse()
 	}
+	return out
+}
 
-	http.NotFound(w, r)
+// ServeHTTP implements http.Handler interface
+func (h *OrderHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
 }
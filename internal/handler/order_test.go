@@ -11,6 +11,7 @@ import (
 
 	"github.com/Napat/golang-testcontainers-demo/internal/handler"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -29,6 +30,14 @@ func (m *MockOrderRepo) SearchOrders(ctx context.Context, query map[string]inter
 	return args.Get(0).([]model.Order), args.Error(1)
 }
 
+func (m *MockOrderRepo) ListOrders(ctx context.Context, page pagination.Request, filter model.OrderFilter) (*pagination.Page[model.Order], error) {
+	args := m.Called(ctx, page, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pagination.Page[model.Order]), args.Error(1)
+}
+
 func TestOrderHandler_CreateOrder(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -102,8 +111,14 @@ func TestOrderHandler_CreateOrder(t *testing.T) {
 			if tt.setupMock {
 				mockRepo.On("CreateOrder", mock.Anything, mock.AnythingOfType("*model.Order")).Return(tt.mockError)
 			}
+			mockProducer := new(MockProducerRepo)
+			if tt.expectedStatus == http.StatusCreated {
+				mockProducer.On("SendMessage", mock.Anything, "order:"+tt.input.ID, mock.MatchedBy(func(order model.OrderResponse) bool {
+					return order.ID == tt.input.ID
+				})).Return(nil).Once()
+			}
 
-			handler := handler.NewOrderHandler(mockRepo)
+			handler := handler.NewOrderHandler(mockRepo, mockProducer)
 			routes := handler.GetRoutes()
 
 			// Find the create order route
@@ -129,6 +144,7 @@ func TestOrderHandler_CreateOrder(t *testing.T) {
 			if tt.setupMock {
 				mockRepo.AssertExpectations(t)
 			}
+			mockProducer.AssertExpectations(t)
 		})
 	}
 }
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+)
+
+// parsePage reads limit, sort and cursor from the query string. It writes
+// 400 on invalid input and reports whether the request may proceed.
+func parsePage(w http.ResponseWriter, r *http.Request, sorts ...string) (pagination.Request, bool) {
+	page, err := pagination.Parse(r.URL.Query(), sorts...)
+	if err != nil {
+		response.RespondWithError(w, r, http.StatusBadRequest, err.Error())
+		return page, false
+	}
+	return page, true
+}
+
+// writePageError answers a failed list query: 400 when the repository
+// refused the cursor, the mapped status of err otherwise
+func writePageError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, pagination.ErrInvalidCursor) {
+		response.RespondWithError(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	response.WriteError(w, r, err)
+}
+
+// queryFloat parses an optional float query parameter, nil when it is absent
+func queryFloat(r *http.Request, name string) (*float64, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// queryInt parses an optional integer query parameter, nil when it is absent
+func queryInt(r *http.Request, name string) (*int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/etag"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+)
+
+// checkPrecondition enforces If-Match against the current version of a
+// resource. It writes 428 when the header is missing or 412 when it names an
+// older version, and reports whether the request may proceed.
+func checkPrecondition(w http.ResponseWriter, r *http.Request, version int) bool {
+	err := etag.Check(r, version)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, etag.ErrPreconditionRequired):
+		response.RespondWithError(w, r, http.StatusPreconditionRequired, err.Error())
+	default:
+		response.RespondWithError(w, r, http.StatusPreconditionFailed, err.Error())
+	}
+	return false
+}
@@ -2,52 +2,103 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
-	"strconv"
-	"strings"
+	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/etag"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/projection"
 	"github.com/Napat/golang-testcontainers-demo/pkg/response"
 	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
 )
 
 type ProductRepository interface {
 	Create(ctx context.Context, product *model.Product) error
-	GetAll(ctx context.Context) ([]*model.Product, error)
+	List(ctx context.Context, page pagination.Request, filter model.ProductFilter) (*pagination.Page[*model.Product], error)
 	GetByID(ctx context.Context, id int64) (*model.Product, error)
 	Update(ctx context.Context, product *model.Product) error
-	Delete(ctx context.Context, id int64) error
+	Delete(ctx context.Context, id int64, version int) error
+	Restore(ctx context.Context, id int64) error
+	Purge(ctx context.Context, id int64) error
 }
 
 type ProductHandler struct {
 	productRepo ProductRepository
+	producer    MessageProducer
 	routes      []routes.Route
+	mux         http.Handler
 }
 
-func NewProductHandler(repo ProductRepository) *ProductHandler {
+func NewProductHandler(repo ProductRepository, producer MessageProducer) *ProductHandler {
 	h := &ProductHandler{
 		productRepo: repo,
+		producer:    producer,
 	}
 
 	h.routes = []routes.Route{
 		{
-			Method:  http.MethodGet,
-			Pattern: "/products",
-			Handler: h.getAllProducts,
+			Name:        "products.list",
+			Method:      http.MethodGet,
+			Pattern:     "/products",
+			Handler:     h.getAllProducts,
+			Permissions: []string{authz.ProductsRead},
 		},
 		{
-			Method:  http.MethodPost,
-			Pattern: "/products",
-			Handler: h.createProduct,
+			Name:        "products.create",
+			Method:      http.MethodPost,
+			Pattern:     "/products",
+			Handler:     h.createProduct,
+			Permissions: []string{authz.ProductsWrite},
 		},
 		{
-			Method:  http.MethodGet,
-			Pattern: "/products/",
-			Handler: h.getProductByID,
+			Name:        "products.get",
+			Method:      http.MethodGet,
+			Pattern:     "/products/{id}",
+			Handler:     h.getProductByID,
+			Permissions: []string{authz.ProductsRead},
+		},
+		{
+			Name:        "products.update",
+			Method:      http.MethodPut,
+			Pattern:     "/products/{id}",
+			Handler:     h.updateProduct,
+			Permissions: []string{authz.ProductsWrite},
+		},
+		{
+			Name:        "products.delete",
+			Method:      http.MethodDelete,
+			Pattern:     "/products/{id}",
+			Handler:     h.deleteProduct,
+			Permissions: []string{authz.ProductsWrite},
+		},
+		{
+			Name:        "admin.products.list",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/products",
+			Handler:     h.listDeletedProducts,
+			Permissions: []string{authz.ProductsAdmin},
+		},
+		{
+			Name:        "admin.products.restore",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/products/{id}/restore",
+			Handler:     h.restoreProduct,
+			Permissions: []string{authz.ProductsAdmin},
+		},
+		{
+			Name:        "admin.products.purge",
+			Method:      http.MethodDelete,
+			Pattern:     "/admin/products/{id}",
+			Handler:     h.purgeProduct,
+			Permissions: []string{authz.ProductsAdmin},
 		},
 	}
+	h.mux = routes.NewHandler(h.routes)
 
 	return h
 }
@@ -57,20 +108,54 @@ func (h *ProductHandler) GetRoutes() []routes.Route {
 	return h.routes
 }
 
-// @Summary Get all products
-// @Description Get a list of all products
+// @Summary List products
+// @Description List products one page at a time. Pass next_cursor from the previous page as cursor to continue.
 // @Tags products
 // @Accept json
 // @Produce json
-// @Success 200 {array} model.Product
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "Opaque cursor from the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(id, -id, name, -name, price, -price, stock, -stock, created_at, -created_at)
+// @Param min_price query number false "Minimum price"
+// @Param max_price query number false "Maximum price"
+// @Param min_stock query int false "Minimum stock, 1 lists only products in stock"
+// @Param fields query string false "Comma separated fields to return, e.g. id,name,price"
+// @Success 200 {object} pagination.Page[model.ProductResponse]
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
 // @Router /api/v1/products [get]
 func (h *ProductHandler) getAllProducts(w http.ResponseWriter, r *http.Request) {
-	products, err := h.productRepo.GetAll(r.Context())
+	page, ok := parsePage(w, r, model.ProductSortFields...)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, model.ProductResponse{})
+	if !ok {
+		return
+	}
+
+	var filter model.ProductFilter
+	var err error
+	if filter.MinPrice, err = queryFloat(r, "min_price"); err != nil {
+		response.RespondWithError(w, r, http.StatusBadRequest, "min_price must be a number")
+		return
+	}
+	if filter.MaxPrice, err = queryFloat(r, "max_price"); err != nil {
+		response.RespondWithError(w, r, http.StatusBadRequest, "max_price must be a number")
+		return
+	}
+	if filter.MinStock, err = queryInt(r, "min_stock"); err != nil {
+		response.RespondWithError(w, r, http.StatusBadRequest, "min_stock must be an integer")
+		return
+	}
+
+	products, err := h.productRepo.List(r.Context(), page, filter)
 	if err != nil {
-		response.RespondWithError(w, http.StatusInternalServerError, err.Error(), "getAllProducts")
+		writePageError(w, r, err)
 		return
 	}
-	response.RespondWithJSON(w, http.StatusOK, products)
+	response.RespondWithJSON(w, http.StatusOK, projection.SelectPage(pagination.MapPage(products, (*model.Product).ToResponse), fields))
 }
 
 // @Summary Create a new product
@@ -78,25 +163,29 @@ func (h *ProductHandler) getAllProducts(w http.ResponseWriter, r *http.Request)
 // @Tags products
 // @Accept json
 // @Produce json
-// @Param product body model.Product true "Product object"
-// @Success 201 {object} model.Product
+// @Param product body model.ProductCreate true "Product creation request"
+// @Success 201 {object} model.ProductResponse
+// @Failure 400 {object} response.Problem
+// @Failure 409 {object} response.Problem "SKU already exists"
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Failure 503 {object} response.Problem "Database unavailable"
+// @Security BearerAuth
 // @Router /api/v1/products [post]
 func (h *ProductHandler) createProduct(w http.ResponseWriter, r *http.Request) {
-	var product model.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
-		response.RespondWithError(w, http.StatusBadRequest, "Invalid request payload", "createProduct")
+	var req model.ProductCreate
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
 
-	if err := h.productRepo.Create(r.Context(), &product); err != nil {
-		log.Printf("Error creating product: %v", err)
-		response.RespondWithError(w, http.StatusInternalServerError, "Failed to create product", "createProduct")
+	product := req.Product()
+	if err := h.productRepo.Create(r.Context(), product); err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
+	h.publishChange(r.Context(), model.ProductEventCreated, product.ID, product)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(product)
+	response.RespondWithJSON(w, http.StatusCreated, product.ToResponse())
 }
 
 // @Summary Get product by ID
@@ -105,37 +194,238 @@ func (h *ProductHandler) createProduct(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param id path int true "Product ID"
-// @Success 200 {object} model.Product
+// @Param fields query string false "Comma separated fields to return, e.g. id,name,price"
+// @Success 200 {object} model.ProductResponse
+// @Header 200 {string} ETag "Current version of the product"
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Security BearerAuth
 // @Router /api/v1/products/{id} [get]
 func (h *ProductHandler) getProductByID(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/v1/products/")
-	id, err := strconv.ParseInt(idStr, 10, 64)
+	id, err := routes.Int64Param(r, "id")
 	if err != nil {
-		response.RespondWithError(w, http.StatusBadRequest, "Invalid product ID", "getProductByID")
+		response.WriteError(w, r, err)
+		return
+	}
+	fields, ok := parseFields(w, r, model.ProductResponse{})
+	if !ok {
 		return
 	}
 
 	product, err := h.productRepo.GetByID(r.Context(), id)
 	if err != nil {
-		response.RespondWithError(w, http.StatusInternalServerError, err.Error(), "getProductByID")
+		response.WriteError(w, r, err)
 		return
 	}
 
-	response.RespondWithJSON(w, http.StatusOK, product)
+	etag.Set(w, product.Version)
+	response.RespondWithJSON(w, http.StatusOK, projection.Select(product.ToResponse(), fields))
 }
 
-// ServeHTTP implements http.Handler interface
-func (h *ProductHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Remove /api/v1 prefix if present for both test and production compatibility
-	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+// @Summary Update a product
+// @Description Replace a product. If-Match must carry the ETag returned by GET.
+// @Tags products
+// @Accept json
+// @Produce json
+// @Param id path int true "Product ID"
+// @Param If-Match header string true "ETag of the version being modified"
+// @Param product body model.ProductUpdate true "Product update request"
+// @Success 200 {object} model.ProductResponse
+// @Header 200 {string} ETag "Current version of the product"
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Failure 409 {object} response.Problem
+// @Failure 412 {object} response.Problem
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Failure 428 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/products/{id} [put]
+func (h *ProductHandler) updateProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.Int64Param(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	var req model.ProductUpdate
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
 
-	// Find matching route
-	for _, route := range h.routes {
-		if strings.TrimSuffix(path, "/") == strings.TrimSuffix(route.Pattern, "/") && r.Method == route.Method {
-			route.Handler(w, r)
-			return
-		}
+	ctx := r.Context()
+	product, err := h.productRepo.GetByID(ctx, id)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
 	}
+	if !checkPrecondition(w, r, product.Version) {
+		return
+	}
+
+	req.Apply(product)
 
-	http.NotFound(w, r)
+	if err := h.productRepo.Update(ctx, product); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	h.publishChange(ctx, model.ProductEventUpdated, product.ID, product)
+
+	etag.Set(w, product.Version)
+	response.RespondWithJSON(w, http.StatusOK, product.ToResponse())
+}
+
+// @Summary Delete a product
+// @Description Soft delete a product. It can be restored by an administrator until the retention window purges it. If-Match must carry the ETag returned by GET.
+// @Tags products
+// @Param id path int true "Product ID"
+// @Param If-Match header string true "ETag of the version being deleted"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Failure 409 {object} response.Problem
+// @Failure 412 {object} response.Problem
+// @Failure 428 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/products/{id} [delete]
+func (h *ProductHandler) deleteProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.Int64Param(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	product, err := h.productRepo.GetByID(ctx, id)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	if !checkPrecondition(w, r, product.Version) {
+		return
+	}
+
+	if err := h.productRepo.Delete(ctx, id, product.Version); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	h.publishChange(ctx, model.ProductEventDeleted, id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary List deleted products
+// @Description List soft deleted products one page at a time
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "Opaque cursor from the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(id, -id, name, -name, price, -price, stock, -stock, created_at, -created_at)
+// @Param fields query string false "Comma separated fields to return, e.g. id,name,price"
+// @Success 200 {object} pagination.Page[model.ProductResponse]
+// @Failure 400 {object} response.Problem
+// @Failure 500 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/admin/products [get]
+func (h *ProductHandler) listDeletedProducts(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePage(w, r, model.ProductSortFields...)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, model.ProductResponse{})
+	if !ok {
+		return
+	}
+
+	products, err := h.productRepo.List(r.Context(), page, model.ProductFilter{Deleted: true})
+	if err != nil {
+		writePageError(w, r, err)
+		return
+	}
+	response.RespondWithJSON(w, http.StatusOK, projection.SelectPage(pagination.MapPage(products, (*model.Product).ToResponse), fields))
+}
+
+// @Summary Restore a deleted product
+// @Tags admin
+// @Produce json
+// @Param id path int true "Product ID"
+// @Success 200 {object} model.ProductResponse
+// @Header 200 {string} ETag "Current version of the product"
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Failure 409 {object} response.Problem "Product is not deleted"
+// @Security BearerAuth
+// @Router /api/v1/admin/products/{id}/restore [post]
+func (h *ProductHandler) restoreProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.Int64Param(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.productRepo.Restore(ctx, id); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	product, err := h.productRepo.GetByID(ctx, id)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	h.publishChange(ctx, model.ProductEventRestored, id, product)
+
+	etag.Set(w, product.Version)
+	response.RespondWithJSON(w, http.StatusOK, product.ToResponse())
+}
+
+// @Summary Purge a deleted product
+// @Description Permanently remove a soft deleted product. Live products must be deleted first.
+// @Tags admin
+// @Param id path int true "Product ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Failure 409 {object} response.Problem "Product is not deleted"
+// @Security BearerAuth
+// @Router /api/v1/admin/products/{id} [delete]
+func (h *ProductHandler) purgeProduct(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.Int64Param(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.productRepo.Purge(r.Context(), id); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishChange publishes a change event carrying the product as stored, or
+// only its id when product is nil. Publishing is best effort, the change is
+// already stored.
+func (h *ProductHandler) publishChange(ctx context.Context, eventType string, id int64, product *model.Product) {
+	event := model.ProductChangedEvent{
+		Type:       eventType,
+		ProductID:  id,
+		OccurredAt: time.Now().UTC(),
+	}
+	if product != nil {
+		snapshot := product.ToResponse()
+		event.Product = &snapshot
+	}
+
+	// A client that hangs up must not cancel the event of a committed change
+	if err := h.producer.SendMessage(context.WithoutCancel(ctx), fmt.Sprintf("product:%d", id), event); err != nil {
+		logging.FromContext(ctx).Warn("failed to publish product change event", "product_id", id, "event", eventType, "error", err)
+	}
+}
+
+// ServeHTTP implements http.Handler interface
+func (h *ProductHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
 }
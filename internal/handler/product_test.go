@@ -4,12 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/Napat/golang-testcontainers-demo/internal/handler"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_product"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -31,17 +36,25 @@ func (m *MockProductRepo) GetByID(ctx context.Context, id int64) (*model.Product
 	return args.Get(0).(*model.Product), args.Error(1)
 }
 
-func (m *MockProductRepo) List(ctx context.Context) ([]*model.Product, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*model.Product), args.Error(1)
+func (m *MockProductRepo) List(ctx context.Context, page pagination.Request, filter model.ProductFilter) (*pagination.Page[*model.Product], error) {
+	args := m.Called(ctx, page, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pagination.Page[*model.Product]), args.Error(1)
 }
 
-func (m *MockProductRepo) GetAll(ctx context.Context) ([]*model.Product, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*model.Product), args.Error(1)
+func (m *MockProductRepo) Delete(ctx context.Context, id int64, version int) error {
+	args := m.Called(ctx, id, version)
+	return args.Error(0)
+}
+
+func (m *MockProductRepo) Restore(ctx context.Context, id int64) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
 }
 
-func (m *MockProductRepo) Delete(ctx context.Context, id int64) error {
+func (m *MockProductRepo) Purge(ctx context.Context, id int64) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
 }
@@ -54,15 +67,16 @@ func (m *MockProductRepo) Update(ctx context.Context, product *model.Product) er
 func TestProductHandler_CreateProduct(t *testing.T) {
 	tests := []struct {
 		name           string
-		input          model.Product
+		input          model.ProductCreate
 		expectedStatus int
 		mockError      error
 	}{
 		{
 			name: "success",
-			input: model.Product{
+			input: model.ProductCreate{
 				Name:        "Test Product",
 				Price:       9.99,
+				SKU:         "TEST-001",
 				Description: "Product description 1",
 			},
 			expectedStatus: http.StatusCreated,
@@ -70,21 +84,36 @@ func TestProductHandler_CreateProduct(t *testing.T) {
 		},
 		{
 			name: "repository error",
-			input: model.Product{
+			input: model.ProductCreate{
 				Name:  "Test Product",
 				Price: 9.99,
+				SKU:   "TEST-002",
 			},
 			expectedStatus: http.StatusInternalServerError,
 			mockError:      assert.AnError,
 		},
+		{
+			name: "validation error",
+			input: model.ProductCreate{
+				Price: -1,
+				Stock: -5,
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := new(MockProductRepo)
-			mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Product")).Return(tt.mockError)
+			if tt.expectedStatus != http.StatusUnprocessableEntity {
+				mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Product")).Return(tt.mockError)
+			}
+			mockProducer := new(MockProducerRepo)
+			if tt.expectedStatus == http.StatusCreated {
+				expectProductEvent(mockProducer, model.ProductEventCreated, 0)
+			}
 
-			handler := handler.NewProductHandler(mockRepo)
+			handler := handler.NewProductHandler(mockRepo, mockProducer)
 			routes := handler.GetRoutes()
 
 			// Find the create product route
@@ -108,8 +137,257 @@ func TestProductHandler_CreateProduct(t *testing.T) {
 
 			assert.Equal(t, tt.expectedStatus, rec.Code)
 			mockRepo.AssertExpectations(t)
+			mockProducer.AssertExpectations(t)
 		})
 	}
 }
 
+// expectProductEvent expects one change event of eventType for the product
+// with id, carrying the product unless it was deleted
+func expectProductEvent(producer *MockProducerRepo, eventType string, id int64) {
+	producer.On("SendMessage", mock.Anything, fmt.Sprintf("product:%d", id), mock.MatchedBy(func(event model.ProductChangedEvent) bool {
+		return event.Type == eventType && event.ProductID == id && (event.Product != nil) == (eventType != model.ProductEventDeleted)
+	})).Return(nil).Once()
+}
+
 // Additional tests for GetProduct and ListProducts would follow the same pattern...
+
+func TestProductHandler_GetProductByID(t *testing.T) {
+	tests := []struct {
+		name           string
+		repoError      error
+		expectedStatus int
+	}{
+		{"found", nil, http.StatusOK},
+		{"not found", repository_product.ErrProductNotFound, http.StatusNotFound},
+		{"database down", errors.NewUnavailable("ProductRepository.GetByID", assert.AnError), http.StatusServiceUnavailable},
+		{"unexpected failure", assert.AnError, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockProductRepo)
+			var product *model.Product
+			if tt.repoError == nil {
+				product = &model.Product{BaseModel: model.BaseModel{ID: 7, Version: 1}, Name: "Test Product"}
+			}
+			mockRepo.On("GetByID", mock.Anything, int64(7)).Return(product, tt.repoError)
+
+			h := handler.NewProductHandler(mockRepo, new(MockProducerRepo))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/products/7", nil))
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedStatus >= http.StatusInternalServerError {
+				assert.NotContains(t, rec.Body.String(), assert.AnError.Error())
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProductHandler_ConditionalWrites(t *testing.T) {
+	stored := func() *model.Product {
+		return &model.Product{
+			BaseModel: model.BaseModel{ID: 7, Version: 2},
+			Name:      "Test Product",
+			Price:     9.99,
+			SKU:       "TEST-007",
+			Stock:     10,
+		}
+	}
+	update := model.ProductUpdate{Name: "Renamed", Price: 12.5, SKU: "TEST-007", Stock: 8}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		ifMatch        string
+		body           interface{}
+		lookupError    error
+		writeError     error
+		expectWrite    bool
+		expectedStatus int
+		expectedETag   string
+	}{
+		{
+			name:           "get returns ETag",
+			method:         http.MethodGet,
+			path:           "/products/7",
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"2"`,
+		},
+		{
+			name:           "get unknown product",
+			method:         http.MethodGet,
+			path:           "/products/7",
+			lookupError:    repository_product.ErrProductNotFound,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "get invalid id",
+			method:         http.MethodGet,
+			path:           "/products/abc",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "put with current ETag",
+			method:         http.MethodPut,
+			path:           "/products/7",
+			ifMatch:        `"2"`,
+			body:           update,
+			expectWrite:    true,
+			expectedStatus: http.StatusOK,
+			expectedETag:   `"2"`,
+		},
+		{
+			name:           "put without If-Match",
+			method:         http.MethodPut,
+			path:           "/products/7",
+			body:           update,
+			expectedStatus: http.StatusPreconditionRequired,
+		},
+		{
+			name:           "put with stale ETag",
+			method:         http.MethodPut,
+			path:           "/products/7",
+			ifMatch:        `"1"`,
+			body:           update,
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "put invalid product",
+			method:         http.MethodPut,
+			path:           "/products/7",
+			ifMatch:        `"2"`,
+			body:           model.ProductUpdate{Name: "Renamed", Price: -1, SKU: "TEST-007"},
+			expectedStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:           "put loses race",
+			method:         http.MethodPut,
+			path:           "/products/7",
+			ifMatch:        `"2"`,
+			body:           update,
+			writeError:     &repository.VersionConflictError{Entity: "product", ID: 7, Expected: 2, Current: 3},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "delete with current ETag",
+			method:         http.MethodDelete,
+			path:           "/products/7",
+			ifMatch:        `"2"`,
+			expectWrite:    true,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:           "delete with wildcard loses race",
+			method:         http.MethodDelete,
+			path:           "/products/7",
+			ifMatch:        "*",
+			writeError:     &repository.VersionConflictError{Entity: "product", ID: 7, Expected: 2, Current: 3},
+			expectedStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockProductRepo)
+			// Invalid bodies are rejected before the product is loaded
+			if tt.path == "/products/7" && tt.expectedStatus != http.StatusUnprocessableEntity {
+				if tt.lookupError != nil {
+					mockRepo.On("GetByID", mock.Anything, int64(7)).Return(nil, tt.lookupError)
+				} else {
+					mockRepo.On("GetByID", mock.Anything, int64(7)).Return(stored(), nil)
+				}
+			}
+			mockProducer := new(MockProducerRepo)
+			if tt.expectWrite || tt.writeError != nil {
+				switch tt.method {
+				case http.MethodPut:
+					mockRepo.On("Update", mock.Anything, mock.MatchedBy(func(p *model.Product) bool {
+						return p.Name == update.Name && p.Version == 2
+					})).Return(tt.writeError)
+					if tt.writeError == nil {
+						expectProductEvent(mockProducer, model.ProductEventUpdated, 7)
+					}
+				case http.MethodDelete:
+					mockRepo.On("Delete", mock.Anything, int64(7), 2).Return(tt.writeError)
+					if tt.writeError == nil {
+						expectProductEvent(mockProducer, model.ProductEventDeleted, 7)
+					}
+				}
+			}
+
+			h := handler.NewProductHandler(mockRepo, mockProducer)
+
+			var body bytes.Buffer
+			if tt.body != nil {
+				json.NewEncoder(&body).Encode(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, &body)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			assert.Equal(t, tt.expectedETag, rec.Header().Get("ETag"))
+			mockRepo.AssertExpectations(t)
+			mockProducer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestProductHandler_Admin(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		writeError     error
+		expectedStatus int
+	}{
+		{"restore deleted product", http.MethodPost, "/admin/products/7/restore", nil, http.StatusOK},
+		{"restore live product", http.MethodPost, "/admin/products/7/restore", repository.ErrNotDeleted, http.StatusConflict},
+		{"restore invalid id", http.MethodPost, "/admin/products/abc/restore", nil, http.StatusBadRequest},
+		{"purge deleted product", http.MethodDelete, "/admin/products/7", nil, http.StatusNoContent},
+		{"purge live product", http.MethodDelete, "/admin/products/7", repository.ErrNotDeleted, http.StatusConflict},
+		{"purge unknown product", http.MethodDelete, "/admin/products/7", repository_product.ErrProductNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockProductRepo)
+			mockProducer := new(MockProducerRepo)
+			if tt.expectedStatus != http.StatusBadRequest {
+				switch tt.method {
+				case http.MethodPost:
+					mockRepo.On("Restore", mock.Anything, int64(7)).Return(tt.writeError)
+					if tt.writeError == nil {
+						mockRepo.On("GetByID", mock.Anything, int64(7)).Return(&model.Product{BaseModel: model.BaseModel{ID: 7, Version: 4}, Name: "Test Product"}, nil)
+						expectProductEvent(mockProducer, model.ProductEventRestored, 7)
+					}
+				case http.MethodDelete:
+					mockRepo.On("Purge", mock.Anything, int64(7)).Return(tt.writeError)
+				}
+			}
+
+			h := handler.NewProductHandler(mockRepo, mockProducer)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if rec.Code == http.StatusOK {
+				assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+			}
+			mockRepo.AssertExpectations(t)
+			mockProducer.AssertExpectations(t)
+		})
+	}
+}
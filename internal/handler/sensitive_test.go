@@ -0,0 +1,183 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/handler"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/projection"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// sensitiveFields lists the JSON names of every model field tagged
+// sensitive:"true". None of them may appear in a response, a cached value or
+// a published event.
+var sensitiveFields = projection.Sensitive(
+	model.User{}, model.Product{}, model.Order{}, model.Item{},
+	model.WebhookSubscription{}, model.WebhookDelivery{},
+)
+
+// assertNoSensitiveFields fails when a JSON document contains a sensitive
+// field at any depth other than the allowed paths, such as "$.secret"
+func assertNoSensitiveFields(t *testing.T, data []byte, allowed ...string) {
+	t.Helper()
+	var doc interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	var walk func(path string, v interface{})
+	walk = func(path string, v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			for key, value := range v {
+				for _, name := range sensitiveFields {
+					if key == name && !slices.Contains(allowed, path+"."+key) {
+						t.Errorf("sensitive field %q serialized at %s", key, path)
+					}
+				}
+				walk(path+"."+key, value)
+			}
+		case []interface{}:
+			for _, item := range v {
+				walk(path+"[]", item)
+			}
+		}
+	}
+	walk("$", doc)
+}
+
+func TestSensitiveFieldsAreTagged(t *testing.T) {
+	assert.Contains(t, sensitiveFields, "password")
+	assert.Contains(t, sensitiveFields, "secret")
+}
+
+func TestUserResponses_NeverExposeSensitiveFields(t *testing.T) {
+	user := &model.User{
+		ID:       uuid.Must(uuid.NewV7()),
+		Username: "testuser",
+		Email:    "test@example.com",
+		FullName: "Test User",
+		Password: "$argon2id$v=19$m=65536,t=3,p=2$c2FsdA$aGFzaA",
+		Status:   model.StatusActive,
+		Version:  3,
+	}
+
+	mockRepo := new(MockUserRepo)
+	mockCache := new(MockCache)
+	mockProducer := new(MockProducerRepo)
+
+	var cached, published [][]byte
+	capture := func(into *[][]byte, arg int) func(mock.Arguments) {
+		return func(args mock.Arguments) {
+			data, err := json.Marshal(args.Get(arg))
+			require.NoError(t, err)
+			*into = append(*into, data)
+		}
+	}
+
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+	mockRepo.On("GetByID", mock.Anything, user.ID).Return(user, nil)
+	mockRepo.On("List", mock.Anything, mock.Anything, mock.Anything).Return(&pagination.Page[*model.User]{Data: []*model.User{user}, Total: 1}, nil)
+	mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+	mockCache.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(assert.AnError)
+	mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(capture(&cached, 2)).Return(nil)
+	mockCache.On("Delete", mock.Anything, mock.Anything).Return(nil)
+	mockProducer.On("SendMessage", mock.Anything, mock.Anything, mock.Anything).Run(capture(&published, 2)).Return(nil)
+
+	h := handler.NewUserHandler(mockRepo, mockCache, mockProducer)
+	id := user.ID.String()
+
+	requests := []struct {
+		method, path, ifMatch string
+		body                  interface{}
+		expectedCode          int
+	}{
+		{http.MethodPost, "/users", "", model.UserCreate{Username: "newuser", Email: "new@example.com", FullName: "New User", Password: "password123"}, http.StatusCreated},
+		{http.MethodGet, "/users/" + id, "", nil, http.StatusOK},
+		{http.MethodGet, "/users", "", nil, http.StatusOK},
+		{http.MethodGet, "/users?fields=id,password", "", nil, http.StatusBadRequest},
+		{http.MethodPatch, "/users/" + id, `"3"`, map[string]string{"full_name": "Renamed"}, http.StatusOK},
+		{http.MethodPost, "/users/" + id + "/status", "", model.UserStatusChange{Status: model.StatusSuspended}, http.StatusOK},
+		{http.MethodGet, "/admin/users", "", nil, http.StatusOK},
+	}
+
+	for _, tt := range requests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			var body bytes.Buffer
+			if tt.body != nil {
+				require.NoError(t, json.NewEncoder(&body).Encode(tt.body))
+			}
+			req := httptest.NewRequest(tt.method, tt.path, &body)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assertNoSensitiveFields(t, rec.Body.Bytes())
+		})
+	}
+
+	require.NotEmpty(t, cached)
+	require.NotEmpty(t, published)
+	for _, data := range append(cached, published...) {
+		assertNoSensitiveFields(t, data)
+	}
+}
+
+func TestWebhookResponses_NeverExposeSensitiveFields(t *testing.T) {
+	subscription := newSubscription()
+	attempt := &model.WebhookAttempt{ID: 1, DeliveryID: 1, SubscriptionID: subscription.ID, EventID: "event-1", Attempt: 1}
+
+	repo := new(MockWebhookRepo)
+	repo.On("Create", mock.Anything, mock.AnythingOfType("*model.WebhookSubscription")).Return(nil)
+	repo.On("GetByID", mock.Anything, subscription.ID).Return(subscription, nil)
+	repo.On("List", mock.Anything, mock.Anything).Return(&pagination.Page[*model.WebhookSubscription]{Data: []*model.WebhookSubscription{subscription}, Total: 1}, nil)
+	repo.On("Update", mock.Anything, mock.AnythingOfType("*model.WebhookSubscription")).Return(nil)
+	repo.On("Attempts", mock.Anything, subscription.ID, mock.Anything).Return(&pagination.Page[*model.WebhookAttempt]{Data: []*model.WebhookAttempt{attempt}, Total: 1}, nil)
+
+	h := handler.NewWebhookHandler(repo)
+
+	requests := []struct {
+		method, path, ifMatch string
+		body                  interface{}
+		expectedCode          int
+		allowed               []string
+	}{
+		// The response to a create is the only one that carries the secret
+		{http.MethodPost, "/webhooks", "", model.WebhookCreate{URL: "https://partner.example.com/hooks", Events: []string{"*"}}, http.StatusCreated, []string{"$.secret"}},
+		{http.MethodGet, "/webhooks", "", nil, http.StatusOK, nil},
+		{http.MethodGet, "/webhooks/7", "", nil, http.StatusOK, nil},
+		{http.MethodPut, "/webhooks/7", `"3"`, model.WebhookUpdate{URL: "https://partner.example.com/hooks", Events: []string{"*"}, Active: true}, http.StatusOK, nil},
+		{http.MethodGet, "/webhooks/7/attempts", "", nil, http.StatusOK, nil},
+	}
+
+	for _, tt := range requests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			var body bytes.Buffer
+			if tt.body != nil {
+				require.NoError(t, json.NewEncoder(&body).Encode(tt.body))
+			}
+			req := httptest.NewRequest(tt.method, tt.path, &body)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code, rec.Body.String())
+			assertNoSensitiveFields(t, rec.Body.Bytes(), tt.allowed...)
+		})
+	}
+}
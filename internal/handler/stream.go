@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/stream"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
+)
+
+// streamRetry is the reconnection delay, in milliseconds, suggested to
+// event stream clients
+const streamRetry = 3000
+
+type StreamHandler struct {
+	broker  *stream.Broker
+	metrics *metrics.StreamMetrics
+	routes  []routes.Route
+	mux     http.Handler
+}
+
+// NewStreamHandler serves the events of broker. A nil broker, when the stream
+// is disabled, answers 503.
+func NewStreamHandler(broker *stream.Broker) *StreamHandler {
+	h := &StreamHandler{
+		broker:  broker,
+		metrics: metrics.NewStreamMetrics(),
+	}
+
+	h.routes = []routes.Route{
+		{
+			Name:        "events.stream",
+			Method:      http.MethodGet,
+			Pattern:     "/events/stream",
+			Handler:     h.streamEvents,
+			Permissions: []string{authz.EventsRead},
+		},
+	}
+	h.mux = routes.NewHandler(h.routes)
+
+	return h
+}
+
+// GetRoutes returns all routes for this handler
+func (h *StreamHandler) GetRoutes() []routes.Route {
+	return h.routes
+}
+
+// @Summary Stream domain events
+// @Description Push user, product and order change events as Server-Sent Events. Each event carries the CloudEvent id as its SSE id and the event type as its SSE event, its data is the CloudEvent as JSON. A client that reconnects with Last-Event-ID first gets the buffered events it missed; when that event is no longer buffered it gets a "reset" event and should reload its data. Idle connections get a comment as heartbeat. The stream requires the Authorization header, which the browser EventSource cannot send, so browsers need a fetch based client.
+// @Tags events
+// @Produce text/event-stream
+// @Param types query string false "Comma separated event filters: event types, <prefix>.* or *" example(order.*,product.created)
+// @Param Last-Event-ID header string false "Id of the last event received, to resume after it"
+// @Param last_event_id query string false "Same as Last-Event-ID, used when the header is absent, e.g. to resume from an id kept across page loads"
+// @Success 200 {string} string "Event stream"
+// @Failure 422 {object} response.Problem "Unknown event filter"
+// @Failure 503 {object} response.Problem "Event stream disabled or shutting down"
+// @Security BearerAuth
+// @Router /api/v1/events/stream [get]
+func (h *StreamHandler) streamEvents(w http.ResponseWriter, r *http.Request) {
+	if h.broker == nil {
+		response.RespondWithError(w, r, http.StatusServiceUnavailable, "event stream is disabled")
+		return
+	}
+
+	var filters []string
+	var errs validate.Errors
+	for _, filter := range strings.Split(r.URL.Query().Get("types"), ",") {
+		filter = strings.TrimSpace(filter)
+		if filter == "" {
+			continue
+		}
+		if !model.ValidEventFilter(filter) {
+			errs.Add("types", "unknown event filter: "+filter)
+		}
+		filters = append(filters, filter)
+	}
+	if err := errs.Err(); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	// The header is sent by clients when they reconnect by themselves, the
+	// query lets a new connection resume from an id the client kept
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+
+	sub, err := h.broker.Subscribe(filters, lastEventID)
+	if err != nil {
+		response.RespondWithError(w, r, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer h.broker.Unsubscribe(sub)
+
+	config := h.broker.Config()
+	logger := logging.FromContext(r.Context())
+	rc := http.NewResponseController(w)
+
+	// The connection outlives the server write timeout, every write gets its
+	// own deadline instead so that a stalled client is let go
+	write := func(frame string) error {
+		if err := rc.SetWriteDeadline(time.Now().Add(config.WriteTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			return err
+		}
+		if _, err := io.WriteString(w, frame); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := write(fmt.Sprintf("retry: %d\n\n", streamRetry)); err != nil {
+		logger.Warn("event stream not supported by connection", "error", err)
+		return
+	}
+	if lastEventID != "" && !sub.Resumed {
+		if err := write("event: reset\ndata: {}\n\n"); err != nil {
+			return
+		}
+	}
+	for _, e := range sub.Replay {
+		if err := h.writeEvent(write, e); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(config.Heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if err := write(": heartbeat\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				if errors.Is(sub.Err(), stream.ErrSlowConsumer) {
+					logger.Warn("event stream client too slow, closing connection")
+				}
+				return
+			}
+			if err := h.writeEvent(write, e); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes e as an SSE event whose data is the envelope as JSON
+func (h *StreamHandler) writeEvent(write func(string) error, e *event.Envelope) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := write("id: " + e.ID + "\nevent: " + e.Type + "\ndata: " + string(data) + "\n\n"); err != nil {
+		return err
+	}
+	h.metrics.EventsSent.WithLabelValues(e.Type).Inc()
+	return nil
+}
+
+// ServeHTTP implements http.Handler interface
+func (h *StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
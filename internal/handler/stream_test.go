@@ -0,0 +1,192 @@
+package handler_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/handler"
+	"github.com/Napat/golang-testcontainers-demo/internal/stream"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseFrame is one event of a stream, comments and retry hints excluded
+type sseFrame struct {
+	id, event, data string
+}
+
+// openStream connects to the stream of server and returns a function reading
+// the next event, or ok false once the stream ended
+func openStream(t *testing.T, server *httptest.Server, query, lastEventID string) (next func() (sseFrame, bool), lines chan string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/events/stream"+query, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := server.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines = make(chan string, 64)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	// The retry hint is written once the subscription exists
+	select {
+	case line := <-lines:
+		require.Equal(t, "retry: 3000", line)
+		<-lines
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream did not start")
+	}
+
+	next = func() (sseFrame, bool) {
+		var frame sseFrame
+		for {
+			select {
+			case line, ok := <-lines:
+				if !ok {
+					return frame, false
+				}
+				switch {
+				case line == "" && frame.event != "":
+					return frame, true
+				case strings.HasPrefix(line, "id: "):
+					frame.id = strings.TrimPrefix(line, "id: ")
+				case strings.HasPrefix(line, "event: "):
+					frame.event = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					frame.data = strings.TrimPrefix(line, "data: ")
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("no event received")
+			}
+		}
+	}
+	return next, lines
+}
+
+// newStreamServer serves a new broker. Cleanup closes the broker before the
+// server, which waits for the open streams to end.
+func newStreamServer(t *testing.T, config stream.Config) (*stream.Broker, *httptest.Server) {
+	broker := stream.NewBroker(config)
+	server := httptest.NewServer(handler.NewStreamHandler(broker))
+	t.Cleanup(server.Close)
+	t.Cleanup(broker.Close)
+	return broker, server
+}
+
+func publish(broker *stream.Broker, id, eventType string) {
+	broker.Publish(&event.Envelope{ID: id, Type: eventType, Data: json.RawMessage(`{"id":1}`)})
+}
+
+func TestStreamHandler(t *testing.T) {
+	t.Run("live events matching the filter", func(t *testing.T) {
+		broker, server := newStreamServer(t, stream.Config{})
+
+		next, _ := openStream(t, server, "?types=order.*,product.created", "")
+		publish(broker, "1", model.UserEventCreated)
+		publish(broker, "2", model.OrderEventCreated)
+		publish(broker, "3", model.ProductEventCreated)
+
+		frame, ok := next()
+		require.True(t, ok)
+		assert.Equal(t, "2", frame.id)
+		assert.Equal(t, model.OrderEventCreated, frame.event)
+		var e event.Envelope
+		require.NoError(t, json.Unmarshal([]byte(frame.data), &e))
+		assert.Equal(t, "2", e.ID)
+		assert.JSONEq(t, `{"id":1}`, string(e.Data))
+
+		frame, ok = next()
+		require.True(t, ok)
+		assert.Equal(t, "3", frame.id)
+	})
+
+	t.Run("resume from the replay buffer", func(t *testing.T) {
+		broker, server := newStreamServer(t, stream.Config{})
+
+		publish(broker, "1", model.UserEventCreated)
+		publish(broker, "2", model.UserEventUpdated)
+		publish(broker, "3", model.UserEventDeleted)
+
+		next, _ := openStream(t, server, "", "1")
+		for _, want := range []string{"2", "3"} {
+			frame, ok := next()
+			require.True(t, ok)
+			assert.Equal(t, want, frame.id)
+		}
+	})
+
+	t.Run("reset when the last event is not buffered", func(t *testing.T) {
+		_, server := newStreamServer(t, stream.Config{})
+
+		next, _ := openStream(t, server, "?last_event_id=gone", "")
+		frame, ok := next()
+		require.True(t, ok)
+		assert.Equal(t, "reset", frame.event)
+		assert.Empty(t, frame.id)
+	})
+
+	t.Run("heartbeat", func(t *testing.T) {
+		_, server := newStreamServer(t, stream.Config{Heartbeat: 10 * time.Millisecond})
+
+		_, lines := openStream(t, server, "", "")
+		select {
+		case line := <-lines:
+			assert.Equal(t, ": heartbeat", line)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no heartbeat")
+		}
+	})
+
+	t.Run("closing the broker ends the stream", func(t *testing.T) {
+		broker, server := newStreamServer(t, stream.Config{})
+
+		next, _ := openStream(t, server, "", "")
+		broker.Close()
+
+		_, ok := next()
+		assert.False(t, ok)
+	})
+
+	t.Run("rejected requests", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			broker         *stream.Broker
+			path           string
+			expectedStatus int
+		}{
+			{"unknown filter", stream.NewBroker(stream.Config{}), "/events/stream?types=invoice.*", http.StatusUnprocessableEntity},
+			{"disabled", nil, "/events/stream", http.StatusServiceUnavailable},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				rec := httptest.NewRecorder()
+				handler.NewStreamHandler(tt.broker).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+				assert.Equal(t, tt.expectedStatus, rec.Code)
+			})
+		}
+
+		closed := stream.NewBroker(stream.Config{})
+		closed.Close()
+		rec := httptest.NewRecorder()
+		handler.NewStreamHandler(closed).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events/stream", nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	})
+}
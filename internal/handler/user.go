@@ -2,29 +2,36 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"strings"
 	"time"
 
-	repository_user "github.com/Napat/golang-testcontainers-demo/internal/repository/repository_user"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/etag"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/projection"
 	"github.com/Napat/golang-testcontainers-demo/pkg/response"
 	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
 	"github.com/google/uuid"
 )
 
 type UserRepository interface {
 	Create(ctx context.Context, user *model.User) error
 	GetByID(ctx context.Context, id uuid.UUID) (*model.User, error)
-	GetAll(ctx context.Context) ([]*model.User, error)
+	List(ctx context.Context, page pagination.Request, filter model.UserFilter) (*pagination.Page[*model.User], error)
+	Update(ctx context.Context, user *model.User) error
+	Restore(ctx context.Context, id uuid.UUID) error
+	Purge(ctx context.Context, id uuid.UUID) error
 }
 
 type CacheRepository interface {
 	Get(ctx context.Context, key string, value interface{}) error
 	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Delete(ctx context.Context, key string) error
 }
 
 type UserHandler struct {
@@ -32,6 +39,7 @@ type UserHandler struct {
 	cache    CacheRepository
 	producer MessageProducer
 	routes   []routes.Route
+	mux      http.Handler
 }
 
 func NewUserHandler(userRepo UserRepository, cache CacheRepository, producer MessageProducer) *UserHandler {
@@ -43,21 +51,77 @@ func NewUserHandler(userRepo UserRepository, cache CacheRepository, producer Mes
 
 	h.routes = []routes.Route{
 		{
-			Method:  http.MethodGet,
-			Pattern: "/users",
-			Handler: h.getAllUsers,
+			Name:        "users.list",
+			Method:      http.MethodGet,
+			Pattern:     "/users",
+			Handler:     h.getAllUsers,
+			Permissions: []string{authz.UsersRead},
 		},
 		{
+			Name:    "users.create",
 			Method:  http.MethodPost,
 			Pattern: "/users",
 			Handler: h.createUser,
+			Public:  true,
+		},
+		{
+			Name:        "users.get",
+			Method:      http.MethodGet,
+			Pattern:     "/users/{id}",
+			Handler:     h.getUserByID,
+			Permissions: []string{authz.UsersRead},
+		},
+		{
+			Name:        "users.update",
+			Method:      http.MethodPut,
+			Pattern:     "/users/{id}",
+			Handler:     h.updateUser,
+			Permissions: []string{authz.UsersWrite},
+		},
+		{
+			Name:        "users.patch",
+			Method:      http.MethodPatch,
+			Pattern:     "/users/{id}",
+			Handler:     h.patchUser,
+			Permissions: []string{authz.UsersWrite},
+		},
+		{
+			Name:        "users.delete",
+			Method:      http.MethodDelete,
+			Pattern:     "/users/{id}",
+			Handler:     h.deleteUser,
+			Permissions: []string{authz.UsersWrite},
 		},
 		{
-			Method:  http.MethodGet,
-			Pattern: "/users/",
-			Handler: h.getUserByID,
+			Name:        "users.change_status",
+			Method:      http.MethodPost,
+			Pattern:     "/users/{id}/status",
+			Handler:     h.changeUserStatus,
+			Permissions: []string{authz.UsersWrite},
+		},
+		{
+			Name:        "admin.users.list",
+			Method:      http.MethodGet,
+			Pattern:     "/admin/users",
+			Handler:     h.listDeletedUsers,
+			Permissions: []string{authz.UsersAdmin},
+		},
+		{
+			Name:        "admin.users.restore",
+			Method:      http.MethodPost,
+			Pattern:     "/admin/users/{id}/restore",
+			Handler:     h.restoreUser,
+			Permissions: []string{authz.UsersAdmin},
+		},
+		{
+			Name:        "admin.users.purge",
+			Method:      http.MethodDelete,
+			Pattern:     "/admin/users/{id}",
+			Handler:     h.purgeUser,
+			Permissions: []string{authz.UsersAdmin},
 		},
 	}
+	h.mux = routes.NewHandler(h.routes)
 
 	return h
 }
@@ -73,40 +137,44 @@ func (h *UserHandler) GetRoutes() []routes.Route {
 // @Accept json
 // @Produce json
 // @Param user body model.UserCreate true "User creation request"
-// @Success 201 {object} model.User
-// @Failure 400 {object} map[string]string "Error response"
-// @Failure 500 {object} map[string]string "Error response"
+// @Success 201 {object} model.UserResponse
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 409 {object} response.Problem "Username or email already taken"
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Failure 500 {object} response.Problem "Error response"
+// @Failure 503 {object} response.Problem "Database unavailable"
 // @Router /api/v1/users [post]
 func (h *UserHandler) createUser(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	var user model.User
+	var req model.UserCreate
 
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		response.RespondWithError(w, http.StatusBadRequest, "Invalid request payload", "createUser")
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
 
-	user.ID = uuid.Must(uuid.NewV7())
-	log.Printf("Generated UUIDv7 for new user: %s", user.ID)
+	user, err := req.User()
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	logging.FromContext(ctx).Debug("generated user id", "user_id", user.ID)
 
-	if err := h.userRepo.Create(ctx, &user); err != nil {
-		log.Printf("Error creating user: %v", err)
-		response.RespondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to create user: %v", err), "createUser")
+	if err := h.userRepo.Create(ctx, user); err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
 
+	public := user.ToResponse()
 	cacheKey := fmt.Sprintf("user:%s", user.ID)
-	if err := h.cache.Set(r.Context(), cacheKey, user, time.Hour); err != nil {
-		log.Printf("Failed to cache user: %v", err)
+	if err := h.cache.Set(r.Context(), cacheKey, public, time.Hour); err != nil {
+		logging.FromContext(ctx).Warn("failed to cache user", "user_id", user.ID, "error", err)
 	}
 
-	if err := h.producer.SendMessage(cacheKey, user); err != nil {
-		log.Printf("Failed to send user to Kafka: %v", err)
-	}
+	// The user.created event was written to the outbox by Create and is
+	// published by the outbox relay
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(user)
+	response.RespondWithJSON(w, http.StatusCreated, public)
 }
 
 // @Summary Get user by ID
@@ -115,85 +183,454 @@ func (h *UserHandler) createUser(w http.ResponseWriter, r *http.Request) {
 // @Accept json
 // @Produce json
 // @Param id path string true "User ID"
-// @Success 200 {object} model.User
-// @Failure 404 {object} map[string]string "Error response"
-// @Failure 500 {object} map[string]string "Error response"
+// @Param fields query string false "Comma separated fields to return, e.g. id,username,email"
+// @Success 200 {object} model.UserResponse
+// @Header 200 {string} ETag "Current version of the user"
+// @Failure 404 {object} response.Problem "Error response"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
 // @Router /api/v1/users/{id} [get]
 func (h *UserHandler) getUserByID(w http.ResponseWriter, r *http.Request) {
-	// Extract ID from path: /users/{id}
-	path := strings.Trim(r.URL.Path, "/")
-	parts := strings.Split(path, "/")
-	if len(parts) != 2 {
-		response.RespondWithError(w, http.StatusBadRequest, "Invalid path", "getUserByID")
+	id, err := routes.UUIDParam(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
 		return
 	}
 
-	id, err := uuid.Parse(parts[1])
-	if err != nil {
-		response.RespondWithError(w, http.StatusBadRequest, "Invalid user ID format", "getUserByID")
+	fields, ok := parseFields(w, r, model.UserResponse{})
+	if !ok {
 		return
 	}
 
 	ctx := r.Context()
 	cacheKey := fmt.Sprintf("user:%s", id)
 
-	var user *model.User
-	err = h.cache.Get(ctx, cacheKey, &user)
-	if err == nil {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(user)
+	var public model.UserResponse
+	if err := h.cache.Get(ctx, cacheKey, &public); err == nil {
+		etag.Set(w, public.Version)
+		response.RespondWithJSON(w, http.StatusOK, projection.Select(public, fields))
 		return
 	}
 
-	user, err = h.userRepo.GetByID(ctx, id)
+	user, err := h.userRepo.GetByID(ctx, id)
 	if err != nil {
-		status := http.StatusInternalServerError
-		if err == repository_user.ErrUserNotFound {
-			status = http.StatusNotFound
-		}
-		response.RespondWithError(w, status, err.Error(), "getUserByID")
+		response.WriteError(w, r, err)
 		return
 	}
 
-	if err := h.cache.Set(ctx, cacheKey, user, time.Hour); err != nil {
-		log.Printf("Failed to cache user: %v", err)
+	public = user.ToResponse()
+	if err := h.cache.Set(ctx, cacheKey, public, time.Hour); err != nil {
+		logging.FromContext(ctx).Warn("failed to cache user", "user_id", user.ID, "error", err)
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	etag.Set(w, public.Version)
+	response.RespondWithJSON(w, http.StatusOK, projection.Select(public, fields))
 }
 
-// @Summary Get all users
-// @Description Get a list of all users in the system
+// @Summary List users
+// @Description List users one page at a time. Pass next_cursor from the previous page as cursor to continue.
 // @Tags users
 // @Accept json
 // @Produce json
-// @Success 200 {array} model.User
-// @Failure 500 {object} map[string]string "Error response"
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "Opaque cursor from the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(id, -id, username, -username, email, -email, created_at, -created_at)
+// @Param status query string false "Filter by status" Enums(active, inactive, suspended, deleted)
+// @Param email_domain query string false "Filter by exact email domain, e.g. example.com"
+// @Param fields query string false "Comma separated fields to return, e.g. id,username,email"
+// @Success 200 {object} pagination.Page[model.UserResponse]
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
 // @Router /api/v1/users [get]
 func (h *UserHandler) getAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.userRepo.GetAll(r.Context())
+	page, ok := parsePage(w, r, model.UserSortFields...)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, model.UserResponse{})
+	if !ok {
+		return
+	}
+
+	filter := model.UserFilter{
+		Status:      model.UserStatus(r.URL.Query().Get("status")),
+		EmailDomain: strings.TrimPrefix(r.URL.Query().Get("email_domain"), "@"),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		response.RespondWithError(w, r, http.StatusBadRequest, fmt.Sprintf("invalid status %q", filter.Status))
+		return
+	}
+
+	users, err := h.userRepo.List(r.Context(), page, filter)
 	if err != nil {
-		response.RespondWithError(w, http.StatusInternalServerError, err.Error(), "getAllUsers")
+		writePageError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	response.RespondWithJSON(w, http.StatusOK, projection.SelectPage(pagination.MapPage(users, (*model.User).ToResponse), fields))
 }
 
-// ServeHTTP implements http.Handler interface
-func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// Remove /api/v1 prefix if present for both test and production compatibility
-	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+// @Summary Update a user
+// @Description Replace the email and full name of a user
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param If-Match header string true "ETag of the version being modified"
+// @Param user body model.UserUpdate true "User update request"
+// @Success 200 {object} model.UserResponse
+// @Header 200 {string} ETag "Current version of the user"
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 404 {object} response.Problem "Error response"
+// @Failure 409 {object} response.Problem "Error response"
+// @Failure 412 {object} response.Problem "Error response"
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Failure 428 {object} response.Problem "Error response"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
+// @Router /api/v1/users/{id} [put]
+func (h *UserHandler) updateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.UUIDParam(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
 
-	// Find matching route
-	for _, route := range h.routes {
-		if strings.TrimSuffix(path, "/") == strings.TrimSuffix(route.Pattern, "/") && r.Method == route.Method {
-			route.Handler(w, r)
+	var req model.UserUpdate
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	user, ok := h.loadMutableUser(w, r, id)
+	if !ok || !checkPrecondition(w, r, user.Version) {
+		return
+	}
+
+	previous := user.Status
+	user.Email = req.Email
+	user.FullName = req.FullName
+	if req.Status != "" {
+		if err := user.TransitionTo(model.UserStatus(req.Status)); err != nil {
+			response.RespondWithError(w, r, http.StatusConflict, err.Error())
 			return
 		}
 	}
 
-	http.NotFound(w, r)
+	eventType := model.UserEventUpdated
+	if model.UserStatus(req.Status) == model.StatusDeleted {
+		eventType = model.UserEventDeleted
+	}
+	h.saveUser(w, r, user, eventType, previous, "")
+}
+
+// @Summary Partially update a user
+// @Description Update only the fields present in the request body
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param If-Match header string true "ETag of the version being modified"
+// @Param user body model.UserPatch true "User patch request"
+// @Success 200 {object} model.UserResponse
+// @Header 200 {string} ETag "Current version of the user"
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 404 {object} response.Problem "Error response"
+// @Failure 409 {object} response.Problem "Error response"
+// @Failure 412 {object} response.Problem "Error response"
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Failure 428 {object} response.Problem "Error response"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
+// @Router /api/v1/users/{id} [patch]
+func (h *UserHandler) patchUser(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.UUIDParam(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	var req model.UserPatch
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	user, ok := h.loadMutableUser(w, r, id)
+	if !ok || !checkPrecondition(w, r, user.Version) {
+		return
+	}
+
+	if req.Email != nil {
+		user.Email = *req.Email
+	}
+	if req.FullName != nil {
+		user.FullName = *req.FullName
+	}
+
+	h.saveUser(w, r, user, model.UserEventUpdated, user.Status, "")
+}
+
+// @Summary Delete a user
+// @Description Soft delete a user. Deleted users disappear from every endpoint except the admin ones and can be restored by an administrator until the retention window purges them.
+// @Tags users
+// @Param id path string true "User ID"
+// @Param If-Match header string true "ETag of the version being modified"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 404 {object} response.Problem "Error response"
+// @Failure 412 {object} response.Problem "Error response"
+// @Failure 428 {object} response.Problem "Error response"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
+// @Router /api/v1/users/{id} [delete]
+func (h *UserHandler) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.UUIDParam(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.userRepo.GetByID(ctx, id)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	if !checkPrecondition(w, r, user.Version) {
+		return
+	}
+
+	previous := user.Status
+	if err := user.TransitionTo(model.StatusDeleted); err != nil {
+		response.RespondWithError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+
+	if err := h.userRepo.Update(ctx, user); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	h.publishChange(ctx, model.UserEventDeleted, user, previous, "")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary Change user status
+// @Description Move a user to another status. Allowed transitions: active, inactive and suspended can move between each other or to deleted. Moving to deleted soft deletes the user.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param If-Match header string false "ETag of the version being modified"
+// @Param status body model.UserStatusChange true "Status change request"
+// @Success 200 {object} model.UserResponse
+// @Header 200 {string} ETag "Current version of the user"
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 404 {object} response.Problem "Error response"
+// @Failure 409 {object} response.Problem "Error response"
+// @Failure 412 {object} response.Problem "Error response"
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
+// @Router /api/v1/users/{id}/status [post]
+func (h *UserHandler) changeUserStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.UUIDParam(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	var req model.UserStatusChange
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	user, err := h.userRepo.GetByID(ctx, id)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	// If-Match is optional here: a status change does not overwrite other fields
+	if r.Header.Get("If-Match") != "" && !checkPrecondition(w, r, user.Version) {
+		return
+	}
+
+	previous := user.Status
+	if err := user.TransitionTo(req.Status); err != nil {
+		response.RespondWithError(w, r, http.StatusConflict, err.Error())
+		return
+	}
+	if user.Status == previous {
+		etag.Set(w, user.Version)
+		response.RespondWithJSON(w, http.StatusOK, user.ToResponse())
+		return
+	}
+
+	eventType := model.UserEventStatusChanged
+	if req.Status == model.StatusDeleted {
+		eventType = model.UserEventDeleted
+	}
+	h.saveUser(w, r, user, eventType, previous, req.Reason)
+}
+
+// @Summary List deleted users
+// @Description List soft deleted users one page at a time
+// @Tags admin
+// @Produce json
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "Opaque cursor from the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(id, -id, username, -username, email, -email, created_at, -created_at)
+// @Param fields query string false "Comma separated fields to return, e.g. id,username,email"
+// @Success 200 {object} pagination.Page[model.UserResponse]
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
+// @Router /api/v1/admin/users [get]
+func (h *UserHandler) listDeletedUsers(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePage(w, r, model.UserSortFields...)
+	if !ok {
+		return
+	}
+	fields, ok := parseFields(w, r, model.UserResponse{})
+	if !ok {
+		return
+	}
+
+	users, err := h.userRepo.List(r.Context(), page, model.UserFilter{Deleted: true})
+	if err != nil {
+		writePageError(w, r, err)
+		return
+	}
+
+	response.RespondWithJSON(w, http.StatusOK, projection.SelectPage(pagination.MapPage(users, (*model.User).ToResponse), fields))
+}
+
+// @Summary Restore a deleted user
+// @Description Bring a soft deleted user back. The user is restored as inactive and has to be reactivated explicitly.
+// @Tags admin
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} model.UserResponse
+// @Header 200 {string} ETag "Current version of the user"
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 404 {object} response.Problem "Error response"
+// @Failure 409 {object} response.Problem "User is not deleted"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id}/restore [post]
+func (h *UserHandler) restoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.UUIDParam(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.userRepo.Restore(ctx, id); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	user, err := h.userRepo.GetByID(ctx, id)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	h.publishChange(ctx, model.UserEventRestored, user, model.StatusDeleted, "")
+
+	etag.Set(w, user.Version)
+	response.RespondWithJSON(w, http.StatusOK, user.ToResponse())
+}
+
+// @Summary Purge a deleted user
+// @Description Permanently remove a soft deleted user and its role assignments. Live users must be deleted first.
+// @Tags admin
+// @Param id path string true "User ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Problem "Error response"
+// @Failure 404 {object} response.Problem "Error response"
+// @Failure 409 {object} response.Problem "User is not deleted"
+// @Failure 500 {object} response.Problem "Error response"
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id} [delete]
+func (h *UserHandler) purgeUser(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.UUIDParam(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.userRepo.Purge(r.Context(), id); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadMutableUser loads a user that is about to be modified. GetByID does not
+// return deleted users, so modifying one answers 404.
+func (h *UserHandler) loadMutableUser(w http.ResponseWriter, r *http.Request, id uuid.UUID) (*model.User, bool) {
+	user, err := h.userRepo.GetByID(r.Context(), id)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// saveUser validates and stores a modified user, then publishes eventType
+func (h *UserHandler) saveUser(w http.ResponseWriter, r *http.Request, user *model.User, eventType string, previous model.UserStatus, reason string) {
+	if err := user.Validate(); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	if err := h.userRepo.Update(ctx, user); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	h.publishChange(ctx, eventType, user, previous, reason)
+
+	etag.Set(w, user.Version)
+	response.RespondWithJSON(w, http.StatusOK, user.ToResponse())
+}
+
+// publishChange invalidates the cached copy of the user and publishes a
+// change event. Both are best effort, the change is already stored.
+func (h *UserHandler) publishChange(ctx context.Context, eventType string, user *model.User, previous model.UserStatus, reason string) {
+	cacheKey := fmt.Sprintf("user:%s", user.ID)
+	if err := h.cache.Delete(ctx, cacheKey); err != nil {
+		logging.FromContext(ctx).Warn("failed to invalidate cached user", "user_id", user.ID, "error", err)
+	}
+
+	snapshot := user.ToResponse()
+
+	event := model.UserChangedEvent{
+		Type:       eventType,
+		UserID:     user.ID,
+		Status:     user.Status,
+		Reason:     reason,
+		User:       &snapshot,
+		OccurredAt: time.Now().UTC(),
+	}
+	if previous != user.Status {
+		event.Previous = previous
+	}
+
+	// The change is committed, a client that hangs up must not cancel its
+	// event. The producer's publish timeout still bounds the wait.
+	if err := h.producer.SendMessage(context.WithoutCancel(ctx), cacheKey, event); err != nil {
+		logging.FromContext(ctx).Warn("failed to publish user change event", "user_id", user.ID, "event", eventType, "error", err)
+	}
+}
+
+// ServeHTTP implements http.Handler interface
+func (h *UserHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
 }
@@ -4,14 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/Napat/golang-testcontainers-demo/internal/handler"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_user"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -34,9 +37,27 @@ func (m *MockUserRepo) GetByID(ctx context.Context, id uuid.UUID) (*model.User,
 	return args.Get(0).(*model.User), args.Error(1)
 }
 
-func (m *MockUserRepo) GetAll(ctx context.Context) ([]*model.User, error) {
-	args := m.Called(ctx)
-	return args.Get(0).([]*model.User), args.Error(1)
+func (m *MockUserRepo) List(ctx context.Context, page pagination.Request, filter model.UserFilter) (*pagination.Page[*model.User], error) {
+	args := m.Called(ctx, page, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*pagination.Page[*model.User]), args.Error(1)
+}
+
+func (m *MockUserRepo) Update(ctx context.Context, user *model.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) Restore(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepo) Purge(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
 }
 
 type MockCache struct {
@@ -53,12 +74,17 @@ func (m *MockCache) Set(ctx context.Context, key string, value interface{}, expi
 	return args.Error(0)
 }
 
+func (m *MockCache) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
 type MockProducerRepo struct {
 	mock.Mock
 }
 
-func (m *MockProducerRepo) SendMessage(topic string, message interface{}) error {
-	args := m.Called(topic, message)
+func (m *MockProducerRepo) SendMessage(ctx context.Context, topic string, message interface{}) error {
+	args := m.Called(ctx, topic, message)
 	return args.Error(0)
 }
 
@@ -66,10 +92,12 @@ func TestUserHandler_CreateUser(t *testing.T) {
 	tests := []struct {
 		name           string
 		input          model.UserCreate
+		rawBody        string
 		expectedStatus int
+		expectedBody   string
 		mockError      error
+		skipRepo       bool
 		setupCache     bool
-		setupProducer  bool
 	}{
 		{
 			name: "success",
@@ -82,18 +110,19 @@ func TestUserHandler_CreateUser(t *testing.T) {
 			expectedStatus: http.StatusCreated,
 			mockError:      nil,
 			setupCache:     true,
-			setupProducer:  true,
 		},
 		{
 			name: "repository error",
 			input: model.UserCreate{
 				Username: "testuser",
 				Email:    "test@example.com",
+				FullName: "Test User",
+				Password: "password123",
 			},
 			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"internal server error","instance":"/users"}`,
 			mockError:      assert.AnError,
 			setupCache:     false,
-			setupProducer:  false,
 		},
 		{
 			name: "duplicate username error",
@@ -103,10 +132,32 @@ func TestUserHandler_CreateUser(t *testing.T) {
 				FullName: "Test User",
 				Password: "password123",
 			},
-			expectedStatus: http.StatusInternalServerError,
-			mockError:      errors.New("Error 1062 (23000): Duplicate entry 'existinguser' for key 'users.username'"),
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"type":"about:blank","title":"Conflict","status":409,"detail":"user with this username already exists","instance":"/users","errors":[{"field":"username","message":"user with this username already exists"}]}`,
+			mockError:      errors.NewDuplicate("UserRepository.Create", "user", "username", nil),
 			setupCache:     false,
-			setupProducer:  false,
+		},
+		{
+			name: "every invalid field is reported",
+			input: model.UserCreate{
+				Username: "a!",
+				Email:    "not-an-email",
+				Password: "short",
+			},
+			expectedStatus: http.StatusUnprocessableEntity,
+			expectedBody: `{"type":"about:blank","title":"Unprocessable Entity","status":422,"detail":"request validation failed","instance":"/users","errors":[
+				{"field":"email","message":"must be a valid email address"},
+				{"field":"full_name","message":"is required"},
+				{"field":"password","message":"must be at least 8 characters long"},
+				{"field":"username","message":"username must be at least 3 characters long"}]}`,
+			skipRepo: true,
+		},
+		{
+			name:           "server managed fields are rejected",
+			rawBody:        `{"id":"01a1466d-7292-7081-83ef-ebd94dbc80ce","username":"testuser","email":"test@example.com","full_name":"Test User","password":"password123"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"type":"about:blank","title":"Bad Request","status":400,"detail":"Invalid request payload","instance":"/users","errors":[{"field":"id","message":"unknown field"}]}`,
+			skipRepo:       true,
 		},
 	}
 
@@ -116,14 +167,13 @@ func TestUserHandler_CreateUser(t *testing.T) {
 			mockCache := new(MockCache)
 			mockProducer := new(MockProducerRepo)
 
-			mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.User")).Return(tt.mockError)
+			if !tt.skipRepo {
+				mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.User")).Return(tt.mockError)
+			}
 
 			if tt.setupCache {
 				mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 			}
-			if tt.setupProducer {
-				mockProducer.On("SendMessage", mock.Anything, mock.Anything).Return(nil)
-			}
 
 			handler := handler.NewUserHandler(mockRepo, mockCache, mockProducer)
 			routes := handler.GetRoutes()
@@ -142,21 +192,421 @@ func TestUserHandler_CreateUser(t *testing.T) {
 			}
 
 			body, _ := json.Marshal(tt.input)
+			if tt.rawBody != "" {
+				body = []byte(tt.rawBody)
+			}
 			req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBuffer(body))
 			rec := httptest.NewRecorder()
 
 			createUserHandler(rec, req)
 
 			assert.Equal(t, tt.expectedStatus, rec.Code)
+			if tt.expectedBody != "" {
+				assert.JSONEq(t, tt.expectedBody, rec.Body.String())
+			}
 			mockRepo.AssertExpectations(t)
 			if tt.setupCache {
 				mockCache.AssertExpectations(t)
 			}
-			if tt.setupProducer {
-				mockProducer.AssertExpectations(t)
-			}
+			// The created event goes through the outbox written by the repository
+			mockProducer.AssertNotCalled(t, "SendMessage", mock.Anything, mock.Anything, mock.Anything)
 		})
 	}
 }
 
+func TestUserHandler_CreateUser_HashesHashShapedPassword(t *testing.T) {
+	// A caller chosen hash would let the next login run with its parameters
+	submitted := "$argon2id$v=19$m=4294967295,t=4294967295,p=255$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+	mockRepo := new(MockUserRepo)
+	mockCache := new(MockCache)
+	var stored *model.User
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.User")).
+		Run(func(args mock.Arguments) { stored = args.Get(1).(*model.User) }).
+		Return(nil)
+	mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	h := handler.NewUserHandler(mockRepo, mockCache, new(MockProducerRepo))
+	body, _ := json.Marshal(model.UserCreate{Username: "testuser", Email: "test@example.com", FullName: "Test User", Password: submitted})
+	req := httptest.NewRequest(http.MethodPost, "/users", bytes.NewBuffer(body))
+	rec := httptest.NewRecorder()
+	for _, route := range h.GetRoutes() {
+		if route.Method == http.MethodPost && route.Pattern == "/users" {
+			route.Handler(rec, req)
+		}
+	}
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	if assert.NotNil(t, stored) {
+		assert.NotEqual(t, submitted, stored.Password)
+		assert.True(t, stored.CheckPassword(submitted))
+	}
+}
+
 // Additional tests for GetUserByID and GetAllUsers would follow the same pattern...
+
+func TestUserHandler_Lifecycle(t *testing.T) {
+	newUser := func(status model.UserStatus) *model.User {
+		return &model.User{
+			ID:       uuid.Must(uuid.NewV7()),
+			Username: "testuser",
+			Email:    "test@example.com",
+			FullName: "Test User",
+			Password: "$argon2id$hash",
+			Status:   status,
+			Version:  3,
+		}
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		suffix         string
+		body           interface{}
+		ifMatch        string
+		user           *model.User
+		lookupError    error
+		updateError    error
+		expectUpdate   bool
+		expectedEvent  string
+		expectedStatus model.UserStatus
+		expectedCode   int
+	}{
+		{
+			name:          "put replaces fields",
+			ifMatch:       `"3"`,
+			method:        http.MethodPut,
+			body:          model.UserUpdate{Email: "new@example.com", FullName: "New Name"},
+			user:          newUser(model.StatusActive),
+			expectUpdate:  true,
+			expectedEvent: model.UserEventUpdated,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:         "put requires all fields",
+			method:       http.MethodPut,
+			body:         model.UserUpdate{Email: "new@example.com"},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:          "patch updates present fields",
+			ifMatch:       `"3"`,
+			method:        http.MethodPatch,
+			body:          map[string]string{"full_name": "Patched Name"},
+			user:          newUser(model.StatusInactive),
+			expectUpdate:  true,
+			expectedEvent: model.UserEventUpdated,
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:         "patch deleted user",
+			ifMatch:      `"3"`,
+			method:       http.MethodPatch,
+			body:         map[string]string{"full_name": "Patched Name"},
+			lookupError:  repository_user.ErrUserNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "patch unknown user",
+			method:       http.MethodPatch,
+			body:         map[string]string{"full_name": "Patched Name"},
+			lookupError:  repository_user.ErrUserNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:           "delete marks user deleted",
+			ifMatch:        `"3"`,
+			method:         http.MethodDelete,
+			user:           newUser(model.StatusSuspended),
+			expectUpdate:   true,
+			expectedEvent:  model.UserEventDeleted,
+			expectedStatus: model.StatusDeleted,
+			expectedCode:   http.StatusNoContent,
+		},
+		{
+			name:         "delete already deleted user",
+			ifMatch:      `"3"`,
+			method:       http.MethodDelete,
+			lookupError:  repository_user.ErrUserNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "put without If-Match",
+			method:       http.MethodPut,
+			body:         model.UserUpdate{Email: "new@example.com", FullName: "New Name"},
+			user:         newUser(model.StatusActive),
+			expectedCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:         "patch with stale ETag",
+			method:       http.MethodPatch,
+			body:         map[string]string{"full_name": "Patched Name"},
+			ifMatch:      `"2"`,
+			user:         newUser(model.StatusActive),
+			expectedCode: http.StatusPreconditionFailed,
+		},
+		{
+			name:         "delete without If-Match",
+			method:       http.MethodDelete,
+			user:         newUser(model.StatusActive),
+			expectedCode: http.StatusPreconditionRequired,
+		},
+		{
+			name:         "concurrent write wins the race",
+			method:       http.MethodPatch,
+			body:         map[string]string{"full_name": "Patched Name"},
+			ifMatch:      `"3"`,
+			user:         newUser(model.StatusActive),
+			updateError:  &repository.VersionConflictError{Entity: "user", Expected: 3, Current: 4},
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:           "suspend active user",
+			method:         http.MethodPost,
+			suffix:         "/status",
+			body:           model.UserStatusChange{Status: model.StatusSuspended, Reason: "abuse"},
+			user:           newUser(model.StatusActive),
+			expectUpdate:   true,
+			expectedEvent:  model.UserEventStatusChanged,
+			expectedStatus: model.StatusSuspended,
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name:           "status change to deleted",
+			method:         http.MethodPost,
+			suffix:         "/status",
+			body:           model.UserStatusChange{Status: model.StatusDeleted},
+			user:           newUser(model.StatusActive),
+			expectUpdate:   true,
+			expectedEvent:  model.UserEventDeleted,
+			expectedStatus: model.StatusDeleted,
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name:         "deleted user cannot be reactivated",
+			method:       http.MethodPost,
+			suffix:       "/status",
+			body:         model.UserStatusChange{Status: model.StatusActive},
+			lookupError:  repository_user.ErrUserNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+		{
+			name:         "unknown status",
+			method:       http.MethodPost,
+			suffix:       "/status",
+			body:         map[string]string{"status": "archived"},
+			expectedCode: http.StatusUnprocessableEntity,
+		},
+		{
+			name:         "post without status suffix",
+			method:       http.MethodPost,
+			body:         model.UserStatusChange{Status: model.StatusActive},
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepo)
+			mockCache := new(MockCache)
+			mockProducer := new(MockProducerRepo)
+
+			id := uuid.Must(uuid.NewV7())
+			if tt.user != nil {
+				id = tt.user.ID
+			}
+			if tt.user != nil || tt.lookupError != nil {
+				mockRepo.On("GetByID", mock.Anything, id).Return(tt.user, tt.lookupError)
+			}
+			if tt.updateError != nil {
+				mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*model.User")).Return(tt.updateError)
+			}
+			if tt.expectUpdate {
+				mockRepo.On("Update", mock.Anything, mock.AnythingOfType("*model.User")).Return(nil)
+				mockCache.On("Delete", mock.Anything, "user:"+id.String()).Return(nil)
+				mockProducer.On("SendMessage", mock.Anything, "user:"+id.String(), mock.MatchedBy(func(event model.UserChangedEvent) bool {
+					return event.Type == tt.expectedEvent && event.UserID == id && event.User != nil && event.User.ID == id
+				})).Return(nil)
+			}
+
+			h := handler.NewUserHandler(mockRepo, mockCache, mockProducer)
+			var body bytes.Buffer
+			if tt.body != nil {
+				json.NewEncoder(&body).Encode(tt.body)
+			}
+			req := httptest.NewRequest(tt.method, "/users/"+id.String()+tt.suffix, &body)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if rec.Code == http.StatusOK {
+				assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+			}
+			if tt.expectedStatus != "" {
+				assert.Equal(t, tt.expectedStatus, tt.user.Status)
+			}
+			mockRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+			mockProducer.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserHandler_List(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedPage   pagination.Request
+		expectedFilter model.UserFilter
+		expectList     bool
+		expectedCode   int
+	}{
+		{
+			name:         "defaults",
+			expectedPage: pagination.Request{Limit: pagination.DefaultLimit, Sort: "id"},
+			expectList:   true,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:           "filters and sort",
+			query:          "?limit=10&sort=-created_at&status=active&email_domain=example.com",
+			expectedPage:   pagination.Request{Limit: 10, Sort: "created_at", Desc: true},
+			expectedFilter: model.UserFilter{Status: model.StatusActive, EmailDomain: "example.com"},
+			expectList:     true,
+			expectedCode:   http.StatusOK,
+		},
+		{
+			name:         "unknown status",
+			query:        "?status=archived",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "unsortable field",
+			query:        "?sort=password",
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			name:         "limit too large",
+			query:        "?limit=1000",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepo)
+			page := &pagination.Page[*model.User]{
+				Data:       []*model.User{{ID: uuid.Must(uuid.NewV7()), Username: "johndoe"}},
+				NextCursor: "next",
+				Total:      5,
+			}
+			if tt.expectList {
+				mockRepo.On("List", mock.Anything, tt.expectedPage, tt.expectedFilter).Return(page, nil)
+			}
+
+			h := handler.NewUserHandler(mockRepo, new(MockCache), new(MockProducerRepo))
+			req := httptest.NewRequest(http.MethodGet, "/users"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectList {
+				var body map[string]interface{}
+				assert.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+				assert.Equal(t, "next", body["next_cursor"])
+				assert.Equal(t, float64(5), body["total"])
+				assert.Len(t, body["data"], 1)
+			}
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUserHandler_Admin(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		suffix       string
+		writeError   error
+		expectedCode int
+	}{
+		{
+			name:         "restore deleted user",
+			method:       http.MethodPost,
+			suffix:       "/restore",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "restore live user",
+			method:       http.MethodPost,
+			suffix:       "/restore",
+			writeError:   repository.ErrNotDeleted,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "post without restore suffix",
+			method:       http.MethodPost,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+		{
+			name:         "purge deleted user",
+			method:       http.MethodDelete,
+			expectedCode: http.StatusNoContent,
+		},
+		{
+			name:         "purge live user",
+			method:       http.MethodDelete,
+			writeError:   repository.ErrNotDeleted,
+			expectedCode: http.StatusConflict,
+		},
+		{
+			name:         "purge unknown user",
+			method:       http.MethodDelete,
+			writeError:   repository_user.ErrUserNotFound,
+			expectedCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepo)
+			mockCache := new(MockCache)
+			mockProducer := new(MockProducerRepo)
+
+			id := uuid.Must(uuid.NewV7())
+			restored := &model.User{ID: id, Username: "testuser", Status: model.StatusInactive, Version: 5}
+
+			switch {
+			case tt.method == http.MethodPost && tt.suffix != "":
+				mockRepo.On("Restore", mock.Anything, id).Return(tt.writeError)
+				if tt.writeError == nil {
+					mockRepo.On("GetByID", mock.Anything, id).Return(restored, nil)
+					mockCache.On("Delete", mock.Anything, "user:"+id.String()).Return(nil)
+					mockProducer.On("SendMessage", mock.Anything, "user:"+id.String(), mock.MatchedBy(func(event model.UserChangedEvent) bool {
+						return event.Type == model.UserEventRestored && event.Previous == model.StatusDeleted
+					})).Return(nil)
+				}
+			case tt.method == http.MethodDelete:
+				mockRepo.On("Purge", mock.Anything, id).Return(tt.writeError)
+			}
+
+			h := handler.NewUserHandler(mockRepo, mockCache, mockProducer)
+			req := httptest.NewRequest(tt.method, "/admin/users/"+id.String()+tt.suffix, nil)
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if rec.Code == http.StatusOK {
+				assert.Equal(t, `"5"`, rec.Header().Get("ETag"))
+			}
+			mockRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+			mockProducer.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/etag"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
+)
+
+// webhookSecretBytes is the size of a generated signing secret
+const webhookSecretBytes = 32
+
+type WebhookRepository interface {
+	Create(ctx context.Context, subscription *model.WebhookSubscription) error
+	GetByID(ctx context.Context, id int64) (*model.WebhookSubscription, error)
+	List(ctx context.Context, page pagination.Request) (*pagination.Page[*model.WebhookSubscription], error)
+	Update(ctx context.Context, subscription *model.WebhookSubscription) error
+	Delete(ctx context.Context, id int64) error
+	Attempts(ctx context.Context, id int64, page pagination.Request) (*pagination.Page[*model.WebhookAttempt], error)
+}
+
+type WebhookHandler struct {
+	repo   WebhookRepository
+	routes []routes.Route
+	mux    http.Handler
+}
+
+func NewWebhookHandler(repo WebhookRepository) *WebhookHandler {
+	h := &WebhookHandler{
+		repo: repo,
+	}
+
+	h.routes = []routes.Route{
+		{
+			Name:        "webhooks.list",
+			Method:      http.MethodGet,
+			Pattern:     "/webhooks",
+			Handler:     h.listWebhooks,
+			Permissions: []string{authz.WebhooksRead},
+		},
+		{
+			Name:        "webhooks.create",
+			Method:      http.MethodPost,
+			Pattern:     "/webhooks",
+			Handler:     h.createWebhook,
+			Permissions: []string{authz.WebhooksWrite},
+		},
+		{
+			Name:        "webhooks.get",
+			Method:      http.MethodGet,
+			Pattern:     "/webhooks/{id}",
+			Handler:     h.getWebhook,
+			Permissions: []string{authz.WebhooksRead},
+		},
+		{
+			Name:        "webhooks.update",
+			Method:      http.MethodPut,
+			Pattern:     "/webhooks/{id}",
+			Handler:     h.updateWebhook,
+			Permissions: []string{authz.WebhooksWrite},
+		},
+		{
+			Name:        "webhooks.delete",
+			Method:      http.MethodDelete,
+			Pattern:     "/webhooks/{id}",
+			Handler:     h.deleteWebhook,
+			Permissions: []string{authz.WebhooksWrite},
+		},
+		{
+			Name:        "webhooks.attempts",
+			Method:      http.MethodGet,
+			Pattern:     "/webhooks/{id}/attempts",
+			Handler:     h.listAttempts,
+			Permissions: []string{authz.WebhooksRead},
+		},
+	}
+	h.mux = routes.NewHandler(h.routes)
+
+	return h
+}
+
+// GetRoutes returns all routes for this handler
+func (h *WebhookHandler) GetRoutes() []routes.Route {
+	return h.routes
+}
+
+// @Summary List webhook subscriptions
+// @Description List webhook subscriptions one page at a time. Secrets are never returned.
+// @Tags webhooks
+// @Produce json
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "Opaque cursor from the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(id, -id)
+// @Success 200 {object} pagination.Page[model.WebhookResponse]
+// @Failure 400 {object} response.Problem
+// @Failure 500 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks [get]
+func (h *WebhookHandler) listWebhooks(w http.ResponseWriter, r *http.Request) {
+	page, ok := parsePage(w, r, model.WebhookSortFields...)
+	if !ok {
+		return
+	}
+
+	subscriptions, err := h.repo.List(r.Context(), page)
+	if err != nil {
+		writePageError(w, r, err)
+		return
+	}
+	response.RespondWithJSON(w, http.StatusOK, pagination.MapPage(subscriptions, (*model.WebhookSubscription).ToResponse))
+}
+
+// @Summary Create a webhook subscription
+// @Description Subscribe an HTTP endpoint to events. Filters are event types, "<prefix>.*" or "*". The signing secret is generated when omitted and is only returned by this call.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param webhook body model.WebhookCreate true "Webhook subscription request"
+// @Success 201 {object} model.WebhookCreated
+// @Failure 400 {object} response.Problem
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Failure 503 {object} response.Problem "Database unavailable"
+// @Security BearerAuth
+// @Router /api/v1/webhooks [post]
+func (h *WebhookHandler) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req model.WebhookCreate
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	subscription := req.Subscription()
+	if subscription.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			response.WriteError(w, r, err)
+			return
+		}
+		subscription.Secret = secret
+	}
+
+	if err := h.repo.Create(r.Context(), subscription); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	etag.Set(w, subscription.Version)
+	response.RespondWithJSON(w, http.StatusCreated, model.WebhookCreated{
+		WebhookResponse: subscription.ToResponse(),
+		Secret:          subscription.Secret,
+	})
+}
+
+// @Summary Get a webhook subscription
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook subscription ID"
+// @Success 200 {object} model.WebhookResponse
+// @Header 200 {string} ETag "Current version of the subscription"
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks/{id} [get]
+func (h *WebhookHandler) getWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.Int64Param(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	subscription, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	etag.Set(w, subscription.Version)
+	response.RespondWithJSON(w, http.StatusOK, subscription.ToResponse())
+}
+
+// @Summary Update a webhook subscription
+// @Description Replace the endpoint, filters and state of a subscription. Setting active to true re-enables a subscription disabled after repeated failures and resets its failure count. If-Match must carry the ETag returned by GET.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook subscription ID"
+// @Param If-Match header string true "ETag of the version being modified"
+// @Param webhook body model.WebhookUpdate true "Webhook subscription update request"
+// @Success 200 {object} model.WebhookResponse
+// @Header 200 {string} ETag "Current version of the subscription"
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Failure 409 {object} response.Problem
+// @Failure 412 {object} response.Problem
+// @Failure 422 {object} response.Problem "Invalid fields, all listed in errors"
+// @Failure 428 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks/{id} [put]
+func (h *WebhookHandler) updateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.Int64Param(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	var req model.WebhookUpdate
+	if err := validate.Decode(r, &req); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	subscription, err := h.repo.GetByID(ctx, id)
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	if !checkPrecondition(w, r, subscription.Version) {
+		return
+	}
+
+	req.Apply(subscription)
+
+	if err := h.repo.Update(ctx, subscription); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	etag.Set(w, subscription.Version)
+	response.RespondWithJSON(w, http.StatusOK, subscription.ToResponse())
+}
+
+// @Summary Delete a webhook subscription
+// @Description Remove a subscription together with its pending deliveries and attempt history
+// @Tags webhooks
+// @Param id path int true "Webhook subscription ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks/{id} [delete]
+func (h *WebhookHandler) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.Int64Param(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	if err := h.repo.Delete(r.Context(), id); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// @Summary List delivery attempts
+// @Description List the requests made to a subscription's endpoint one page at a time, with the response status, error and when the delivery is retried. Use sort=-id for the latest first.
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook subscription ID"
+// @Param limit query int false "Page size (1-100)" default(20)
+// @Param cursor query string false "Opaque cursor from the previous page"
+// @Param sort query string false "Sort field, prefix with - for descending" Enums(id, -id)
+// @Success 200 {object} pagination.Page[model.WebhookAttempt]
+// @Failure 400 {object} response.Problem
+// @Failure 404 {object} response.Problem
+// @Security BearerAuth
+// @Router /api/v1/webhooks/{id}/attempts [get]
+func (h *WebhookHandler) listAttempts(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.Int64Param(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	page, ok := parsePage(w, r, model.WebhookSortFields...)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+	if _, err := h.repo.GetByID(ctx, id); err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+
+	attempts, err := h.repo.Attempts(ctx, id, page)
+	if err != nil {
+		writePageError(w, r, err)
+		return
+	}
+	response.RespondWithJSON(w, http.StatusOK, attempts)
+}
+
+// ServeHTTP implements http.Handler interface
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// generateWebhookSecret returns a random hex secret for a subscription
+// created without one
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
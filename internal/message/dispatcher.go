@@ -0,0 +1,243 @@
+package message
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+)
+
+const (
+	// DefaultInterval is how often the dispatcher polls when no interval is configured
+	DefaultInterval = time.Second
+	// DefaultBatchSize is the number of messages claimed, and published, at once
+	DefaultBatchSize = 50
+	// DefaultLease holds a claimed message back from other instances until
+	// it is settled
+	DefaultLease = 30 * time.Second
+	// DefaultMaxAttempts is the number of publishes tried for one message
+	DefaultMaxAttempts = 5
+	// DefaultMinBackoff is the delay after the first failed publish
+	DefaultMinBackoff = 5 * time.Second
+	// DefaultMaxBackoff caps the delay between publishes of one message
+	DefaultMaxBackoff = 5 * time.Minute
+)
+
+// ScheduleQueue hands out the due scheduled messages
+type ScheduleQueue interface {
+	Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]model.MessageClaim, error)
+	Ack(ctx context.Context, id string) error
+	Retry(ctx context.Context, id string, at time.Time) error
+	Backlog(ctx context.Context, now time.Time) (model.MessageBacklog, error)
+}
+
+// DispatchStore keeps the status of the messages the dispatcher publishes
+type DispatchStore interface {
+	Queue(ctx context.Context, id string) (*model.Message, error)
+	MarkPublished(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, reason string) error
+}
+
+// Config tunes a Dispatcher, zero values take the defaults above
+type Config struct {
+	Interval    time.Duration
+	BatchSize   int
+	Lease       time.Duration
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.Lease <= 0 {
+		c.Lease = DefaultLease
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = DefaultMaxAttempts
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = DefaultMinBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultMaxBackoff
+	}
+	return c
+}
+
+// Dispatcher publishes scheduled messages once they are due. A message is
+// queued, published and then released from the schedule, a failed publish
+// is retried with exponential backoff and jitter until MaxAttempts were
+// made. Publishing is at least once: an instance that stops between the
+// publish and the release leaves the message to be claimed again when its
+// lease ends, and it is published again with the same event id.
+type Dispatcher struct {
+	schedule  ScheduleQueue
+	store     DispatchStore
+	publisher Publisher
+	config    Config
+	metrics   *metrics.MessageSchedulerMetrics
+	now       func() time.Time
+	jitter    func(time.Duration) time.Duration
+}
+
+func NewDispatcher(schedule ScheduleQueue, store DispatchStore, publisher Publisher, config Config) *Dispatcher {
+	return &Dispatcher{
+		schedule:  schedule,
+		store:     store,
+		publisher: publisher,
+		config:    config.withDefaults(),
+		metrics:   metrics.NewMessageSchedulerMetrics(),
+		now:       time.Now,
+		jitter:    func(d time.Duration) time.Duration { return rand.N(d + 1) },
+	}
+}
+
+// Run dispatches once immediately and then on every interval until ctx is
+// done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		d.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce publishes every due message, BatchSize at a time, updates the
+// backlog metrics and returns the number published
+func (d *Dispatcher) RunOnce(ctx context.Context) int {
+	published := 0
+	for ctx.Err() == nil {
+		claims, err := d.schedule.Claim(ctx, d.now(), d.config.BatchSize, d.config.Lease)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to claim scheduled messages", "error", err)
+			break
+		}
+
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		for _, claim := range claims {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if d.dispatch(ctx, claim) {
+					mu.Lock()
+					published++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(claims) < d.config.BatchSize {
+			break
+		}
+	}
+
+	d.observeBacklog(ctx)
+	return published
+}
+
+// dispatch publishes the claimed message and settles it. It reports whether
+// the message was published.
+func (d *Dispatcher) dispatch(ctx context.Context, claim model.MessageClaim) bool {
+	logger := slog.Default().With("message_id", claim.ID, "attempt", claim.Attempt)
+
+	// Once claimed a message is settled even when the dispatcher is
+	// stopping, the lease covers an instance that cannot
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), d.config.Lease)
+	defer cancel()
+
+	m, err := d.store.Queue(ctx, claim.ID)
+	if err != nil {
+		logger.Error("failed to queue scheduled message", "error", err)
+		return false
+	}
+	if m == nil || m.Status != model.MessageQueued {
+		// Cancelled, or settled by an earlier claim
+		d.metrics.DispatchedTotal.WithLabelValues("skipped").Inc()
+		d.ack(ctx, logger, claim.ID)
+		return false
+	}
+
+	publishErr := publishMessage(ctx, d.publisher, m.ID, m.SentEvent())
+	if publishErr == nil {
+		d.metrics.DispatchedTotal.WithLabelValues(model.MessagePublished).Inc()
+		if err := d.store.MarkPublished(ctx, m.ID); err != nil {
+			// Left claimed, the message is published again once the lease ends
+			logger.Error("failed to record message status", "status", model.MessagePublished, "error", err)
+			return true
+		}
+		d.ack(ctx, logger, m.ID)
+		return true
+	}
+
+	if claim.Attempt < d.config.MaxAttempts {
+		retryIn := d.backoff(claim.Attempt)
+		logger.Warn("scheduled message not published, retrying", "retry_in", retryIn.String(), "error", publishErr)
+		d.metrics.DispatchedTotal.WithLabelValues("retry").Inc()
+		if err := d.schedule.Retry(ctx, m.ID, d.now().Add(retryIn)); err != nil {
+			logger.Error("failed to reschedule message", "error", err)
+		}
+		return false
+	}
+
+	logger.Error("scheduled message not published, giving up", "error", publishErr)
+	d.metrics.DispatchedTotal.WithLabelValues(model.MessageFailed).Inc()
+	if err := d.store.MarkFailed(ctx, m.ID, publishErr.Error()); err != nil {
+		logger.Error("failed to record message status", "status", model.MessageFailed, "error", err)
+		return false
+	}
+	d.ack(ctx, logger, m.ID)
+	return false
+}
+
+func (d *Dispatcher) ack(ctx context.Context, logger *slog.Logger, id string) {
+	if err := d.schedule.Ack(ctx, id); err != nil {
+		logger.Error("failed to release scheduled message", "error", err)
+	}
+}
+
+// observeBacklog updates the backlog gauges
+func (d *Dispatcher) observeBacklog(ctx context.Context) {
+	backlog, err := d.schedule.Backlog(context.WithoutCancel(ctx), d.now())
+	if err != nil {
+		slog.WarnContext(ctx, "failed to measure message schedule backlog", "error", err)
+		return
+	}
+	d.metrics.ScheduledMessages.Set(float64(backlog.Scheduled))
+	d.metrics.DueMessages.Set(float64(backlog.Due))
+	d.metrics.InFlightMessages.Set(float64(backlog.InFlight))
+	d.metrics.LagSeconds.Set(backlog.Lag.Seconds())
+}
+
+// backoff returns the delay before the next publish of a message that has
+// failed attempts times: MinBackoff doubled for every further failure, capped
+// at MaxBackoff, of which a random half is dropped so that messages that
+// failed together do not retry together
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.MinBackoff
+	for i := 1; i < attempts && delay < d.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.config.MaxBackoff)
+	return delay/2 + d.jitter(delay/2)
+}
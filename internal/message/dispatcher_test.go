@@ -0,0 +1,131 @@
+package message
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var dispatchNow = time.Unix(1700000000, 0)
+
+func newTestDispatcher(schedule ScheduleQueue, store DispatchStore, publisher Publisher, config Config) *Dispatcher {
+	d := NewDispatcher(schedule, store, publisher, config)
+	d.now = func() time.Time { return dispatchNow }
+	d.jitter = func(max time.Duration) time.Duration { return max }
+	return d
+}
+
+// scheduled stores a message scheduled for at and adds it to schedule
+func scheduled(t *testing.T, store *fakeStore, schedule *fakeSchedule, at time.Time) *model.Message {
+	t.Helper()
+	m := model.MessageRequest{Content: "reminder"}.Message()
+	m.Status = model.MessageScheduled
+	m.DeliverAt = &at
+	require.NoError(t, store.Create(context.Background(), m))
+	require.NoError(t, schedule.Add(context.Background(), m.ID, at))
+	return m
+}
+
+func TestDispatcher_RunOnce(t *testing.T) {
+	t.Run("publishes due messages", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		due := scheduled(t, store, schedule, dispatchNow.Add(-time.Minute))
+		later := scheduled(t, store, schedule, dispatchNow.Add(time.Hour))
+
+		d := newTestDispatcher(schedule, store, publisher, Config{})
+		assert.Equal(t, 1, d.RunOnce(context.Background()))
+
+		call := <-publisher.sent
+		assert.Equal(t, due.ID, call.key)
+		envelope := call.value.(*event.Envelope)
+		assert.Equal(t, "message-"+due.ID, envelope.ID)
+		payload, err := event.Decode[model.MessageSentEvent](event.Types, envelope)
+		require.NoError(t, err)
+		assert.Equal(t, due.SentEvent(), payload)
+
+		status, _ := store.get(due.ID)
+		assert.Equal(t, model.MessagePublished, status)
+		status, _ = store.get(later.ID)
+		assert.Equal(t, model.MessageScheduled, status)
+		assert.Empty(t, schedule.claimed)
+		assert.Contains(t, schedule.due, later.ID)
+	})
+
+	t.Run("skips cancelled messages", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		m := scheduled(t, store, schedule, dispatchNow.Add(-time.Minute))
+		cancelled, err := store.Cancel(context.Background(), m.ID)
+		require.NoError(t, err)
+		require.True(t, cancelled)
+
+		d := newTestDispatcher(schedule, store, publisher, Config{})
+		assert.Equal(t, 0, d.RunOnce(context.Background()))
+
+		assert.Empty(t, publisher.sent)
+		assert.Empty(t, schedule.claimed)
+		status, _ := store.get(m.ID)
+		assert.Equal(t, model.MessageCancelled, status)
+	})
+
+	t.Run("retries a refused publish with backoff", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		publisher.err = errors.NewUnavailable("ProducerRepository.SendMessage", assert.AnError)
+		m := scheduled(t, store, schedule, dispatchNow.Add(-time.Minute))
+
+		d := newTestDispatcher(schedule, store, publisher, Config{MinBackoff: time.Second})
+		assert.Equal(t, 0, d.RunOnce(context.Background()))
+
+		assert.Equal(t, dispatchNow.Add(time.Second), schedule.due[m.ID])
+		assert.Empty(t, schedule.claimed)
+		status, _ := store.get(m.ID)
+		assert.Equal(t, model.MessageQueued, status)
+
+		// Due again: the second attempt waits twice as long
+		d.now = func() time.Time { return dispatchNow.Add(time.Second) }
+		d.RunOnce(context.Background())
+		assert.Equal(t, dispatchNow.Add(3*time.Second), schedule.due[m.ID])
+		assert.Equal(t, 2, schedule.attempts[m.ID])
+	})
+
+	t.Run("fails after max attempts", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		publisher.err = errors.NewUnavailable("ProducerRepository.SendMessage", assert.AnError)
+		m := scheduled(t, store, schedule, dispatchNow.Add(-time.Minute))
+
+		d := newTestDispatcher(schedule, store, publisher, Config{MaxAttempts: 1})
+		assert.Equal(t, 0, d.RunOnce(context.Background()))
+
+		status, reason := store.get(m.ID)
+		assert.Equal(t, model.MessageFailed, status)
+		assert.Equal(t, publisher.err.Error(), reason)
+		assert.Empty(t, schedule.due)
+		assert.Empty(t, schedule.claimed)
+	})
+
+	t.Run("claims in batches", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		for range 3 {
+			scheduled(t, store, schedule, dispatchNow.Add(-time.Minute))
+		}
+
+		d := newTestDispatcher(schedule, store, publisher, Config{BatchSize: 2})
+		assert.Equal(t, 3, d.RunOnce(context.Background()))
+		assert.Empty(t, schedule.due)
+	})
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := newTestDispatcher(newFakeSchedule(), newFakeStore(), newFakePublisher(), Config{MinBackoff: time.Second, MaxBackoff: 5 * time.Second})
+
+	assert.Equal(t, time.Second, d.backoff(1))
+	assert.Equal(t, 2*time.Second, d.backoff(2))
+	assert.Equal(t, 4*time.Second, d.backoff(3))
+	assert.Equal(t, 5*time.Second, d.backoff(4))
+	assert.Equal(t, 5*time.Second, d.backoff(10))
+}
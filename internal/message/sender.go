@@ -0,0 +1,254 @@
+// Package message sends the messages of the message API to the event bus
+// and keeps their delivery status: a message is stored as queued, answered
+// right away, and settled as published or failed once the broker
+// acknowledged or refused it. A message sent for later is stored as
+// scheduled and held in a schedule until the Dispatcher publishes it.
+//
+// A message sent now is held in the schedule too, due RecoverAfter later,
+// and taken off once settled. If the instance stops before the broker
+// answered, the message is still queued when it falls due and the
+// Dispatcher publishes it.
+package message
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+)
+
+const (
+	// DefaultRecoverAfter is how long a message sent now may stay queued
+	// before the Dispatcher publishes it
+	DefaultRecoverAfter = 2 * time.Minute
+
+	// statusTimeout bounds recording the status of a message, which happens
+	// after the request that sent it is gone
+	statusTimeout = 5 * time.Second
+)
+
+// ErrClosed is returned by Send once the sender is closed
+var ErrClosed = stderrors.New("message sender closed")
+
+// Store keeps messages and their status
+type Store interface {
+	Create(ctx context.Context, m *model.Message) error
+	GetByID(ctx context.Context, id string) (*model.Message, error)
+	Cancel(ctx context.Context, id string) (bool, error)
+	MarkPublished(ctx context.Context, id string) error
+	MarkFailed(ctx context.Context, id string, reason string) error
+}
+
+// Schedule holds the ids of scheduled messages until they are due
+type Schedule interface {
+	Add(ctx context.Context, id string, at time.Time) error
+	Remove(ctx context.Context, id string) error
+}
+
+// Publisher publishes events, the event bus in production
+type Publisher interface {
+	SendMessage(ctx context.Context, key string, value interface{}) error
+}
+
+// Sender stores messages and publishes them in the background
+type Sender struct {
+	store        Store
+	publisher    Publisher
+	schedule     Schedule
+	recoverAfter time.Duration
+	metrics      *metrics.MessageAPIMetrics
+	now          func() time.Time
+
+	mu       sync.Mutex
+	closed   bool
+	inflight sync.WaitGroup
+}
+
+// NewSender creates a sender. Messages sent now that are still queued after
+// recoverAfter, DefaultRecoverAfter when zero, are left to the Dispatcher;
+// it must be longer than a publish takes.
+func NewSender(store Store, publisher Publisher, schedule Schedule, recoverAfter time.Duration) *Sender {
+	if recoverAfter <= 0 {
+		recoverAfter = DefaultRecoverAfter
+	}
+	return &Sender{
+		store:        store,
+		publisher:    publisher,
+		schedule:     schedule,
+		recoverAfter: recoverAfter,
+		metrics:      metrics.NewMessageAPIMetrics(),
+		now:          time.Now,
+	}
+}
+
+// Send stores m as queued, then publishes it without waiting for the
+// broker. The publish keeps the values of ctx, such as the trace context,
+// but not its cancellation: a client that hangs up once its message is
+// stored does not take the message back. m is added to the schedule, due
+// after recoverAfter, before it is stored, so a message is never queued
+// without a way to be published; if the schedule cannot take it, nothing
+// is stored and the error returned.
+//
+// A message with a DeliverAt is stored as scheduled and added to the
+// schedule instead. If the schedule cannot take it, it is marked failed and
+// the error returned.
+func (s *Sender) Send(ctx context.Context, m *model.Message) error {
+	if m.DeliverAt != nil {
+		return s.scheduleMessage(ctx, m)
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return errors.NewUnavailable("Sender.Send", ErrClosed)
+	}
+	s.inflight.Add(1)
+	s.mu.Unlock()
+
+	if err := s.schedule.Add(ctx, m.ID, s.now().Add(s.recoverAfter)); err != nil {
+		s.inflight.Done()
+		return err
+	}
+
+	m.Status = model.MessageQueued
+	if err := s.store.Create(ctx, m); err != nil {
+		// The Dispatcher skips an id without a message, removing it only
+		// spares it the lookup
+		s.release(ctx, m.ID)
+		s.inflight.Done()
+		return err
+	}
+	s.metrics.StatusTotal.WithLabelValues(model.MessageQueued).Inc()
+
+	// m belongs to the caller from here on, the publish works on copies
+	id, payload := m.ID, m.SentEvent()
+	go func() {
+		defer s.inflight.Done()
+		s.publish(context.WithoutCancel(ctx), id, payload)
+	}()
+	return nil
+}
+
+func (s *Sender) scheduleMessage(ctx context.Context, m *model.Message) error {
+	m.Status = model.MessageScheduled
+	if err := s.store.Create(ctx, m); err != nil {
+		return err
+	}
+
+	if err := s.schedule.Add(ctx, m.ID, *m.DeliverAt); err != nil {
+		logging.FromContext(ctx).Error("failed to schedule message", "message_id", m.ID, "error", err)
+		statusCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), statusTimeout)
+		defer cancel()
+		if markErr := s.store.MarkFailed(statusCtx, m.ID, err.Error()); markErr != nil {
+			logging.FromContext(ctx).Error("failed to record message status", "message_id", m.ID, "status", model.MessageFailed, "error", markErr)
+		}
+		s.metrics.StatusTotal.WithLabelValues(model.MessageFailed).Inc()
+		return err
+	}
+	s.metrics.StatusTotal.WithLabelValues(model.MessageScheduled).Inc()
+	return nil
+}
+
+// Cancel cancels the scheduled message with id and returns it. Only a
+// message still scheduled can be cancelled, once due it is on its way.
+func (s *Sender) Cancel(ctx context.Context, id string) (*model.Message, error) {
+	cancelled, err := s.store.Cancel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !cancelled {
+		m, err := s.store.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return nil, errors.NewConflict("Sender.Cancel",
+			fmt.Sprintf("message is %s, only scheduled messages can be cancelled", m.Status), nil)
+	}
+	s.metrics.StatusTotal.WithLabelValues(model.MessageCancelled).Inc()
+
+	// The dispatcher skips cancelled messages, an id left in the schedule
+	// only costs it a lookup
+	if err := s.schedule.Remove(ctx, id); err != nil {
+		logging.FromContext(ctx).Warn("failed to remove cancelled message from schedule", "message_id", id, "error", err)
+	}
+	return s.store.GetByID(ctx, id)
+}
+
+// publish sends payload keyed by the message id, so the events of one
+// message stay on one partition, and records how it went. A message whose
+// status could not be recorded stays in the schedule for the Dispatcher to
+// settle.
+func (s *Sender) publish(ctx context.Context, id string, payload model.MessageSentEvent) {
+	logger := logging.FromContext(ctx).With("message_id", id)
+
+	publishErr := publishMessage(ctx, s.publisher, id, payload)
+
+	ctx, cancel := context.WithTimeout(ctx, statusTimeout)
+	defer cancel()
+
+	if publishErr != nil {
+		logger.Warn("message not published", "error", publishErr)
+		s.metrics.StatusTotal.WithLabelValues(model.MessageFailed).Inc()
+		if err := s.store.MarkFailed(ctx, id, publishErr.Error()); err != nil {
+			logger.Error("failed to record message status", "status", model.MessageFailed, "error", err)
+			return
+		}
+		s.release(ctx, id)
+		return
+	}
+
+	s.metrics.StatusTotal.WithLabelValues(model.MessagePublished).Inc()
+	if err := s.store.MarkPublished(ctx, id); err != nil {
+		logger.Error("failed to record message status", "status", model.MessagePublished, "error", err)
+		return
+	}
+	s.release(ctx, id)
+}
+
+// release takes a settled message sent now off the schedule. One left there
+// costs the Dispatcher a lookup once due, it skips messages no longer queued.
+func (s *Sender) release(ctx context.Context, id string) {
+	if err := s.schedule.Remove(ctx, id); err != nil {
+		logging.FromContext(ctx).Warn("failed to remove sent message from schedule", "message_id", id, "error", err)
+	}
+}
+
+// publishMessage sends payload in an event envelope whose id is derived
+// from the message id, so a message published again keeps its id and
+// consumers can drop duplicates
+func publishMessage(ctx context.Context, publisher Publisher, id string, payload model.MessageSentEvent) error {
+	envelope, err := event.Types.New(ctx, model.MessageSent, id, payload)
+	if err != nil {
+		return err
+	}
+	envelope.ID = "message-" + id
+	return publisher.SendMessage(ctx, id, envelope)
+}
+
+// Close refuses new messages and waits until the messages in flight are
+// settled or ctx ends. It has the signature of a shutdown.Manager handler.
+func (s *Sender) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
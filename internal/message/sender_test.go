@@ -0,0 +1,386 @@
+package message
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore keeps every message and its status
+type fakeStore struct {
+	mu        sync.Mutex
+	createErr error
+	messages  map[string]model.Message
+	status    map[string]string
+	reasons   map[string]string
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{messages: map[string]model.Message{}, status: map[string]string{}, reasons: map[string]string{}}
+}
+
+func (f *fakeStore) Create(ctx context.Context, m *model.Message) error {
+	if f.createErr != nil {
+		return f.createErr
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m.CreatedAt = time.Unix(1700000000, 0).UTC()
+	f.messages[m.ID] = *m
+	f.status[m.ID] = m.Status
+	return nil
+}
+
+func (f *fakeStore) GetByID(ctx context.Context, id string) (*model.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	m, ok := f.messages[id]
+	if !ok {
+		return nil, errors.NewNotFound("fakeStore.GetByID", "message", id)
+	}
+	m.Status = f.status[id]
+	return &m, nil
+}
+
+func (f *fakeStore) Cancel(ctx context.Context, id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status[id] != model.MessageScheduled {
+		return false, nil
+	}
+	f.status[id] = model.MessageCancelled
+	return true, nil
+}
+
+func (f *fakeStore) Queue(ctx context.Context, id string) (*model.Message, error) {
+	f.mu.Lock()
+	if f.status[id] == model.MessageScheduled {
+		f.status[id] = model.MessageQueued
+	}
+	_, ok := f.messages[id]
+	f.mu.Unlock()
+	if !ok {
+		return nil, nil
+	}
+	return f.GetByID(ctx, id)
+}
+
+func (f *fakeStore) MarkPublished(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status[id] == model.MessageQueued {
+		f.status[id] = model.MessagePublished
+	}
+	return nil
+}
+
+func (f *fakeStore) MarkFailed(ctx context.Context, id string, reason string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.status[id] == model.MessageQueued || f.status[id] == model.MessageScheduled {
+		f.status[id] = model.MessageFailed
+		f.reasons[id] = reason
+	}
+	return nil
+}
+
+func (f *fakeStore) get(id string) (string, string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.status[id], f.reasons[id]
+}
+
+// fakePublisher blocks every publish until release is closed, when set
+type fakePublisher struct {
+	release chan struct{}
+	err     error
+	sent    chan publishCall
+}
+
+type publishCall struct {
+	ctx   context.Context
+	key   string
+	value interface{}
+}
+
+func newFakePublisher() *fakePublisher {
+	return &fakePublisher{sent: make(chan publishCall, 8)}
+}
+
+func (f *fakePublisher) SendMessage(ctx context.Context, key string, value interface{}) error {
+	if f.release != nil {
+		<-f.release
+	}
+	f.sent <- publishCall{ctx: ctx, key: key, value: value}
+	return f.err
+}
+
+// fakeSchedule keeps the due time of every scheduled message, the claimed
+// ones and their attempts
+type fakeSchedule struct {
+	mu       sync.Mutex
+	addErr   error
+	due      map[string]time.Time
+	claimed  map[string]bool
+	attempts map[string]int
+}
+
+func newFakeSchedule() *fakeSchedule {
+	return &fakeSchedule{due: map[string]time.Time{}, claimed: map[string]bool{}, attempts: map[string]int{}}
+}
+
+func (f *fakeSchedule) Add(ctx context.Context, id string, at time.Time) error {
+	if f.addErr != nil {
+		return f.addErr
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.due[id] = at
+	return nil
+}
+
+func (f *fakeSchedule) Remove(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.due, id)
+	delete(f.attempts, id)
+	return nil
+}
+
+func (f *fakeSchedule) Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]model.MessageClaim, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var claims []model.MessageClaim
+	for id, at := range f.due {
+		if len(claims) == limit {
+			break
+		}
+		if at.After(now) {
+			continue
+		}
+		delete(f.due, id)
+		f.claimed[id] = true
+		f.attempts[id]++
+		claims = append(claims, model.MessageClaim{ID: id, Attempt: f.attempts[id]})
+	}
+	return claims, nil
+}
+
+func (f *fakeSchedule) Ack(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.claimed, id)
+	delete(f.attempts, id)
+	return nil
+}
+
+func (f *fakeSchedule) Retry(ctx context.Context, id string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.claimed, id)
+	f.due[id] = at
+	return nil
+}
+
+func (f *fakeSchedule) Backlog(ctx context.Context, now time.Time) (model.MessageBacklog, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return model.MessageBacklog{Scheduled: int64(len(f.due)), InFlight: int64(len(f.claimed))}, nil
+}
+
+type ctxKey struct{}
+
+func TestSender_Send(t *testing.T) {
+	t.Run("published once acknowledged", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		s := NewSender(store, publisher, schedule, 0)
+
+		m := model.MessageRequest{Content: "hello"}.Message()
+		require.NoError(t, s.Send(context.Background(), m))
+		assert.Equal(t, model.MessageQueued, m.Status)
+
+		require.NoError(t, s.Close(context.Background()))
+		call := <-publisher.sent
+		assert.Equal(t, m.ID, call.key)
+		envelope := call.value.(*event.Envelope)
+		assert.Equal(t, "message-"+m.ID, envelope.ID)
+		assert.Equal(t, model.MessageSent, envelope.Type)
+		payload, err := event.Decode[model.MessageSentEvent](event.Types, envelope)
+		require.NoError(t, err)
+		assert.Equal(t, model.MessageSentEvent{ID: m.ID, Content: "hello", CreatedAt: m.CreatedAt}, payload)
+		status, _ := store.get(m.ID)
+		assert.Equal(t, model.MessagePublished, status)
+		assert.Empty(t, schedule.due)
+	})
+
+	t.Run("failed when refused", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		publisher.err = errors.NewTimeout("ProducerRepository.SendMessage", context.DeadlineExceeded)
+		s := NewSender(store, publisher, schedule, 0)
+
+		m := model.MessageRequest{Content: "hello"}.Message()
+		require.NoError(t, s.Send(context.Background(), m))
+		require.NoError(t, s.Close(context.Background()))
+
+		status, reason := store.get(m.ID)
+		assert.Equal(t, model.MessageFailed, status)
+		assert.Equal(t, "request timed out: context deadline exceeded", reason)
+		assert.Empty(t, schedule.due)
+	})
+
+	t.Run("not stored", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		store.createErr = errors.NewUnavailable("MessageRepository.Create", assert.AnError)
+		s := NewSender(store, publisher, schedule, 0)
+
+		err := s.Send(context.Background(), model.MessageRequest{Content: "hello"}.Message())
+		assert.Equal(t, store.createErr, err)
+		require.NoError(t, s.Close(context.Background()))
+		assert.Empty(t, publisher.sent)
+		assert.Empty(t, schedule.due)
+	})
+
+	t.Run("not stored when the schedule is unavailable", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		schedule.addErr = errors.NewUnavailable("ScheduleRepository.Add", assert.AnError)
+		s := NewSender(store, publisher, schedule, 0)
+
+		m := model.MessageRequest{Content: "hello"}.Message()
+		assert.Equal(t, schedule.addErr, s.Send(context.Background(), m))
+		require.NoError(t, s.Close(context.Background()))
+		assert.Empty(t, publisher.sent)
+		_, err := store.GetByID(context.Background(), m.ID)
+		assert.Error(t, err)
+	})
+
+	t.Run("interrupted send is published by the dispatcher", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		publisher.release = make(chan struct{})
+		s := NewSender(store, publisher, schedule, time.Minute)
+		s.now = func() time.Time { return dispatchNow }
+
+		// The instance stops while the broker has not answered
+		m := model.MessageRequest{Content: "hello"}.Message()
+		require.NoError(t, s.Send(context.Background(), m))
+		assert.Equal(t, dispatchNow.Add(time.Minute), schedule.due[m.ID])
+
+		d := newTestDispatcher(schedule, store, newFakePublisher(), Config{})
+		assert.Equal(t, 0, d.RunOnce(context.Background()))
+		d.now = func() time.Time { return dispatchNow.Add(time.Minute) }
+		assert.Equal(t, 1, d.RunOnce(context.Background()))
+
+		status, _ := store.get(m.ID)
+		assert.Equal(t, model.MessagePublished, status)
+		assert.Empty(t, schedule.due)
+		assert.Empty(t, schedule.claimed)
+		close(publisher.release)
+		require.NoError(t, s.Close(context.Background()))
+	})
+
+	t.Run("publish outlives the request", func(t *testing.T) {
+		store, publisher := newFakeStore(), newFakePublisher()
+		publisher.release = make(chan struct{})
+		s := NewSender(store, publisher, newFakeSchedule(), 0)
+
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "trace"))
+		require.NoError(t, s.Send(ctx, model.MessageRequest{Content: "hello"}.Message()))
+		cancel()
+		close(publisher.release)
+
+		call := <-publisher.sent
+		assert.NoError(t, call.ctx.Err())
+		assert.Equal(t, "trace", call.ctx.Value(ctxKey{}))
+		require.NoError(t, s.Close(context.Background()))
+	})
+}
+
+func TestSender_Schedule(t *testing.T) {
+	at := time.Now().Add(time.Hour)
+
+	t.Run("scheduled", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		s := NewSender(store, publisher, schedule, 0)
+
+		m := model.MessageRequest{Content: "hello", DeliverAt: &at}.Message()
+		require.NoError(t, s.Send(context.Background(), m))
+		require.NoError(t, s.Close(context.Background()))
+
+		assert.Equal(t, model.MessageScheduled, m.Status)
+		assert.Equal(t, *m.DeliverAt, schedule.due[m.ID])
+		assert.Empty(t, publisher.sent)
+	})
+
+	t.Run("failed when the schedule is unavailable", func(t *testing.T) {
+		store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+		schedule.addErr = errors.NewUnavailable("ScheduleRepository.Add", assert.AnError)
+		s := NewSender(store, publisher, schedule, 0)
+
+		m := model.MessageRequest{Content: "hello", DeliverAt: &at}.Message()
+		assert.Equal(t, schedule.addErr, s.Send(context.Background(), m))
+		status, _ := store.get(m.ID)
+		assert.Equal(t, model.MessageFailed, status)
+	})
+}
+
+func TestSender_Cancel(t *testing.T) {
+	at := time.Now().Add(time.Hour)
+	store, publisher, schedule := newFakeStore(), newFakePublisher(), newFakeSchedule()
+	s := NewSender(store, publisher, schedule, 0)
+
+	scheduled := model.MessageRequest{Content: "later", DeliverAt: &at}.Message()
+	require.NoError(t, s.Send(context.Background(), scheduled))
+	queued := model.MessageRequest{Content: "now"}.Message()
+	require.NoError(t, s.Send(context.Background(), queued))
+	require.NoError(t, s.Close(context.Background()))
+
+	m, err := s.Cancel(context.Background(), scheduled.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.MessageCancelled, m.Status)
+	assert.NotContains(t, schedule.due, scheduled.ID)
+
+	var e *errors.Error
+	_, err = s.Cancel(context.Background(), scheduled.ID)
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, 409, e.Code)
+	assert.Equal(t, "message is cancelled, only scheduled messages can be cancelled", e.Message)
+
+	_, err = s.Cancel(context.Background(), queued.ID)
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, 409, e.Code)
+
+	_, err = s.Cancel(context.Background(), "missing")
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, 404, e.Code)
+}
+
+func TestSender_Close(t *testing.T) {
+	store, publisher := newFakeStore(), newFakePublisher()
+	publisher.release = make(chan struct{})
+	s := NewSender(store, publisher, newFakeSchedule(), 0)
+
+	m := model.MessageRequest{Content: "hello"}.Message()
+	require.NoError(t, s.Send(context.Background(), m))
+
+	// A publish in flight holds Close until ctx ends
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Close(ctx), context.DeadlineExceeded)
+
+	err := s.Send(context.Background(), model.MessageRequest{Content: "late"}.Message())
+	assert.ErrorIs(t, err, ErrClosed)
+	var e *errors.Error
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, 503, e.Code)
+
+	close(publisher.release)
+	require.NoError(t, s.Close(context.Background()))
+	status, _ := store.get(m.ID)
+	assert.Equal(t, model.MessagePublished, status)
+}
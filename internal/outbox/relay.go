@@ -0,0 +1,239 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/Napat/golang-testcontainers-demo/pkg/tracing"
+)
+
+const (
+	// DefaultInterval is how often the relay polls when no interval is configured
+	DefaultInterval = time.Second
+	// DefaultBatchSize caps the messages read by a single poll
+	DefaultBatchSize = 100
+	// DefaultMaxBackoff caps the delay between attempts to publish one message
+	DefaultMaxBackoff = 5 * time.Minute
+	// DefaultMaxAttempts is how often a message is tried before it is parked
+	DefaultMaxAttempts = 20
+
+	// minBackoff is the delay after the first failed attempt, doubled after
+	// every further one
+	minBackoff = time.Second
+)
+
+// Store holds the outbox messages written alongside the changes they describe
+type Store interface {
+	// Pending returns the due messages that have no earlier unpublished
+	// message of the same aggregate, oldest first
+	Pending(ctx context.Context, limit int) ([]*model.OutboxMessage, error)
+	MarkPublished(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, cause error, retryIn time.Duration) error
+	Park(ctx context.Context, id int64, cause error) error
+	Stats(ctx context.Context) (pending int64, lag time.Duration, err error)
+}
+
+// Publisher sends a message to Kafka
+type Publisher interface {
+	SendMessage(ctx context.Context, key string, value interface{}) error
+}
+
+// Relay publishes outbox messages in the order they were written. Delivery is
+// at least once: a message is marked published only after Kafka accepted it,
+// so a crash in between sends it again and consumers must tolerate
+// duplicates. Messages of one aggregate are never reordered, a failing
+// message holds back the later ones of its aggregate until it goes through
+// or, after maxAttempts, is parked.
+type Relay struct {
+	store       Store
+	publisher   Publisher
+	interval    time.Duration
+	batchSize   int
+	maxBackoff  time.Duration
+	maxAttempts int
+	metrics     *metrics.OutboxMetrics
+}
+
+// NewRelay creates a relay that polls store every interval and publishes up
+// to batchSize messages at a time. A message that failed maxAttempts times
+// is parked.
+func NewRelay(store Store, publisher Publisher, interval time.Duration, batchSize int, maxBackoff time.Duration, maxAttempts int) *Relay {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxBackoff
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	return &Relay{
+		store:       store,
+		publisher:   publisher,
+		interval:    interval,
+		batchSize:   batchSize,
+		maxBackoff:  maxBackoff,
+		maxAttempts: maxAttempts,
+		metrics:     metrics.NewOutboxMetrics(),
+	}
+}
+
+// Run relays once immediately and then on every interval until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		r.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce publishes every due message and refreshes the lag metrics. It
+// returns the number of messages published. A failure is recorded on the
+// message and logged, it holds back the later messages of that aggregate
+// only. Publishing or parking the head of an aggregate makes its next
+// message due, so batches are read until one settles nothing.
+func (r *Relay) RunOnce(ctx context.Context) int {
+	published := 0
+	// Messages tried in this run that are still pending because recording
+	// the outcome failed, they are not sent again until the next run
+	tried := make(map[int64]bool)
+	for ctx.Err() == nil {
+		n, parked := r.relayBatch(ctx, tried)
+		published += n
+		if n == 0 && parked == 0 {
+			break
+		}
+	}
+
+	r.updateStats(ctx)
+	return published
+}
+
+// relayBatch publishes one batch of due messages, skipping those already
+// tried in this run, and returns the number published and parked
+func (r *Relay) relayBatch(ctx context.Context, tried map[int64]bool) (published, parked int) {
+	messages, err := r.store.Pending(ctx, r.batchSize)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read outbox", "error", err)
+		return 0, 0
+	}
+
+	for _, msg := range messages {
+		if ctx.Err() != nil {
+			break
+		}
+		if tried[msg.ID] {
+			continue
+		}
+		tried[msg.ID] = true
+
+		if err := r.publish(ctx, msg); err != nil {
+			r.metrics.FailuresTotal.WithLabelValues(msg.EventType).Inc()
+			if r.fail(ctx, msg, err) {
+				parked++
+			}
+			continue
+		}
+		r.metrics.PublishedTotal.WithLabelValues(msg.EventType).Inc()
+
+		// The message is sent again on the next run and, being still
+		// pending, keeps holding back the later messages of its aggregate
+		if err := r.store.MarkPublished(ctx, msg.ID); err != nil {
+			slog.ErrorContext(ctx, "failed to mark outbox message published", "outbox_id", msg.ID, "error", err)
+			continue
+		}
+		published++
+	}
+
+	return published, parked
+}
+
+// fail records a failed publish of msg, which is retried after a backoff
+// or, on its last attempt, parked. It reports whether msg was parked.
+func (r *Relay) fail(ctx context.Context, msg *model.OutboxMessage, cause error) bool {
+	attempts := msg.Attempts + 1
+	if attempts >= r.maxAttempts {
+		r.metrics.ParkedTotal.WithLabelValues(msg.EventType).Inc()
+		slog.ErrorContext(ctx, "giving up on outbox message, parking it",
+			"outbox_id", msg.ID,
+			"event_type", msg.EventType,
+			"attempts", attempts,
+			"error", cause,
+		)
+		if err := r.store.Park(ctx, msg.ID, cause); err != nil {
+			slog.ErrorContext(ctx, "failed to park outbox message", "outbox_id", msg.ID, "error", err)
+			return false
+		}
+		return true
+	}
+
+	retryIn := r.backoff(msg.Attempts)
+	slog.WarnContext(ctx, "failed to publish outbox message",
+		"outbox_id", msg.ID,
+		"event_type", msg.EventType,
+		"attempts", attempts,
+		"retry_in", retryIn.String(),
+		"error", cause,
+	)
+	if err := r.store.MarkFailed(ctx, msg.ID, cause, retryIn); err != nil {
+		slog.ErrorContext(ctx, "failed to record outbox failure", "outbox_id", msg.ID, "error", err)
+	}
+	return false
+}
+
+// publish sends msg in an event envelope with the request id and trace
+// context of the request that wrote it. The envelope id is derived from the
+// outbox id, so a message sent again after a crash keeps its id and
+// consumers can drop duplicates.
+func (r *Relay) publish(ctx context.Context, msg *model.OutboxMessage) error {
+	if msg.RequestID != "" {
+		ctx = requestid.NewContext(ctx, msg.RequestID)
+	}
+	ctx = tracing.Extract(ctx, msg.TraceContext)
+	envelope, err := event.Types.New(ctx, msg.EventType, msg.Key, json.RawMessage(msg.Payload))
+	if err != nil {
+		return err
+	}
+	envelope.ID = fmt.Sprintf("outbox-%d", msg.ID)
+	if !msg.CreatedAt.IsZero() {
+		envelope.Time = msg.CreatedAt.UTC()
+	}
+	return r.publisher.SendMessage(ctx, msg.Key, envelope)
+}
+
+// backoff returns the delay before the next attempt of a message that has
+// already failed attempts times
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := minBackoff
+	for i := 0; i < attempts && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.maxBackoff)
+}
+
+func (r *Relay) updateStats(ctx context.Context) {
+	pending, lag, err := r.store.Stats(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read outbox stats", "error", err)
+		return
+	}
+	r.metrics.PendingMessages.Set(float64(pending))
+	r.metrics.LagSeconds.Set(lag.Seconds())
+}
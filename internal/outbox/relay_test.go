@@ -0,0 +1,228 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeStore answers Pending like the repository: due messages that are the
+// oldest unpublished one of their aggregate
+type fakeStore struct {
+	messages []*model.OutboxMessage
+	failures map[int64]time.Duration
+	parked   map[int64]bool
+	markErr  map[int64]error
+	now      time.Time
+}
+
+func (f *fakeStore) Pending(ctx context.Context, limit int) ([]*model.OutboxMessage, error) {
+	var pending []*model.OutboxMessage
+	heads := make(map[string]bool)
+	for _, msg := range f.messages {
+		aggregate := msg.AggregateType + ":" + msg.AggregateID
+		if f.parked[msg.ID] || heads[aggregate] {
+			continue
+		}
+		heads[aggregate] = true
+		if !msg.NextAttemptAt.After(f.now) && len(pending) < limit {
+			pending = append(pending, msg)
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeStore) MarkPublished(ctx context.Context, id int64) error {
+	if err := f.markErr[id]; err != nil {
+		return err
+	}
+	for i, msg := range f.messages {
+		if msg.ID == id {
+			f.messages = append(f.messages[:i], f.messages[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("not found")
+}
+
+func (f *fakeStore) MarkFailed(ctx context.Context, id int64, cause error, retryIn time.Duration) error {
+	f.failures[id] = retryIn
+	for _, msg := range f.messages {
+		if msg.ID == id {
+			msg.Attempts++
+			msg.NextAttemptAt = f.now.Add(retryIn)
+		}
+	}
+	return nil
+}
+
+func (f *fakeStore) Park(ctx context.Context, id int64, cause error) error {
+	if f.parked == nil {
+		f.parked = make(map[int64]bool)
+	}
+	f.parked[id] = true
+	return nil
+}
+
+func (f *fakeStore) Stats(ctx context.Context) (int64, time.Duration, error) {
+	return int64(len(f.messages) - len(f.parked)), 0, nil
+}
+
+type sent struct {
+	key       string
+	value     string
+	requestID string
+}
+
+type fakePublisher struct {
+	sent      []sent
+	envelopes []*event.Envelope
+	failKey   string
+}
+
+func (f *fakePublisher) SendMessage(ctx context.Context, key string, value interface{}) error {
+	if key == f.failKey {
+		return errors.New("broker unavailable")
+	}
+	envelope := value.(*event.Envelope)
+	f.envelopes = append(f.envelopes, envelope)
+	f.sent = append(f.sent, sent{key: key, value: string(envelope.Data), requestID: requestid.FromContext(ctx)})
+	return nil
+}
+
+// ids returns the outbox ids of the sent messages, in order
+func (f *fakePublisher) ids() []int64 {
+	var ids []int64
+	for _, s := range f.sent {
+		var v struct{ N int64 }
+		json.Unmarshal([]byte(s.value), &v)
+		ids = append(ids, v.N)
+	}
+	return ids
+}
+
+func message(id int64, aggregateID string) *model.OutboxMessage {
+	return &model.OutboxMessage{
+		ID:            id,
+		AggregateType: "user",
+		AggregateID:   aggregateID,
+		EventType:     model.UserEventCreated,
+		Key:           "user:" + aggregateID,
+		Payload:       []byte(fmt.Sprintf(`{"n":%d}`, id)),
+	}
+}
+
+func TestRelay_RunOnce(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := message(1, "a")
+	first.RequestID = "req-1"
+	store := &fakeStore{
+		messages: []*model.OutboxMessage{first, message(2, "b"), message(3, "a"), message(4, "c")},
+		failures: map[int64]time.Duration{},
+		now:      now,
+	}
+	publisher := &fakePublisher{failKey: "user:b"}
+
+	r := NewRelay(store, publisher, time.Second, 2, time.Minute, 0)
+
+	assert.Equal(t, 3, r.RunOnce(context.Background()))
+	assert.Equal(t, []sent{
+		{key: "user:a", value: `{"n":1}`, requestID: "req-1"},
+		{key: "user:a", value: `{"n":3}`},
+		{key: "user:c", value: `{"n":4}`},
+	}, publisher.sent)
+	assert.Equal(t, map[int64]time.Duration{2: time.Second}, store.failures)
+
+	// The envelope id follows the outbox id so duplicates can be dropped
+	envelope := publisher.envelopes[0]
+	assert.Equal(t, "outbox-1", envelope.ID)
+	assert.Equal(t, model.UserEventCreated, envelope.Type)
+	assert.Equal(t, "user:a", envelope.Subject)
+	assert.Equal(t, "req-1", envelope.CorrelationID)
+
+	t.Run("failed message waits for its backoff", func(t *testing.T) {
+		publisher.failKey = ""
+		publisher.sent = nil
+		assert.Equal(t, 0, r.RunOnce(context.Background()))
+
+		store.now = now.Add(time.Second)
+		assert.Equal(t, 1, r.RunOnce(context.Background()))
+		assert.Equal(t, []sent{{key: "user:b", value: `{"n":2}`}}, publisher.sent)
+		assert.Empty(t, store.messages)
+	})
+}
+
+func TestRelay_RepublishesUnmarkedMessage(t *testing.T) {
+	store := &fakeStore{
+		messages: []*model.OutboxMessage{message(1, "a"), message(2, "a"), message(3, "b")},
+		failures: map[int64]time.Duration{},
+		markErr:  map[int64]error{1: errors.New("connection reset")},
+	}
+	publisher := &fakePublisher{}
+	r := NewRelay(store, publisher, time.Second, 10, time.Minute, 0)
+
+	// Message 1 reached Kafka but is still pending, so message 2 must wait
+	assert.Equal(t, 1, r.RunOnce(context.Background()))
+	assert.Equal(t, []int64{1, 3}, publisher.ids())
+
+	delete(store.markErr, 1)
+	publisher.sent = nil
+	assert.Equal(t, 2, r.RunOnce(context.Background()))
+	assert.Equal(t, []int64{1, 2}, publisher.ids())
+}
+
+func TestRelay_WaitingMessageHoldsBackItsAggregateOnly(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeStore{failures: map[int64]time.Duration{}, now: now}
+	// A full batch of messages of one aggregate waits behind its first one
+	for id := int64(1); id <= 3; id++ {
+		msg := message(id, "a")
+		msg.NextAttemptAt = now.Add(time.Minute)
+		store.messages = append(store.messages, msg)
+	}
+	store.messages = append(store.messages, message(4, "b"), message(5, "b"), message(6, "c"))
+	publisher := &fakePublisher{}
+	r := NewRelay(store, publisher, time.Second, 2, time.Minute, 0)
+
+	assert.Equal(t, 3, r.RunOnce(context.Background()))
+	assert.Equal(t, []int64{4, 6, 5}, publisher.ids())
+	assert.Len(t, store.messages, 3)
+}
+
+func TestRelay_ParksAfterMaxAttempts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	failing := message(1, "a")
+	failing.Key = "poison"
+	failing.Attempts = 2
+	store := &fakeStore{
+		messages: []*model.OutboxMessage{failing, message(2, "a")},
+		failures: map[int64]time.Duration{},
+		now:      now,
+	}
+	publisher := &fakePublisher{failKey: "poison"}
+	r := NewRelay(store, publisher, time.Second, 10, time.Minute, 3)
+
+	// The third failure parks the message and the aggregate moves on
+	assert.Equal(t, 1, r.RunOnce(context.Background()))
+	assert.True(t, store.parked[1])
+	assert.Empty(t, store.failures)
+	assert.Equal(t, []int64{2}, publisher.ids())
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	r := NewRelay(nil, nil, 0, 0, 10*time.Second, 0)
+
+	assert.Equal(t, time.Second, r.backoff(0))
+	assert.Equal(t, 2*time.Second, r.backoff(1))
+	assert.Equal(t, 8*time.Second, r.backoff(3))
+	assert.Equal(t, 10*time.Second, r.backoff(4))
+	assert.Equal(t, 10*time.Second, r.backoff(100))
+}
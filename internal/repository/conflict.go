@@ -0,0 +1,30 @@
+package repository
+
+import (
+	stderrors "errors"
+	"fmt"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+)
+
+// ErrVersionConflict matches every *VersionConflictError with errors.Is
+var ErrVersionConflict = stderrors.New("version conflict")
+
+// VersionConflictError is returned by compare-and-swap writes when the stored
+// version is no longer the version the caller read
+type VersionConflictError struct {
+	Entity   string
+	ID       interface{}
+	Expected int
+	Current  int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("%s %v was modified concurrently: expected version %d, current version is %d",
+		e.Entity, e.ID, e.Expected, e.Current)
+}
+
+// Is matches ErrVersionConflict and the errors.ErrConflict category
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict || target == errors.ErrConflict
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+)
+
+// FromElasticsearch translates the outcome of an Elasticsearch request into
+// the error taxonomy of pkg/errors. err is the transport error returned by
+// the client; when it is nil the HTTP status of res decides. It returns nil
+// for successful responses.
+func FromElasticsearch(op string, res *esapi.Response, err error) error {
+	if err != nil {
+		if classified, ok := fromCommon(op, err); ok {
+			return classified
+		}
+		return errors.NewUnavailable(op, err)
+	}
+	if res == nil || !res.IsError() {
+		return nil
+	}
+
+	cause := fmt.Errorf("elasticsearch %s: %s", res.Status(), elasticsearchReason(res))
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return &errors.Error{Code: http.StatusNotFound, Message: "document not found", Op: op, Err: cause}
+	case res.StatusCode == http.StatusConflict:
+		return errors.NewConflict(op, "document was modified concurrently", cause)
+	case res.StatusCode == http.StatusBadRequest:
+		return &errors.Error{Code: http.StatusUnprocessableEntity, Message: "invalid search request", Op: op, Err: cause}
+	case res.StatusCode == http.StatusRequestTimeout || res.StatusCode == http.StatusGatewayTimeout:
+		return errors.NewTimeout(op, cause)
+	case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+		return errors.NewUnavailable(op, cause)
+	}
+	return errors.NewInternalError(op, cause)
+}
+
+// elasticsearchReason reads error.reason from an error response body
+func elasticsearchReason(res *esapi.Response) string {
+	var body struct {
+		Error struct {
+			Type   string `json:"type"`
+			Reason string `json:"reason"`
+		} `json:"error"`
+	}
+	if res.Body == nil || json.NewDecoder(res.Body).Decode(&body) != nil || body.Error.Reason == "" {
+		return "no reason given"
+	}
+	return body.Error.Type + ": " + body.Error.Reason
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	stderrors "errors"
+	"net"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+)
+
+// fromCommon classifies the errors every driver can return: errors that are
+// already in the taxonomy, context expiry and network failures
+func fromCommon(op string, err error) (error, bool) {
+	var classified *errors.Error
+	if stderrors.As(err, &classified) {
+		return err, true
+	}
+	if stderrors.Is(err, ErrVersionConflict) {
+		return err, true
+	}
+
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return errors.NewTimeout(op, err), true
+	}
+
+	var netErr net.Error
+	if stderrors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return errors.NewTimeout(op, err), true
+		}
+		return errors.NewUnavailable(op, err), true
+	}
+	if stderrors.Is(err, driver.ErrBadConn) {
+		return errors.NewUnavailable(op, err), true
+	}
+	return nil, false
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/go-redis/redis/v8"
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromMySQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCode  int
+		wantField string
+	}{
+		{"nil", nil, 0, ""},
+		{"duplicate", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'bob' for key 'users.username'"}, http.StatusConflict, "username"},
+		{"data too long", &mysql.MySQLError{Number: 1406, Message: "Data too long for column 'username'"}, http.StatusUnprocessableEntity, ""},
+		{"lock wait timeout", &mysql.MySQLError{Number: 1205}, http.StatusGatewayTimeout, ""},
+		{"too many connections", &mysql.MySQLError{Number: 1040}, http.StatusServiceUnavailable, ""},
+		{"broken connection", mysql.ErrInvalidConn, http.StatusServiceUnavailable, ""},
+		{"context deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, ""},
+		{"version conflict", &VersionConflictError{Entity: "user", Expected: 1, Current: 2}, http.StatusConflict, ""},
+		{"unknown", &mysql.MySQLError{Number: 1146, Message: "Table 'testdb.users' doesn't exist"}, http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := FromMySQL("UserRepository.Create", "user", tt.err)
+			if tt.wantCode == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			got := errors.From(err)
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantField, got.Field)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestFromPostgres(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		wantCode  int
+		wantField string
+	}{
+		{"duplicate", &pq.Error{Code: "23505", Detail: "Key (sku)=(ABC-1) already exists.", Constraint: "products_sku_key"}, http.StatusConflict, "sku"},
+		{"duplicate without detail", &pq.Error{Code: "23505", Constraint: "products_sku_key"}, http.StatusConflict, "products_sku_key"},
+		{"not null", &pq.Error{Code: "23502", Column: "name"}, http.StatusUnprocessableEntity, "name"},
+		{"invalid text", &pq.Error{Code: "22P02"}, http.StatusUnprocessableEntity, ""},
+		{"deadlock", &pq.Error{Code: "40P01"}, http.StatusConflict, ""},
+		{"statement timeout", &pq.Error{Code: "57014"}, http.StatusGatewayTimeout, ""},
+		{"too many connections", &pq.Error{Code: "53300"}, http.StatusServiceUnavailable, ""},
+		{"undefined table", &pq.Error{Code: "42P01"}, http.StatusInternalServerError, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errors.From(FromPostgres("ProductRepository.Create", "product", tt.err))
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantField, got.Field)
+		})
+	}
+}
+
+func TestFromRedis(t *testing.T) {
+	miss := FromRedis("CacheRepository.Get", redis.Nil)
+	assert.ErrorIs(t, miss, errors.ErrNotFound)
+	assert.ErrorIs(t, miss, redis.Nil)
+
+	assert.ErrorIs(t, FromRedis("CacheRepository.Get", redis.ErrClosed), errors.ErrUnavailable)
+	assert.ErrorIs(t, FromRedis("CacheRepository.Get", context.DeadlineExceeded), errors.ErrTimeout)
+	assert.Equal(t, http.StatusInternalServerError, errors.From(FromRedis("CacheRepository.Get", stderrors.New("WRONGTYPE"))).Code)
+}
+
+func TestFromKafka(t *testing.T) {
+	assert.NoError(t, FromKafka("ProducerRepository.SendMessage", nil))
+	assert.ErrorIs(t, FromKafka("ProducerRepository.SendMessage", sarama.ErrOutOfBrokers), errors.ErrUnavailable)
+	assert.ErrorIs(t, FromKafka("ProducerRepository.SendMessage", fmt.Errorf("send: %w", sarama.ErrNotLeaderForPartition)), errors.ErrUnavailable)
+	assert.ErrorIs(t, FromKafka("ProducerRepository.SendMessage", context.DeadlineExceeded), errors.ErrTimeout)
+	assert.Equal(t, http.StatusInternalServerError, errors.From(FromKafka("ProducerRepository.SendMessage", sarama.ErrMessageSizeTooLarge)).Code)
+}
+
+func TestFromElasticsearch(t *testing.T) {
+	response := func(status int, body string) *esapi.Response {
+		return &esapi.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}
+	}
+
+	tests := []struct {
+		name     string
+		res      *esapi.Response
+		err      error
+		wantCode int
+	}{
+		{"success", response(http.StatusOK, `{}`), nil, 0},
+		{"transport failure", nil, stderrors.New("connection refused"), http.StatusServiceUnavailable},
+		{"not found", response(http.StatusNotFound, `{"error":{"type":"index_not_found_exception","reason":"no such index [orders]"}}`), nil, http.StatusNotFound},
+		{"version conflict", response(http.StatusConflict, `{}`), nil, http.StatusConflict},
+		{"bad query", response(http.StatusBadRequest, `{"error":{"type":"parsing_exception","reason":"unknown query"}}`), nil, http.StatusUnprocessableEntity},
+		{"overloaded", response(http.StatusTooManyRequests, `{}`), nil, http.StatusServiceUnavailable},
+		{"gateway timeout", response(http.StatusGatewayTimeout, `{}`), nil, http.StatusGatewayTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := FromElasticsearch("OrderRepository.ListOrders", tt.res, tt.err)
+			if tt.wantCode == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.wantCode, errors.From(err).Code)
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	stderrors "errors"
+
+	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+)
+
+// FromKafka translates an error returned by sarama into the error taxonomy of
+// pkg/errors. Unreachable or closed brokers and leaderless partitions are
+// unavailable, so callers may retry them later.
+func FromKafka(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if classified, ok := fromCommon(op, err); ok {
+		return classified
+	}
+
+	for _, unavailable := range []error{
+		sarama.ErrOutOfBrokers,
+		sarama.ErrNotConnected,
+		sarama.ErrClosedClient,
+		sarama.ErrShuttingDown,
+		sarama.ErrLeaderNotAvailable,
+		sarama.ErrNotLeaderForPartition,
+		sarama.ErrRequestTimedOut,
+		sarama.ErrBrokerNotAvailable,
+	} {
+		if stderrors.Is(err, unavailable) {
+			return errors.NewUnavailable(op, err)
+		}
+	}
+	var produceErrs sarama.ProducerErrors
+	if stderrors.As(err, &produceErrs) && len(produceErrs) > 0 {
+		return FromKafka(op, produceErrs[0].Err)
+	}
+	return errors.NewInternalError(op, err)
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/go-sql-driver/mysql"
+)
+
+// MySQL server error numbers, see
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	mysqlDuplicateEntry     = 1062
+	mysqlColumnCannotBeNull = 1048
+	mysqlDataTooLong        = 1406
+	mysqlOutOfRange         = 1264
+	mysqlTruncatedValue     = 1265
+	mysqlCheckViolated      = 3819
+	mysqlRowIsReferenced    = 1451
+	mysqlNoReferencedRow    = 1452
+	mysqlLockWaitTimeout    = 1205
+	mysqlDeadlock           = 1213
+	mysqlQueryTimeout       = 3024
+	mysqlTooManyConnections = 1040
+	mysqlServerShutdown     = 1053
+)
+
+// FromMySQL translates an error returned by the MySQL driver into the error
+// taxonomy of pkg/errors. entity names the record in duplicate key messages,
+// e.g. "user". Errors that are already classified pass through unchanged.
+func FromMySQL(op, entity string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if classified, ok := fromCommon(op, err); ok {
+		return classified
+	}
+
+	var myErr *mysql.MySQLError
+	if stderrors.As(err, &myErr) {
+		switch myErr.Number {
+		case mysqlDuplicateEntry:
+			return errors.NewDuplicate(op, entity, mysqlDuplicateKey(myErr.Message), err)
+		case mysqlColumnCannotBeNull, mysqlDataTooLong, mysqlOutOfRange, mysqlTruncatedValue, mysqlCheckViolated:
+			return &errors.Error{Code: http.StatusUnprocessableEntity, Message: "invalid " + entity, Op: op, Err: err}
+		case mysqlNoReferencedRow:
+			return &errors.Error{Code: http.StatusUnprocessableEntity, Message: entity + " references a record that does not exist", Op: op, Err: err}
+		case mysqlRowIsReferenced:
+			return errors.NewConflict(op, entity+" is still referenced by other records", err)
+		case mysqlDeadlock:
+			return errors.NewConflict(op, "concurrent update, please retry", err)
+		case mysqlLockWaitTimeout, mysqlQueryTimeout:
+			return errors.NewTimeout(op, err)
+		case mysqlTooManyConnections, mysqlServerShutdown:
+			return errors.NewUnavailable(op, err)
+		}
+	}
+
+	if stderrors.Is(err, mysql.ErrInvalidConn) {
+		return errors.NewUnavailable(op, err)
+	}
+	return errors.NewInternalError(op, err)
+}
+
+// mysqlDuplicateKey extracts the column from "Duplicate entry 'x' for key
+// 'users.username'". Single column unique keys are named after the column.
+func mysqlDuplicateKey(message string) string {
+	i := strings.LastIndex(message, "for key '")
+	if i < 0 {
+		return "value"
+	}
+	key := strings.TrimSuffix(message[i+len("for key '"):], "'")
+	if dot := strings.LastIndex(key, "."); dot >= 0 {
+		key = key[dot+1:]
+	}
+	return key
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/lib/pq"
+)
+
+// FromPostgres translates an error returned by lib/pq into the error taxonomy
+// of pkg/errors, using the SQLSTATE code. entity names the record in
+// messages, e.g. "product". Errors that are already classified pass through.
+func FromPostgres(op, entity string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if classified, ok := fromCommon(op, err); ok {
+		return classified
+	}
+
+	var pqErr *pq.Error
+	if !stderrors.As(err, &pqErr) {
+		return errors.NewInternalError(op, err)
+	}
+
+	// https://www.postgresql.org/docs/current/errcodes-appendix.html
+	switch pqErr.Code {
+	case "23505": // unique_violation
+		return errors.NewDuplicate(op, entity, postgresDuplicateKey(pqErr), err)
+	case "23502": // not_null_violation
+		return &errors.Error{Code: http.StatusUnprocessableEntity, Message: pqErr.Column + " is required", Op: op, Field: pqErr.Column, Err: err}
+	case "23503": // foreign_key_violation
+		return &errors.Error{Code: http.StatusUnprocessableEntity, Message: entity + " references a record that does not exist", Op: op, Err: err}
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		return errors.NewConflict(op, "concurrent update, please retry", err)
+	case "57014", "55P03": // query_canceled (statement_timeout), lock_not_available
+		return errors.NewTimeout(op, err)
+	}
+
+	switch pqErr.Code.Class() {
+	case "22", "23": // data exception, integrity constraint violation
+		return &errors.Error{Code: http.StatusUnprocessableEntity, Message: "invalid " + entity, Op: op, Field: pqErr.Column, Err: err}
+	case "08", "53", "57": // connection exception, insufficient resources, operator intervention
+		return errors.NewUnavailable(op, err)
+	}
+	return errors.NewInternalError(op, err)
+}
+
+// postgresDuplicateKey extracts the column from the detail of a unique
+// violation, "Key (sku)=(ABC-1) already exists."
+func postgresDuplicateKey(err *pq.Error) string {
+	detail := strings.TrimPrefix(err.Detail, "Key (")
+	if end := strings.Index(detail, ")="); end > 0 && detail != err.Detail {
+		return detail[:end]
+	}
+	if err.Constraint != "" {
+		return err.Constraint
+	}
+	return "value"
+}
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+// FromRedis translates an error returned by go-redis into the error taxonomy
+// of pkg/errors. A missing key (redis.Nil) is a not found error that still
+// matches redis.Nil with errors.Is.
+func FromRedis(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if stderrors.Is(err, redis.Nil) {
+		return &errors.Error{Code: http.StatusNotFound, Message: "key not found", Op: op, Err: err}
+	}
+	if classified, ok := fromCommon(op, err); ok {
+		return classified
+	}
+	if stderrors.Is(err, redis.ErrClosed) {
+		return errors.NewUnavailable(op, err)
+	}
+
+	// Server replies that mean the node cannot serve the command right now
+	var redisErr redis.Error
+	if stderrors.As(err, &redisErr) {
+		for _, prefix := range []string{"LOADING", "READONLY", "MASTERDOWN", "CLUSTERDOWN", "TRYAGAIN", "BUSY"} {
+			if strings.HasPrefix(redisErr.Error(), prefix) {
+				return errors.NewUnavailable(op, err)
+			}
+		}
+	}
+	return errors.NewInternalError(op, err)
+}
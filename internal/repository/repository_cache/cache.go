@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
 	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
 	"github.com/go-redis/redis/v8"
 )
@@ -32,7 +33,7 @@ func (r *CacheRepository) Set(ctx context.Context, key string, value interface{}
 		return err
 	}
 
-	return r.client.Set(ctx, key, data, expiration).Err()
+	return repository.FromRedis("CacheRepository.Set", r.client.Set(ctx, key, data, expiration).Err())
 }
 
 func (r *CacheRepository) Get(ctx context.Context, key string, result interface{}) error {
@@ -46,9 +47,18 @@ func (r *CacheRepository) Get(ctx context.Context, key string, result interface{
 		if err == redis.Nil {
 			r.metrics.MissesTotal.Inc()
 		}
-		return err
+		return repository.FromRedis("CacheRepository.Get", err)
 	}
 
 	r.metrics.HitsTotal.Inc()
 	return json.Unmarshal(data, result)
 }
+
+func (r *CacheRepository) Delete(ctx context.Context, key string) error {
+	timer := time.Now()
+	defer func() {
+		r.metrics.OperationDuration.WithLabelValues("delete").Observe(time.Since(timer).Seconds())
+	}()
+
+	return repository.FromRedis("CacheRepository.Delete", r.client.Del(ctx, key).Err())
+}
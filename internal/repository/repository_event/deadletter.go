@@ -0,0 +1,426 @@
+package repository_event
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+)
+
+// Headers set on a message forwarded to a retry or dead-letter topic. The
+// original headers are kept, the original position is recorded once, when
+// the message first fails.
+const (
+	AttemptsHeader          = "x-attempts"
+	FailureReasonHeader     = "x-failure-reason"
+	FailedAtHeader          = "x-failed-at"
+	OriginalTopicHeader     = "x-original-topic"
+	OriginalPartitionHeader = "x-original-partition"
+	OriginalOffsetHeader    = "x-original-offset"
+
+	// resolvesHeader marks a record of the dead-letter topic that settles an
+	// earlier message, named by its id, instead of carrying one
+	resolvesHeader   = "x-dlq-resolves"
+	resolutionHeader = "x-dlq-resolution"
+
+	// maxReasonLength bounds the failure reason kept in a header
+	maxReasonLength = 1024
+	// scanTimeout bounds a read of the whole dead-letter topic
+	scanTimeout = 10 * time.Second
+)
+
+// RetryTopic returns the topic of retry tier n, counted from 1, for messages
+// of topic that failed in group
+func RetryTopic(topic, group string, n int) string {
+	return fmt.Sprintf("%s.%s.retry.%d", topic, group, n)
+}
+
+// DeadLetterTopic returns the topic for messages of topic that failed in
+// group on every retry tier
+func DeadLetterTopic(topic, group string) string {
+	return fmt.Sprintf("%s.%s.dlq", topic, group)
+}
+
+// Failure describes why a consumed message is forwarded
+type Failure struct {
+	Reason   string
+	Attempts int // processing attempts so far, across every tier
+	FailedAt time.Time
+}
+
+// FailureOf returns the failure recorded on a forwarded message, the zero
+// Failure for a message that has not failed before
+func FailureOf(msg *sarama.ConsumerMessage) Failure {
+	var f Failure
+	for _, h := range msg.Headers {
+		if h == nil {
+			continue
+		}
+		switch string(h.Key) {
+		case AttemptsHeader:
+			f.Attempts, _ = strconv.Atoi(string(h.Value))
+		case FailureReasonHeader:
+			f.Reason = string(h.Value)
+		case FailedAtHeader:
+			f.FailedAt, _ = time.Parse(time.RFC3339Nano, string(h.Value))
+		}
+	}
+	return f
+}
+
+// FailureRepository forwards messages that failed processing to retry and
+// dead-letter topics
+type FailureRepository struct {
+	producer sarama.SyncProducer
+}
+
+func NewFailureRepository(producer sarama.SyncProducer) *FailureRepository {
+	return &FailureRepository{
+		producer: producer,
+	}
+}
+
+// Forward publishes msg to topic with its key, value and headers, and the
+// failure in the x- headers
+func (r *FailureRepository) Forward(ctx context.Context, topic string, msg *sarama.ConsumerMessage, failure Failure) error {
+	reason := failure.Reason
+	if len(reason) > maxReasonLength {
+		reason = reason[:maxReasonLength]
+	}
+
+	out := &sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.ByteEncoder(msg.Key),
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	original := false
+	for _, h := range msg.Headers {
+		if h == nil {
+			continue
+		}
+		switch string(h.Key) {
+		case AttemptsHeader, FailureReasonHeader, FailedAtHeader:
+			continue
+		case OriginalTopicHeader:
+			original = true
+		}
+		out.Headers = append(out.Headers, *h)
+	}
+	if !original {
+		out.Headers = append(out.Headers,
+			header(OriginalTopicHeader, msg.Topic),
+			header(OriginalPartitionHeader, strconv.Itoa(int(msg.Partition))),
+			header(OriginalOffsetHeader, strconv.FormatInt(msg.Offset, 10)),
+		)
+	}
+	out.Headers = append(out.Headers,
+		header(AttemptsHeader, strconv.Itoa(failure.Attempts)),
+		header(FailureReasonHeader, reason),
+		header(FailedAtHeader, failure.FailedAt.UTC().Format(time.RFC3339Nano)),
+	)
+
+	if _, _, err := send(ctx, r.producer, out); err != nil {
+		return repository.FromKafka("FailureRepository.Forward", err)
+	}
+	return nil
+}
+
+// DeadLetterRepository reads the dead-letter topics of the consumer groups
+// of topic, see DeadLetterTopic, and replays or discards their messages.
+//
+// Kafka cannot remove a single record, so settling a message appends a
+// marker record naming it to the same topic. Pending messages are those
+// without a marker. Every call reads the whole dead-letter topic of its
+// group, which the topic's retention keeps small.
+type DeadLetterRepository struct {
+	client   sarama.Client
+	producer sarama.SyncProducer
+	topic    string // topic consumed by the groups
+}
+
+func NewDeadLetterRepository(client sarama.Client, producer sarama.SyncProducer, topic string) *DeadLetterRepository {
+	return &DeadLetterRepository{
+		client:   client,
+		producer: producer,
+		topic:    topic,
+	}
+}
+
+// List returns up to limit pending messages of group, oldest first within
+// each partition, without their headers and values. A group without a
+// dead-letter topic has none.
+func (r *DeadLetterRepository) List(ctx context.Context, group string, limit int) ([]*model.DeadLetter, error) {
+	pending, err := r.pending(ctx, group)
+	if err != nil {
+		return nil, repository.FromKafka("DeadLetterRepository.List", err)
+	}
+
+	letters := make([]*model.DeadLetter, 0, min(len(pending), limit))
+	for _, msg := range pending {
+		if len(letters) == limit {
+			break
+		}
+		letters = append(letters, deadLetter(msg, false))
+	}
+	return letters, nil
+}
+
+// Get returns the pending message id of group with its headers and value
+func (r *DeadLetterRepository) Get(ctx context.Context, group, id string) (*model.DeadLetter, error) {
+	msg, err := r.find(ctx, "DeadLetterRepository.Get", group, id)
+	if err != nil {
+		return nil, err
+	}
+	return deadLetter(msg, true), nil
+}
+
+// Replay publishes the pending message id of group to its original topic
+// with its original headers, so it is processed again from the first
+// attempt, and settles it. A failure between the two steps may replay it
+// twice, which consumers tolerate like any other duplicate.
+func (r *DeadLetterRepository) Replay(ctx context.Context, group, id string) error {
+	const op = "DeadLetterRepository.Replay"
+	msg, err := r.find(ctx, op, group, id)
+	if err != nil {
+		return err
+	}
+
+	out := &sarama.ProducerMessage{
+		Key:   sarama.ByteEncoder(msg.Key),
+		Value: sarama.ByteEncoder(msg.Value),
+	}
+	for _, h := range msg.Headers {
+		if h == nil {
+			continue
+		}
+		switch string(h.Key) {
+		case OriginalTopicHeader:
+			out.Topic = string(h.Value)
+		case AttemptsHeader, FailureReasonHeader, FailedAtHeader, OriginalPartitionHeader, OriginalOffsetHeader:
+		default:
+			out.Headers = append(out.Headers, *h)
+		}
+	}
+	if out.Topic == "" {
+		return errors.NewConflict(op, "dead letter has no original topic", nil)
+	}
+
+	if _, _, err := send(ctx, r.producer, out); err != nil {
+		return repository.FromKafka(op, err)
+	}
+	return r.resolve(ctx, op, group, msg, "replayed")
+}
+
+// Discard settles the pending message id of group without processing it
+func (r *DeadLetterRepository) Discard(ctx context.Context, group, id string) error {
+	const op = "DeadLetterRepository.Discard"
+	msg, err := r.find(ctx, op, group, id)
+	if err != nil {
+		return err
+	}
+	return r.resolve(ctx, op, group, msg, "discarded")
+}
+
+func (r *DeadLetterRepository) resolve(ctx context.Context, op, group string, msg *sarama.ConsumerMessage, resolution string) error {
+	marker := &sarama.ProducerMessage{
+		Topic: DeadLetterTopic(r.topic, group),
+		Key:   sarama.ByteEncoder(msg.Key),
+		Headers: []sarama.RecordHeader{
+			header(resolvesHeader, messageID(msg)),
+			header(resolutionHeader, resolution),
+		},
+	}
+	if _, _, err := send(ctx, r.producer, marker); err != nil {
+		return repository.FromKafka(op, err)
+	}
+	return nil
+}
+
+// find returns the pending message id of group, or a not found error when
+// it does not exist or was settled already
+func (r *DeadLetterRepository) find(ctx context.Context, op, group, id string) (*sarama.ConsumerMessage, error) {
+	pending, err := r.pending(ctx, group)
+	if err != nil {
+		return nil, repository.FromKafka(op, err)
+	}
+	for _, msg := range pending {
+		if messageID(msg) == id {
+			return msg, nil
+		}
+	}
+	return nil, errors.NewNotFound(op, "dead letter", id)
+}
+
+// pending reads every partition of the dead-letter topic of group up to
+// its high water mark and returns the messages that have no marker
+func (r *DeadLetterRepository) pending(ctx context.Context, group string) ([]*sarama.ConsumerMessage, error) {
+	if r.client == nil || r.producer == nil {
+		// Kafka was unreachable when the service started
+		return nil, sarama.ErrNotConnected
+	}
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+
+	topic := DeadLetterTopic(r.topic, group)
+	partitions, err := r.client.Partitions(topic)
+	if stderrors.Is(err, sarama.ErrUnknownTopicOrPartition) {
+		// The group has not subscribed with dead letters enabled yet
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	consumer, err := sarama.NewConsumerFromClient(r.client)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+
+	var messages []*sarama.ConsumerMessage
+	resolved := make(map[string]bool)
+	for _, partition := range partitions {
+		read, err := r.readPartition(ctx, consumer, topic, partition)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range read {
+			if id := headerValue(msg, resolvesHeader); id != "" {
+				resolved[id] = true
+				continue
+			}
+			messages = append(messages, msg)
+		}
+	}
+
+	pending := messages[:0]
+	for _, msg := range messages {
+		if !resolved[messageID(msg)] {
+			pending = append(pending, msg)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		if pending[i].Partition != pending[j].Partition {
+			return pending[i].Partition < pending[j].Partition
+		}
+		return pending[i].Offset < pending[j].Offset
+	})
+	return pending, nil
+}
+
+func (r *DeadLetterRepository) readPartition(ctx context.Context, consumer sarama.Consumer, topic string, partition int32) ([]*sarama.ConsumerMessage, error) {
+	oldest, err := r.client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return nil, err
+	}
+	newest, err := r.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	if err != nil {
+		return nil, err
+	}
+	if newest <= oldest {
+		return nil, nil
+	}
+
+	pc, err := consumer.ConsumePartition(topic, partition, oldest)
+	if err != nil {
+		return nil, err
+	}
+	defer pc.Close()
+
+	var messages []*sarama.ConsumerMessage
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case err := <-pc.Errors():
+			return nil, err
+		case msg := <-pc.Messages():
+			messages = append(messages, msg)
+			if msg.Offset >= newest-1 {
+				return messages, nil
+			}
+		}
+	}
+}
+
+// deadLetter describes msg, with its headers and value when full is set
+func deadLetter(msg *sarama.ConsumerMessage, full bool) *model.DeadLetter {
+	failure := FailureOf(msg)
+	letter := &model.DeadLetter{
+		ID:            messageID(msg),
+		Key:           string(msg.Key),
+		OriginalTopic: headerValue(msg, OriginalTopicHeader),
+		Attempts:      failure.Attempts,
+		Reason:        failure.Reason,
+		FailedAt:      failure.FailedAt,
+	}
+	if envelope, err := event.FromMessage(msg); err == nil {
+		letter.EventType = envelope.Type
+	}
+	if partition, err := strconv.ParseInt(headerValue(msg, OriginalPartitionHeader), 10, 32); err == nil {
+		letter.OriginalPartition = int32(partition)
+	}
+	if offset, err := strconv.ParseInt(headerValue(msg, OriginalOffsetHeader), 10, 64); err == nil {
+		letter.OriginalOffset = offset
+	}
+
+	if full {
+		letter.Headers = make(map[string]string, len(msg.Headers))
+		for _, h := range msg.Headers {
+			if h != nil {
+				letter.Headers[string(h.Key)] = string(h.Value)
+			}
+		}
+		letter.Value = string(msg.Value)
+	}
+	return letter
+}
+
+// messageID names a message of the dead-letter topic by its position
+func messageID(msg *sarama.ConsumerMessage) string {
+	return fmt.Sprintf("%d-%d", msg.Partition, msg.Offset)
+}
+
+func headerValue(msg *sarama.ConsumerMessage, key string) string {
+	for _, h := range msg.Headers {
+		if h != nil && strings.EqualFold(string(h.Key), key) {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func header(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
+
+// CreateTopics creates the topics that do not exist yet with as many
+// partitions as source and the broker's default replication
+func CreateTopics(admin sarama.ClusterAdmin, source string, topics []string) error {
+	metadata, err := admin.DescribeTopics([]string{source})
+	if err != nil {
+		return repository.FromKafka("CreateTopics", err)
+	}
+	if len(metadata) == 0 || metadata[0].Err != sarama.ErrNoError {
+		return errors.NewNotFound("CreateTopics", "topic", source)
+	}
+
+	for _, topic := range topics {
+		err := admin.CreateTopic(topic, &sarama.TopicDetail{
+			NumPartitions:     int32(len(metadata[0].Partitions)),
+			ReplicationFactor: -1,
+		}, false)
+		if err != nil && !stderrors.Is(err, sarama.ErrTopicAlreadyExists) {
+			return repository.FromKafka("CreateTopics", fmt.Errorf("create %s: %w", topic, err))
+		}
+	}
+	return nil
+}
@@ -1,51 +1,126 @@
 package repository_event
 
 import (
-	"encoding/json"
+	"context"
 	"time"
 
 	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
 	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/Napat/golang-testcontainers-demo/pkg/tracing"
 )
 
+// DefaultPublishTimeout bounds a publish when no timeout is configured
+const DefaultPublishTimeout = 5 * time.Second
+
 type ProducerRepository struct {
 	producer sarama.SyncProducer
 	topic    string
+	mode     event.Mode
+	timeout  time.Duration
 	metrics  *metrics.MessageMetrics
 }
 
-func NewProducerRepository(producer sarama.SyncProducer, topic string) *ProducerRepository {
+// NewProducerRepository creates a repository that publishes to topic with
+// envelopes laid out in mode. A publish waits at most timeout for the broker.
+func NewProducerRepository(producer sarama.SyncProducer, topic string, mode event.Mode, timeout time.Duration) *ProducerRepository {
+	if timeout <= 0 {
+		timeout = DefaultPublishTimeout
+	}
 	return &ProducerRepository{
 		producer: producer,
 		topic:    topic,
+		mode:     mode,
+		timeout:  timeout,
 		metrics:  metrics.NewMessageMetrics(),
 	}
 }
 
-func (r *ProducerRepository) SendMessage(key string, value interface{}) error {
+// SendMessage publishes value under key, wrapped in a CloudEvents envelope
+// whose subject is the key. value is either an *event.Envelope or a payload
+// registered in event.Types, anything else is refused. The request id of
+// ctx, if any, becomes the correlation id and also travels in the
+// X-Request-ID header. The send is traced with a producer span whose context
+// is injected as the traceparent header.
+//
+// The wait for the broker ends with ctx or after the configured timeout,
+// whichever comes first, with a timeout error.
+func (r *ProducerRepository) SendMessage(ctx context.Context, key string, value interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
 	timer := time.Now()
 	defer func() {
 		r.metrics.PublishDuration.WithLabelValues(r.topic).Observe(time.Since(timer).Seconds())
 	}()
 
-	data, err := json.Marshal(value)
+	envelope, err := event.Types.Wrap(ctx, key, value)
+	if err != nil {
+		r.metrics.MessagesPublished.WithLabelValues(r.topic, "error").Inc()
+		return err
+	}
+	headers, data, err := envelope.Encode(r.mode)
 	if err != nil {
 		r.metrics.MessagesPublished.WithLabelValues(r.topic, "error").Inc()
 		return err
 	}
 
 	msg := &sarama.ProducerMessage{
-		Topic: r.topic,
-		Key:   sarama.StringEncoder(key),
-		Value: sarama.ByteEncoder(data),
+		Topic:   r.topic,
+		Key:     sarama.StringEncoder(key),
+		Value:   sarama.ByteEncoder(data),
+		Headers: headers,
+	}
+	if envelope.CorrelationID != "" {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(requestid.Header), Value: []byte(envelope.CorrelationID)})
 	}
 
-	_, _, err = r.producer.SendMessage(msg)
+	_, span := tracing.StartProducerSpan(ctx, msg, envelope.ID)
+	partition, offset, err := send(ctx, r.producer, msg)
+	tracing.EndProducerSpan(span, partition, offset, err)
 	if err != nil {
-		r.metrics.MessagesPublished.WithLabelValues(r.topic, "error").Inc()
-		return err
+		status := "error"
+		if ctx.Err() != nil {
+			status = "timeout"
+		}
+		r.metrics.MessagesPublished.WithLabelValues(r.topic, status).Inc()
+		return repository.FromKafka("ProducerRepository.SendMessage", err)
 	}
 
 	r.metrics.MessagesPublished.WithLabelValues(r.topic, "success").Inc()
 	return nil
 }
+
+// send hands msg to producer and waits for the broker until ctx ends. A sync
+// producer cannot take back a message, so one given up on may still be
+// written later; like any failed publish it may then be delivered twice when
+// the caller retries.
+func send(ctx context.Context, producer sarama.SyncProducer, msg *sarama.ProducerMessage) (int32, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return -1, -1, err
+	}
+	if producer == nil {
+		// Kafka was unreachable when the service started
+		return -1, -1, sarama.ErrNotConnected
+	}
+
+	type result struct {
+		partition int32
+		offset    int64
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		partition, offset, err := producer.SendMessage(msg)
+		done <- result{partition, offset, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.partition, res.offset, res.err
+	case <-ctx.Done():
+		return -1, -1, ctx.Err()
+	}
+}
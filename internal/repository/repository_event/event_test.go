@@ -0,0 +1,119 @@
+package repository_event
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// consumed turns a produced message into the message a consumer reads
+func consumed(t *testing.T, msg *sarama.ProducerMessage) *sarama.ConsumerMessage {
+	t.Helper()
+	value, err := msg.Value.Encode()
+	require.NoError(t, err)
+
+	out := &sarama.ConsumerMessage{Topic: msg.Topic, Value: value}
+	for _, h := range msg.Headers {
+		out.Headers = append(out.Headers, &h)
+	}
+	return out
+}
+
+func TestSendMessage(t *testing.T) {
+	for _, mode := range []event.Mode{event.Binary, event.Structured} {
+		t.Run(string(mode), func(t *testing.T) {
+			producer := mocks.NewSyncProducer(t, nil)
+			defer producer.Close()
+
+			var sent []*sarama.ConsumerMessage
+			record := func(msg *sarama.ProducerMessage) error {
+				sent = append(sent, consumed(t, msg))
+				return nil
+			}
+			producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(record)
+			producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(record)
+
+			repo := NewProducerRepository(producer, "events", mode, time.Second)
+			ctx := requestid.NewContext(context.Background(), "req-1")
+			require.NoError(t, repo.SendMessage(ctx, "user:1", model.UserChangedEvent{Type: model.UserEventUpdated, Status: model.StatusActive}))
+			require.NoError(t, repo.SendMessage(context.Background(), "message", model.MessageSentEvent{ID: "1", Content: "hello"}))
+			require.Len(t, sent, 2)
+
+			envelope, err := event.FromMessage(sent[0])
+			require.NoError(t, err)
+			assert.Equal(t, model.UserEventUpdated, envelope.Type)
+			assert.Equal(t, "user:1", envelope.Subject)
+			assert.Equal(t, "req-1", envelope.CorrelationID)
+			assert.Equal(t, 1, envelope.SchemaVersion)
+			assert.Contains(t, sent[0].Headers, &sarama.RecordHeader{Key: []byte(requestid.Header), Value: []byte("req-1")})
+
+			changed, err := event.Decode[model.UserChangedEvent](event.Types, envelope)
+			require.NoError(t, err)
+			assert.Equal(t, model.StatusActive, changed.Status)
+
+			envelope, err = event.FromMessage(sent[1])
+			require.NoError(t, err)
+			assert.Equal(t, model.MessageSent, envelope.Type)
+			assert.Empty(t, envelope.CorrelationID)
+		})
+	}
+}
+
+func TestSendMessage_RefusesUnregisteredValues(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	repo := NewProducerRepository(producer, "events", event.Binary, time.Second)
+
+	// A stored user, password hash included, never goes out as is
+	err := repo.SendMessage(context.Background(), "user:1", &model.User{Username: "alice", Password: "secret"})
+	assert.ErrorIs(t, err, event.ErrUnknownType)
+	err = repo.SendMessage(context.Background(), "key", map[string]string{"a": "b"})
+	assert.ErrorIs(t, err, event.ErrUnknownType)
+}
+
+func TestSendMessage_Deadline(t *testing.T) {
+	producer := mocks.NewSyncProducer(t, nil)
+	defer producer.Close()
+
+	// A broker that answers only once the test is done
+	release := make(chan struct{})
+	defer close(release)
+	slow := func(*sarama.ProducerMessage) error {
+		<-release
+		return nil
+	}
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(slow)
+	producer.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(slow)
+
+	repo := NewProducerRepository(producer, "events", event.Binary, 20*time.Millisecond)
+	message := model.MessageSentEvent{ID: "1", Content: "hello"}
+
+	start := time.Now()
+	err := repo.SendMessage(context.Background(), "message", message)
+	assert.ErrorIs(t, err, errors.ErrTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// The caller's deadline applies when it is shorter
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	repo = NewProducerRepository(producer, "events", event.Binary, time.Minute)
+	start = time.Now()
+	err = repo.SendMessage(ctx, "message", message)
+	assert.ErrorIs(t, err, errors.ErrTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// A cancelled request does not reach the broker
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, repo.SendMessage(ctx, "message", message), context.Canceled)
+}
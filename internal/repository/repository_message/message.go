@@ -0,0 +1,256 @@
+package repository_message
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+)
+
+var ErrMessageNotFound = &errors.Error{Code: http.StatusNotFound, Message: "message not found"}
+
+// maxErrorLength bounds the publish error kept on a row
+const maxErrorLength = 1000
+
+// MessageRepository stores the messages sent through the message API and
+// their delivery status
+type MessageRepository struct {
+	db *sql.DB
+}
+
+func NewMessageRepository(db *sql.DB) *MessageRepository {
+	return &MessageRepository{
+		db: db,
+	}
+}
+
+const messageColumns = `
+            id,
+            sender_id,
+            content,
+            status,
+            error,
+            deliver_at,
+            created_at,
+            updated_at,
+            published_at`
+
+func scanMessage(row interface{ Scan(...interface{}) error }) (*model.Message, error) {
+	m := &model.Message{}
+	var senderID, publishErr sql.NullString
+	err := row.Scan(
+		&m.ID,
+		&senderID,
+		&m.Content,
+		&m.Status,
+		&publishErr,
+		&m.DeliverAt,
+		&m.CreatedAt,
+		&m.UpdatedAt,
+		&m.PublishedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.SenderID = senderID.String
+	m.Error = publishErr.String
+	return m, nil
+}
+
+// Create stores m with its status and sets its timestamps
+func (r *MessageRepository) Create(ctx context.Context, m *model.Message) error {
+	now := time.Now().UTC()
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO messages (
+            id,
+            sender_id,
+            content,
+            status,
+            deliver_at,
+            created_at,
+            updated_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		m.ID,
+		sql.NullString{String: m.SenderID, Valid: m.SenderID != ""},
+		m.Content,
+		m.Status,
+		m.DeliverAt,
+		now,
+		now,
+	)
+	if err != nil {
+		return repository.FromMySQL("MessageRepository.Create", "message", err)
+	}
+
+	m.CreatedAt = now
+	m.UpdatedAt = now
+	return nil
+}
+
+func (r *MessageRepository) GetByID(ctx context.Context, id string) (*model.Message, error) {
+	m, err := scanMessage(r.db.QueryRowContext(ctx,
+		"SELECT"+messageColumns+" FROM messages WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, repository.FromMySQL("MessageRepository.GetByID", "message", err)
+	}
+	return m, nil
+}
+
+// List returns one page of messages ordered by id, that is by creation time
+func (r *MessageRepository) List(ctx context.Context, page pagination.Request, filter model.MessageFilter) (*pagination.Page[*model.Message], error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.SenderID != "" {
+		where = append(where, "sender_id = ?")
+		args = append(args, filter.SenderID)
+	}
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM messages"+whereClause(where), args...).Scan(&total); err != nil {
+		return nil, repository.FromMySQL("MessageRepository.List", "message", err)
+	}
+
+	op, dir := ">", "ASC"
+	if page.Desc {
+		op, dir = "<", "DESC"
+	}
+	if page.Cursor != nil {
+		keys := page.Cursor.Strings()
+		if len(keys) != 1 {
+			return nil, pagination.ErrInvalidCursor
+		}
+		where = append(where, "id "+op+" ?")
+		args = append(args, keys[0])
+	}
+	// One extra row tells whether there is a next page
+	args = append(args, page.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT"+messageColumns+" FROM messages"+whereClause(where)+fmt.Sprintf(" ORDER BY id %s LIMIT ?", dir),
+		args...)
+	if err != nil {
+		return nil, repository.FromMySQL("MessageRepository.List", "message", err)
+	}
+	defer rows.Close()
+
+	messages := make([]*model.Message, 0, page.Limit)
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, repository.FromMySQL("MessageRepository.List", "message", err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, repository.FromMySQL("MessageRepository.List", "message", err)
+	}
+
+	result := &pagination.Page[*model.Message]{Data: messages, Total: total}
+	if len(messages) > page.Limit {
+		result.Data = messages[:page.Limit]
+		result.NextCursor = page.Next(result.Data[page.Limit-1].ID)
+	}
+	return result, nil
+}
+
+// Queue moves the scheduled message with id to queued, now that it is due,
+// and returns it, nil when there is no such message. A message that is
+// already queued stays so, its publish was interrupted and is tried again;
+// any other status is returned unchanged for the caller to skip.
+func (r *MessageRepository) Queue(ctx context.Context, id string) (*model.Message, error) {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE messages SET status = ? WHERE id = ? AND status = ?",
+		model.MessageQueued, id, model.MessageScheduled)
+	if err != nil {
+		return nil, repository.FromMySQL("MessageRepository.Queue", "message", err)
+	}
+
+	m, err := r.GetByID(ctx, id)
+	if err == ErrMessageNotFound {
+		return nil, nil
+	}
+	return m, err
+}
+
+// Cancel moves the message with id to cancelled if it is still scheduled,
+// and reports whether it did
+func (r *MessageRepository) Cancel(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE messages SET status = ? WHERE id = ? AND status = ?",
+		model.MessageCancelled, id, model.MessageScheduled)
+	if err != nil {
+		return false, repository.FromMySQL("MessageRepository.Cancel", "message", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, repository.FromMySQL("MessageRepository.Cancel", "message", err)
+	}
+	return rows > 0, nil
+}
+
+// MarkPublished records that the broker acknowledged the message with id.
+// Only a queued message moves, so a late acknowledgement never overwrites
+// a settled status.
+func (r *MessageRepository) MarkPublished(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE messages
+        SET status = ?, error = NULL, published_at = NOW(6)
+        WHERE id = ? AND status = ?`,
+		model.MessagePublished, id, model.MessageQueued)
+	return repository.FromMySQL("MessageRepository.MarkPublished", "message", err)
+}
+
+// MarkFailed records that the queued message with id could not be
+// published, or the scheduled one could not be scheduled, and why
+func (r *MessageRepository) MarkFailed(ctx context.Context, id string, reason string) error {
+	if len(reason) > maxErrorLength {
+		reason = reason[:maxErrorLength]
+	}
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE messages
+        SET status = ?, error = ?
+        WHERE id = ? AND status IN (?, ?)`,
+		model.MessageFailed, reason, id, model.MessageQueued, model.MessageScheduled)
+	return repository.FromMySQL("MessageRepository.MarkFailed", "message", err)
+}
+
+// PurgeDeletedOlderThan removes up to limit published, failed or cancelled
+// messages that settled more than age ago, so messages can share the retention worker
+// with soft deleted records
+func (r *MessageRepository) PurgeDeletedOlderThan(ctx context.Context, age time.Duration, limit int) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM messages WHERE status IN (?, ?, ?) AND updated_at < NOW() - INTERVAL ? SECOND ORDER BY updated_at LIMIT ?",
+		model.MessagePublished, model.MessageFailed, model.MessageCancelled, int64(age.Seconds()), limit)
+	if err != nil {
+		return 0, repository.FromMySQL("MessageRepository.PurgeDeletedOlderThan", "message", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, repository.FromMySQL("MessageRepository.PurgeDeletedOlderThan", "message", err)
+	}
+	return rows, nil
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
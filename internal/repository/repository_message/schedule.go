@@ -0,0 +1,171 @@
+package repository_message
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/go-redis/redis/v8"
+)
+
+// The keys share a hash tag so the claim script can touch all of them on a
+// Redis Cluster
+const (
+	scheduledKey  = "{messages}:scheduled"  // sorted set of message ids by due time
+	processingKey = "{messages}:processing" // sorted set of claimed ids by lease deadline
+	attemptsKey   = "{messages}:attempts"   // hash of message id to claims so far
+)
+
+var ErrScheduleUnavailable = &errors.Error{Code: http.StatusServiceUnavailable, Message: "message schedule unavailable"}
+
+// claimScript takes the claims whose lease expired first, then due scheduled
+// messages, up to the limit. Every claimed id gets a new lease and one more
+// attempt; the reply is a flat list of id and attempt pairs.
+var claimScript = redis.NewScript(`
+local now, limit, deadline = ARGV[1], tonumber(ARGV[2]), ARGV[3]
+local ids = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now, 'LIMIT', 0, limit)
+if #ids < limit then
+  local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now, 'LIMIT', 0, limit - #ids)
+  for _, id in ipairs(due) do
+    redis.call('ZREM', KEYS[1], id)
+    table.insert(ids, id)
+  end
+end
+local claims = {}
+for _, id in ipairs(ids) do
+  redis.call('ZADD', KEYS[2], deadline, id)
+  table.insert(claims, id)
+  table.insert(claims, redis.call('HINCRBY', KEYS[3], id, 1))
+end
+return claims
+`)
+
+// ScheduleRepository holds the messages scheduled for later in Redis until
+// a dispatcher claims them. A claim is leased: a message whose dispatcher
+// neither acknowledges nor reschedules it before the lease ends is claimed
+// again, so every message is published at least once.
+type ScheduleRepository struct {
+	client *redis.Client
+}
+
+func NewScheduleRepository(client *redis.Client) *ScheduleRepository {
+	return &ScheduleRepository{
+		client: client,
+	}
+}
+
+// Add schedules the message with id for at
+func (r *ScheduleRepository) Add(ctx context.Context, id string, at time.Time) error {
+	if r.client == nil {
+		return ErrScheduleUnavailable
+	}
+	err := r.client.ZAdd(ctx, scheduledKey, &redis.Z{Score: float64(at.UnixMilli()), Member: id}).Err()
+	return repository.FromRedis("ScheduleRepository.Add", err)
+}
+
+// Remove takes the message with id off the schedule. A message already
+// claimed stays with its dispatcher.
+func (r *ScheduleRepository) Remove(ctx context.Context, id string) error {
+	if r.client == nil {
+		return ErrScheduleUnavailable
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, scheduledKey, id)
+		pipe.HDel(ctx, attemptsKey, id)
+		return nil
+	})
+	return repository.FromRedis("ScheduleRepository.Remove", err)
+}
+
+// Claim leases up to limit messages due at now to the caller for lease
+func (r *ScheduleRepository) Claim(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]model.MessageClaim, error) {
+	if r.client == nil {
+		return nil, ErrScheduleUnavailable
+	}
+
+	reply, err := claimScript.Run(ctx, r.client,
+		[]string{scheduledKey, processingKey, attemptsKey},
+		now.UnixMilli(), limit, now.Add(lease).UnixMilli(),
+	).Result()
+	if err != nil {
+		return nil, repository.FromRedis("ScheduleRepository.Claim", err)
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values)%2 != 0 {
+		return nil, errors.NewUnavailable("ScheduleRepository.Claim", fmt.Errorf("unexpected claim reply %v", reply))
+	}
+	claims := make([]model.MessageClaim, 0, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		id, _ := values[i].(string)
+		attempt, _ := values[i+1].(int64)
+		claims = append(claims, model.MessageClaim{ID: id, Attempt: int(attempt)})
+	}
+	return claims, nil
+}
+
+// Ack releases the claim on the message with id once it is settled
+func (r *ScheduleRepository) Ack(ctx context.Context, id string) error {
+	if r.client == nil {
+		return ErrScheduleUnavailable
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, processingKey, id)
+		pipe.HDel(ctx, attemptsKey, id)
+		return nil
+	})
+	return repository.FromRedis("ScheduleRepository.Ack", err)
+}
+
+// Retry releases the claim on the message with id and schedules it again for
+// at, keeping its attempts
+func (r *ScheduleRepository) Retry(ctx context.Context, id string, at time.Time) error {
+	if r.client == nil {
+		return ErrScheduleUnavailable
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRem(ctx, processingKey, id)
+		pipe.ZAdd(ctx, scheduledKey, &redis.Z{Score: float64(at.UnixMilli()), Member: id})
+		return nil
+	})
+	return repository.FromRedis("ScheduleRepository.Retry", err)
+}
+
+// Backlog describes the scheduled messages at now
+func (r *ScheduleRepository) Backlog(ctx context.Context, now time.Time) (model.MessageBacklog, error) {
+	if r.client == nil {
+		return model.MessageBacklog{}, ErrScheduleUnavailable
+	}
+
+	var (
+		scheduled, due, inFlight *redis.IntCmd
+		oldest                   *redis.ZSliceCmd
+	)
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		scheduled = pipe.ZCard(ctx, scheduledKey)
+		due = pipe.ZCount(ctx, scheduledKey, "-inf", strconv.FormatInt(now.UnixMilli(), 10))
+		inFlight = pipe.ZCard(ctx, processingKey)
+		oldest = pipe.ZRangeWithScores(ctx, scheduledKey, 0, 0)
+		return nil
+	})
+	if err != nil {
+		return model.MessageBacklog{}, repository.FromRedis("ScheduleRepository.Backlog", err)
+	}
+
+	backlog := model.MessageBacklog{
+		Scheduled: scheduled.Val(),
+		Due:       due.Val(),
+		InFlight:  inFlight.Val(),
+	}
+	if z := oldest.Val(); len(z) > 0 {
+		if lag := now.Sub(time.UnixMilli(int64(z[0].Score))); lag > 0 {
+			backlog.Lag = lag
+		}
+	}
+	return backlog, nil
+}
@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
 	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
 	"github.com/elastic/go-elasticsearch/v8"
 )
 
@@ -38,15 +41,18 @@ func (r *OrderRepository) CreateOrder(ctx context.Context, order *model.Order) e
 		return err
 	}
 
-	_, err = r.client.Index(
+	res, err := r.client.Index(
 		indexName,
 		bytes.NewReader(body),
 		r.client.Index.WithDocumentID(order.ID),
 		r.client.Index.WithContext(ctx),
+		r.client.Index.WithHeader(opaqueID(ctx)),
 		r.client.Index.WithRefresh("true"), // Force immediate refresh
 	)
-
-	if err != nil {
+	if err == nil {
+		defer res.Body.Close()
+	}
+	if err := repository.FromElasticsearch("OrderRepository.CreateOrder", res, err); err != nil {
 		r.metrics.SearchesTotal.WithLabelValues("orders", "error").Inc()
 		return err
 	}
@@ -147,15 +153,18 @@ func (r *OrderRepository) SearchOrders(ctx context.Context, params map[string]in
 
 	res, err := r.client.Search(
 		r.client.Search.WithContext(ctx),
+		r.client.Search.WithHeader(opaqueID(ctx)),
 		r.client.Search.WithIndex(indexName),
 		r.client.Search.WithBody(&buf),
 		// r.client.Search.WithPretty(), // Add pretty printing for debugging
 	)
-	if err != nil {
+	if err == nil {
+		defer res.Body.Close()
+	}
+	if err := repository.FromElasticsearch("OrderRepository.SearchOrders", res, err); err != nil {
 		r.metrics.SearchesTotal.WithLabelValues("orders", "error").Inc()
-		return nil, fmt.Errorf("error getting response: %w", err)
+		return nil, err
 	}
-	defer res.Body.Close()
 
 	// // Debug: Print raw response
 	// if debugResp, err := io.ReadAll(res.Body); err == nil {
@@ -211,3 +220,129 @@ func (r *OrderRepository) SearchOrders(ctx context.Context, params map[string]in
 	r.metrics.SearchesTotal.WithLabelValues("orders", "success").Inc()
 	return orders, nil
 }
+
+// ListOrders returns one page of orders. Elasticsearch pages with
+// search_after: the sort values of the last hit, always ending with the
+// order id as a tie-breaker, become the cursor for the next page.
+func (r *OrderRepository) ListOrders(ctx context.Context, page pagination.Request, filter model.OrderFilter) (*pagination.Page[model.Order], error) {
+	timer := time.Now()
+	defer func() {
+		r.metrics.SearchDuration.WithLabelValues("orders").Observe(time.Since(timer).Seconds())
+	}()
+
+	var filters []map[string]interface{}
+	if filter.Status != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"status": filter.Status}})
+	}
+	if filter.CustomerID != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]interface{}{"customer_id": filter.CustomerID}})
+	}
+	query := map[string]interface{}{"match_all": map[string]interface{}{}}
+	if len(filters) > 0 {
+		query = map[string]interface{}{"bool": map[string]interface{}{"filter": filters}}
+	}
+
+	order := "asc"
+	if page.Desc {
+		order = "desc"
+	}
+	sort := []map[string]interface{}{{page.Sort: order}}
+	if page.Sort != "id" {
+		sort = append(sort, map[string]interface{}{"id": order})
+	}
+
+	searchQuery := map[string]interface{}{
+		"query":            query,
+		"sort":             sort,
+		"size":             page.Limit + 1, // one extra hit tells whether there is a next page
+		"track_total_hits": true,
+	}
+	if page.Cursor != nil {
+		if len(page.Cursor.Values) != len(sort) {
+			r.metrics.SearchesTotal.WithLabelValues("orders", "error").Inc()
+			return nil, pagination.ErrInvalidCursor
+		}
+		searchQuery["search_after"] = page.Cursor.Values
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(searchQuery); err != nil {
+		r.metrics.SearchesTotal.WithLabelValues("orders", "error").Inc()
+		return nil, fmt.Errorf("error encoding query: %w", err)
+	}
+
+	res, err := r.client.Search(
+		r.client.Search.WithContext(ctx),
+		r.client.Search.WithHeader(opaqueID(ctx)),
+		r.client.Search.WithIndex(indexName),
+		r.client.Search.WithBody(&buf),
+	)
+	if err == nil {
+		defer res.Body.Close()
+	}
+	if err := repository.FromElasticsearch("OrderRepository.ListOrders", res, err); err != nil {
+		r.metrics.SearchesTotal.WithLabelValues("orders", "error").Inc()
+		return nil, err
+	}
+
+	var response struct {
+		Hits struct {
+			Total struct {
+				Value int64 `json:"value"`
+			} `json:"total"`
+			Hits []struct {
+				Source model.Order     `json:"_source"`
+				Sort   json.RawMessage `json:"sort"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		r.metrics.SearchesTotal.WithLabelValues("orders", "error").Inc()
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	hits := response.Hits.Hits
+	result := &pagination.Page[model.Order]{
+		Data:  make([]model.Order, 0, page.Limit),
+		Total: response.Hits.Total.Value,
+	}
+	for i, hit := range hits {
+		if i == page.Limit {
+			break
+		}
+		result.Data = append(result.Data, hit.Source)
+	}
+	if len(hits) > page.Limit {
+		cursor, err := sortValues(hits[page.Limit-1].Sort)
+		if err != nil {
+			r.metrics.SearchesTotal.WithLabelValues("orders", "error").Inc()
+			return nil, fmt.Errorf("error parsing sort values: %w", err)
+		}
+		result.NextCursor = page.Next(cursor...)
+	}
+
+	r.metrics.ResultsReturned.WithLabelValues("orders").Observe(float64(result.Total))
+	r.metrics.SearchesTotal.WithLabelValues("orders", "success").Inc()
+	return result, nil
+}
+
+// opaqueID tags a call with the request id of ctx as X-Opaque-Id, so
+// Elasticsearch slow logs and tasks can be matched to the API request
+func opaqueID(ctx context.Context) map[string]string {
+	if id := requestid.FromContext(ctx); id != "" {
+		return map[string]string{"X-Opaque-Id": id}
+	}
+	return nil
+}
+
+// sortValues decodes a hit's sort array keeping numbers exact, dates come
+// back as epoch milliseconds and must be passed to search_after unchanged
+func sortValues(raw json.RawMessage) ([]interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var values []interface{}
+	if err := dec.Decode(&values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
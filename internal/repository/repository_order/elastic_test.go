@@ -0,0 +1,41 @@
+package repository_order
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderRepository_OpaqueID(t *testing.T) {
+	var opaqueIDs []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opaqueIDs = append(opaqueIDs, r.Header.Get("X-Opaque-Id"))
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hits":{"total":{"value":0},"hits":[]}}`))
+	}))
+	defer server.Close()
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	require.NoError(t, err)
+	repo := NewOrderRepository(client)
+
+	ctx := requestid.NewContext(context.Background(), "req-1")
+	require.NoError(t, repo.CreateOrder(ctx, &model.Order{ID: "order-1"}))
+	_, err = repo.SearchOrders(ctx, map[string]interface{}{"customer_id": "c-1"})
+	require.NoError(t, err)
+	_, err = repo.ListOrders(ctx, pagination.Request{Limit: 10, Sort: "id"}, model.OrderFilter{})
+	require.NoError(t, err)
+	_, err = repo.SearchOrders(context.Background(), map[string]interface{}{})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"req-1", "req-1", "req-1", ""}, opaqueIDs)
+}
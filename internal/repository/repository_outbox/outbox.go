@@ -0,0 +1,220 @@
+package repository_outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+)
+
+// maxErrorLength bounds the publish error kept on a row
+const maxErrorLength = 1000
+
+// Insert adds msg to the outbox inside tx, so it is committed or rolled back
+// together with the change it describes. msg.ID is set from the new row.
+func Insert(ctx context.Context, tx *sql.Tx, msg *model.OutboxMessage) error {
+	var traceContext []byte
+	if len(msg.TraceContext) > 0 {
+		var err error
+		traceContext, err = json.Marshal(msg.TraceContext)
+		if err != nil {
+			return fmt.Errorf("failed to encode outbox trace context: %w", err)
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `
+        INSERT INTO outbox (
+            aggregate_type,
+            aggregate_id,
+            event_type,
+            message_key,
+            payload,
+            request_id,
+            trace_context
+        ) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		msg.AggregateType,
+		msg.AggregateID,
+		msg.EventType,
+		msg.Key,
+		string(msg.Payload),
+		msg.RequestID,
+		traceContext,
+	)
+	if err != nil {
+		return repository.FromMySQL("outbox.Insert", "outbox message", err)
+	}
+
+	msg.ID, err = result.LastInsertId()
+	if err != nil {
+		return repository.FromMySQL("outbox.Insert", "outbox message", err)
+	}
+	return nil
+}
+
+// OutboxRepository reads and settles the rows written by Insert for the
+// outbox relay
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db: db,
+	}
+}
+
+// Pending returns up to limit messages that are due, in the order they were
+// written. Only the oldest unpublished message of each aggregate is returned,
+// a later one waits until every earlier one is published or parked, so a
+// message waiting for its next attempt holds back its own aggregate only.
+// Whether a message is due is decided by the database, on the clock that set
+// next_attempt_at.
+func (r *OutboxRepository) Pending(ctx context.Context, limit int) ([]*model.OutboxMessage, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT
+            o.id,
+            o.aggregate_type,
+            o.aggregate_id,
+            o.event_type,
+            o.message_key,
+            o.payload,
+            o.request_id,
+            o.trace_context,
+            o.attempts,
+            o.created_at,
+            o.next_attempt_at
+        FROM outbox o
+        WHERE o.published_at IS NULL
+          AND o.parked_at IS NULL
+          AND o.next_attempt_at <= NOW(6)
+          AND NOT EXISTS (
+              SELECT 1
+              FROM outbox earlier
+              WHERE earlier.aggregate_type = o.aggregate_type
+                AND earlier.aggregate_id = o.aggregate_id
+                AND earlier.id < o.id
+                AND earlier.published_at IS NULL
+                AND earlier.parked_at IS NULL
+          )
+        ORDER BY o.id
+        LIMIT ?`, limit)
+	if err != nil {
+		return nil, repository.FromMySQL("OutboxRepository.Pending", "outbox message", err)
+	}
+	defer rows.Close()
+
+	var messages []*model.OutboxMessage
+	for rows.Next() {
+		msg := &model.OutboxMessage{}
+		var traceContext []byte
+		if err := rows.Scan(
+			&msg.ID,
+			&msg.AggregateType,
+			&msg.AggregateID,
+			&msg.EventType,
+			&msg.Key,
+			&msg.Payload,
+			&msg.RequestID,
+			&traceContext,
+			&msg.Attempts,
+			&msg.CreatedAt,
+			&msg.NextAttemptAt,
+		); err != nil {
+			return nil, repository.FromMySQL("OutboxRepository.Pending", "outbox message", err)
+		}
+		// A context that cannot be read only costs the link to the request
+		if len(traceContext) > 0 {
+			_ = json.Unmarshal(traceContext, &msg.TraceContext)
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, repository.FromMySQL("OutboxRepository.Pending", "outbox message", err)
+	}
+
+	return messages, nil
+}
+
+// MarkPublished records that the message with id reached Kafka
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id int64) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE outbox SET published_at = NOW(6) WHERE id = ?", id)
+	return repository.FromMySQL("OutboxRepository.MarkPublished", "outbox message", err)
+}
+
+// MarkFailed counts a failed publish of the message with id and holds it
+// back for retryIn. The delay is added by the database so it uses the same
+// clock that wrote created_at.
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, cause error, retryIn time.Duration) error {
+	msg := cause.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE outbox
+        SET attempts = attempts + 1,
+            last_error = ?,
+            next_attempt_at = NOW(6) + INTERVAL ? MICROSECOND
+        WHERE id = ?`,
+		msg, retryIn.Microseconds(), id)
+	return repository.FromMySQL("OutboxRepository.MarkFailed", "outbox message", err)
+}
+
+// Park gives up on the message with id after its last failed publish. A
+// parked message is kept with its error for inspection but no longer sent,
+// and the later messages of its aggregate go out without it.
+func (r *OutboxRepository) Park(ctx context.Context, id int64, cause error) error {
+	msg := cause.Error()
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE outbox
+        SET attempts = attempts + 1,
+            last_error = ?,
+            parked_at = NOW(6)
+        WHERE id = ?`,
+		msg, id)
+	return repository.FromMySQL("OutboxRepository.Park", "outbox message", err)
+}
+
+// Stats returns the number of unpublished messages that are not parked and
+// the age of the oldest one, zero when the outbox is drained
+func (r *OutboxRepository) Stats(ctx context.Context) (int64, time.Duration, error) {
+	var (
+		pending int64
+		lag     sql.NullInt64
+	)
+	err := r.db.QueryRowContext(ctx, `
+        SELECT COUNT(*), TIMESTAMPDIFF(MICROSECOND, MIN(created_at), NOW(6))
+        FROM outbox
+        WHERE published_at IS NULL AND parked_at IS NULL`).Scan(&pending, &lag)
+	if err != nil {
+		return 0, 0, repository.FromMySQL("OutboxRepository.Stats", "outbox message", err)
+	}
+
+	return pending, time.Duration(lag.Int64) * time.Microsecond, nil
+}
+
+// PurgeDeletedOlderThan removes up to limit messages published more than age
+// ago, so the outbox can share the retention worker with soft deleted records
+func (r *OutboxRepository) PurgeDeletedOlderThan(ctx context.Context, age time.Duration, limit int) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM outbox WHERE published_at < NOW() - INTERVAL ? SECOND ORDER BY published_at LIMIT ?",
+		int64(age.Seconds()), limit)
+	if err != nil {
+		return 0, repository.FromMySQL("OutboxRepository.PurgeDeletedOlderThan", "outbox message", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, repository.FromMySQL("OutboxRepository.PurgeDeletedOlderThan", "outbox message", err)
+	}
+	return rows, nil
+}
@@ -3,14 +3,20 @@ package repository_product
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
 	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
 )
 
-var ErrProductNotFound = errors.New("product not found")
+var ErrProductNotFound = &errors.Error{Code: http.StatusNotFound, Message: "product not found"}
 
 type ProductRepository struct {
 	db      *sql.DB
@@ -54,17 +60,19 @@ func (r *ProductRepository) Create(ctx context.Context, product *model.Product)
 
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("create", "products", "error").Inc()
-		return err
+		return repository.FromPostgres("ProductRepository.Create", "product", err)
 	}
 
+	product.Version = 1
 	r.metrics.QueriesTotal.WithLabelValues("create", "products", "success").Inc()
 	r.metrics.ConnectionsOpen.WithLabelValues("postgres").Set(float64(r.db.Stats().OpenConnections))
-	return err
+	return nil
 }
 
 func (r *ProductRepository) CreateProduct(ctx context.Context, product *model.Product) error {
 	query := `INSERT INTO products (name, price, created_at, updated_at) VALUES ($1, $2, NOW(), NOW()) RETURNING id`
-	return r.db.QueryRowContext(ctx, query, product.Name, product.Price).Scan(&product.ID)
+	err := r.db.QueryRowContext(ctx, query, product.Name, product.Price).Scan(&product.ID)
+	return repository.FromPostgres("ProductRepository.CreateProduct", "product", err)
 }
 
 func (r *ProductRepository) GetByID(ctx context.Context, id int64) (*model.Product, error) {
@@ -86,7 +94,7 @@ func (r *ProductRepository) GetByID(ctx context.Context, id int64) (*model.Produ
             updated_at,
             version
         FROM products
-        WHERE id = $1`
+        WHERE id = $1 AND deleted_at IS NULL`
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&product.ID,
@@ -105,7 +113,7 @@ func (r *ProductRepository) GetByID(ctx context.Context, id int64) (*model.Produ
 	}
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("get", "products", "error").Inc()
-		return nil, err
+		return nil, repository.FromPostgres("ProductRepository.GetByID", "product", err)
 	}
 
 	r.metrics.QueriesTotal.WithLabelValues("get", "products", "success").Inc()
@@ -131,12 +139,13 @@ func (r *ProductRepository) GetAll(ctx context.Context) ([]*model.Product, error
             updated_at,
             version
         FROM products
+        WHERE deleted_at IS NULL
         ORDER BY id`
 
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("list", "products", "error").Inc()
-		return nil, err
+		return nil, repository.FromPostgres("ProductRepository.GetAll", "product", err)
 	}
 	defer rows.Close()
 
@@ -156,7 +165,7 @@ func (r *ProductRepository) GetAll(ctx context.Context) ([]*model.Product, error
 		)
 		if err != nil {
 			r.metrics.QueriesTotal.WithLabelValues("list", "products", "error").Inc()
-			return nil, err
+			return nil, repository.FromPostgres("ProductRepository.GetAll", "product", err)
 		}
 		products = append(products, product)
 	}
@@ -180,30 +189,29 @@ func (r *ProductRepository) Update(ctx context.Context, product *model.Product)
             price = $3,
             sku = $4,
             stock = $5,
-            updated_at = NOW()
-        WHERE id = $6`
+            updated_at = NOW(),
+            version = version + 1
+        WHERE id = $6 AND version = $7 AND deleted_at IS NULL
+        RETURNING version, updated_at`
 
-	result, err := r.db.ExecContext(ctx, query,
+	// Compare-and-swap on version so that concurrent writers cannot silently
+	// overwrite each other
+	err := r.db.QueryRowContext(ctx, query,
 		product.Name,
 		product.Description,
 		product.Price,
 		product.SKU,
 		product.Stock,
 		product.ID,
-	)
-	if err != nil {
+		product.Version,
+	).Scan(&product.Version, &product.UpdatedAt)
+	if err == sql.ErrNoRows {
 		r.metrics.QueriesTotal.WithLabelValues("update", "products", "error").Inc()
-		return err
+		return r.versionMismatch(ctx, product.ID, product.Version)
 	}
-
-	rows, err := result.RowsAffected()
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("update", "products", "error").Inc()
-		return err
-	}
-	if rows == 0 {
-		r.metrics.QueriesTotal.WithLabelValues("update", "products", "error").Inc()
-		return ErrProductNotFound
+		return repository.FromPostgres("ProductRepository.Update", "product", err)
 	}
 
 	r.metrics.QueriesTotal.WithLabelValues("update", "products", "success").Inc()
@@ -211,26 +219,36 @@ func (r *ProductRepository) Update(ctx context.Context, product *model.Product)
 	return nil
 }
 
-func (r *ProductRepository) Delete(ctx context.Context, id int64) error {
+// Delete soft deletes a product if its stored version still equals version.
+// The row is kept with a deleted_at timestamp until it is restored or purged.
+func (r *ProductRepository) Delete(ctx context.Context, id int64, version int) error {
 	timer := time.Now()
 	defer func() {
 		r.metrics.QueryDuration.WithLabelValues("delete", "products").Observe(time.Since(timer).Seconds())
 	}()
 
-	result, err := r.db.ExecContext(ctx, "DELETE FROM products WHERE id = $1", id)
+	query := `
+        UPDATE products
+        SET
+            deleted_at = NOW(),
+            updated_at = NOW(),
+            version = version + 1
+        WHERE id = $1 AND version = $2 AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id, version)
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("delete", "products", "error").Inc()
-		return err
+		return repository.FromPostgres("ProductRepository.Delete", "product", err)
 	}
 
 	rows, err := result.RowsAffected()
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("delete", "products", "error").Inc()
-		return err
+		return repository.FromPostgres("ProductRepository.Delete", "product", err)
 	}
 	if rows == 0 {
 		r.metrics.QueriesTotal.WithLabelValues("delete", "products", "error").Inc()
-		return ErrProductNotFound
+		return r.versionMismatch(ctx, id, version)
 	}
 
 	r.metrics.QueriesTotal.WithLabelValues("delete", "products", "success").Inc()
@@ -238,12 +256,172 @@ func (r *ProductRepository) Delete(ctx context.Context, id int64) error {
 	return nil
 }
 
-func (r *ProductRepository) List(ctx context.Context) ([]*model.Product, error) {
+// Restore brings a soft deleted product back
+func (r *ProductRepository) Restore(ctx context.Context, id int64) error {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("restore", "products").Observe(time.Since(timer).Seconds())
+	}()
+
+	query := `
+        UPDATE products
+        SET
+            deleted_at = NULL,
+            updated_at = NOW(),
+            version = version + 1
+        WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("restore", "products", "error").Inc()
+		return repository.FromPostgres("ProductRepository.Restore", "product", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("restore", "products", "error").Inc()
+		return repository.FromPostgres("ProductRepository.Restore", "product", err)
+	}
+	if rows == 0 {
+		r.metrics.QueriesTotal.WithLabelValues("restore", "products", "error").Inc()
+		return r.notDeleted(ctx, id)
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("restore", "products", "success").Inc()
+	r.metrics.ConnectionsOpen.WithLabelValues("postgres").Set(float64(r.db.Stats().OpenConnections))
+	return nil
+}
+
+// Purge permanently removes a soft deleted product. Live products cannot be purged.
+func (r *ProductRepository) Purge(ctx context.Context, id int64) error {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("purge", "products").Observe(time.Since(timer).Seconds())
+	}()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM products WHERE id = $1 AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("purge", "products", "error").Inc()
+		return repository.FromPostgres("ProductRepository.Purge", "product", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("purge", "products", "error").Inc()
+		return repository.FromPostgres("ProductRepository.Purge", "product", err)
+	}
+	if rows == 0 {
+		r.metrics.QueriesTotal.WithLabelValues("purge", "products", "error").Inc()
+		return r.notDeleted(ctx, id)
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("purge", "products", "success").Inc()
+	r.metrics.ConnectionsOpen.WithLabelValues("postgres").Set(float64(r.db.Stats().OpenConnections))
+	return nil
+}
+
+// PurgeDeletedOlderThan permanently removes up to limit products that were
+// soft deleted more than age ago, oldest first, and returns how many were removed
+func (r *ProductRepository) PurgeDeletedOlderThan(ctx context.Context, age time.Duration, limit int) (int64, error) {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("purge_expired", "products").Observe(time.Since(timer).Seconds())
+	}()
+
+	query := `
+        DELETE FROM products
+        WHERE id IN (
+            SELECT id FROM products
+            WHERE deleted_at < NOW() - $1 * INTERVAL '1 second'
+            ORDER BY deleted_at
+            LIMIT $2
+        )`
+
+	// The cutoff is computed by the database so it uses the same clock and
+	// time zone that wrote deleted_at
+	result, err := r.db.ExecContext(ctx, query, int64(age.Seconds()), limit)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("purge_expired", "products", "error").Inc()
+		return 0, repository.FromPostgres("ProductRepository.PurgeDeletedOlderThan", "product", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("purge_expired", "products", "error").Inc()
+		return 0, repository.FromPostgres("ProductRepository.PurgeDeletedOlderThan", "product", err)
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("purge_expired", "products", "success").Inc()
+	r.metrics.ConnectionsOpen.WithLabelValues("postgres").Set(float64(r.db.Stats().OpenConnections))
+	return rows, nil
+}
+
+// productSortColumns maps the sortable fields to their columns
+var productSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"price":      "price",
+	"stock":      "stock",
+	"created_at": "created_at",
+}
+
+// List returns one page of products using keyset pagination on (sort column, id)
+func (r *ProductRepository) List(ctx context.Context, page pagination.Request, filter model.ProductFilter) (*pagination.Page[*model.Product], error) {
 	timer := time.Now()
 	defer func() {
 		r.metrics.QueryDuration.WithLabelValues("list", "products").Observe(time.Since(timer).Seconds())
 	}()
 
+	column, ok := productSortColumns[page.Sort]
+	if !ok {
+		r.metrics.QueriesTotal.WithLabelValues("list", "products", "error").Inc()
+		return nil, fmt.Errorf("%w: %s", pagination.ErrInvalidSort, page.Sort)
+	}
+
+	where := []string{"deleted_at IS NULL"}
+	if filter.Deleted {
+		where[0] = "deleted_at IS NOT NULL"
+	}
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.MinPrice != nil {
+		where = append(where, "price >= "+arg(*filter.MinPrice))
+	}
+	if filter.MaxPrice != nil {
+		where = append(where, "price <= "+arg(*filter.MaxPrice))
+	}
+	if filter.MinStock != nil {
+		where = append(where, "stock >= "+arg(*filter.MinStock))
+	}
+	filterClause := " WHERE " + strings.Join(where, " AND ")
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM products"+filterClause, args...).Scan(&total); err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("list", "products", "error").Inc()
+		return nil, repository.FromPostgres("ProductRepository.List", "product", err)
+	}
+
+	op, dir := ">", "ASC"
+	if page.Desc {
+		op, dir = "<", "DESC"
+	}
+	if page.Cursor != nil {
+		keys := page.Cursor.Strings()
+		switch {
+		case column == "id" && len(keys) == 1:
+			where = append(where, "id "+op+" "+arg(keys[0]))
+		case column != "id" && len(keys) == 2:
+			// Row comparison lets Postgres use a (column, id) index
+			where = append(where, fmt.Sprintf("(%s, id) %s (%s, %s)", column, op, arg(keys[0]), arg(keys[1])))
+		default:
+			r.metrics.QueriesTotal.WithLabelValues("list", "products", "error").Inc()
+			return nil, pagination.ErrInvalidCursor
+		}
+	}
+
 	query := `
         SELECT 
             id,
@@ -254,18 +432,25 @@ func (r *ProductRepository) List(ctx context.Context) ([]*model.Product, error)
             stock,
             created_at,
             updated_at,
+            deleted_at,
             version
-        FROM products
-        ORDER BY id`
+        FROM products WHERE ` + strings.Join(where, " AND ")
+	if column == "id" {
+		query += fmt.Sprintf(" ORDER BY id %s", dir)
+	} else {
+		query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s", column, dir)
+	}
+	// One extra row tells whether there is a next page
+	query += " LIMIT " + arg(page.Limit+1)
 
-	rows, err := r.db.QueryContext(ctx, query)
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("list", "products", "error").Inc()
-		return nil, err
+		return nil, repository.FromPostgres("ProductRepository.List", "product", err)
 	}
 	defer rows.Close()
 
-	var products []*model.Product
+	products := make([]*model.Product, 0, page.Limit)
 	for rows.Next() {
 		product := &model.Product{}
 		err := rows.Scan(
@@ -277,16 +462,76 @@ func (r *ProductRepository) List(ctx context.Context) ([]*model.Product, error)
 			&product.Stock,
 			&product.CreatedAt,
 			&product.UpdatedAt,
+			&product.DeletedAt,
 			&product.Version,
 		)
 		if err != nil {
 			r.metrics.QueriesTotal.WithLabelValues("list", "products", "error").Inc()
-			return nil, err
+			return nil, repository.FromPostgres("ProductRepository.List", "product", err)
 		}
 		products = append(products, product)
 	}
+	if err := rows.Err(); err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("list", "products", "error").Inc()
+		return nil, repository.FromPostgres("ProductRepository.List", "product", err)
+	}
+
+	result := &pagination.Page[*model.Product]{Data: products, Total: total}
+	if len(products) > page.Limit {
+		result.Data = products[:page.Limit]
+		last := result.Data[page.Limit-1]
+		id := strconv.FormatInt(last.ID, 10)
+		if column == "id" {
+			result.NextCursor = page.Next(id)
+		} else {
+			result.NextCursor = page.Next(productSortKey(last, column), id)
+		}
+	}
 
 	r.metrics.QueriesTotal.WithLabelValues("list", "products", "success").Inc()
 	r.metrics.ConnectionsOpen.WithLabelValues("postgres").Set(float64(r.db.Stats().OpenConnections))
-	return products, nil
+	return result, nil
+}
+
+// productSortKey formats a product's sort column as text Postgres can cast back
+func productSortKey(product *model.Product, column string) string {
+	switch column {
+	case "name":
+		return product.Name
+	case "price":
+		return strconv.FormatFloat(product.Price, 'f', -1, 64)
+	case "stock":
+		return strconv.Itoa(product.Stock)
+	case "created_at":
+		return product.CreatedAt.Format("2006-01-02 15:04:05.999999")
+	}
+	return strconv.FormatInt(product.ID, 10)
+}
+
+// versionMismatch explains why a compare-and-swap write matched no rows:
+// either the product does not exist or its version has moved on
+func (r *ProductRepository) versionMismatch(ctx context.Context, id int64, expected int) error {
+	var current int
+	err := r.db.QueryRowContext(ctx, "SELECT version FROM products WHERE id = $1 AND deleted_at IS NULL", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return repository.FromPostgres("ProductRepository.versionMismatch", "product", err)
+	}
+	return &repository.VersionConflictError{Entity: "product", ID: id, Expected: expected, Current: current}
+}
+
+// notDeleted explains why a restore or purge matched no rows: either the
+// product does not exist or it has not been deleted
+func (r *ProductRepository) notDeleted(ctx context.Context, id int64) error {
+	var exists int
+	err := r.db.QueryRowContext(ctx, "SELECT 1 FROM products WHERE id = $1", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return repository.FromPostgres("ProductRepository.notDeleted", "product", err)
+	}
+	return repository.ErrNotDeleted
 }
@@ -0,0 +1,134 @@
+package repository_role
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/google/uuid"
+)
+
+var ErrRoleNotFound = &errors.Error{Code: http.StatusNotFound, Message: "role not found"}
+
+// RoleRepository reads and assigns roles stored in the MySQL roles,
+// role_permissions and user_roles tables
+type RoleRepository struct {
+	db      *sql.DB
+	metrics *metrics.DatabaseMetrics
+}
+
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{
+		db:      db,
+		metrics: metrics.NewDatabaseMetrics("role"),
+	}
+}
+
+func (r *RoleRepository) PermissionsForUser(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	query := `
+        SELECT DISTINCT rp.permission
+        FROM user_roles ur
+        JOIN role_permissions rp ON rp.role_id = ur.role_id
+        WHERE ur.user_id = ?`
+
+	return r.queryPermissions(ctx, "permissions_for_user", query, userID)
+}
+
+func (r *RoleRepository) PermissionsForRole(ctx context.Context, role string) ([]string, error) {
+	query := `
+        SELECT rp.permission
+        FROM roles r
+        JOIN role_permissions rp ON rp.role_id = r.id
+        WHERE r.name = ?`
+
+	return r.queryPermissions(ctx, "permissions_for_role", query, role)
+}
+
+// AssignRole grants a role to a user. Assigning a role the user already holds is a no-op.
+func (r *RoleRepository) AssignRole(ctx context.Context, userID uuid.UUID, role string) error {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("assign_role", "user_roles").Observe(time.Since(timer).Seconds())
+	}()
+
+	query := `
+        INSERT IGNORE INTO user_roles (user_id, role_id)
+        SELECT ?, id FROM roles WHERE name = ?`
+
+	result, err := r.db.ExecContext(ctx, query, userID, role)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("assign_role", "user_roles", "error").Inc()
+		return repository.FromMySQL("RoleRepository.AssignRole", "role", err)
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows == 0 {
+		// Nothing inserted: either the role does not exist or it was already assigned
+		var exists int
+		if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM roles WHERE name = ?", role).Scan(&exists); err != nil {
+			r.metrics.QueriesTotal.WithLabelValues("assign_role", "user_roles", "error").Inc()
+			return repository.FromMySQL("RoleRepository.AssignRole", "role", err)
+		}
+		if exists == 0 {
+			r.metrics.QueriesTotal.WithLabelValues("assign_role", "user_roles", "error").Inc()
+			return ErrRoleNotFound
+		}
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("assign_role", "user_roles", "success").Inc()
+	return nil
+}
+
+func (r *RoleRepository) RevokeRole(ctx context.Context, userID uuid.UUID, role string) error {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("revoke_role", "user_roles").Observe(time.Since(timer).Seconds())
+	}()
+
+	query := `
+        DELETE ur FROM user_roles ur
+        JOIN roles r ON r.id = ur.role_id
+        WHERE ur.user_id = ? AND r.name = ?`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, role); err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("revoke_role", "user_roles", "error").Inc()
+		return repository.FromMySQL("RoleRepository.RevokeRole", "role", err)
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("revoke_role", "user_roles", "success").Inc()
+	return nil
+}
+
+func (r *RoleRepository) queryPermissions(ctx context.Context, op, query string, arg interface{}) ([]string, error) {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues(op, "role_permissions").Observe(time.Since(timer).Seconds())
+	}()
+
+	rows, err := r.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues(op, "role_permissions", "error").Inc()
+		return nil, repository.FromMySQL("RoleRepository.queryPermissions", "role", err)
+	}
+	defer rows.Close()
+
+	permissions := make([]string, 0)
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			r.metrics.QueriesTotal.WithLabelValues(op, "role_permissions", "error").Inc()
+			return nil, repository.FromMySQL("RoleRepository.queryPermissions", "role", err)
+		}
+		permissions = append(permissions, permission)
+	}
+	if err := rows.Err(); err != nil {
+		r.metrics.QueriesTotal.WithLabelValues(op, "role_permissions", "error").Inc()
+		return nil, repository.FromMySQL("RoleRepository.queryPermissions", "role", err)
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues(op, "role_permissions", "success").Inc()
+	return permissions, nil
+}
@@ -0,0 +1,52 @@
+package repository_token
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/go-redis/redis/v8"
+)
+
+const revokedKeyPrefix = "auth:revoked:"
+
+var ErrStoreUnavailable = &errors.Error{Code: http.StatusServiceUnavailable, Message: "token revocation store unavailable"}
+
+// RevocationRepository keeps revoked token IDs in Redis until the tokens expire
+type RevocationRepository struct {
+	client *redis.Client
+}
+
+func NewRevocationRepository(client *redis.Client) *RevocationRepository {
+	return &RevocationRepository{
+		client: client,
+	}
+}
+
+// Revoke marks tokenID revoked for ttl with SET NX, so it reports whether
+// this call revoked it even when several race for the same token
+func (r *RevocationRepository) Revoke(ctx context.Context, tokenID string, ttl time.Duration) (bool, error) {
+	if r.client == nil {
+		return false, ErrStoreUnavailable
+	}
+
+	revoked, err := r.client.SetNX(ctx, revokedKeyPrefix+tokenID, 1, ttl).Result()
+	if err != nil {
+		return false, repository.FromRedis("RevocationRepository.Revoke", err)
+	}
+	return revoked, nil
+}
+
+func (r *RevocationRepository) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	if r.client == nil {
+		return false, ErrStoreUnavailable
+	}
+
+	n, err := r.client.Exists(ctx, revokedKeyPrefix+tokenID).Result()
+	if err != nil {
+		return false, repository.FromRedis("RevocationRepository.IsRevoked", err)
+	}
+	return n > 0, nil
+}
@@ -3,16 +3,26 @@ package repository_user
 import (
 	"context"
 	"database/sql"
-	"errors"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_outbox"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
 	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/password"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/Napat/golang-testcontainers-demo/pkg/tracing"
 	"github.com/google/uuid"
 )
 
-var ErrUserNotFound = errors.New("user not found")
+var ErrUserNotFound = &errors.Error{Code: http.StatusNotFound, Message: "user not found"}
 
 type UserRepository struct {
 	db      *sql.DB
@@ -41,10 +51,16 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 		user.ID = uuid.Must(uuid.NewV7())
 	}
 
+	// Never persist a plaintext password, callers hash it with User.SetPassword
+	if !password.Default().IsHashed(user.Password) {
+		r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
+		return stderrors.New("refusing to store an unhashed password")
+	}
+
 	// Start a transaction
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return repository.FromMySQL("UserRepository.Create", "user", err)
 	}
 	// Defer a rollback in case anything fails
 	defer tx.Rollback()
@@ -56,7 +72,7 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 		user.Username, user.Email).Scan(&exists)
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
-		return fmt.Errorf("failed to check username/email existence: %w", err)
+		return repository.FromMySQL("UserRepository.Create", "user", fmt.Errorf("failed to check username/email existence: %w", err))
 	}
 	if exists > 0 {
 		// Check which one is duplicate
@@ -66,14 +82,13 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 			user.Username, user.Email).Scan(&existingUser.Username, &existingUser.Email)
 		if err != nil && err != sql.ErrNoRows {
 			r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
-			return err
+			return repository.FromMySQL("UserRepository.Create", "user", err)
 		}
+		r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
 		if existingUser.Username == user.Username {
-			r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
-			return fmt.Errorf("Error 1062 (23000): Duplicate entry '%s' for key 'users.username'", user.Username)
+			return errors.NewDuplicate("UserRepository.Create", "user", "username", nil)
 		}
-		r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
-		return fmt.Errorf("Error 1062 (23000): Duplicate entry '%s' for key 'users.email'", user.Email)
+		return errors.NewDuplicate("UserRepository.Create", "user", "email", nil)
 	}
 
 	query := `
@@ -96,8 +111,31 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 		user.FullName,
 		user.Password,
 		string(user.Status),
-		1,
+		1, // Initial version
 	)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
+		return repository.FromMySQL("UserRepository.Create", "user", err)
+	}
+
+	// Record the user.created event in the same transaction so it is
+	// published exactly when the user exists
+	created := *user
+	created.Version = 1
+	payload, err := json.Marshal(created.ToResponse())
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
+		return fmt.Errorf("failed to encode user created event: %w", err)
+	}
+	err = repository_outbox.Insert(ctx, tx, &model.OutboxMessage{
+		AggregateType: "user",
+		AggregateID:   user.ID.String(),
+		EventType:     model.UserEventCreated,
+		Key:           "user:" + user.ID.String(),
+		Payload:       payload,
+		RequestID:     requestid.FromContext(ctx),
+		TraceContext:  tracing.Inject(ctx),
+	})
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
 		return err
@@ -106,8 +144,9 @@ func (r *UserRepository) Create(ctx context.Context, user *model.User) error {
 	// If everything went well, commit the transaction
 	if err = tx.Commit(); err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("create", "users", "error").Inc()
-		return err
+		return repository.FromMySQL("UserRepository.Create", "user", err)
 	}
+	user.Version = 1
 
 	r.metrics.QueriesTotal.WithLabelValues("create", "users", "success").Inc()
 	r.metrics.ConnectionsOpen.WithLabelValues("mysql").Set(float64(r.db.Stats().OpenConnections))
@@ -135,7 +174,7 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User
             updated_at,
             version
         FROM users
-        WHERE id = ?`
+        WHERE id = ? AND deleted_at IS NULL`
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
 		&user.ID,
@@ -154,7 +193,7 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User
 	}
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("get_by_id", "users", "error").Inc()
-		return nil, err
+		return nil, repository.FromMySQL("UserRepository.GetByID", "user", err)
 	}
 
 	r.metrics.QueriesTotal.WithLabelValues("get_by_id", "users", "success").Inc()
@@ -163,6 +202,54 @@ func (r *UserRepository) GetByID(ctx context.Context, id uuid.UUID) (*model.User
 	return user, nil
 }
 
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("get_by_username", "users").Observe(time.Since(timer).Seconds())
+	}()
+
+	user := &model.User{}
+
+	query := `
+        SELECT
+            id,
+            username,
+            email,
+            full_name,
+            password,
+            status,
+            created_at,
+            updated_at,
+            version
+        FROM users
+        WHERE username = ? AND deleted_at IS NULL`
+
+	err := r.db.QueryRowContext(ctx, query, username).Scan(
+		&user.ID,
+		&user.Username,
+		&user.Email,
+		&user.FullName,
+		&user.Password,
+		&user.Status,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.Version,
+	)
+	if err == sql.ErrNoRows {
+		r.metrics.QueriesTotal.WithLabelValues("get_by_username", "users", "error").Inc()
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("get_by_username", "users", "error").Inc()
+		return nil, repository.FromMySQL("UserRepository.GetByUsername", "user", err)
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("get_by_username", "users", "success").Inc()
+	r.metrics.ConnectionsOpen.WithLabelValues("mysql").Set(float64(r.db.Stats().OpenConnections))
+
+	return user, nil
+}
+
 func (r *UserRepository) GetAll(ctx context.Context) ([]*model.User, error) {
 	timer := time.Now()
 	defer func() {
@@ -182,12 +269,13 @@ func (r *UserRepository) GetAll(ctx context.Context) ([]*model.User, error) {
             updated_at,
             version
         FROM users
+        WHERE deleted_at IS NULL
         ORDER BY id`
 
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("get_all", "users", "error").Inc()
-		return nil, err
+		return nil, repository.FromMySQL("UserRepository.GetAll", "user", err)
 	}
 	defer rows.Close()
 
@@ -208,7 +296,7 @@ func (r *UserRepository) GetAll(ctx context.Context) ([]*model.User, error) {
 		)
 		if err != nil {
 			r.metrics.QueriesTotal.WithLabelValues("get_all", "users", "error").Inc()
-			return nil, err
+			return nil, repository.FromMySQL("UserRepository.GetAll", "user", err)
 		}
 		users = append(users, user)
 	}
@@ -219,20 +307,172 @@ func (r *UserRepository) GetAll(ctx context.Context) ([]*model.User, error) {
 	return users, nil
 }
 
+// userSortColumns maps the sortable fields to their columns
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"username":   "username",
+	"email":      "email",
+	"created_at": "created_at",
+}
+
+// List returns one page of users using keyset pagination on (sort column, id).
+// UUIDv7 ids are time ordered, so the default sort by id is also creation order.
+func (r *UserRepository) List(ctx context.Context, page pagination.Request, filter model.UserFilter) (*pagination.Page[*model.User], error) {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("list", "users").Observe(time.Since(timer).Seconds())
+	}()
+
+	column, ok := userSortColumns[page.Sort]
+	if !ok {
+		r.metrics.QueriesTotal.WithLabelValues("list", "users", "error").Inc()
+		return nil, fmt.Errorf("%w: %s", pagination.ErrInvalidSort, page.Sort)
+	}
+
+	where := []string{"deleted_at IS NULL"}
+	if filter.Deleted {
+		where[0] = "deleted_at IS NOT NULL"
+	}
+	var args []interface{}
+	if filter.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, filter.Status)
+	}
+	if filter.EmailDomain != "" {
+		// Compared as a whole, so % and _ in the filter are not wildcards
+		where = append(where, "SUBSTRING_INDEX(email, '@', -1) = ?")
+		args = append(args, filter.EmailDomain)
+	}
+	filterClause := " WHERE " + strings.Join(where, " AND ")
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+filterClause, args...).Scan(&total); err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("list", "users", "error").Inc()
+		return nil, repository.FromMySQL("UserRepository.List", "user", err)
+	}
+
+	op, dir := ">", "ASC"
+	if page.Desc {
+		op, dir = "<", "DESC"
+	}
+	if page.Cursor != nil {
+		keys := page.Cursor.Strings()
+		switch {
+		case column == "id" && len(keys) == 1:
+			where = append(where, "id "+op+" ?")
+			args = append(args, keys[0])
+		case column != "id" && len(keys) == 2:
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, op))
+			args = append(args, keys[0], keys[0], keys[1])
+		default:
+			r.metrics.QueriesTotal.WithLabelValues("list", "users", "error").Inc()
+			return nil, pagination.ErrInvalidCursor
+		}
+	}
+
+	query := `
+        SELECT
+            id,
+            username,
+            email,
+            full_name,
+            password,
+            status,
+            created_at,
+            updated_at,
+            deleted_at,
+            version
+        FROM users WHERE ` + strings.Join(where, " AND ")
+	if column == "id" {
+		query += fmt.Sprintf(" ORDER BY id %s LIMIT ?", dir)
+	} else {
+		query += fmt.Sprintf(" ORDER BY %[1]s %[2]s, id %[2]s LIMIT ?", column, dir)
+	}
+	// One extra row tells whether there is a next page
+	args = append(args, page.Limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("list", "users", "error").Inc()
+		return nil, repository.FromMySQL("UserRepository.List", "user", err)
+	}
+	defer rows.Close()
+
+	users := make([]*model.User, 0, page.Limit)
+	for rows.Next() {
+		user := &model.User{}
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.FullName,
+			&user.Password,
+			&user.Status,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.DeletedAt,
+			&user.Version,
+		)
+		if err != nil {
+			r.metrics.QueriesTotal.WithLabelValues("list", "users", "error").Inc()
+			return nil, repository.FromMySQL("UserRepository.List", "user", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("list", "users", "error").Inc()
+		return nil, repository.FromMySQL("UserRepository.List", "user", err)
+	}
+
+	result := &pagination.Page[*model.User]{Data: users, Total: total}
+	if len(users) > page.Limit {
+		result.Data = users[:page.Limit]
+		last := result.Data[page.Limit-1]
+		if column == "id" {
+			result.NextCursor = page.Next(last.ID.String())
+		} else {
+			result.NextCursor = page.Next(userSortKey(last, column), last.ID.String())
+		}
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("list", "users", "success").Inc()
+	r.metrics.ConnectionsOpen.WithLabelValues("mysql").Set(float64(r.db.Stats().OpenConnections))
+
+	return result, nil
+}
+
+// userSortKey formats a user's sort column the way MySQL compares it
+func userSortKey(user *model.User, column string) string {
+	switch column {
+	case "username":
+		return user.Username
+	case "email":
+		return user.Email
+	case "created_at":
+		return user.CreatedAt.UTC().Format("2006-01-02 15:04:05.999999")
+	}
+	return user.ID.String()
+}
+
 func (r *UserRepository) Update(ctx context.Context, user *model.User) error {
 	timer := time.Now()
 	defer func() {
 		r.metrics.QueryDuration.WithLabelValues("update", "users").Observe(time.Since(timer).Seconds())
 	}()
 
+	// Compare-and-swap on version so that concurrent writers cannot silently
+	// overwrite each other. Moving a user to the deleted status soft deletes
+	// it; MySQL evaluates SET left to right, so IF() sees the new status.
 	query := `
         UPDATE users
         SET
             username = ?,
             email = ?,
             full_name = ?,
-            status = ?
-        WHERE id = ?`
+            status = ?,
+            deleted_at = IF(status = 'deleted', NOW(), NULL),
+            version = version + 1
+        WHERE id = ? AND version = ? AND deleted_at IS NULL`
 
 	result, err := r.db.ExecContext(ctx, query,
 		user.Username,
@@ -240,44 +480,56 @@ func (r *UserRepository) Update(ctx context.Context, user *model.User) error {
 		user.FullName,
 		user.Status,
 		user.ID,
+		user.Version,
 	)
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("update", "users", "error").Inc()
-		return err
+		return repository.FromMySQL("UserRepository.Update", "user", err)
 	}
 
 	rows, err := result.RowsAffected()
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("update", "users", "error").Inc()
-		return err
+		return repository.FromMySQL("UserRepository.Update", "user", err)
 	}
 	if rows == 0 {
 		r.metrics.QueriesTotal.WithLabelValues("update", "users", "error").Inc()
-		return ErrUserNotFound
+		return r.versionMismatch(ctx, user.ID, user.Version)
 	}
 
+	user.Version++
 	r.metrics.QueriesTotal.WithLabelValues("update", "users", "success").Inc()
 	r.metrics.ConnectionsOpen.WithLabelValues("mysql").Set(float64(r.db.Stats().OpenConnections))
 
 	return nil
 }
 
+// Delete soft deletes a user: the row is kept with status deleted and a
+// deleted_at timestamp until it is restored or purged
 func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	timer := time.Now()
 	defer func() {
 		r.metrics.QueryDuration.WithLabelValues("delete", "users").Observe(time.Since(timer).Seconds())
 	}()
 
-	result, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE id = ?", id)
+	query := `
+        UPDATE users
+        SET
+            status = 'deleted',
+            deleted_at = NOW(),
+            version = version + 1
+        WHERE id = ? AND deleted_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("delete", "users", "error").Inc()
-		return err
+		return repository.FromMySQL("UserRepository.Delete", "user", err)
 	}
 
 	rows, err := result.RowsAffected()
 	if err != nil {
 		r.metrics.QueriesTotal.WithLabelValues("delete", "users", "error").Inc()
-		return err
+		return repository.FromMySQL("UserRepository.Delete", "user", err)
 	}
 	if rows == 0 {
 		r.metrics.QueriesTotal.WithLabelValues("delete", "users", "error").Inc()
@@ -289,3 +541,162 @@ func (r *UserRepository) Delete(ctx context.Context, id uuid.UUID) error {
 
 	return nil
 }
+
+// Restore brings a soft deleted user back as inactive, so it has to be
+// reactivated explicitly
+func (r *UserRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("restore", "users").Observe(time.Since(timer).Seconds())
+	}()
+
+	query := `
+        UPDATE users
+        SET
+            status = 'inactive',
+            deleted_at = NULL,
+            version = version + 1
+        WHERE id = ? AND deleted_at IS NOT NULL`
+
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("restore", "users", "error").Inc()
+		return repository.FromMySQL("UserRepository.Restore", "user", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("restore", "users", "error").Inc()
+		return repository.FromMySQL("UserRepository.Restore", "user", err)
+	}
+	if rows == 0 {
+		r.metrics.QueriesTotal.WithLabelValues("restore", "users", "error").Inc()
+		return r.notDeleted(ctx, id)
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("restore", "users", "success").Inc()
+	r.metrics.ConnectionsOpen.WithLabelValues("mysql").Set(float64(r.db.Stats().OpenConnections))
+
+	return nil
+}
+
+// Purge permanently removes a soft deleted user. Live users cannot be purged.
+func (r *UserRepository) Purge(ctx context.Context, id uuid.UUID) error {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("purge", "users").Observe(time.Since(timer).Seconds())
+	}()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("purge", "users", "error").Inc()
+		return repository.FromMySQL("UserRepository.Purge", "user", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("purge", "users", "error").Inc()
+		return repository.FromMySQL("UserRepository.Purge", "user", err)
+	}
+	if rows == 0 {
+		r.metrics.QueriesTotal.WithLabelValues("purge", "users", "error").Inc()
+		return r.notDeleted(ctx, id)
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("purge", "users", "success").Inc()
+	r.metrics.ConnectionsOpen.WithLabelValues("mysql").Set(float64(r.db.Stats().OpenConnections))
+
+	return nil
+}
+
+// PurgeDeletedOlderThan permanently removes up to limit users that were soft
+// deleted more than age ago, oldest first, and returns how many were removed
+func (r *UserRepository) PurgeDeletedOlderThan(ctx context.Context, age time.Duration, limit int) (int64, error) {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("purge_expired", "users").Observe(time.Since(timer).Seconds())
+	}()
+
+	// The cutoff is computed by the database so it uses the same clock and
+	// time zone that wrote deleted_at
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM users WHERE deleted_at < NOW() - INTERVAL ? SECOND ORDER BY deleted_at LIMIT ?",
+		int64(age.Seconds()), limit)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("purge_expired", "users", "error").Inc()
+		return 0, repository.FromMySQL("UserRepository.PurgeDeletedOlderThan", "user", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("purge_expired", "users", "error").Inc()
+		return 0, repository.FromMySQL("UserRepository.PurgeDeletedOlderThan", "user", err)
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("purge_expired", "users", "success").Inc()
+	r.metrics.ConnectionsOpen.WithLabelValues("mysql").Set(float64(r.db.Stats().OpenConnections))
+
+	return rows, nil
+}
+
+// UpdatePassword replaces the stored password hash, e.g. when rehashing on login
+func (r *UserRepository) UpdatePassword(ctx context.Context, id uuid.UUID, hash string) error {
+	timer := time.Now()
+	defer func() {
+		r.metrics.QueryDuration.WithLabelValues("update_password", "users").Observe(time.Since(timer).Seconds())
+	}()
+
+	if !password.Default().IsHashed(hash) {
+		r.metrics.QueriesTotal.WithLabelValues("update_password", "users", "error").Inc()
+		return stderrors.New("refusing to store an unhashed password")
+	}
+
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", hash, id)
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("update_password", "users", "error").Inc()
+		return repository.FromMySQL("UserRepository.UpdatePassword", "user", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		r.metrics.QueriesTotal.WithLabelValues("update_password", "users", "error").Inc()
+		return repository.FromMySQL("UserRepository.UpdatePassword", "user", err)
+	}
+	if rows == 0 {
+		r.metrics.QueriesTotal.WithLabelValues("update_password", "users", "error").Inc()
+		return ErrUserNotFound
+	}
+
+	r.metrics.QueriesTotal.WithLabelValues("update_password", "users", "success").Inc()
+	r.metrics.ConnectionsOpen.WithLabelValues("mysql").Set(float64(r.db.Stats().OpenConnections))
+
+	return nil
+}
+
+// versionMismatch explains why a compare-and-swap write matched no rows:
+// either the user does not exist or its version has moved on
+func (r *UserRepository) versionMismatch(ctx context.Context, id uuid.UUID, expected int) error {
+	var current int
+	err := r.db.QueryRowContext(ctx, "SELECT version FROM users WHERE id = ? AND deleted_at IS NULL", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return repository.FromMySQL("UserRepository.versionMismatch", "user", err)
+	}
+	return &repository.VersionConflictError{Entity: "user", ID: id, Expected: expected, Current: current}
+}
+
+// notDeleted explains why a restore or purge matched no rows: either the
+// user does not exist or it has not been deleted
+func (r *UserRepository) notDeleted(ctx context.Context, id uuid.UUID) error {
+	var exists int
+	err := r.db.QueryRowContext(ctx, "SELECT 1 FROM users WHERE id = ?", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return repository.FromMySQL("UserRepository.notDeleted", "user", err)
+	}
+	return repository.ErrNotDeleted
+}
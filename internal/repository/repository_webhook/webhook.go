@@ -0,0 +1,594 @@
+package repository_webhook
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+)
+
+var ErrWebhookNotFound = &errors.Error{Code: http.StatusNotFound, Message: "webhook subscription not found"}
+
+// maxErrorLength bounds the delivery error kept on a row
+const maxErrorLength = 1000
+
+// WebhookRepository stores webhook subscriptions, the deliveries queued for
+// them and the outcome of every attempt
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db: db,
+	}
+}
+
+const subscriptionColumns = `
+            id,
+            url,
+            secret,
+            events,
+            description,
+            active,
+            consecutive_failures,
+            disabled_at,
+            created_at,
+            updated_at,
+            version`
+
+func scanSubscription(row interface{ Scan(...interface{}) error }) (*model.WebhookSubscription, error) {
+	s := &model.WebhookSubscription{}
+	var events []byte
+	err := row.Scan(
+		&s.ID,
+		&s.URL,
+		&s.Secret,
+		&events,
+		&s.Description,
+		&s.Active,
+		&s.ConsecutiveFailures,
+		&s.DisabledAt,
+		&s.CreatedAt,
+		&s.UpdatedAt,
+		&s.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(events, &s.Events); err != nil {
+		return nil, fmt.Errorf("decode events of webhook subscription %d: %w", s.ID, err)
+	}
+	return s, nil
+}
+
+// Create stores s and sets its id, version and timestamps
+func (r *WebhookRepository) Create(ctx context.Context, s *model.WebhookSubscription) error {
+	events, err := json.Marshal(s.Events)
+	if err != nil {
+		return fmt.Errorf("encode webhook events: %w", err)
+	}
+
+	now := time.Now().UTC()
+	result, err := r.db.ExecContext(ctx, `
+        INSERT INTO webhook_subscriptions (
+            url,
+            secret,
+            events,
+            description,
+            active,
+            version,
+            created_at,
+            updated_at
+        ) VALUES (?, ?, ?, ?, ?, 1, ?, ?)`,
+		s.URL,
+		s.Secret,
+		events,
+		s.Description,
+		s.Active,
+		now,
+		now,
+	)
+	if err != nil {
+		return repository.FromMySQL("WebhookRepository.Create", "webhook subscription", err)
+	}
+
+	s.ID, err = result.LastInsertId()
+	if err != nil {
+		return repository.FromMySQL("WebhookRepository.Create", "webhook subscription", err)
+	}
+	s.Version = 1
+	s.CreatedAt = now
+	s.UpdatedAt = now
+	return nil
+}
+
+func (r *WebhookRepository) GetByID(ctx context.Context, id int64) (*model.WebhookSubscription, error) {
+	s, err := scanSubscription(r.db.QueryRowContext(ctx,
+		"SELECT"+subscriptionColumns+" FROM webhook_subscriptions WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, repository.FromMySQL("WebhookRepository.GetByID", "webhook subscription", err)
+	}
+	return s, nil
+}
+
+// List returns one page of subscriptions ordered by id
+func (r *WebhookRepository) List(ctx context.Context, page pagination.Request) (*pagination.Page[*model.WebhookSubscription], error) {
+	const op = "WebhookRepository.List"
+
+	var total int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_subscriptions").Scan(&total); err != nil {
+		return nil, repository.FromMySQL(op, "webhook subscription", err)
+	}
+
+	where, args, err := idAfter("id", page)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT"+subscriptionColumns+" FROM webhook_subscriptions"+where+orderByID("id", page),
+		append(args, page.Limit+1)...)
+	if err != nil {
+		return nil, repository.FromMySQL(op, "webhook subscription", err)
+	}
+	defer rows.Close()
+
+	subscriptions := make([]*model.WebhookSubscription, 0, page.Limit)
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, repository.FromMySQL(op, "webhook subscription", err)
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, repository.FromMySQL(op, "webhook subscription", err)
+	}
+
+	result := &pagination.Page[*model.WebhookSubscription]{Data: subscriptions, Total: total}
+	if len(subscriptions) > page.Limit {
+		result.Data = subscriptions[:page.Limit]
+		result.NextCursor = page.Next(result.Data[page.Limit-1].ID)
+	}
+	return result, nil
+}
+
+// Update stores the endpoint, filters and state of s if its version is
+// still current
+func (r *WebhookRepository) Update(ctx context.Context, s *model.WebhookSubscription) error {
+	events, err := json.Marshal(s.Events)
+	if err != nil {
+		return fmt.Errorf("encode webhook events: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+        UPDATE webhook_subscriptions
+        SET
+            url = ?,
+            events = ?,
+            description = ?,
+            active = ?,
+            consecutive_failures = ?,
+            disabled_at = ?,
+            version = version + 1
+        WHERE id = ? AND version = ?`,
+		s.URL,
+		events,
+		s.Description,
+		s.Active,
+		s.ConsecutiveFailures,
+		s.DisabledAt,
+		s.ID,
+		s.Version,
+	)
+	if err != nil {
+		return repository.FromMySQL("WebhookRepository.Update", "webhook subscription", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return repository.FromMySQL("WebhookRepository.Update", "webhook subscription", err)
+	}
+	if rows == 0 {
+		return r.versionMismatch(ctx, s.ID, s.Version)
+	}
+
+	s.Version++
+	return nil
+}
+
+// Delete removes the subscription with id together with its deliveries and
+// their attempts
+func (r *WebhookRepository) Delete(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return repository.FromMySQL("WebhookRepository.Delete", "webhook subscription", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return repository.FromMySQL("WebhookRepository.Delete", "webhook subscription", err)
+	}
+	if rows == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Attempts returns one page of the attempts made for the subscription with
+// id, ordered by id
+func (r *WebhookRepository) Attempts(ctx context.Context, id int64, page pagination.Request) (*pagination.Page[*model.WebhookAttempt], error) {
+	const op = "WebhookRepository.Attempts"
+
+	var total int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_attempts WHERE subscription_id = ?", id).Scan(&total)
+	if err != nil {
+		return nil, repository.FromMySQL(op, "webhook attempt", err)
+	}
+
+	where, args, err := idAfter("a.id", page)
+	if err != nil {
+		return nil, err
+	}
+	if where == "" {
+		where = " WHERE a.subscription_id = ?"
+	} else {
+		where += " AND a.subscription_id = ?"
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT
+            a.id,
+            a.delivery_id,
+            a.subscription_id,
+            d.event_id,
+            d.event_type,
+            a.attempt,
+            a.succeeded,
+            a.status_code,
+            a.error,
+            a.duration_ms,
+            a.next_attempt_at,
+            a.created_at
+        FROM webhook_attempts a
+        JOIN webhook_deliveries d ON d.id = a.delivery_id`+where+orderByID("a.id", page),
+		append(args, id, page.Limit+1)...)
+	if err != nil {
+		return nil, repository.FromMySQL(op, "webhook attempt", err)
+	}
+	defer rows.Close()
+
+	attempts := make([]*model.WebhookAttempt, 0, page.Limit)
+	for rows.Next() {
+		a := &model.WebhookAttempt{}
+		var attemptErr sql.NullString
+		if err := rows.Scan(
+			&a.ID,
+			&a.DeliveryID,
+			&a.SubscriptionID,
+			&a.EventID,
+			&a.EventType,
+			&a.Attempt,
+			&a.Succeeded,
+			&a.StatusCode,
+			&attemptErr,
+			&a.DurationMs,
+			&a.NextAttemptAt,
+			&a.CreatedAt,
+		); err != nil {
+			return nil, repository.FromMySQL(op, "webhook attempt", err)
+		}
+		a.Error = attemptErr.String
+		attempts = append(attempts, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, repository.FromMySQL(op, "webhook attempt", err)
+	}
+
+	result := &pagination.Page[*model.WebhookAttempt]{Data: attempts, Total: total}
+	if len(attempts) > page.Limit {
+		result.Data = attempts[:page.Limit]
+		result.NextCursor = page.Next(result.Data[page.Limit-1].ID)
+	}
+	return result, nil
+}
+
+// Active returns every active subscription
+func (r *WebhookRepository) Active(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT"+subscriptionColumns+" FROM webhook_subscriptions WHERE active ORDER BY id")
+	if err != nil {
+		return nil, repository.FromMySQL("WebhookRepository.Active", "webhook subscription", err)
+	}
+	defer rows.Close()
+
+	var subscriptions []*model.WebhookSubscription
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, repository.FromMySQL("WebhookRepository.Active", "webhook subscription", err)
+		}
+		subscriptions = append(subscriptions, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, repository.FromMySQL("WebhookRepository.Active", "webhook subscription", err)
+	}
+	return subscriptions, nil
+}
+
+// Enqueue queues deliveries to be sent right away. A delivery of an event
+// that is already queued for the same subscription is ignored, so an event
+// delivered twice by the event bus is sent once.
+func (r *WebhookRepository) Enqueue(ctx context.Context, deliveries []*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(deliveries))
+	args := make([]interface{}, 0, 4*len(deliveries))
+	for i, d := range deliveries {
+		placeholders[i] = "(?, ?, ?, ?)"
+		args = append(args, d.SubscriptionID, d.EventID, d.EventType, string(d.Payload))
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+        VALUES `+strings.Join(placeholders, ", ")+`
+        ON DUPLICATE KEY UPDATE id = id`, args...)
+	return repository.FromMySQL("WebhookRepository.Enqueue", "webhook delivery", err)
+}
+
+// Claim returns up to limit pending deliveries that are due, of active
+// subscriptions, and holds them back for lease so that other instances do
+// not send them meanwhile. A delivery whose attempt is not recorded within
+// the lease is claimed again.
+func (r *WebhookRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	const op = "WebhookRepository.Claim"
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, repository.FromMySQL(op, "webhook delivery", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+        SELECT
+            d.id,
+            d.subscription_id,
+            d.event_id,
+            d.event_type,
+            d.payload,
+            d.attempts,
+            s.url,
+            s.secret
+        FROM webhook_deliveries d
+        JOIN webhook_subscriptions s ON s.id = d.subscription_id
+        WHERE d.status = ? AND d.next_attempt_at <= NOW(6) AND s.active
+        ORDER BY d.next_attempt_at
+        LIMIT ?
+        FOR UPDATE OF d SKIP LOCKED`, model.WebhookDeliveryPending, limit)
+	if err != nil {
+		return nil, repository.FromMySQL(op, "webhook delivery", err)
+	}
+
+	var (
+		deliveries []*model.WebhookDelivery
+		ids        []interface{}
+	)
+	for rows.Next() {
+		d := &model.WebhookDelivery{}
+		if err := rows.Scan(
+			&d.ID,
+			&d.SubscriptionID,
+			&d.EventID,
+			&d.EventType,
+			&d.Payload,
+			&d.Attempts,
+			&d.URL,
+			&d.Secret,
+		); err != nil {
+			rows.Close()
+			return nil, repository.FromMySQL(op, "webhook delivery", err)
+		}
+		deliveries = append(deliveries, d)
+		ids = append(ids, d.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, repository.FromMySQL(op, "webhook delivery", err)
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+        UPDATE webhook_deliveries
+        SET next_attempt_at = NOW(6) + INTERVAL ? MICROSECOND
+        WHERE id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`,
+		append([]interface{}{lease.Microseconds()}, ids...)...)
+	if err != nil {
+		return nil, repository.FromMySQL(op, "webhook delivery", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, repository.FromMySQL(op, "webhook delivery", err)
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt stores attempt and settles its delivery: a successful
+// attempt completes it, a failed one schedules it again in retryIn, or fails
+// it for good when retryIn is not positive. The subscription counts failed
+// attempts in a row and is disabled once it reached disableAfter, when
+// disableAfter is positive. disabled reports whether this attempt disabled
+// it.
+func (r *WebhookRepository) RecordAttempt(ctx context.Context, attempt *model.WebhookAttempt, retryIn time.Duration, disableAfter int) (disabled bool, err error) {
+	const op = "WebhookRepository.RecordAttempt"
+
+	msg := attempt.Error
+	if len(msg) > maxErrorLength {
+		msg = msg[:maxErrorLength]
+	}
+	var attemptErr sql.NullString
+	if msg != "" {
+		attemptErr = sql.NullString{String: msg, Valid: true}
+	}
+	retry := !attempt.Succeeded && retryIn > 0
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, repository.FromMySQL(op, "webhook attempt", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+        INSERT INTO webhook_attempts (
+            delivery_id,
+            subscription_id,
+            attempt,
+            succeeded,
+            status_code,
+            error,
+            duration_ms,
+            next_attempt_at
+        ) VALUES (?, ?, ?, ?, ?, ?, ?, IF(?, NOW(6) + INTERVAL ? MICROSECOND, NULL))`,
+		attempt.DeliveryID,
+		attempt.SubscriptionID,
+		attempt.Attempt,
+		attempt.Succeeded,
+		attempt.StatusCode,
+		attemptErr,
+		attempt.DurationMs,
+		retry,
+		retryIn.Microseconds(),
+	)
+	if err != nil {
+		return false, repository.FromMySQL(op, "webhook attempt", err)
+	}
+	if attempt.ID, err = result.LastInsertId(); err != nil {
+		return false, repository.FromMySQL(op, "webhook attempt", err)
+	}
+
+	switch {
+	case attempt.Succeeded:
+		_, err = tx.ExecContext(ctx, `
+            UPDATE webhook_deliveries
+            SET status = ?, attempts = attempts + 1, last_error = NULL, completed_at = NOW(6)
+            WHERE id = ?`,
+			model.WebhookDeliverySucceeded, attempt.DeliveryID)
+	case retry:
+		_, err = tx.ExecContext(ctx, `
+            UPDATE webhook_deliveries
+            SET attempts = attempts + 1, last_error = ?, next_attempt_at = NOW(6) + INTERVAL ? MICROSECOND
+            WHERE id = ?`,
+			attemptErr, retryIn.Microseconds(), attempt.DeliveryID)
+	default:
+		_, err = tx.ExecContext(ctx, `
+            UPDATE webhook_deliveries
+            SET status = ?, attempts = attempts + 1, last_error = ?, completed_at = NOW(6)
+            WHERE id = ?`,
+			model.WebhookDeliveryFailed, attemptErr, attempt.DeliveryID)
+	}
+	if err != nil {
+		return false, repository.FromMySQL(op, "webhook delivery", err)
+	}
+
+	if attempt.Succeeded {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE webhook_subscriptions SET consecutive_failures = 0 WHERE id = ?", attempt.SubscriptionID)
+	} else {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE webhook_subscriptions SET consecutive_failures = consecutive_failures + 1 WHERE id = ?", attempt.SubscriptionID)
+	}
+	if err != nil {
+		return false, repository.FromMySQL(op, "webhook subscription", err)
+	}
+
+	if !attempt.Succeeded && disableAfter > 0 {
+		// The version moves on, so an update prepared before the subscription
+		// was disabled cannot enable it again unnoticed
+		result, err := tx.ExecContext(ctx, `
+            UPDATE webhook_subscriptions
+            SET active = FALSE, disabled_at = NOW(6), version = version + 1
+            WHERE id = ? AND active AND consecutive_failures >= ?`,
+			attempt.SubscriptionID, disableAfter)
+		if err != nil {
+			return false, repository.FromMySQL(op, "webhook subscription", err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return false, repository.FromMySQL(op, "webhook subscription", err)
+		}
+		disabled = rows > 0
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, repository.FromMySQL(op, "webhook attempt", err)
+	}
+	return disabled, nil
+}
+
+// PurgeDeletedOlderThan removes up to limit deliveries, with their attempts,
+// that completed more than age ago, so webhooks can share the retention
+// worker with soft deleted records
+func (r *WebhookRepository) PurgeDeletedOlderThan(ctx context.Context, age time.Duration, limit int) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM webhook_deliveries WHERE completed_at < NOW() - INTERVAL ? SECOND ORDER BY completed_at LIMIT ?",
+		int64(age.Seconds()), limit)
+	if err != nil {
+		return 0, repository.FromMySQL("WebhookRepository.PurgeDeletedOlderThan", "webhook delivery", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, repository.FromMySQL("WebhookRepository.PurgeDeletedOlderThan", "webhook delivery", err)
+	}
+	return rows, nil
+}
+
+func (r *WebhookRepository) versionMismatch(ctx context.Context, id int64, expected int) error {
+	var current int
+	err := r.db.QueryRowContext(ctx, "SELECT version FROM webhook_subscriptions WHERE id = ?", id).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrWebhookNotFound
+	}
+	if err != nil {
+		return repository.FromMySQL("WebhookRepository.versionMismatch", "webhook subscription", err)
+	}
+	return &repository.VersionConflictError{Entity: "webhook subscription", ID: id, Expected: expected, Current: current}
+}
+
+// idAfter returns the condition that continues a page sorted by column, an
+// id, after its cursor
+func idAfter(column string, page pagination.Request) (string, []interface{}, error) {
+	if page.Cursor == nil {
+		return "", nil, nil
+	}
+	keys := page.Cursor.Strings()
+	if len(keys) != 1 {
+		return "", nil, pagination.ErrInvalidCursor
+	}
+	op := ">"
+	if page.Desc {
+		op = "<"
+	}
+	return fmt.Sprintf(" WHERE %s %s ?", column, op), []interface{}{keys[0]}, nil
+}
+
+// orderByID orders a page by column, an id, and reads one extra row that
+// tells whether there is a next page
+func orderByID(column string, page pagination.Request) string {
+	if page.Desc {
+		return fmt.Sprintf(" ORDER BY %s DESC LIMIT ?", column)
+	}
+	return fmt.Sprintf(" ORDER BY %s ASC LIMIT ?", column)
+}
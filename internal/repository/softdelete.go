@@ -0,0 +1,11 @@
+package repository
+
+import (
+	"net/http"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+)
+
+// ErrNotDeleted is returned when restoring or purging a record that has not
+// been soft deleted
+var ErrNotDeleted = &errors.Error{Code: http.StatusConflict, Message: "record is not deleted"}
@@ -0,0 +1,89 @@
+package retention
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const (
+	// DefaultInterval is how often the worker purges when no interval is configured
+	DefaultInterval = time.Hour
+	// DefaultBatchSize caps the rows removed by a single purge statement
+	DefaultBatchSize = 500
+)
+
+// Purger permanently removes records that were soft deleted more than age ago.
+// At most limit records are removed per call; the number removed is returned.
+type Purger interface {
+	PurgeDeletedOlderThan(ctx context.Context, age time.Duration, limit int) (int64, error)
+}
+
+// Worker enforces the retention window for soft deleted records by purging
+// them in batches on a fixed interval
+type Worker struct {
+	purgers   map[string]Purger
+	retention time.Duration
+	interval  time.Duration
+	batchSize int
+}
+
+// NewWorker creates a worker that purges records deleted longer than retention
+// ago. purgers is keyed by a name used in log output, e.g. "users".
+func NewWorker(purgers map[string]Purger, retention, interval time.Duration, batchSize int) *Worker {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Worker{
+		purgers:   purgers,
+		retention: retention,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run purges once immediately and then on every interval until ctx is done
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		w.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce purges every expired record, one batch at a time so a large backlog
+// does not hold long-running locks. It returns the number of records removed
+// per purger. A failing purger is logged and does not stop the others.
+func (w *Worker) RunOnce(ctx context.Context) map[string]int64 {
+	purged := make(map[string]int64, len(w.purgers))
+
+	for name, purger := range w.purgers {
+		for ctx.Err() == nil {
+			n, err := purger.PurgeDeletedOlderThan(ctx, w.retention, w.batchSize)
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to purge deleted records", "table", name, "error", err)
+				break
+			}
+			purged[name] += n
+			if n < int64(w.batchSize) {
+				break
+			}
+		}
+
+		if purged[name] > 0 {
+			slog.InfoContext(ctx, "purged deleted records", "table", name, "count", purged[name], "older_than", w.retention.String())
+		}
+	}
+
+	return purged
+}
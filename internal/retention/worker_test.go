@@ -0,0 +1,75 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePurger struct {
+	remaining int64
+	err       error
+	calls     int
+	age       time.Duration
+}
+
+func (f *fakePurger) PurgeDeletedOlderThan(ctx context.Context, age time.Duration, limit int) (int64, error) {
+	f.calls++
+	f.age = age
+	if f.err != nil {
+		return 0, f.err
+	}
+	n := f.remaining
+	if n > int64(limit) {
+		n = int64(limit)
+	}
+	f.remaining -= n
+	return n, nil
+}
+
+func TestWorker_RunOnce(t *testing.T) {
+	users := &fakePurger{remaining: 25}
+	products := &fakePurger{remaining: 3}
+	broken := &fakePurger{err: errors.New("connection refused")}
+
+	w := NewWorker(map[string]Purger{
+		"users":    users,
+		"products": products,
+		"orders":   broken,
+	}, 30*24*time.Hour, time.Minute, 10)
+
+	purged := w.RunOnce(context.Background())
+
+	assert.Equal(t, int64(25), purged["users"])
+	assert.Equal(t, int64(3), purged["products"])
+	assert.Equal(t, int64(0), purged["orders"])
+
+	// 10 + 10 + 5: the short batch ends the loop
+	assert.Equal(t, 3, users.calls)
+	assert.Equal(t, 1, products.calls)
+	assert.Equal(t, 1, broken.calls)
+	assert.Equal(t, 30*24*time.Hour, users.age)
+}
+
+func TestWorker_RunStopsOnCancel(t *testing.T) {
+	purger := &fakePurger{}
+	w := NewWorker(map[string]Purger{"users": purger}, time.Hour, time.Hour, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		w.Run(ctx)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("worker did not stop after cancel")
+	}
+	assert.Equal(t, DefaultBatchSize, w.batchSize)
+}
@@ -1,11 +1,15 @@
 package router
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
 
 	"github.com/Napat/golang-testcontainers-demo/internal/config"
 	"github.com/Napat/golang-testcontainers-demo/internal/handler/health"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
 	"github.com/Napat/golang-testcontainers-demo/pkg/middleware"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
 	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
 )
 
@@ -14,14 +18,85 @@ func Setup(
 	productHandler routes.Handler,
 	orderHandler routes.Handler,
 	messageHandler routes.Handler,
+	authHandler routes.Handler,
+	deadLetterHandler routes.Handler,
+	webhookHandler routes.Handler,
+	streamHandler routes.Handler,
 	healthHandler *health.HealthHandler,
+	tokens middleware.TokenVerifier,
+	authorizer middleware.Authorizer,
 	cfg *config.Config,
-) http.Handler {
+) (http.Handler, error) {
+	allRoutes := make([]routes.Route, 0)
+	allRoutes = append(allRoutes, userHandler.GetRoutes()...)
+	allRoutes = append(allRoutes, productHandler.GetRoutes()...)
+	allRoutes = append(allRoutes, orderHandler.GetRoutes()...)
+	allRoutes = append(allRoutes, messageHandler.GetRoutes()...)
+	allRoutes = append(allRoutes, authHandler.GetRoutes()...)
+	allRoutes = append(allRoutes, deadLetterHandler.GetRoutes()...)
+	allRoutes = append(allRoutes, webhookHandler.GetRoutes()...)
+	allRoutes = append(allRoutes, streamHandler.GetRoutes()...)
+	healthRoutes := healthHandler.GetRoutes()
+
+	// The route table lists itself, so it is built before being registered
+	var table []routes.Info
+	allRoutes = append(allRoutes, routes.Route{
+		Name:    "debug.routes",
+		Method:  http.MethodGet,
+		Pattern: "/debug/routes",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			response.RespondWithJSON(w, http.StatusOK, table)
+		},
+		Permissions: []string{authz.RoutesRead},
+	})
+	table = append(routes.Describe(routes.Prefix, allRoutes), routes.Describe("", healthRoutes)...)
+
+	names := make(map[string]bool)
+	for _, route := range append(allRoutes, healthRoutes...) {
+		if route.Name == "" {
+			return nil, fmt.Errorf("route %s %s has no name", route.Method, route.Pattern)
+		}
+		if names[route.Name] {
+			return nil, fmt.Errorf("route name %q is used twice", route.Name)
+		}
+		names[route.Name] = true
+	}
+
+	// Every protected route must declare a policy, otherwise it would be
+	// reachable by any authenticated caller
+	for _, route := range allRoutes {
+		if !route.Public && len(route.Permissions) == 0 {
+			return nil, fmt.Errorf("route %s %s has no permission policy", route.Method, route.Pattern)
+		}
+	}
+
 	mux := http.NewServeMux()
 	apiMux := http.NewServeMux()
 
-	// Create middleware chain
-	var middlewares []func(http.Handler) http.Handler
+	// Register health check routes on the root mux first, before any middleware
+	if err := routes.Register(mux, healthRoutes, nil); err != nil {
+		return nil, err
+	}
+
+	// Handle API routes. The route name is added to the request logger, then
+	// authentication and permissions are checked before the route's own
+	// middleware runs.
+	protect := func(route routes.Route, h http.Handler) http.Handler {
+		if !route.Public {
+			h = middleware.RequireAuth(middleware.RequirePermissions(authorizer, route.Permissions...)(h))
+		}
+		return middleware.Route(route.Name)(h)
+	}
+	if err := routes.Register(apiMux, allRoutes, protect); err != nil {
+		return nil, err
+	}
+
+	// Unknown API paths get a problem response instead of the mux's plain text 404
+	apiMux.HandleFunc("/", routes.NotFound)
+
+	// Create middleware chain. The request id comes first so that every
+	// later middleware can log and trace with it.
+	middlewares := []func(http.Handler) http.Handler{middleware.RequestID}
 
 	// Add CORS middleware if enabled
 	if cfg.Server.CORS.Enabled {
@@ -29,6 +104,7 @@ func Setup(
 			AllowedOrigins: cfg.Server.CORS.AllowedOrigins,
 			AllowedMethods: cfg.Server.CORS.AllowedMethods,
 			AllowedHeaders: cfg.Server.CORS.AllowedHeaders,
+			ExposedHeaders: cfg.Server.CORS.ExposedHeaders,
 			MaxAge:         cfg.Server.CORS.MaxAge,
 		}))
 	}
@@ -37,43 +113,17 @@ func Setup(
 	middlewares = append(middlewares,
 		middleware.NewMetricsMiddleware().Handler,
 		middleware.Tracing(cfg.Tracing.ServiceName),
+		middleware.Logging(slog.Default(), cfg.Logging.AccessLog),
 		middleware.Profiling(),
 		middleware.ErrorHandler,
+		middleware.Authenticate(tokens),
 	)
 
-	// Register health check routes on the root mux first, before any middleware
-	healthHandler.RegisterRoutes(mux)
-
-	// Handle API routes
-	routeHandlers := make(map[string]map[string]http.HandlerFunc)
-	allRoutes := make([]routes.Route, 0)
-	allRoutes = append(allRoutes, userHandler.GetRoutes()...)
-	allRoutes = append(allRoutes, productHandler.GetRoutes()...)
-	allRoutes = append(allRoutes, orderHandler.GetRoutes()...)
-	allRoutes = append(allRoutes, messageHandler.GetRoutes()...)
-
-	for _, route := range allRoutes {
-		if routeHandlers[route.Pattern] == nil {
-			routeHandlers[route.Pattern] = make(map[string]http.HandlerFunc)
-		}
-		routeHandlers[route.Pattern][route.Method] = route.Handler
-	}
-
-	for pattern, handlers := range routeHandlers {
-		apiMux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
-			if handler, ok := handlers[r.Method]; ok {
-				handler(w, r)
-				return
-			}
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		})
-	}
-
 	// Apply middleware chain to API routes only
 	handler := middleware.Chain(middlewares...)(apiMux)
 
 	// Mount API routes under /api/v1 after health check routes
-	mux.Handle("/api/v1/", http.StripPrefix("/api/v1", handler))
+	mux.Handle(routes.Prefix+"/", http.StripPrefix(routes.Prefix, handler))
 
-	return mux
+	return mux, nil
 }
@@ -0,0 +1,183 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/config"
+	"github.com/Napat/golang-testcontainers-demo/internal/handler/health"
+	"github.com/Napat/golang-testcontainers-demo/internal/router"
+	"github.com/Napat/golang-testcontainers-demo/pkg/auth"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticHandler []routes.Route
+
+func (h staticHandler) GetRoutes() []routes.Route { return h }
+
+type staticVerifier map[string]*auth.Identity
+
+func (v staticVerifier) Authenticate(ctx context.Context, token string) (*auth.Identity, error) {
+	if identity, ok := v[token]; ok {
+		return identity, nil
+	}
+	return nil, auth.ErrInvalidToken
+}
+
+type staticRoles map[uuid.UUID][]string
+
+func (s staticRoles) PermissionsForUser(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return s[userID], nil
+}
+
+func (s staticRoles) PermissionsForRole(ctx context.Context, role string) ([]string, error) {
+	return nil, nil
+}
+
+func ok(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+func echoID(w http.ResponseWriter, r *http.Request) {
+	id, err := routes.Int64Param(r, "id")
+	if err != nil {
+		response.WriteError(w, r, err)
+		return
+	}
+	response.RespondWithJSON(w, http.StatusOK, map[string]int64{"id": id})
+}
+
+func TestSetup_RejectsRouteWithoutPolicy(t *testing.T) {
+	unprotected := staticHandler{{Name: "things.list", Method: http.MethodGet, Pattern: "/things", Handler: ok}}
+
+	_, err := router.Setup(unprotected, staticHandler{}, staticHandler{}, staticHandler{}, staticHandler{}, staticHandler{}, staticHandler{}, staticHandler{},
+		health.NewHealthHandler(nil, nil, nil, nil, nil), staticVerifier{}, authz.NewEngine(staticRoles{}, ""), &config.Config{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "GET /things")
+}
+
+func TestSetup_EnforcesPermissions(t *testing.T) {
+	reader := &auth.Identity{UserID: uuid.Must(uuid.NewV7()), Username: "reader"}
+	writer := &auth.Identity{UserID: uuid.Must(uuid.NewV7()), Username: "writer"}
+	admin := &auth.Identity{UserID: uuid.Must(uuid.NewV7()), Username: "admin"}
+
+	verifier := staticVerifier{"reader-token": reader, "writer-token": writer, "admin-token": admin}
+	roles := staticRoles{
+		reader.UserID: {authz.ProductsRead},
+		writer.UserID: {"products:*"},
+		admin.UserID:  {authz.RoutesRead},
+	}
+
+	products := staticHandler{
+		{Name: "products.list", Method: http.MethodGet, Pattern: "/products", Handler: ok, Permissions: []string{authz.ProductsRead}},
+		{Name: "products.create", Method: http.MethodPost, Pattern: "/products", Handler: ok, Permissions: []string{authz.ProductsWrite}},
+		{Name: "products.get", Method: http.MethodGet, Pattern: "/products/{id}", Handler: echoID, Permissions: []string{authz.ProductsRead}},
+	}
+	public := staticHandler{{Name: "auth.login", Method: http.MethodPost, Pattern: "/auth/login", Handler: ok, Public: true}}
+
+	h, err := router.Setup(staticHandler{}, products, staticHandler{}, staticHandler{}, public, staticHandler{}, staticHandler{}, staticHandler{},
+		health.NewHealthHandler(nil, nil, nil, nil, nil), verifier, authz.NewEngine(roles, ""), &config.Config{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		expectedStatus int
+	}{
+		{"public route without token", http.MethodPost, "/api/v1/auth/login", "", http.StatusOK},
+		{"protected route without token", http.MethodGet, "/api/v1/products", "", http.StatusUnauthorized},
+		{"invalid token", http.MethodGet, "/api/v1/products", "bogus", http.StatusUnauthorized},
+		{"read with read permission", http.MethodGet, "/api/v1/products", "reader-token", http.StatusOK},
+		{"write without write permission", http.MethodPost, "/api/v1/products", "reader-token", http.StatusForbidden},
+		{"write with wildcard permission", http.MethodPost, "/api/v1/products", "writer-token", http.StatusOK},
+		{"path parameter", http.MethodGet, "/api/v1/products/7", "reader-token", http.StatusOK},
+		{"invalid path parameter", http.MethodGet, "/api/v1/products/seven", "reader-token", http.StatusBadRequest},
+		{"method not allowed", http.MethodDelete, "/api/v1/products", "writer-token", http.StatusMethodNotAllowed},
+		{"unknown path", http.MethodGet, "/api/v1/nothing", "reader-token", http.StatusNotFound},
+		{"route table without permission", http.MethodGet, "/api/v1/debug/routes", "reader-token", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+
+			h.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedStatus, rec.Code)
+		})
+	}
+
+	t.Run("route table", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/debug/routes", nil)
+		req.Header.Set("Authorization", "Bearer admin-token")
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var table []routes.Info
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &table))
+
+		byName := make(map[string]routes.Info)
+		for _, info := range table {
+			byName[info.Name] = info
+		}
+		assert.Equal(t, routes.Info{
+			Name:        "products.get",
+			Method:      http.MethodGet,
+			Path:        "/api/v1/products/{id}",
+			Handler:     "router_test.echoID",
+			Permissions: []string{authz.ProductsRead},
+		}, byName["products.get"])
+		assert.Equal(t, "/api/v1/debug/routes", byName["debug.routes"].Path)
+		assert.Equal(t, "/health/live", byName["health.live"].Path)
+		assert.True(t, byName["health.live"].Public)
+	})
+
+	t.Run("request id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/products", nil)
+		req.Header.Set("X-Request-ID", "client-supplied")
+		rec := httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		assert.Equal(t, "client-supplied", rec.Header().Get("X-Request-ID"))
+		var problem response.Problem
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+		assert.Equal(t, "client-supplied", problem.RequestID)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/v1/products", nil)
+		req.Header.Set("X-Request-ID", "not valid")
+		rec = httptest.NewRecorder()
+
+		h.ServeHTTP(rec, req)
+
+		generated := rec.Header().Get("X-Request-ID")
+		assert.NotEmpty(t, generated)
+		assert.NotEqual(t, "not valid", generated)
+	})
+}
+
+func TestSetup_RejectsDuplicateRouteNames(t *testing.T) {
+	users := staticHandler{{Name: "things", Method: http.MethodGet, Pattern: "/users", Handler: ok, Public: true}}
+	products := staticHandler{{Name: "things", Method: http.MethodGet, Pattern: "/products", Handler: ok, Public: true}}
+
+	_, err := router.Setup(users, products, staticHandler{}, staticHandler{}, staticHandler{}, staticHandler{}, staticHandler{}, staticHandler{},
+		health.NewHealthHandler(nil, nil, nil, nil, nil), staticVerifier{}, authz.NewEngine(staticRoles{}, ""), &config.Config{})
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"things"`)
+}
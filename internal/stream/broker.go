@@ -0,0 +1,248 @@
+// Package stream pushes domain events to long-lived HTTP connections as
+// Server-Sent Events. A Broker receives the events of the event bus, keeps
+// the latest ones for clients that reconnect, and fans them out to the
+// subscriptions of the open connections.
+package stream
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+)
+
+const (
+	// DefaultReplaySize is the number of events kept for resuming clients
+	DefaultReplaySize = 1000
+	// DefaultClientBuffer is the number of events queued for one connection
+	// before it is dropped as too slow
+	DefaultClientBuffer = 64
+	// DefaultHeartbeat is how often an idle connection gets a comment, so
+	// proxies and clients do not time it out
+	DefaultHeartbeat = 15 * time.Second
+	// DefaultWriteTimeout bounds writing one event to a connection
+	DefaultWriteTimeout = 10 * time.Second
+)
+
+var (
+	// ErrClosed is returned by Subscribe once the broker is closed, and is
+	// the Err of the subscriptions it closed
+	ErrClosed = stderrors.New("event stream closed")
+	// ErrSlowConsumer is the Err of a subscription dropped because its queue
+	// was full
+	ErrSlowConsumer = stderrors.New("event stream consumer too slow")
+)
+
+// Config tunes a Broker and the connections it serves. Zero values take the
+// defaults above.
+type Config struct {
+	ReplaySize   int
+	ClientBuffer int
+	Heartbeat    time.Duration
+	WriteTimeout time.Duration
+}
+
+func (c Config) withDefaults() Config {
+	if c.ReplaySize <= 0 {
+		c.ReplaySize = DefaultReplaySize
+	}
+	if c.ClientBuffer <= 0 {
+		c.ClientBuffer = DefaultClientBuffer
+	}
+	if c.Heartbeat <= 0 {
+		c.Heartbeat = DefaultHeartbeat
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = DefaultWriteTimeout
+	}
+	return c
+}
+
+// Broker fans events out to subscriptions. It never blocks a publisher: a
+// subscription whose queue is full is closed with ErrSlowConsumer, and its
+// client catches up from the replay buffer when it reconnects.
+type Broker struct {
+	config  Config
+	metrics *metrics.StreamMetrics
+
+	mu            sync.Mutex
+	replay        []*event.Envelope // ring of the latest events
+	next          int               // position of the next event in replay
+	seen          map[string]bool   // ids of the events in replay
+	subscriptions map[*Subscription]struct{}
+	closed        bool
+}
+
+func NewBroker(config Config) *Broker {
+	config = config.withDefaults()
+	return &Broker{
+		config:        config,
+		metrics:       metrics.NewStreamMetrics(),
+		replay:        make([]*event.Envelope, 0, config.ReplaySize),
+		seen:          make(map[string]bool, config.ReplaySize),
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+// Config returns the configuration of the broker, defaults applied
+func (b *Broker) Config() Config {
+	return b.config
+}
+
+// Subscription is the queue of events of one connection
+type Subscription struct {
+	filters []string
+	events  chan *event.Envelope
+	err     error
+
+	// Replay holds the buffered events after the one the client resumed
+	// from, to be written before the queue
+	Replay []*event.Envelope
+	// Resumed reports whether the event the client resumed from was still
+	// buffered. When it was not, events may have been missed.
+	Resumed bool
+}
+
+// Events returns the queue. It is closed when the subscription is dropped
+// or the broker closed, Err tells which.
+func (s *Subscription) Events() <-chan *event.Envelope {
+	return s.events
+}
+
+// Err returns why the queue was closed. It is valid once Events is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+func (s *Subscription) matches(eventType string) bool {
+	if len(s.filters) == 0 {
+		return true
+	}
+	for _, filter := range s.filters {
+		if model.MatchEventFilter(filter, eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe returns a subscription to the events matching filters, every
+// event when there are none. With lastEventID it replays the buffered
+// events that followed it, so a reconnecting client misses nothing as long
+// as the event is still buffered.
+func (b *Broker) Subscribe(filters []string, lastEventID string) (*Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	s := &Subscription{
+		filters: filters,
+		events:  make(chan *event.Envelope, b.config.ClientBuffer),
+	}
+	if lastEventID != "" && b.seen[lastEventID] {
+		s.Resumed = true
+		found := false
+		for _, e := range b.ordered() {
+			if found && s.matches(e.Type) {
+				s.Replay = append(s.Replay, e)
+			}
+			found = found || e.ID == lastEventID
+		}
+		b.metrics.ReplayedTotal.Add(float64(len(s.Replay)))
+	}
+
+	b.subscriptions[s] = struct{}{}
+	b.metrics.Connections.Inc()
+	return s, nil
+}
+
+// Unsubscribe removes s, after its connection ended
+func (b *Broker) Unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subscriptions[s]; ok {
+		b.drop(s, nil)
+		b.metrics.Disconnected.WithLabelValues("client").Inc()
+	}
+}
+
+// Publish buffers e and queues it for every subscription it matches. An
+// event already buffered, delivered again by the event bus, is ignored.
+func (b *Broker) Publish(e *event.Envelope) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || b.seen[e.ID] {
+		return
+	}
+
+	if len(b.replay) < b.config.ReplaySize {
+		b.replay = append(b.replay, e)
+	} else {
+		delete(b.seen, b.replay[b.next].ID)
+		b.replay[b.next] = e
+	}
+	b.next = (b.next + 1) % b.config.ReplaySize
+	b.seen[e.ID] = true
+
+	for s := range b.subscriptions {
+		if !s.matches(e.Type) {
+			continue
+		}
+		select {
+		case s.events <- e:
+		default:
+			b.drop(s, ErrSlowConsumer)
+			b.metrics.Disconnected.WithLabelValues("slow").Inc()
+		}
+	}
+}
+
+// Close closes every subscription with ErrClosed so that their connections
+// end, and refuses new ones. It has the signature of http.Server's
+// RegisterOnShutdown hooks.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for s := range b.subscriptions {
+		b.drop(s, ErrClosed)
+		b.metrics.Disconnected.WithLabelValues("shutdown").Inc()
+	}
+}
+
+// drop removes s and closes its queue. The caller holds mu.
+func (b *Broker) drop(s *Subscription, err error) {
+	delete(b.subscriptions, s)
+	s.err = err
+	close(s.events)
+	b.metrics.Connections.Dec()
+}
+
+// ordered returns the buffered events, oldest first. The caller holds mu.
+func (b *Broker) ordered() []*event.Envelope {
+	if len(b.replay) < b.config.ReplaySize {
+		return b.replay
+	}
+	return append(b.replay[b.next:len(b.replay):len(b.replay)], b.replay[:b.next]...)
+}
+
+// Register registers a handler for every type in model.ChangeEvents that
+// publishes the event to b
+func Register(r *consumer.Registry, b *Broker) {
+	for _, eventType := range model.ChangeEvents {
+		r.Handle(eventType, func(ctx context.Context, e *event.Envelope) error {
+			b.Publish(e)
+			return nil
+		})
+	}
+}
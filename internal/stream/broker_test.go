@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func envelope(id, eventType string) *event.Envelope {
+	return &event.Envelope{ID: id, Type: eventType}
+}
+
+func ids(events []*event.Envelope) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.ID)
+	}
+	return out
+}
+
+func drain(s *Subscription) []*event.Envelope {
+	var out []*event.Envelope
+	for {
+		select {
+		case e, ok := <-s.Events():
+			if !ok {
+				return out
+			}
+			out = append(out, e)
+		default:
+			return out
+		}
+	}
+}
+
+func TestBroker_Filters(t *testing.T) {
+	b := NewBroker(Config{})
+
+	all, err := b.Subscribe(nil, "")
+	require.NoError(t, err)
+	orders, err := b.Subscribe([]string{"order.*"}, "")
+	require.NoError(t, err)
+	products, err := b.Subscribe([]string{model.ProductEventCreated, model.ProductEventDeleted}, "")
+	require.NoError(t, err)
+
+	b.Publish(envelope("1", model.UserEventCreated))
+	b.Publish(envelope("2", model.OrderEventCreated))
+	b.Publish(envelope("3", model.ProductEventUpdated))
+	b.Publish(envelope("4", model.ProductEventCreated))
+	b.Publish(envelope("2", model.OrderEventCreated))
+
+	assert.Equal(t, []string{"1", "2", "3", "4"}, ids(drain(all)), "duplicates are dropped")
+	assert.Equal(t, []string{"2"}, ids(drain(orders)))
+	assert.Equal(t, []string{"4"}, ids(drain(products)))
+}
+
+func TestBroker_Replay(t *testing.T) {
+	b := NewBroker(Config{ReplaySize: 3})
+	for i := 1; i <= 5; i++ {
+		eventType := model.UserEventUpdated
+		if i%2 == 0 {
+			eventType = model.OrderEventCreated
+		}
+		b.Publish(envelope(fmt.Sprint(i), eventType))
+	}
+
+	s, err := b.Subscribe(nil, "3")
+	require.NoError(t, err)
+	assert.True(t, s.Resumed)
+	assert.Equal(t, []string{"4", "5"}, ids(s.Replay))
+
+	s, err = b.Subscribe([]string{"user.*"}, "3")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"5"}, ids(s.Replay), "replay is filtered")
+
+	s, err = b.Subscribe(nil, "5")
+	require.NoError(t, err)
+	assert.True(t, s.Resumed)
+	assert.Empty(t, s.Replay)
+
+	s, err = b.Subscribe(nil, "2")
+	require.NoError(t, err)
+	assert.False(t, s.Resumed, "evicted from the buffer")
+	assert.Empty(t, s.Replay)
+
+	// An evicted event can be buffered again
+	b.Publish(envelope("1", model.UserEventUpdated))
+	s, err = b.Subscribe(nil, "4")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"5", "1"}, ids(s.Replay))
+}
+
+func TestBroker_SlowConsumer(t *testing.T) {
+	b := NewBroker(Config{ClientBuffer: 2})
+	slow, err := b.Subscribe(nil, "")
+	require.NoError(t, err)
+	fast, err := b.Subscribe(nil, "")
+	require.NoError(t, err)
+
+	b.Publish(envelope("1", model.UserEventCreated))
+	b.Publish(envelope("2", model.UserEventCreated))
+	<-fast.Events()
+	<-fast.Events()
+	b.Publish(envelope("3", model.UserEventCreated))
+
+	assert.Equal(t, []string{"1", "2"}, ids(drain(slow)))
+	assert.ErrorIs(t, slow.Err(), ErrSlowConsumer)
+	assert.Equal(t, []string{"3"}, ids(drain(fast)))
+	assert.NoError(t, fast.Err())
+
+	// Unsubscribing a dropped subscription is harmless
+	b.Unsubscribe(slow)
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := NewBroker(Config{})
+	s, err := b.Subscribe(nil, "")
+	require.NoError(t, err)
+
+	b.Close()
+	b.Close()
+
+	_, ok := <-s.Events()
+	assert.False(t, ok)
+	assert.ErrorIs(t, s.Err(), ErrClosed)
+
+	_, err = b.Subscribe(nil, "")
+	assert.ErrorIs(t, err, ErrClosed)
+	b.Publish(envelope("1", model.UserEventCreated))
+	b.Unsubscribe(s)
+}
+
+func TestRegister(t *testing.T) {
+	b := NewBroker(Config{})
+	r := consumer.NewRegistry()
+	Register(r, b)
+
+	s, err := b.Subscribe(nil, "")
+	require.NoError(t, err)
+	for _, eventType := range model.ChangeEvents {
+		require.NoError(t, r.Dispatch(context.Background(), envelope(eventType, eventType)))
+	}
+	assert.Len(t, drain(s), len(model.ChangeEvents))
+}
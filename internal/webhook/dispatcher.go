@@ -0,0 +1,294 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/netguard"
+)
+
+const (
+	// DefaultInterval is how often the dispatcher polls when no interval is configured
+	DefaultInterval = time.Second
+	// DefaultTimeout bounds one request
+	DefaultTimeout = 10 * time.Second
+	// DefaultWorkers is the number of requests in flight at once
+	DefaultWorkers = 8
+	// DefaultMaxAttempts is the number of requests made for one delivery
+	DefaultMaxAttempts = 8
+	// DefaultMinBackoff is the delay after the first failed attempt
+	DefaultMinBackoff = 10 * time.Second
+	// DefaultMaxBackoff caps the delay between attempts of one delivery
+	DefaultMaxBackoff = time.Hour
+	// DefaultDisableAfter is the number of failed attempts in a row that
+	// disables a subscription
+	DefaultDisableAfter = 20
+
+	// leaseMargin is added to the request timeout to hold a claimed delivery
+	// back from other instances until its attempt is recorded
+	leaseMargin = 30 * time.Second
+	// recordTimeout bounds recording an attempt, which happens even when the
+	// dispatcher is stopping
+	recordTimeout = 5 * time.Second
+	// maxResponseBody caps the response body read to reuse the connection
+	maxResponseBody = 64 << 10
+	// userAgent identifies the sender to receivers
+	userAgent = "golang-testcontainers-demo-webhooks/1.0"
+)
+
+// Store holds the deliveries queued by Register
+type Store interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error)
+	RecordAttempt(ctx context.Context, attempt *model.WebhookAttempt, retryIn time.Duration, disableAfter int) (disabled bool, err error)
+}
+
+// Config tunes a Dispatcher. Zero values take the defaults above, a
+// negative DisableAfter never disables a subscription.
+type Config struct {
+	Interval     time.Duration
+	Timeout      time.Duration
+	Workers      int
+	MaxAttempts  int
+	MinBackoff   time.Duration
+	MaxBackoff   time.Duration
+	DisableAfter int
+}
+
+func (c Config) withDefaults() Config {
+	if c.Interval <= 0 {
+		c.Interval = DefaultInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+	if c.Workers <= 0 {
+		c.Workers = DefaultWorkers
+	}
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = DefaultMaxAttempts
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = DefaultMinBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultMaxBackoff
+	}
+	if c.DisableAfter == 0 {
+		c.DisableAfter = DefaultDisableAfter
+	}
+	return c
+}
+
+// Dispatcher sends due deliveries to their endpoints. A 2xx response settles
+// a delivery, anything else is retried with exponential backoff and jitter
+// until MaxAttempts requests were made. Delivery is at least once: an
+// endpoint that answers after its timeout, or an instance that stops before
+// recording the attempt, gets the event again with the same event id.
+// Deliveries are not ordered.
+type Dispatcher struct {
+	store   Store
+	client  *http.Client
+	config  Config
+	metrics *metrics.WebhookMetrics
+	now     func() time.Time
+	jitter  func(time.Duration) time.Duration
+}
+
+// NewClient returns an HTTP client for webhook requests. Redirects are not
+// followed, a 3xx response is a failed attempt, so a subscription cannot be
+// bounced to an endpoint it was not registered with. Unless allowPrivate is
+// set, connections to loopback, private and link-local addresses are refused
+// whatever the host name resolves to, so a subscription cannot reach the
+// internal network or the cloud metadata service. Proxies are not used, they
+// would be dialed instead of the endpoint.
+func NewClient(allowPrivate bool) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	if !allowPrivate {
+		transport.DialContext = netguard.Dialer().DialContext
+	}
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// NewDispatcher creates a dispatcher that sends with client, see NewClient
+func NewDispatcher(store Store, client *http.Client, config Config) *Dispatcher {
+	return &Dispatcher{
+		store:   store,
+		client:  client,
+		config:  config.withDefaults(),
+		metrics: metrics.NewWebhookMetrics(),
+		now:     time.Now,
+		jitter:  func(d time.Duration) time.Duration { return rand.N(d + 1) },
+	}
+}
+
+// Run dispatches once immediately and then on every interval until ctx is
+// done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		d.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends every due delivery, Workers at a time, and returns the
+// number that succeeded
+func (d *Dispatcher) RunOnce(ctx context.Context) int {
+	succeeded := 0
+	for ctx.Err() == nil {
+		deliveries, err := d.store.Claim(ctx, d.config.Workers, d.config.Timeout+leaseMargin)
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to claim webhook deliveries", "error", err)
+			break
+		}
+
+		var (
+			wg sync.WaitGroup
+			mu sync.Mutex
+		)
+		for _, delivery := range deliveries {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if d.dispatch(ctx, delivery) {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if len(deliveries) < d.config.Workers {
+			break
+		}
+	}
+	return succeeded
+}
+
+// dispatch makes one attempt of delivery and records its outcome. It reports
+// whether the attempt succeeded.
+func (d *Dispatcher) dispatch(ctx context.Context, delivery *model.WebhookDelivery) bool {
+	attempt := &model.WebhookAttempt{
+		DeliveryID:     delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		Attempt:        delivery.Attempts + 1,
+	}
+
+	start := d.now()
+	status, err := d.send(ctx, delivery, attempt.Attempt)
+	elapsed := d.now().Sub(start)
+	d.metrics.AttemptDuration.WithLabelValues(delivery.EventType).Observe(elapsed.Seconds())
+
+	attempt.DurationMs = elapsed.Milliseconds()
+	attempt.StatusCode = status
+	attempt.Succeeded = err == nil
+
+	logger := slog.Default().With(
+		"subscription_id", delivery.SubscriptionID,
+		"delivery_id", delivery.ID,
+		"event_id", delivery.EventID,
+		"event_type", delivery.EventType,
+		"attempt", attempt.Attempt,
+	)
+
+	var retryIn time.Duration
+	outcome := "succeeded"
+	if err != nil {
+		attempt.Error = err.Error()
+		if attempt.Attempt < d.config.MaxAttempts {
+			retryIn = d.backoff(attempt.Attempt)
+			outcome = "retry"
+			logger.Warn("webhook attempt failed, retrying", "retry_in", retryIn.String(), "error", err)
+		} else {
+			outcome = "failed"
+			logger.Error("webhook attempt failed, giving up", "error", err)
+		}
+	}
+	d.metrics.AttemptsTotal.WithLabelValues(delivery.EventType, outcome).Inc()
+
+	// The request was made, a stopping dispatcher still records it so the
+	// event is not sent again
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+	defer cancel()
+	disabled, err := d.store.RecordAttempt(recordCtx, attempt, retryIn, d.config.DisableAfter)
+	if err != nil {
+		logger.Error("failed to record webhook attempt", "error", err)
+		return attempt.Succeeded
+	}
+	if disabled {
+		d.metrics.DisabledSubscriptions.Inc()
+		logger.Warn("webhook subscription disabled after repeated failures", "failures", d.config.DisableAfter)
+	}
+	return attempt.Succeeded
+}
+
+// send posts the event of delivery, signed with the subscription secret. It
+// returns the response status, 0 when none arrived, and an error unless the
+// status is 2xx.
+func (d *Dispatcher) send(ctx context.Context, delivery *model.WebhookDelivery, attempt int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", event.StructuredContentType)
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(EventIDHeader, delivery.EventID)
+	req.Header.Set(EventTypeHeader, delivery.EventType)
+	req.Header.Set(AttemptHeader, strconv.Itoa(attempt))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, d.now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt of a delivery that has
+// failed attempts times: MinBackoff doubled for every further failure, capped
+// at MaxBackoff, of which a random half is dropped so that deliveries that
+// failed together do not retry together
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := d.config.MinBackoff
+	for i := 1; i < attempts && delay < d.config.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, d.config.MaxBackoff)
+	return delay/2 + d.jitter(delay/2)
+}
@@ -0,0 +1,268 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/netguard"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore hands out every pending delivery once per claim and keeps the
+// recorded attempts
+type fakeStore struct {
+	mu         sync.Mutex
+	pending    []*model.WebhookDelivery
+	attempts   []*model.WebhookAttempt
+	retries    []time.Duration
+	disableAt  int
+	failures   int
+	disabled   bool
+	claimCalls int
+}
+
+func (f *fakeStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.claimCalls++
+	n := min(limit, len(f.pending))
+	claimed := f.pending[:n]
+	f.pending = f.pending[n:]
+	return claimed, nil
+}
+
+func (f *fakeStore) RecordAttempt(ctx context.Context, attempt *model.WebhookAttempt, retryIn time.Duration, disableAfter int) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts = append(f.attempts, attempt)
+	f.retries = append(f.retries, retryIn)
+	if attempt.Succeeded {
+		f.failures = 0
+		return false, nil
+	}
+	f.failures++
+	if disableAfter > 0 && f.failures >= disableAfter && !f.disabled {
+		f.disabled = true
+		return true, nil
+	}
+	return false, nil
+}
+
+func newTestDispatcher(store Store, config Config) *Dispatcher {
+	d := NewDispatcher(store, NewClient(true), config)
+	d.now = func() time.Time { return time.Unix(1700000000, 0) }
+	d.jitter = func(max time.Duration) time.Duration { return max }
+	return d
+}
+
+func delivery(id int64, url string, attempts int) *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		ID:             id,
+		SubscriptionID: 1,
+		EventID:        "event-" + strconv.FormatInt(id, 10),
+		EventType:      model.UserEventCreated,
+		Payload:        []byte(`{"id":"event-` + strconv.FormatInt(id, 10) + `"}`),
+		Attempts:       attempts,
+		URL:            url,
+		Secret:         "0123456789abcdef",
+	}
+}
+
+func TestDispatcher_RunOnce(t *testing.T) {
+	t.Run("signed request succeeds", func(t *testing.T) {
+		var got *http.Request
+		var body []byte
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = r
+			body, _ = io.ReadAll(r.Body)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		store := &fakeStore{pending: []*model.WebhookDelivery{delivery(1, server.URL, 0)}}
+		d := newTestDispatcher(store, Config{})
+
+		assert.Equal(t, 1, d.RunOnce(context.Background()))
+
+		require.NotNil(t, got)
+		assert.Equal(t, http.MethodPost, got.Method)
+		assert.Equal(t, "event-1", got.Header.Get(EventIDHeader))
+		assert.Equal(t, model.UserEventCreated, got.Header.Get(EventTypeHeader))
+		assert.Equal(t, "1", got.Header.Get(AttemptHeader))
+		assert.NoError(t, Verify("0123456789abcdef", got.Header.Get(SignatureHeader), body, d.now(), time.Minute))
+
+		require.Len(t, store.attempts, 1)
+		assert.True(t, store.attempts[0].Succeeded)
+		assert.Equal(t, http.StatusNoContent, store.attempts[0].StatusCode)
+		assert.Zero(t, store.retries[0])
+	})
+
+	t.Run("failure is retried with backoff", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		store := &fakeStore{pending: []*model.WebhookDelivery{delivery(1, server.URL, 2)}}
+		d := newTestDispatcher(store, Config{MinBackoff: time.Second, MaxBackoff: time.Minute})
+
+		assert.Equal(t, 0, d.RunOnce(context.Background()))
+
+		require.Len(t, store.attempts, 1)
+		attempt := store.attempts[0]
+		assert.False(t, attempt.Succeeded)
+		assert.Equal(t, 3, attempt.Attempt)
+		assert.Equal(t, http.StatusBadGateway, attempt.StatusCode)
+		assert.Contains(t, attempt.Error, "502")
+		assert.Equal(t, 4*time.Second, store.retries[0])
+	})
+
+	t.Run("last attempt gives up", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer server.Close()
+
+		store := &fakeStore{pending: []*model.WebhookDelivery{delivery(1, server.URL, 2)}}
+		d := newTestDispatcher(store, Config{MaxAttempts: 3})
+
+		d.RunOnce(context.Background())
+
+		require.Len(t, store.attempts, 1)
+		assert.Equal(t, 3, store.attempts[0].Attempt)
+		assert.Zero(t, store.retries[0], "no retry after the last attempt")
+	})
+
+	t.Run("redirect is a failure", func(t *testing.T) {
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			t.Error("redirect followed")
+		}))
+		defer target.Close()
+		server := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+		defer server.Close()
+
+		store := &fakeStore{pending: []*model.WebhookDelivery{delivery(1, server.URL, 0)}}
+		newTestDispatcher(store, Config{}).RunOnce(context.Background())
+
+		require.Len(t, store.attempts, 1)
+		assert.False(t, store.attempts[0].Succeeded)
+		assert.Equal(t, http.StatusTemporaryRedirect, store.attempts[0].StatusCode)
+	})
+
+	t.Run("unreachable endpoint", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+
+		store := &fakeStore{pending: []*model.WebhookDelivery{delivery(1, server.URL, 0)}}
+		newTestDispatcher(store, Config{}).RunOnce(context.Background())
+
+		require.Len(t, store.attempts, 1)
+		assert.False(t, store.attempts[0].Succeeded)
+		assert.Zero(t, store.attempts[0].StatusCode)
+		assert.NotEmpty(t, store.attempts[0].Error)
+	})
+
+	t.Run("internal endpoint is refused", func(t *testing.T) {
+		var called bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		}))
+		defer server.Close()
+
+		store := &fakeStore{pending: []*model.WebhookDelivery{delivery(1, server.URL, 0)}}
+		d := newTestDispatcher(store, Config{})
+		d.client = NewClient(false)
+		d.RunOnce(context.Background())
+
+		assert.False(t, called)
+		require.Len(t, store.attempts, 1)
+		assert.False(t, store.attempts[0].Succeeded)
+		assert.Contains(t, store.attempts[0].Error, netguard.ErrBlockedAddress.Error())
+	})
+
+	t.Run("slow endpoint times out", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		defer close(release)
+
+		store := &fakeStore{pending: []*model.WebhookDelivery{delivery(1, server.URL, 0)}}
+		newTestDispatcher(store, Config{Timeout: 50 * time.Millisecond}).RunOnce(context.Background())
+
+		require.Len(t, store.attempts, 1)
+		assert.False(t, store.attempts[0].Succeeded)
+	})
+
+	t.Run("claims until the queue is drained", func(t *testing.T) {
+		var mu sync.Mutex
+		seen := map[string]int{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			seen[r.Header.Get(EventIDHeader)]++
+			mu.Unlock()
+		}))
+		defer server.Close()
+
+		store := &fakeStore{}
+		for i := range 5 {
+			store.pending = append(store.pending, delivery(int64(i+1), server.URL, 0))
+		}
+		d := newTestDispatcher(store, Config{Workers: 2})
+
+		assert.Equal(t, 5, d.RunOnce(context.Background()))
+		assert.Len(t, seen, 5)
+		assert.Equal(t, 3, store.claimCalls)
+	})
+
+	t.Run("disables after repeated failures", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer server.Close()
+
+		store := &fakeStore{pending: []*model.WebhookDelivery{
+			delivery(1, server.URL, 0),
+			delivery(2, server.URL, 0),
+		}}
+		newTestDispatcher(store, Config{DisableAfter: 2}).RunOnce(context.Background())
+
+		assert.True(t, store.disabled)
+	})
+}
+
+func TestDispatcher_Backoff(t *testing.T) {
+	d := newTestDispatcher(&fakeStore{}, Config{MinBackoff: 10 * time.Second, MaxBackoff: time.Minute})
+
+	// The jitter of newTestDispatcher keeps the whole delay
+	assert.Equal(t, 10*time.Second, d.backoff(1))
+	assert.Equal(t, 20*time.Second, d.backoff(2))
+	assert.Equal(t, 40*time.Second, d.backoff(3))
+	assert.Equal(t, time.Minute, d.backoff(4))
+	assert.Equal(t, time.Minute, d.backoff(50))
+
+	d.jitter = func(time.Duration) time.Duration { return 0 }
+	assert.Equal(t, 5*time.Second, d.backoff(1), "jitter drops at most half")
+	assert.Equal(t, 30*time.Second, d.backoff(50))
+}
+
+func TestConfig_WithDefaults(t *testing.T) {
+	c := Config{DisableAfter: -1}.withDefaults()
+	assert.Equal(t, DefaultInterval, c.Interval)
+	assert.Equal(t, DefaultWorkers, c.Workers)
+	assert.Equal(t, DefaultMaxAttempts, c.MaxAttempts)
+	assert.Equal(t, -1, c.DisableAfter, "negative never disables")
+	assert.Equal(t, DefaultDisableAfter, Config{}.withDefaults().DisableAfter)
+}
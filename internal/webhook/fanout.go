@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+)
+
+// Subscriptions is the part of the webhook repository the event handlers
+// need
+type Subscriptions interface {
+	Active(ctx context.Context) ([]*model.WebhookSubscription, error)
+	Enqueue(ctx context.Context, deliveries []*model.WebhookDelivery) error
+}
+
+// Register registers a handler for every type in model.WebhookEvents that
+// queues the event for each active subscription whose filters match it. The
+// event is sent as it was published, envelope included, by the Dispatcher.
+func Register(r *consumer.Registry, subscriptions Subscriptions) {
+	for _, eventType := range model.WebhookEvents {
+		r.Handle(eventType, func(ctx context.Context, e *event.Envelope) error {
+			return enqueue(ctx, subscriptions, e)
+		})
+	}
+}
+
+func enqueue(ctx context.Context, subscriptions Subscriptions, e *event.Envelope) error {
+	active, err := subscriptions.Active(ctx)
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	var deliveries []*model.WebhookDelivery
+	for _, s := range active {
+		if !s.Matches(e.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(e); err != nil {
+				return consumer.Permanent(err)
+			}
+		}
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			SubscriptionID: s.ID,
+			EventID:        e.ID,
+			EventType:      e.Type,
+			Payload:        payload,
+		})
+	}
+	return subscriptions.Enqueue(ctx, deliveries)
+}
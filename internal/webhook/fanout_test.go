@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSubscriptions struct {
+	active  []*model.WebhookSubscription
+	queued  []*model.WebhookDelivery
+	failure error
+}
+
+func (f *fakeSubscriptions) Active(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	return f.active, f.failure
+}
+
+func (f *fakeSubscriptions) Enqueue(ctx context.Context, deliveries []*model.WebhookDelivery) error {
+	f.queued = append(f.queued, deliveries...)
+	return nil
+}
+
+func subscription(id int64, events ...string) *model.WebhookSubscription {
+	s := &model.WebhookSubscription{Events: events, Active: true}
+	s.ID = id
+	return s
+}
+
+func TestRegister(t *testing.T) {
+	subscriptions := &fakeSubscriptions{active: []*model.WebhookSubscription{
+		subscription(1, "*"),
+		subscription(2, "user.*"),
+		subscription(3, model.OrderEventCreated),
+		subscription(4, "product.*", model.UserEventDeleted),
+	}}
+	r := consumer.NewRegistry()
+	Register(r, subscriptions)
+
+	for _, eventType := range model.WebhookEvents {
+		assert.True(t, r.Handles(eventType), eventType)
+	}
+
+	e, err := event.New(context.Background(), model.UserEventUpdated, 1, "user:42", map[string]string{"username": "jane"})
+	require.NoError(t, err)
+	require.NoError(t, r.Dispatch(context.Background(), e))
+
+	require.Len(t, subscriptions.queued, 2)
+	assert.Equal(t, int64(1), subscriptions.queued[0].SubscriptionID)
+	assert.Equal(t, int64(2), subscriptions.queued[1].SubscriptionID)
+	for _, d := range subscriptions.queued {
+		assert.Equal(t, e.ID, d.EventID)
+		assert.Equal(t, model.UserEventUpdated, d.EventType)
+
+		var sent event.Envelope
+		require.NoError(t, json.Unmarshal(d.Payload, &sent))
+		assert.Equal(t, e.ID, sent.ID)
+		assert.JSONEq(t, `{"username":"jane"}`, string(sent.Data))
+	}
+
+	t.Run("no matching subscription", func(t *testing.T) {
+		subscriptions.queued = nil
+		e, err := event.New(context.Background(), model.ProductEventCreated, 1, "product:1", map[string]int{"id": 1})
+		require.NoError(t, err)
+		subscriptions.active = []*model.WebhookSubscription{subscription(2, "user.*")}
+
+		require.NoError(t, r.Dispatch(context.Background(), e))
+		assert.Empty(t, subscriptions.queued)
+	})
+
+	t.Run("subscriptions unavailable", func(t *testing.T) {
+		subscriptions.failure = errors.New("database unavailable")
+		assert.ErrorIs(t, r.Dispatch(context.Background(), e), subscriptions.failure)
+	})
+}
@@ -0,0 +1,44 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret := "0123456789abcdef"
+	body := []byte(`{"id":"1","type":"user.created"}`)
+	sentAt := time.Unix(1700000000, 0)
+
+	header := Sign(secret, sentAt, body)
+	assert.Regexp(t, `^t=1700000000,v1=[0-9a-f]{64}$`, header)
+
+	assert.NoError(t, Verify(secret, header, body, sentAt.Add(time.Minute), 5*time.Minute))
+	assert.NoError(t, Verify(secret, header, body, sentAt.Add(time.Hour), 0), "zero tolerance accepts any age")
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		body   []byte
+	}{
+		{"tampered body", secret, header, []byte(`{"id":"2","type":"user.created"}`)},
+		{"other secret", "fedcba9876543210", header, body},
+		{"missing signature", secret, "t=1700000000", body},
+		{"malformed timestamp", secret, "t=yesterday,v1=00", body},
+		{"empty header", secret, "", body},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorIs(t, Verify(tt.secret, tt.header, tt.body, sentAt, 5*time.Minute), ErrInvalidSignature)
+		})
+	}
+
+	t.Run("too old", func(t *testing.T) {
+		err := Verify(secret, header, body, sentAt.Add(10*time.Minute), 5*time.Minute)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+		assert.Contains(t, err.Error(), "too old")
+	})
+}
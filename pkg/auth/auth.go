@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidToken is returned when a token is malformed, expired, badly signed or of the wrong type
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenRevoked is returned when a token has been revoked before its expiry
+	ErrTokenRevoked = errors.New("token has been revoked")
+	// ErrInvalidCredentials is returned when a username and password do not match
+	ErrInvalidCredentials = errors.New("invalid username or password")
+)
+
+// Identity is the authenticated caller attached to the request context
+type Identity struct {
+	UserID    uuid.UUID
+	Username  string
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+// RevocationStore keeps revoked token IDs until the tokens would have expired
+// anyway. Revoke is atomic and reports whether this call revoked the token,
+// false when it was already revoked.
+type RevocationStore interface {
+	Revoke(ctx context.Context, tokenID string, ttl time.Duration) (bool, error)
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying the authenticated identity
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the authenticated identity, if any
+func IdentityFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok && identity != nil
+}
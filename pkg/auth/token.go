@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// TokenType distinguishes access tokens from refresh tokens
+type TokenType string
+
+const (
+	AccessToken  TokenType = "access"
+	RefreshToken TokenType = "refresh"
+)
+
+// Claims are the JWT claims issued by the TokenManager
+type Claims struct {
+	jwt.RegisteredClaims
+	Username  string    `json:"username"`
+	TokenType TokenType `json:"token_type"`
+}
+
+// TokenPair is returned on login and refresh
+// @Description Access and refresh token pair
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type" example:"Bearer"`
+	ExpiresIn    int64  `json:"expires_in" example:"900"` // access token lifetime in seconds
+}
+
+// Config holds the signing key and token lifetimes
+type Config struct {
+	Secret     []byte
+	Issuer     string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+// TokenManager issues, verifies, refreshes and revokes HS256-signed JWTs
+type TokenManager struct {
+	cfg         Config
+	revocations RevocationStore
+	now         func() time.Time
+}
+
+// NewTokenManager creates a TokenManager. Revoked token IDs are kept in revocations.
+func NewTokenManager(cfg Config, revocations RevocationStore) *TokenManager {
+	return &TokenManager{
+		cfg:         cfg,
+		revocations: revocations,
+		now:         time.Now,
+	}
+}
+
+// Issue creates a new access and refresh token pair for the user
+func (m *TokenManager) Issue(userID uuid.UUID, username string) (*TokenPair, error) {
+	access, err := m.sign(userID, username, AccessToken, m.cfg.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := m.sign(userID, username, RefreshToken, m.cfg.RefreshTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(m.cfg.AccessTTL.Seconds()),
+	}, nil
+}
+
+// Authenticate verifies an access token and returns the identity it carries
+func (m *TokenManager) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	claims, err := m.Parse(ctx, token, AccessToken)
+	if err != nil {
+		return nil, err
+	}
+	return claims.identity()
+}
+
+// Parse verifies the signature, issuer, expiry, type and revocation status of a token
+func (m *TokenManager) Parse(ctx context.Context, token string, expected TokenType) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims,
+		func(t *jwt.Token) (interface{}, error) {
+			return m.cfg.Secret, nil
+		},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(m.cfg.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(m.now),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if claims.TokenType != expected || claims.ID == "" {
+		return nil, ErrInvalidToken
+	}
+
+	revoked, err := m.revocations.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, fmt.Errorf("check token revocation: %w", err)
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// Redeem revokes a refresh token and returns the identity it carries, for
+// the caller to check the user and issue a new pair. The revocation is
+// atomic: of concurrent redeems of one token only the first succeeds, the
+// others get ErrTokenRevoked, so a refresh token cannot be replayed.
+func (m *TokenManager) Redeem(ctx context.Context, refreshToken string) (*Identity, error) {
+	claims, err := m.Parse(ctx, refreshToken, RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := claims.identity()
+	if err != nil {
+		return nil, err
+	}
+
+	revoked, err := m.revoke(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if !revoked {
+		return nil, ErrTokenRevoked
+	}
+	return identity, nil
+}
+
+// Revoke revokes an access or refresh token until it expires. Tokens that
+// are already invalid are ignored.
+func (m *TokenManager) Revoke(ctx context.Context, token string) error {
+	for _, typ := range []TokenType{AccessToken, RefreshToken} {
+		claims, err := m.Parse(ctx, token, typ)
+		if err == nil {
+			_, err = m.revoke(ctx, claims)
+			return err
+		}
+		if !errors.Is(err, ErrInvalidToken) && !errors.Is(err, ErrTokenRevoked) {
+			return err
+		}
+	}
+	return nil
+}
+
+// revoke revokes the token of claims and reports whether this call did. A
+// token expiring right now is reported as revoked already.
+func (m *TokenManager) revoke(ctx context.Context, claims *Claims) (bool, error) {
+	ttl := claims.ExpiresAt.Sub(m.now())
+	if ttl <= 0 {
+		return false, nil
+	}
+	revoked, err := m.revocations.Revoke(ctx, claims.ID, ttl)
+	if err != nil {
+		return false, fmt.Errorf("revoke token: %w", err)
+	}
+	return revoked, nil
+}
+
+func (m *TokenManager) sign(userID uuid.UUID, username string, typ TokenType, ttl time.Duration) (string, error) {
+	now := m.now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    m.cfg.Issuer,
+			Subject:   userID.String(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Username:  username,
+		TokenType: typ,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.cfg.Secret)
+}
+
+func (c *Claims) identity() (*Identity, error) {
+	userID, err := uuid.Parse(c.Subject)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	return &Identity{
+		UserID:    userID,
+		Username:  c.Username,
+		TokenID:   c.ID,
+		ExpiresAt: c.ExpiresAt.Time,
+	}, nil
+}
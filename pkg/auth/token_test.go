@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryRevocations struct {
+	mu      sync.Mutex
+	revoked map[string]time.Duration
+}
+
+func newMemoryRevocations() *memoryRevocations {
+	return &memoryRevocations{revoked: make(map[string]time.Duration)}
+}
+
+func (m *memoryRevocations) Revoke(ctx context.Context, tokenID string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.revoked[tokenID]; ok {
+		return false, nil
+	}
+	m.revoked[tokenID] = ttl
+	return true, nil
+}
+
+func (m *memoryRevocations) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.revoked[tokenID]
+	return ok, nil
+}
+
+func newTestManager() *TokenManager {
+	return NewTokenManager(Config{
+		Secret:     []byte("test-secret"),
+		Issuer:     "test",
+		AccessTTL:  15 * time.Minute,
+		RefreshTTL: time.Hour,
+	}, newMemoryRevocations())
+}
+
+func TestTokenManager_IssueAndAuthenticate(t *testing.T) {
+	m := newTestManager()
+	userID := uuid.Must(uuid.NewV7())
+
+	pair, err := m.Issue(userID, "testuser")
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", pair.TokenType)
+	assert.Equal(t, int64(900), pair.ExpiresIn)
+
+	identity, err := m.Authenticate(context.Background(), pair.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, userID, identity.UserID)
+	assert.Equal(t, "testuser", identity.Username)
+
+	// A refresh token must never be accepted as an access token
+	_, err = m.Authenticate(context.Background(), pair.RefreshToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestTokenManager_RejectsInvalidTokens(t *testing.T) {
+	m := newTestManager()
+	pair, err := m.Issue(uuid.Must(uuid.NewV7()), "testuser")
+	require.NoError(t, err)
+
+	other := NewTokenManager(Config{
+		Secret:    []byte("another-secret"),
+		Issuer:    "test",
+		AccessTTL: time.Minute,
+	}, newMemoryRevocations())
+	_, err = other.Authenticate(context.Background(), pair.AccessToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	m.now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, err = m.Authenticate(context.Background(), pair.AccessToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = m.Authenticate(context.Background(), "not-a-token")
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestTokenManager_RedeemRevokesRefreshToken(t *testing.T) {
+	m := newTestManager()
+	userID := uuid.Must(uuid.NewV7())
+
+	pair, err := m.Issue(userID, "testuser")
+	require.NoError(t, err)
+
+	identity, err := m.Redeem(context.Background(), pair.RefreshToken)
+	require.NoError(t, err)
+	assert.Equal(t, userID, identity.UserID)
+	assert.Equal(t, "testuser", identity.Username)
+
+	// The refresh token cannot be replayed
+	_, err = m.Redeem(context.Background(), pair.RefreshToken)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	// An access token is not a refresh token
+	_, err = m.Redeem(context.Background(), pair.AccessToken)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestTokenManager_RedeemConcurrently(t *testing.T) {
+	m := newTestManager()
+	pair, err := m.Issue(uuid.Must(uuid.NewV7()), "testuser")
+	require.NoError(t, err)
+
+	// Every redeem passes the revocation check before any revokes, only
+	// one of them may win
+	const n = 8
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded int
+	)
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Redeem(context.Background(), pair.RefreshToken); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			} else {
+				assert.ErrorIs(t, err, ErrTokenRevoked)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, succeeded)
+}
+
+func TestTokenManager_Revoke(t *testing.T) {
+	m := newTestManager()
+	pair, err := m.Issue(uuid.Must(uuid.NewV7()), "testuser")
+	require.NoError(t, err)
+
+	require.NoError(t, m.Revoke(context.Background(), pair.AccessToken))
+
+	_, err = m.Authenticate(context.Background(), pair.AccessToken)
+	assert.ErrorIs(t, err, ErrTokenRevoked)
+
+	// Revoking garbage or an already revoked token is a no-op
+	assert.NoError(t, m.Revoke(context.Background(), pair.AccessToken))
+	assert.NoError(t, m.Revoke(context.Background(), "not-a-token"))
+}
@@ -0,0 +1,120 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Permissions checked by the API routes. A permission has the form
+// "<resource>:<action>"; roles may grant "*" or "<resource>:*".
+const (
+	UsersRead     = "users:read"
+	UsersWrite    = "users:write"
+	UsersAdmin    = "users:admin"
+	ProductsRead  = "products:read"
+	ProductsWrite = "products:write"
+	ProductsAdmin = "products:admin"
+	OrdersRead    = "orders:read"
+	OrdersWrite   = "orders:write"
+	MessagesRead  = "messages:read"
+	MessagesWrite = "messages:write"
+	MessagesAll   = "messages:*" // every message, not only the caller's own
+	EventsRead    = "events:read"
+	EventsAdmin   = "events:admin"
+	WebhooksRead  = "webhooks:read"
+	WebhooksWrite = "webhooks:write"
+	RoutesRead    = "routes:read"
+)
+
+const wildcard = "*"
+
+// ErrForbidden is returned when the caller lacks a required permission
+var ErrForbidden = errors.New("permission denied")
+
+// RoleStore loads the permissions granted through roles
+type RoleStore interface {
+	// PermissionsForUser returns the permissions of every role assigned to
+	// the user, or an empty slice if the user has no roles
+	PermissionsForUser(ctx context.Context, userID uuid.UUID) ([]string, error)
+	// PermissionsForRole returns the permissions granted by a single role
+	PermissionsForRole(ctx context.Context, role string) ([]string, error)
+}
+
+// Engine evaluates route permissions against the roles held by a user
+type Engine struct {
+	store       RoleStore
+	defaultRole string
+}
+
+// NewEngine creates a policy engine. Users without any assigned role are
+// evaluated as members of defaultRole; pass "" to deny them everything.
+func NewEngine(store RoleStore, defaultRole string) *Engine {
+	return &Engine{
+		store:       store,
+		defaultRole: defaultRole,
+	}
+}
+
+// Authorize returns nil when the user holds every required permission and
+// an error wrapping ErrForbidden when at least one is missing
+func (e *Engine) Authorize(ctx context.Context, userID uuid.UUID, required ...string) error {
+	granted, err := e.store.PermissionsForUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to load permissions: %w", err)
+	}
+
+	if len(granted) == 0 && e.defaultRole != "" {
+		granted, err = e.store.PermissionsForRole(ctx, e.defaultRole)
+		if err != nil {
+			return fmt.Errorf("failed to load default role permissions: %w", err)
+		}
+	}
+
+	for _, permission := range required {
+		if !Allows(granted, permission) {
+			return fmt.Errorf("%w: missing %s", ErrForbidden, permission)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether any of the granted permissions covers required
+func Allows(granted []string, required string) bool {
+	for _, g := range granted {
+		if Match(g, required) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the granted permission covers the required one.
+// Segments are separated by ":" and a "*" segment matches any value,
+// including all remaining segments when it is the last one.
+func Match(granted, required string) bool {
+	if granted == wildcard || granted == required {
+		return true
+	}
+
+	g := strings.Split(granted, ":")
+	r := strings.Split(required, ":")
+	for i, segment := range g {
+		if i >= len(r) {
+			return false
+		}
+		if segment == wildcard {
+			if i == len(g)-1 {
+				return true
+			}
+			continue
+		}
+		if segment != r[i] {
+			return false
+		}
+	}
+	return len(g) == len(r)
+}
@@ -0,0 +1,81 @@
+package authz
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type memoryRoles struct {
+	users map[uuid.UUID][]string
+	roles map[string][]string
+	err   error
+}
+
+func (m *memoryRoles) PermissionsForUser(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	return m.users[userID], m.err
+}
+
+func (m *memoryRoles) PermissionsForRole(ctx context.Context, role string) ([]string, error) {
+	return m.roles[role], m.err
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		granted  string
+		required string
+		want     bool
+	}{
+		{"*", "users:write", true},
+		{"users:read", "users:read", true},
+		{"users:*", "users:write", true},
+		{"*:read", "orders:read", true},
+		{"*:read", "orders:write", false},
+		{"users:read", "users:write", false},
+		{"users", "users:read", false},
+		{"users:read:own", "users:read", false},
+		{"orders:*", "users:read", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.granted+"/"+tt.required, func(t *testing.T) {
+			assert.Equal(t, tt.want, Match(tt.granted, tt.required))
+		})
+	}
+}
+
+func TestEngine_Authorize(t *testing.T) {
+	admin := uuid.Must(uuid.NewV7())
+	member := uuid.Must(uuid.NewV7())
+	newcomer := uuid.Must(uuid.NewV7())
+
+	store := &memoryRoles{
+		users: map[uuid.UUID][]string{
+			admin:  {"*"},
+			member: {UsersRead, "orders:*"},
+		},
+		roles: map[string][]string{
+			"user": {ProductsRead},
+		},
+	}
+	engine := NewEngine(store, "user")
+	ctx := context.Background()
+
+	assert.NoError(t, engine.Authorize(ctx, admin, UsersWrite, ProductsWrite))
+	assert.NoError(t, engine.Authorize(ctx, member, UsersRead, OrdersWrite))
+	assert.ErrorIs(t, engine.Authorize(ctx, member, UsersRead, UsersWrite), ErrForbidden)
+
+	// Users without roles fall back to the default role
+	assert.NoError(t, engine.Authorize(ctx, newcomer, ProductsRead))
+	assert.ErrorIs(t, engine.Authorize(ctx, newcomer, OrdersRead), ErrForbidden)
+	assert.ErrorIs(t, NewEngine(store, "").Authorize(ctx, newcomer, ProductsRead), ErrForbidden)
+
+	// Store failures are not reported as denials
+	store.err = errors.New("connection refused")
+	err := engine.Authorize(ctx, admin, UsersRead)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrForbidden)
+}
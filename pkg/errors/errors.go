@@ -1,18 +1,42 @@
 package errors
 
 import (
+	"context"
+	stderrors "errors"
 	"fmt"
 	"net/http"
 )
 
 // Error types
 type Error struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-	Op      string `json:"op,omitempty"` // Operation that failed
-	Err     error  `json:"-"`            // Underlying error
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Op      string       `json:"op,omitempty"`     // Operation that failed
+	Field   string       `json:"field,omitempty"`  // Field that caused a conflict or validation failure
+	Fields  []FieldError `json:"fields,omitempty"` // Per-field validation failures
+	Err     error        `json:"-"`                // Underlying error
+
+	kind bool // set on the category sentinels below
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field" example:"email"`
+	Message string `json:"message" example:"must be a valid email address"`
 }
 
+// Error categories. Match them with errors.Is; any *Error with the same
+// code matches, e.g. errors.Is(err, ErrNotFound) for every kind of not found.
+var (
+	ErrNotFound    = &Error{Code: http.StatusNotFound, Message: "not found", kind: true}
+	ErrConflict    = &Error{Code: http.StatusConflict, Message: "conflict", kind: true}
+	ErrValidation  = &Error{Code: http.StatusUnprocessableEntity, Message: "validation failed", kind: true}
+	ErrUnavailable = &Error{Code: http.StatusServiceUnavailable, Message: "service temporarily unavailable", kind: true}
+	ErrTimeout     = &Error{Code: http.StatusGatewayTimeout, Message: "request timed out", kind: true}
+)
+
+var kinds = []*Error{ErrNotFound, ErrConflict, ErrValidation, ErrUnavailable, ErrTimeout}
+
 func (e *Error) Error() string {
 	if e.Err != nil {
 		return fmt.Sprintf("%s: %v", e.Message, e.Err)
@@ -20,6 +44,36 @@ func (e *Error) Error() string {
 	return e.Message
 }
 
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether e belongs to the category target, when target is one of
+// the category sentinels
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.kind && t.Code == e.Code
+}
+
+// From returns the *Error carried by err. Errors outside the taxonomy are
+// classified by the category they match with errors.Is, context deadlines
+// become timeouts, and anything else is an internal error.
+func From(err error) *Error {
+	var e *Error
+	if stderrors.As(err, &e) {
+		return e
+	}
+	for _, kind := range kinds {
+		if stderrors.Is(err, kind) {
+			return &Error{Code: kind.Code, Message: err.Error(), Err: err}
+		}
+	}
+	if stderrors.Is(err, context.DeadlineExceeded) {
+		return NewTimeout("", err)
+	}
+	return NewInternalError("", err)
+}
+
 // Error constructors
 func NewNotFound(op string, entity string, id interface{}) *Error {
 	return &Error{
@@ -53,3 +107,57 @@ func NewValidationError(op string, message string) *Error {
 		Op:      op,
 	}
 }
+
+func NewUnauthorized(op string, message string) *Error {
+	return &Error{
+		Code:    http.StatusUnauthorized,
+		Message: message,
+		Op:      op,
+	}
+}
+
+func NewForbidden(op string, message string) *Error {
+	return &Error{
+		Code:    http.StatusForbidden,
+		Message: message,
+		Op:      op,
+	}
+}
+
+func NewUnavailable(op string, err error) *Error {
+	return &Error{
+		Code:    http.StatusServiceUnavailable,
+		Message: "service temporarily unavailable",
+		Op:      op,
+		Err:     err,
+	}
+}
+
+func NewConflict(op string, message string, err error) *Error {
+	return &Error{
+		Code:    http.StatusConflict,
+		Message: message,
+		Op:      op,
+		Err:     err,
+	}
+}
+
+// NewDuplicate reports a unique constraint violation on field
+func NewDuplicate(op string, entity string, field string, err error) *Error {
+	return &Error{
+		Code:    http.StatusConflict,
+		Message: fmt.Sprintf("%s with this %s already exists", entity, field),
+		Op:      op,
+		Field:   field,
+		Err:     err,
+	}
+}
+
+func NewTimeout(op string, err error) *Error {
+	return &Error{
+		Code:    http.StatusGatewayTimeout,
+		Message: "request timed out",
+		Op:      op,
+		Err:     err,
+	}
+}
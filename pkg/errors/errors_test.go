@@ -0,0 +1,53 @@
+package errors
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type conflictError struct{}
+
+func (conflictError) Error() string { return "stale write" }
+
+func (conflictError) Is(target error) bool { return target == ErrConflict }
+
+func TestIs(t *testing.T) {
+	userNotFound := &Error{Code: http.StatusNotFound, Message: "user not found"}
+	productNotFound := &Error{Code: http.StatusNotFound, Message: "product not found"}
+
+	assert.ErrorIs(t, userNotFound, ErrNotFound)
+	assert.ErrorIs(t, fmt.Errorf("loading: %w", userNotFound), ErrNotFound)
+	assert.ErrorIs(t, NewDuplicate("op", "user", "email", nil), ErrConflict)
+	assert.NotErrorIs(t, userNotFound, productNotFound)
+	assert.NotErrorIs(t, userNotFound, ErrConflict)
+}
+
+func TestFrom(t *testing.T) {
+	duplicate := NewDuplicate("UserRepository.Create", "user", "email", nil)
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantMsg  string
+	}{
+		{"classified", duplicate, http.StatusConflict, "user with this email already exists"},
+		{"wrapped", fmt.Errorf("create: %w", duplicate), http.StatusConflict, "user with this email already exists"},
+		{"category member", conflictError{}, http.StatusConflict, "stale write"},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, "request timed out"},
+		{"unknown", stderrors.New("dial tcp: secret-host:3306"), http.StatusInternalServerError, "internal server error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := From(tt.err)
+			assert.Equal(t, tt.wantCode, got.Code)
+			assert.Equal(t, tt.wantMsg, got.Message)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package etag
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrPreconditionRequired is returned when a conditional request has no If-Match header
+	ErrPreconditionRequired = errors.New("If-Match header is required")
+	// ErrPreconditionFailed is returned when If-Match does not name the current version
+	ErrPreconditionFailed = errors.New("resource has been modified, fetch it again and retry")
+)
+
+// Format returns the strong entity tag for a version, e.g. "3"
+func Format(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// Set writes the ETag header for a version
+func Set(w http.ResponseWriter, version int) {
+	w.Header().Set("ETag", Format(version))
+}
+
+// Check compares the request's If-Match header with the current version.
+// The header is required; "*" matches any existing version. Weak tags never
+// match because If-Match uses strong comparison.
+func Check(r *http.Request, current int) error {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return ErrPreconditionRequired
+	}
+	if Matches(header, current) {
+		return nil
+	}
+	return ErrPreconditionFailed
+}
+
+// Matches reports whether an If-Match header value names version
+func Matches(header string, version int) bool {
+	want := Format(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == want {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package etag
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		current int
+		want    error
+	}{
+		{"missing header", "", 3, ErrPreconditionRequired},
+		{"current version", `"3"`, 3, nil},
+		{"stale version", `"2"`, 3, ErrPreconditionFailed},
+		{"list containing current", `"1", "3"`, 3, nil},
+		{"wildcard", "*", 7, nil},
+		{"weak tag", `W/"3"`, 3, ErrPreconditionFailed},
+		{"unquoted", "3", 3, ErrPreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/users/1", nil)
+			if tt.ifMatch != "" {
+				req.Header.Set("If-Match", tt.ifMatch)
+			}
+			assert.Equal(t, tt.want, Check(req, tt.current))
+		})
+	}
+}
+
+func TestSet(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Set(rec, 12)
+	assert.Equal(t, `"12"`, rec.Header().Get("ETag"))
+}
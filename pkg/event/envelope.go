@@ -0,0 +1,83 @@
+// Package event defines the envelope every message published to Kafka is
+// wrapped in. The envelope follows CloudEvents 1.0, with the schema version
+// of the payload and the correlating request id as extension attributes.
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/google/uuid"
+)
+
+const (
+	// SpecVersion is the CloudEvents version of the envelope
+	SpecVersion = "1.0"
+	// Source identifies this service as the producer of its events
+	Source = "/golang-testcontainers-demo"
+	// ContentType is the encoding of the event data
+	ContentType = "application/json"
+)
+
+var (
+	// ErrUnknownType is returned for an event type that is not registered
+	ErrUnknownType = errors.New("unknown event type")
+	// ErrUnsupportedVersion is returned for a payload newer than the registered schema
+	ErrUnsupportedVersion = errors.New("unsupported event schema version")
+	// ErrNotEvent is returned for a message that carries no envelope
+	ErrNotEvent = errors.New("message is not a cloud event")
+)
+
+// Envelope describes an event and carries its payload as JSON
+type Envelope struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	SchemaVersion   int             `json:"schemaversion"`
+	CorrelationID   string          `json:"correlationid,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+}
+
+// New wraps data, encoded as JSON, in an envelope with a fresh id. The
+// request id of ctx, if any, becomes the correlation id.
+func New(ctx context.Context, eventType string, version int, subject string, data any) (*Envelope, error) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s data: %w", eventType, err)
+	}
+	return &Envelope{
+		SpecVersion:     SpecVersion,
+		ID:              uuid.Must(uuid.NewV7()).String(),
+		Type:            eventType,
+		Source:          Source,
+		Subject:         subject,
+		Time:            time.Now().UTC(),
+		DataContentType: ContentType,
+		SchemaVersion:   version,
+		CorrelationID:   requestid.FromContext(ctx),
+		Data:            payload,
+	}, nil
+}
+
+// Validate reports whether the required attributes are set
+func (e *Envelope) Validate() error {
+	switch {
+	case e.SpecVersion != SpecVersion:
+		return fmt.Errorf("%w: specversion %q", ErrNotEvent, e.SpecVersion)
+	case e.ID == "":
+		return fmt.Errorf("%w: missing id", ErrNotEvent)
+	case e.Type == "":
+		return fmt.Errorf("%w: missing type", ErrNotEvent)
+	case e.Source == "":
+		return fmt.Errorf("%w: missing source", ErrNotEvent)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type created struct {
+	Name string `json:"name"`
+}
+
+type renamed struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+func (r renamed) EventType() string { return r.Type }
+
+func testRegistry() *Registry {
+	r := NewRegistry()
+	Register[created](r, "thing.created", 2)
+	Register[renamed](r, "thing.renamed", 1)
+	Register[renamed](r, "thing.moved", 1)
+	return r
+}
+
+func TestRegistry_Wrap(t *testing.T) {
+	r := testRegistry()
+	ctx := requestid.NewContext(context.Background(), "req-1")
+
+	e, err := r.Wrap(ctx, "thing:1", created{Name: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, SpecVersion, e.SpecVersion)
+	assert.NotEmpty(t, e.ID)
+	assert.Equal(t, "thing.created", e.Type)
+	assert.Equal(t, Source, e.Source)
+	assert.Equal(t, "thing:1", e.Subject)
+	assert.Equal(t, 2, e.SchemaVersion)
+	assert.Equal(t, "req-1", e.CorrelationID)
+	assert.JSONEq(t, `{"name":"a"}`, string(e.Data))
+
+	// The type of a payload shared by several types comes from EventType()
+	e, err = r.Wrap(ctx, "thing:1", renamed{Type: "thing.moved"})
+	require.NoError(t, err)
+	assert.Equal(t, "thing.moved", e.Type)
+
+	same, err := r.Wrap(ctx, "thing:1", e)
+	require.NoError(t, err)
+	assert.Same(t, e, same)
+
+	_, err = r.Wrap(ctx, "thing:1", map[string]string{"a": "b"})
+	assert.ErrorIs(t, err, ErrUnknownType)
+	_, err = r.Wrap(ctx, "thing:1", renamed{Type: "thing.deleted"})
+	assert.ErrorIs(t, err, ErrUnknownType)
+
+	assert.Panics(t, func() { Register[created](r, "thing.created", 3) })
+}
+
+func TestRegistry_Decode(t *testing.T) {
+	r := testRegistry()
+	e, err := r.New(context.Background(), "thing.created", "thing:1", created{Name: "a"})
+	require.NoError(t, err)
+
+	payload, err := r.Decode(e)
+	require.NoError(t, err)
+	assert.Equal(t, &created{Name: "a"}, payload)
+
+	got, err := Decode[created](r, e)
+	require.NoError(t, err)
+	assert.Equal(t, "a", got.Name)
+
+	_, err = Decode[renamed](r, e)
+	assert.Error(t, err)
+
+	// Older payloads are read, newer ones are not
+	e.SchemaVersion = 1
+	_, err = r.Decode(e)
+	assert.NoError(t, err)
+	e.SchemaVersion = 3
+	_, err = r.Decode(e)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+
+	e.Type = "thing.deleted"
+	_, err = r.Decode(e)
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
+// consumed turns encoded headers and value into a consumed message
+func consumed(headers []sarama.RecordHeader, value []byte) *sarama.ConsumerMessage {
+	msg := &sarama.ConsumerMessage{Value: value}
+	for _, h := range headers {
+		msg.Headers = append(msg.Headers, &h)
+	}
+	return msg
+}
+
+func TestEncode(t *testing.T) {
+	e := &Envelope{
+		SpecVersion:     SpecVersion,
+		ID:              "id-1",
+		Type:            "thing.created",
+		Source:          Source,
+		Subject:         "thing:1",
+		Time:            time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		DataContentType: ContentType,
+		SchemaVersion:   2,
+		CorrelationID:   "req-1",
+		Data:            json.RawMessage(`{"name":"a"}`),
+	}
+
+	t.Run("binary", func(t *testing.T) {
+		headers, value, err := e.Encode(Binary)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"name":"a"}`, string(value))
+		assert.Contains(t, headers, header("ce_type", "thing.created"))
+		assert.Contains(t, headers, header("ce_schemaversion", "2"))
+		assert.Contains(t, headers, header("content-type", ContentType))
+
+		decoded, err := FromMessage(consumed(headers, value))
+		require.NoError(t, err)
+		assert.Equal(t, e, decoded)
+	})
+
+	t.Run("structured", func(t *testing.T) {
+		headers, value, err := e.Encode(Structured)
+		require.NoError(t, err)
+		assert.Equal(t, []sarama.RecordHeader{header("content-type", StructuredContentType)}, headers)
+
+		var fields map[string]any
+		require.NoError(t, json.Unmarshal(value, &fields))
+		assert.Equal(t, "1.0", fields["specversion"])
+		assert.Equal(t, "req-1", fields["correlationid"])
+		assert.Equal(t, map[string]any{"name": "a"}, fields["data"])
+
+		decoded, err := FromMessage(consumed(headers, value))
+		require.NoError(t, err)
+		assert.Equal(t, e, decoded)
+	})
+}
+
+func TestFromMessage_NotEvent(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  *sarama.ConsumerMessage
+	}{
+		{"plain json", &sarama.ConsumerMessage{Value: []byte(`{"content":"hello"}`)}},
+		{"missing id", consumed([]sarama.RecordHeader{header("ce_specversion", "1.0"), header("ce_type", "a"), header("ce_source", "/s")}, nil)},
+		{"bad structured", consumed([]sarama.RecordHeader{header("content-type", StructuredContentType)}, []byte(`hello`))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromMessage(tt.msg)
+			assert.ErrorIs(t, err, ErrNotEvent)
+		})
+	}
+}
+
+func TestTypes(t *testing.T) {
+	for _, eventType := range []string{
+		model.UserEventCreated,
+		model.UserEventUpdated,
+		model.UserEventStatusChanged,
+		model.UserEventDeleted,
+		model.UserEventRestored,
+		model.ProductEventCreated,
+		model.ProductEventUpdated,
+		model.ProductEventDeleted,
+		model.ProductEventRestored,
+		model.OrderEventCreated,
+		model.MessageSent,
+	} {
+		_, ok := Types.Version(eventType)
+		assert.True(t, ok, eventType)
+	}
+
+	// Stored users carry their password hash and are not an event payload
+	_, err := Types.Wrap(context.Background(), "user:1", &model.User{})
+	assert.ErrorIs(t, err, ErrUnknownType)
+}
+
+func TestParseMode(t *testing.T) {
+	mode, err := ParseMode("")
+	require.NoError(t, err)
+	assert.Equal(t, Binary, mode)
+
+	mode, err = ParseMode("structured")
+	require.NoError(t, err)
+	assert.Equal(t, Structured, mode)
+
+	_, err = ParseMode("avro")
+	assert.Error(t, err)
+}
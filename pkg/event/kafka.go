@@ -0,0 +1,121 @@
+package event
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// Mode selects how an envelope is laid out in a Kafka message
+type Mode string
+
+const (
+	// Binary puts the attributes in ce_ headers and the data in the value
+	Binary Mode = "binary"
+	// Structured puts the whole envelope, as JSON, in the value
+	Structured Mode = "structured"
+)
+
+const (
+	// StructuredContentType marks a message value holding a JSON envelope
+	StructuredContentType = "application/cloudevents+json"
+
+	contentTypeHeader = "content-type"
+	headerPrefix      = "ce_"
+)
+
+// ParseMode returns the mode named s, Binary when s is empty
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", Binary:
+		return Binary, nil
+	case Structured:
+		return Structured, nil
+	}
+	return "", fmt.Errorf("unknown event mode %q, want binary or structured", s)
+}
+
+// Encode returns the headers and value of a Kafka message carrying e
+func (e *Envelope) Encode(mode Mode) ([]sarama.RecordHeader, []byte, error) {
+	if mode == Structured {
+		value, err := json.Marshal(e)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []sarama.RecordHeader{header(contentTypeHeader, StructuredContentType)}, value, nil
+	}
+
+	headers := []sarama.RecordHeader{
+		header(headerPrefix+"specversion", e.SpecVersion),
+		header(headerPrefix+"id", e.ID),
+		header(headerPrefix+"type", e.Type),
+		header(headerPrefix+"source", e.Source),
+		header(headerPrefix+"time", e.Time.Format(time.RFC3339Nano)),
+		header(headerPrefix+"schemaversion", strconv.Itoa(e.SchemaVersion)),
+	}
+	if e.Subject != "" {
+		headers = append(headers, header(headerPrefix+"subject", e.Subject))
+	}
+	if e.CorrelationID != "" {
+		headers = append(headers, header(headerPrefix+"correlationid", e.CorrelationID))
+	}
+	if e.DataContentType != "" {
+		headers = append(headers, header(contentTypeHeader, e.DataContentType))
+	}
+	return headers, e.Data, nil
+}
+
+// FromMessage reads the envelope of a consumed message in either mode
+func FromMessage(msg *sarama.ConsumerMessage) (*Envelope, error) {
+	attrs := make(map[string]string)
+	for _, h := range msg.Headers {
+		if h != nil {
+			attrs[strings.ToLower(string(h.Key))] = string(h.Value)
+		}
+	}
+
+	var e Envelope
+	if strings.HasPrefix(attrs[contentTypeHeader], StructuredContentType) {
+		if err := json.Unmarshal(msg.Value, &e); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrNotEvent, err)
+		}
+		return &e, e.Validate()
+	}
+
+	if attrs[headerPrefix+"specversion"] == "" {
+		return nil, ErrNotEvent
+	}
+	e = Envelope{
+		SpecVersion:     attrs[headerPrefix+"specversion"],
+		ID:              attrs[headerPrefix+"id"],
+		Type:            attrs[headerPrefix+"type"],
+		Source:          attrs[headerPrefix+"source"],
+		Subject:         attrs[headerPrefix+"subject"],
+		DataContentType: attrs[contentTypeHeader],
+		CorrelationID:   attrs[headerPrefix+"correlationid"],
+		Data:            msg.Value,
+	}
+	if v := attrs[headerPrefix+"time"]; v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: time: %v", ErrNotEvent, err)
+		}
+		e.Time = t
+	}
+	if v := attrs[headerPrefix+"schemaversion"]; v != "" {
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: schemaversion: %v", ErrNotEvent, err)
+		}
+		e.SchemaVersion = version
+	}
+	return &e, e.Validate()
+}
+
+func header(key, value string) sarama.RecordHeader {
+	return sarama.RecordHeader{Key: []byte(key), Value: []byte(value)}
+}
@@ -0,0 +1,106 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Registry maps event types to the schema version and payload struct they
+// are published with
+type Registry struct {
+	types  map[string]schema
+	byType map[reflect.Type][]string
+}
+
+type schema struct {
+	version int
+	payload reflect.Type
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		types:  make(map[string]schema),
+		byType: make(map[reflect.Type][]string),
+	}
+}
+
+// Register records T as the payload of eventType at schema version.
+// Registering a type twice panics.
+func Register[T any](r *Registry, eventType string, version int) {
+	if _, ok := r.types[eventType]; ok {
+		panic(fmt.Sprintf("event: type %q already registered", eventType))
+	}
+	payload := reflect.TypeFor[T]()
+	r.types[eventType] = schema{version: version, payload: payload}
+	r.byType[payload] = append(r.byType[payload], eventType)
+}
+
+// Version returns the current schema version of eventType
+func (r *Registry) Version(eventType string) (int, bool) {
+	s, ok := r.types[eventType]
+	return s.version, ok
+}
+
+// New wraps data in an envelope of eventType at its registered version
+func (r *Registry) New(ctx context.Context, eventType, subject string, data any) (*Envelope, error) {
+	version, ok := r.Version(eventType)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, eventType)
+	}
+	return New(ctx, eventType, version, subject, data)
+}
+
+// Wrap returns value in an envelope. Envelopes are returned as they are.
+// The type is taken from an EventType() string method when value has one,
+// otherwise from the only event type registered for the Go type of value.
+// Values of unregistered types are refused, so arbitrary structs never
+// reach the wire.
+func (r *Registry) Wrap(ctx context.Context, subject string, value any) (*Envelope, error) {
+	switch v := value.(type) {
+	case *Envelope:
+		return v, nil
+	case interface{ EventType() string }:
+		return r.New(ctx, v.EventType(), subject, value)
+	}
+
+	types := r.byType[reflect.TypeOf(value)]
+	if len(types) != 1 {
+		return nil, fmt.Errorf("%w for %T", ErrUnknownType, value)
+	}
+	return r.New(ctx, types[0], subject, value)
+}
+
+// Decode returns a pointer to the registered payload struct of e, filled
+// from its data
+func (r *Registry) Decode(e *Envelope) (any, error) {
+	s, ok := r.types[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, e.Type)
+	}
+	if e.SchemaVersion > s.version {
+		return nil, fmt.Errorf("%w: %s version %d, supported up to %d", ErrUnsupportedVersion, e.Type, e.SchemaVersion, s.version)
+	}
+
+	payload := reflect.New(s.payload).Interface()
+	if err := json.Unmarshal(e.Data, payload); err != nil {
+		return nil, fmt.Errorf("decode %s data: %w", e.Type, err)
+	}
+	return payload, nil
+}
+
+// Decode returns the payload of e as T, which must be the payload struct
+// registered for its type
+func Decode[T any](r *Registry, e *Envelope) (T, error) {
+	var zero T
+	payload, err := r.Decode(e)
+	if err != nil {
+		return zero, err
+	}
+	typed, ok := payload.(*T)
+	if !ok {
+		return zero, fmt.Errorf("event %s carries %T, not %T", e.Type, payload, zero)
+	}
+	return *typed, nil
+}
@@ -0,0 +1,21 @@
+package event
+
+import "github.com/Napat/golang-testcontainers-demo/pkg/model"
+
+// Types registers every event this service publishes. Bump the version of a
+// type when its payload changes in a way older consumers cannot read.
+var Types = NewRegistry()
+
+func init() {
+	Register[model.UserResponse](Types, model.UserEventCreated, 1)
+	Register[model.UserChangedEvent](Types, model.UserEventUpdated, 1)
+	Register[model.UserChangedEvent](Types, model.UserEventStatusChanged, 1)
+	Register[model.UserChangedEvent](Types, model.UserEventDeleted, 1)
+	Register[model.UserChangedEvent](Types, model.UserEventRestored, 1)
+	Register[model.ProductChangedEvent](Types, model.ProductEventCreated, 1)
+	Register[model.ProductChangedEvent](Types, model.ProductEventUpdated, 1)
+	Register[model.ProductChangedEvent](Types, model.ProductEventDeleted, 1)
+	Register[model.ProductChangedEvent](Types, model.ProductEventRestored, 1)
+	Register[model.OrderResponse](Types, model.OrderEventCreated, 1)
+	Register[model.MessageSentEvent](Types, model.MessageSent, 1)
+}
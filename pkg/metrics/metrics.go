@@ -115,8 +115,22 @@ type MessageMetrics struct {
 	PublishDuration   *prometheus.HistogramVec
 }
 
+var (
+	messageMetricsSingleton    *MessageMetrics
+	messageMetricsSingletonMux sync.Mutex
+)
+
+// NewMessageMetrics creates the message metrics once and returns the same
+// instance afterwards
 func NewMessageMetrics() *MessageMetrics {
-	m := &MessageMetrics{
+	messageMetricsSingletonMux.Lock()
+	defer messageMetricsSingletonMux.Unlock()
+
+	if messageMetricsSingleton != nil {
+		return messageMetricsSingleton
+	}
+
+	messageMetricsSingleton = &MessageMetrics{
 		MessagesPublished: promauto.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "messages_published_total",
@@ -133,7 +147,102 @@ func NewMessageMetrics() *MessageMetrics {
 			[]string{"topic"},
 		),
 	}
-	return m
+
+	return messageMetricsSingleton
+}
+
+// MessageAPIMetrics สำหรับเก็บ metrics ของสถานะข้อความที่ส่งผ่าน message API
+type MessageAPIMetrics struct {
+	StatusTotal *prometheus.CounterVec
+}
+
+var (
+	messageAPIMetricsSingleton    *MessageAPIMetrics
+	messageAPIMetricsSingletonMux sync.Mutex
+)
+
+// NewMessageAPIMetrics creates the message API metrics once and returns the
+// same instance afterwards
+func NewMessageAPIMetrics() *MessageAPIMetrics {
+	messageAPIMetricsSingletonMux.Lock()
+	defer messageAPIMetricsSingletonMux.Unlock()
+
+	if messageAPIMetricsSingleton != nil {
+		return messageAPIMetricsSingleton
+	}
+
+	messageAPIMetricsSingleton = &MessageAPIMetrics{
+		StatusTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "api_messages_total",
+				Help: "Total number of messages of the message API reaching a status: scheduled, queued, published, failed or cancelled",
+			},
+			[]string{"status"},
+		),
+	}
+
+	return messageAPIMetricsSingleton
+}
+
+// MessageSchedulerMetrics สำหรับเก็บ metrics ของข้อความที่ตั้งเวลาส่งและ dispatcher
+type MessageSchedulerMetrics struct {
+	ScheduledMessages prometheus.Gauge
+	DueMessages       prometheus.Gauge
+	InFlightMessages  prometheus.Gauge
+	LagSeconds        prometheus.Gauge
+	DispatchedTotal   *prometheus.CounterVec
+}
+
+var (
+	messageSchedulerMetricsSingleton    *MessageSchedulerMetrics
+	messageSchedulerMetricsSingletonMux sync.Mutex
+)
+
+// NewMessageSchedulerMetrics creates the message scheduler metrics once and
+// returns the same instance afterwards
+func NewMessageSchedulerMetrics() *MessageSchedulerMetrics {
+	messageSchedulerMetricsSingletonMux.Lock()
+	defer messageSchedulerMetricsSingletonMux.Unlock()
+
+	if messageSchedulerMetricsSingleton != nil {
+		return messageSchedulerMetricsSingleton
+	}
+
+	messageSchedulerMetricsSingleton = &MessageSchedulerMetrics{
+		ScheduledMessages: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "message_scheduler_scheduled_messages",
+				Help: "Number of scheduled messages waiting to be published, due or not",
+			},
+		),
+		DueMessages: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "message_scheduler_due_messages",
+				Help: "Number of scheduled messages that are due and not yet claimed",
+			},
+		),
+		InFlightMessages: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "message_scheduler_in_flight_messages",
+				Help: "Number of scheduled messages claimed by a dispatcher",
+			},
+		),
+		LagSeconds: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "message_scheduler_lag_seconds",
+				Help: "How long the oldest due scheduled message has been waiting",
+			},
+		),
+		DispatchedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "message_scheduler_dispatched_total",
+				Help: "Total number of claimed scheduled messages by outcome: published, retry, failed or skipped",
+			},
+			[]string{"outcome"},
+		),
+	}
+
+	return messageSchedulerMetricsSingleton
 }
 
 // SearchMetrics สำหรับเก็บ metrics ของ search operations
@@ -183,3 +292,228 @@ func NewSearchMetrics() *SearchMetrics {
 
 	return searchMetricsSingleton
 }
+
+// OutboxMetrics สำหรับเก็บ metrics ของ transactional outbox
+type OutboxMetrics struct {
+	PendingMessages prometheus.Gauge
+	LagSeconds      prometheus.Gauge
+	PublishedTotal  *prometheus.CounterVec
+	FailuresTotal   *prometheus.CounterVec
+	ParkedTotal     *prometheus.CounterVec
+}
+
+var (
+	outboxMetricsSingleton    *OutboxMetrics
+	outboxMetricsSingletonMux sync.Mutex
+)
+
+// NewOutboxMetrics creates the outbox metrics once and returns the same
+// instance afterwards
+func NewOutboxMetrics() *OutboxMetrics {
+	outboxMetricsSingletonMux.Lock()
+	defer outboxMetricsSingletonMux.Unlock()
+
+	if outboxMetricsSingleton != nil {
+		return outboxMetricsSingleton
+	}
+
+	outboxMetricsSingleton = &OutboxMetrics{
+		PendingMessages: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "outbox_pending_messages",
+				Help: "Number of outbox messages not yet published",
+			},
+		),
+		LagSeconds: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "outbox_lag_seconds",
+				Help: "Age of the oldest unpublished outbox message",
+			},
+		),
+		PublishedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "outbox_published_total",
+				Help: "Total number of outbox messages published",
+			},
+			[]string{"event_type"},
+		),
+		FailuresTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "outbox_publish_failures_total",
+				Help: "Total number of failed outbox publish attempts",
+			},
+			[]string{"event_type"},
+		),
+		ParkedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "outbox_parked_total",
+				Help: "Total number of outbox messages given up after their last attempt",
+			},
+			[]string{"event_type"},
+		),
+	}
+
+	return outboxMetricsSingleton
+}
+
+// WebhookMetrics สำหรับเก็บ metrics ของการส่ง webhook
+type WebhookMetrics struct {
+	AttemptsTotal         *prometheus.CounterVec
+	AttemptDuration       *prometheus.HistogramVec
+	DisabledSubscriptions prometheus.Counter
+}
+
+var (
+	webhookMetricsSingleton    *WebhookMetrics
+	webhookMetricsSingletonMux sync.Mutex
+)
+
+// NewWebhookMetrics creates the webhook metrics once and returns the same
+// instance afterwards
+func NewWebhookMetrics() *WebhookMetrics {
+	webhookMetricsSingletonMux.Lock()
+	defer webhookMetricsSingletonMux.Unlock()
+
+	if webhookMetricsSingleton != nil {
+		return webhookMetricsSingleton
+	}
+
+	webhookMetricsSingleton = &WebhookMetrics{
+		AttemptsTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "webhook_attempts_total",
+				Help: "Total number of webhook requests by outcome: succeeded, retry or failed",
+			},
+			[]string{"event_type", "status"},
+		),
+		AttemptDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "webhook_attempt_duration_seconds",
+				Help:    "Duration of webhook requests",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"event_type"},
+		),
+		DisabledSubscriptions: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "webhook_subscriptions_disabled_total",
+				Help: "Total number of webhook subscriptions disabled after failing too often",
+			},
+		),
+	}
+
+	return webhookMetricsSingleton
+}
+
+// StreamMetrics สำหรับเก็บ metrics ของ Server-Sent Events stream
+type StreamMetrics struct {
+	Connections   prometheus.Gauge
+	EventsSent    *prometheus.CounterVec
+	Disconnected  *prometheus.CounterVec
+	ReplayedTotal prometheus.Counter
+}
+
+var (
+	streamMetricsSingleton    *StreamMetrics
+	streamMetricsSingletonMux sync.Mutex
+)
+
+// NewStreamMetrics creates the event stream metrics once and returns the
+// same instance afterwards
+func NewStreamMetrics() *StreamMetrics {
+	streamMetricsSingletonMux.Lock()
+	defer streamMetricsSingletonMux.Unlock()
+
+	if streamMetricsSingleton != nil {
+		return streamMetricsSingleton
+	}
+
+	streamMetricsSingleton = &StreamMetrics{
+		Connections: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "event_stream_connections",
+				Help: "Number of open event stream connections",
+			},
+		),
+		EventsSent: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "event_stream_events_sent_total",
+				Help: "Total number of events written to event stream connections",
+			},
+			[]string{"event_type"},
+		),
+		Disconnected: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "event_stream_disconnects_total",
+				Help: "Total number of event stream connections closed by reason: client, slow or shutdown",
+			},
+			[]string{"reason"},
+		),
+		ReplayedTotal: promauto.NewCounter(
+			prometheus.CounterOpts{
+				Name: "event_stream_replayed_total",
+				Help: "Total number of events replayed to resuming event stream connections",
+			},
+		),
+	}
+
+	return streamMetricsSingleton
+}
+
+// ConsumerMetrics สำหรับเก็บ metrics ของ Kafka consumer
+type ConsumerMetrics struct {
+	MessagesTotal      *prometheus.CounterVec
+	ProcessingDuration *prometheus.HistogramVec
+	Lag                *prometheus.GaugeVec
+	DeadLettersTotal   *prometheus.CounterVec
+}
+
+var (
+	consumerMetricsSingleton    *ConsumerMetrics
+	consumerMetricsSingletonMux sync.Mutex
+)
+
+// NewConsumerMetrics creates the consumer metrics once and returns the same
+// instance afterwards
+func NewConsumerMetrics() *ConsumerMetrics {
+	consumerMetricsSingletonMux.Lock()
+	defer consumerMetricsSingletonMux.Unlock()
+
+	if consumerMetricsSingleton != nil {
+		return consumerMetricsSingleton
+	}
+
+	consumerMetricsSingleton = &ConsumerMetrics{
+		MessagesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "kafka_consumer_messages_total",
+				Help: "Total number of consumed messages by outcome",
+			},
+			[]string{"group", "topic", "event_type", "status"},
+		),
+		ProcessingDuration: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "kafka_consumer_processing_duration_seconds",
+				Help:    "Duration of message processing, retries included",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"group", "topic", "event_type"},
+		),
+		Lag: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "kafka_consumer_lag",
+				Help: "Messages in the partition after the last processed one",
+			},
+			[]string{"group", "topic", "partition"},
+		),
+		DeadLettersTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "event_bus_dead_letters_total",
+				Help: "Total number of events the memory or Redis bus gave up on, which have no dead-letter topic to go to",
+			},
+			[]string{"group", "source", "reason"},
+		),
+	}
+
+	return consumerMetricsSingleton
+}
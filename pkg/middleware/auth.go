@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"strings"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/auth"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+)
+
+// TokenVerifier verifies a bearer token and returns the identity it carries
+type TokenVerifier interface {
+	Authenticate(ctx context.Context, token string) (*auth.Identity, error)
+}
+
+// Authenticate returns a middleware that verifies the bearer token, when one
+// is present, and stores the caller's identity in the request context.
+// Requests carrying an invalid or revoked token are rejected; requests
+// without a token pass through so public routes keep working.
+func Authenticate(verifier TokenVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scheme, token, ok := strings.Cut(header, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+				writeUnauthorized(w, r, "authorization header must use the Bearer scheme")
+				return
+			}
+
+			identity, err := verifier.Authenticate(r.Context(), token)
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), identity)))
+			case stderrors.Is(err, auth.ErrInvalidToken), stderrors.Is(err, auth.ErrTokenRevoked):
+				writeUnauthorized(w, r, err.Error())
+			default:
+				WriteError(w, r, errors.NewUnavailable("authenticate", err))
+			}
+		})
+	}
+}
+
+// RequireAuth rejects requests that were not authenticated by Authenticate
+func RequireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := auth.IdentityFromContext(r.Context()); !ok {
+			writeUnauthorized(w, r, "authentication required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeUnauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+	WriteError(w, r, errors.NewUnauthorized("authenticate", message))
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/auth"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// Authorizer decides whether a user holds the required permissions
+type Authorizer interface {
+	Authorize(ctx context.Context, userID uuid.UUID, required ...string) error
+}
+
+// RequirePermissions returns a middleware that rejects authenticated callers
+// lacking any of the given permissions with 403 Forbidden. It must run after
+// Authenticate.
+func RequirePermissions(authorizer Authorizer, permissions ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, ok := auth.IdentityFromContext(r.Context())
+			if !ok {
+				writeUnauthorized(w, r, "authentication required")
+				return
+			}
+
+			err := authorizer.Authorize(r.Context(), identity.UserID, permissions...)
+			switch {
+			case err == nil:
+				next.ServeHTTP(w, r)
+			case stderrors.Is(err, authz.ErrForbidden):
+				WriteError(w, r, errors.NewForbidden("authorize", err.Error()))
+			default:
+				WriteError(w, r, errors.NewUnavailable("authorize", err))
+			}
+		})
+	}
+}
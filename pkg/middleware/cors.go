@@ -11,6 +11,7 @@ type CORSConfig struct {
 	AllowedOrigins []string
 	AllowedMethods []string
 	AllowedHeaders []string
+	ExposedHeaders []string
 	MaxAge         int
 }
 
@@ -19,7 +20,8 @@ func DefaultCORSConfig() *CORSConfig {
 	return &CORSConfig{
 		AllowedOrigins: []string{"*"},
 		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		AllowedHeaders: []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "X-Request-ID"},
+		ExposedHeaders: []string{"ETag", "X-Request-ID"},
 		MaxAge:         86400, // 24 hours
 	}
 }
@@ -38,6 +40,9 @@ func CORS(config *CORSConfig) func(http.Handler) http.Handler {
 				for _, allowedOrigin := range config.AllowedOrigins {
 					if allowedOrigin == "*" || allowedOrigin == origin {
 						w.Header().Set("Access-Control-Allow-Origin", origin)
+						if len(config.ExposedHeaders) > 0 {
+							w.Header().Set("Access-Control-Expose-Headers", strings.Join(config.ExposedHeaders, ", "))
+						}
 						break
 					}
 				}
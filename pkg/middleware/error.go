@@ -1,30 +1,21 @@
 package middleware
 
 import (
-	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
+	"runtime/debug"
 
 	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
 )
 
-type ErrorResponse struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
-
 func ErrorHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("panic: %v", err)
-				response := ErrorResponse{
-					Code:    http.StatusInternalServerError,
-					Message: "internal server error",
-				}
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(response)
+				logging.FromContext(r.Context()).Error("panic recovered", "panic", fmt.Sprint(err), "stack", string(debug.Stack()))
+				WriteError(w, r, errors.NewInternalError("recover", fmt.Errorf("panic: %v", err)))
 			}
 		}()
 
@@ -32,24 +23,7 @@ func ErrorHandler(next http.Handler) http.Handler {
 	})
 }
 
-func WriteError(w http.ResponseWriter, err error) {
-	var response ErrorResponse
-
-	switch e := err.(type) {
-	case *errors.Error:
-		response = ErrorResponse{
-			Code:    e.Code,
-			Message: e.Message,
-		}
-	default:
-		response = ErrorResponse{
-			Code:    http.StatusInternalServerError,
-			Message: "internal server error",
-		}
-		log.Printf("unexpected error: %v", err)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(response.Code)
-	json.NewEncoder(w).Encode(response)
+// WriteError sends err as an RFC 7807 problem through the shared HTTP error mapper
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	response.WriteError(w, r, err)
 }
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type routeKey struct{}
+
+// matchedRoute is filled in by Route once the mux has matched the request,
+// so the access log written further out can name it
+type matchedRoute struct {
+	name string
+}
+
+// Logging gives every request a logger carrying its request id and trace id
+// and stores it in the context for logging.FromContext. It must run after
+// RequestID and Tracing. With accessLog set, one record is written per
+// request after the response is sent.
+func Logging(base *slog.Logger, accessLog bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			logger := base
+			if id := requestid.FromContext(ctx); id != "" {
+				logger = logger.With("request_id", id)
+			}
+			if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+				logger = logger.With("trace_id", sc.TraceID().String())
+			}
+
+			route := &matchedRoute{}
+			ctx = context.WithValue(logging.NewContext(ctx, logger), routeKey{}, route)
+			if !accessLog {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			start := time.Now()
+			aw := &accessWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(aw, r.WithContext(ctx))
+
+			attrs := []any{
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", aw.status,
+				"bytes", aw.bytes,
+				"duration_ms", time.Since(start).Milliseconds(),
+			}
+			if route.name != "" {
+				attrs = append(attrs, "route", route.name)
+			}
+			logger.Info("http request", attrs...)
+		})
+	}
+}
+
+// Route adds the name of the matched route to the request logger and the
+// access log
+func Route(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if route, ok := ctx.Value(routeKey{}).(*matchedRoute); ok {
+				route.name = name
+			}
+			ctx = logging.NewContext(ctx, logging.FromContext(ctx).With("route", name))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+type accessWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (w *accessWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *accessWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
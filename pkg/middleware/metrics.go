@@ -52,3 +52,8 @@ func (rw *ResponseWriter) WriteHeader(code int) {
 func (rw *ResponseWriter) Status() string {
 	return http.StatusText(rw.statusCode)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *ResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+)
+
+// RequestID accepts the client's X-Request-ID when it is valid, otherwise
+// assigns a new one. The id is stored in the request context and echoed on
+// the response, so it must run before any middleware that logs or traces.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
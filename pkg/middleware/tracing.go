@@ -3,6 +3,8 @@ package middleware
 import (
 	"net/http"
 
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/propagation"
@@ -25,6 +27,9 @@ func Tracing(serviceName string) func(http.Handler) http.Handler {
 				),
 			)
 			defer span.End()
+			if id := requestid.FromContext(ctx); id != "" {
+				span.SetAttributes(attribute.String("http.request_id", id))
+			}
 
 			// Wrap response writer to capture status code
 			rw := &responseWriter{ResponseWriter: w}
@@ -47,3 +52,8 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
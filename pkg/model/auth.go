@@ -0,0 +1,14 @@
+package model
+
+// LoginRequest represents a login request
+// @Description Login request body
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// TokenRequest carries a token to refresh or revoke
+// @Description Refresh or revoke request body
+type TokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
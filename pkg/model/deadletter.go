@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// DeadLetter is a message that failed processing on every retry tier and was
+// moved to the dead-letter topic
+// @Description Dead-lettered Kafka message
+type DeadLetter struct {
+	ID                string            `json:"id" example:"0-42"` // <partition>-<offset> in the dead-letter topic
+	Key               string            `json:"key,omitempty" example:"user:0192d5e4-7a3b-7c8d-9e0f-1a2b3c4d5e6f"`
+	EventType         string            `json:"event_type,omitempty" example:"user.updated"`
+	OriginalTopic     string            `json:"original_topic" example:"events"`
+	OriginalPartition int32             `json:"original_partition"`
+	OriginalOffset    int64             `json:"original_offset"`
+	Attempts          int               `json:"attempts" example:"7"`
+	Reason            string            `json:"reason" example:"redis: connection refused"`
+	FailedAt          time.Time         `json:"failed_at"`
+	Headers           map[string]string `json:"headers,omitempty"`
+	Value             string            `json:"value,omitempty"`
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"slices"
+	"strings"
+)
+
+// ChangeEvents lists the user, product and order change events published to
+// consumers outside the service, through webhooks and the event stream
+var ChangeEvents = []string{
+	UserEventCreated,
+	UserEventUpdated,
+	UserEventStatusChanged,
+	UserEventDeleted,
+	UserEventRestored,
+	ProductEventCreated,
+	ProductEventUpdated,
+	ProductEventDeleted,
+	ProductEventRestored,
+	OrderEventCreated,
+}
+
+// MatchEventFilter reports whether filter, an event type, "<prefix>.*" or
+// "*", covers eventType
+func MatchEventFilter(filter, eventType string) bool {
+	if filter == "*" || filter == eventType {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(filter, "*")
+	return ok && strings.HasSuffix(prefix, ".") && strings.HasPrefix(eventType, prefix)
+}
+
+// ValidEventFilter reports whether filter covers at least one of the
+// ChangeEvents
+func ValidEventFilter(filter string) bool {
+	return slices.ContainsFunc(ChangeEvents, func(eventType string) bool {
+		return MatchEventFilter(filter, eventType)
+	})
+}
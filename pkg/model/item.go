@@ -1,6 +1,10 @@
 package model
 
-import "fmt"
+import (
+    "fmt"
+
+    "github.com/Napat/golang-testcontainers-demo/pkg/validate"
+)
 
 type Item struct {
     ProductID   string  `json:"product_id"`
@@ -10,23 +14,25 @@ type Item struct {
     Subtotal    float64 `json:"subtotal"`
 }
 
+// Validate checks the item and reports every invalid field
 func (i *Item) Validate() error {
+    var errs validate.Errors
     if i.ProductID == "" {
-        return fmt.Errorf("product ID is required")
+        errs.Add("product_id", "product ID is required")
     }
     if i.ProductName == "" {
-        return fmt.Errorf("product name is required")
+        errs.Add("product_name", "product name is required")
     }
     if i.Quantity <= 0 {
-        return fmt.Errorf("quantity must be positive")
+        errs.Add("quantity", "quantity must be positive")
     }
     if i.UnitPrice < 0 {
-        return fmt.Errorf("unit price must be non-negative")
+        errs.Add("unit_price", "unit price must be non-negative")
     }
     // Verify subtotal calculation
     expectedSubtotal := float64(i.Quantity) * i.UnitPrice
     if i.Subtotal != expectedSubtotal {
-        return fmt.Errorf("invalid subtotal: expected %.2f, got %.2f", expectedSubtotal, i.Subtotal)
+        errs.Add("subtotal", fmt.Sprintf("invalid subtotal: expected %.2f, got %.2f", expectedSubtotal, i.Subtotal))
     }
-    return nil
+    return errs.Err()
 }
@@ -1,18 +1,151 @@
 package model
 
-import "time"
+import (
+	"fmt"
+	"slices"
+	"time"
 
-// MessageRequest represents a message sending request
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
+	"github.com/google/uuid"
+)
+
+// MessageSent is the event type of a message sent through the message API,
+// its payload is the MessageSentEvent
+const MessageSent = "message.sent"
+
+// Message states. A message is queued once stored, then published when the
+// broker acknowledged it or failed when the broker refused it. A message
+// sent for later is scheduled until it is due, when it is queued, unless it
+// was cancelled before.
+const (
+	MessageScheduled = "scheduled"
+	MessageQueued    = "queued"
+	MessagePublished = "published"
+	MessageFailed    = "failed"
+	MessageCancelled = "cancelled"
+)
+
+// MessageStatuses lists every message state
+var MessageStatuses = []string{MessageScheduled, MessageQueued, MessagePublished, MessageFailed, MessageCancelled}
+
+// MaxMessageDelay is how far ahead a message can be scheduled
+const MaxMessageDelay = 30 * 24 * time.Hour
+
+// ValidMessageStatus reports whether status is a known message state
+func ValidMessageStatus(status string) bool {
+	return slices.Contains(MessageStatuses, status)
+}
+
+// MessageSortFields lists the fields messages can be sorted by. Ids are
+// UUIDv7, so they sort in creation order.
+var MessageSortFields = []string{"id"}
+
+// MessageFilter narrows a message listing, zero values are ignored
+type MessageFilter struct {
+	Status   string
+	SenderID string
+}
+
+// MessageRequest represents a message sending request. DeliverAt or Delay,
+// not both, schedule the message for later.
 // @Description Message sending request body
 type MessageRequest struct {
-	Content string `json:"content" binding:"required"`
+	Content   string     `json:"content" binding:"required"`
+	DeliverAt *time.Time `json:"deliver_at,omitempty" example:"2026-01-02T09:00:00Z"`
+	Delay     string     `json:"delay,omitempty" example:"15m"`
+}
+
+// Validate checks the schedule of the request
+func (r *MessageRequest) Validate() error {
+	var errs validate.Errors
+	if r.DeliverAt != nil && r.Delay != "" {
+		errs.Add("delay", "cannot be combined with deliver_at")
+		return errs.Err()
+	}
+	if r.Delay != "" {
+		delay, err := time.ParseDuration(r.Delay)
+		switch {
+		case err != nil || delay < 0:
+			errs.Add("delay", "must be a duration such as 90s or 2h")
+		case delay > MaxMessageDelay:
+			errs.Add("delay", fmt.Sprintf("must be at most %s", MaxMessageDelay))
+		}
+	}
+	if r.DeliverAt != nil && time.Until(*r.DeliverAt) > MaxMessageDelay {
+		errs.Add("deliver_at", fmt.Sprintf("must be within %s", MaxMessageDelay))
+	}
+	return errs.Err()
+}
+
+// Message returns a new message with a generated id. It is scheduled when
+// the request asks for a time still ahead, otherwise queued.
+func (r MessageRequest) Message() *Message {
+	m := &Message{
+		ID:      uuid.Must(uuid.NewV7()).String(),
+		Content: r.Content,
+		Status:  MessageQueued,
+	}
+
+	now := time.Now().UTC()
+	var at time.Time
+	switch {
+	case r.DeliverAt != nil:
+		at = r.DeliverAt.UTC()
+	case r.Delay != "":
+		delay, _ := time.ParseDuration(r.Delay)
+		at = now.Add(delay)
+	}
+	if at.After(now) {
+		// Stored with microseconds
+		at = at.Truncate(time.Microsecond)
+		m.Status = MessageScheduled
+		m.DeliverAt = &at
+	}
+	return m
 }
 
 // Message represents a message in the system
-// @Description Message information
+// @Description Message information and delivery status
 type Message struct {
+	ID          string     `json:"id"`
+	SenderID    string     `json:"sender_id,omitempty"`
+	Content     string     `json:"content"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	DeliverAt   *time.Time `json:"deliver_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// SentEvent returns the payload published for m
+func (m *Message) SentEvent() MessageSentEvent {
+	return MessageSentEvent{
+		ID:        m.ID,
+		Content:   m.Content,
+		CreatedAt: m.CreatedAt,
+	}
+}
+
+// MessageSentEvent is published for every message sent through the message
+// API
+type MessageSentEvent struct {
 	ID        string    `json:"id"`
 	Content   string    `json:"content"`
 	CreatedAt time.Time `json:"created_at"`
-	Status    string    `json:"status"`
+}
+
+// MessageClaim is a due scheduled message held by one dispatcher, Attempt
+// counts the times it was claimed
+type MessageClaim struct {
+	ID      string
+	Attempt int
+}
+
+// MessageBacklog describes the scheduled messages waiting to be published
+type MessageBacklog struct {
+	Scheduled int64         // messages waiting, due or not
+	Due       int64         // waiting messages that are due
+	InFlight  int64         // messages claimed by a dispatcher
+	Lag       time.Duration // how long the oldest due message has been waiting
 }
@@ -3,6 +3,8 @@ package model
 import (
 	"fmt"
 	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
 )
 
 type Order struct {
@@ -16,23 +18,63 @@ type Order struct {
     UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// OrderResponse is the public representation of an order
+// @Description Order information
+type OrderResponse struct {
+	ID            string    `json:"id"`
+	CustomerID    string    `json:"customer_id"`
+	Status        string    `json:"status"`
+	Total         float64   `json:"total"`
+	PaymentMethod string    `json:"payment_method"`
+	Items         []Item    `json:"items"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ToResponse returns the public representation of the order
+func (o *Order) ToResponse() OrderResponse {
+	return OrderResponse{
+		ID:            o.ID,
+		CustomerID:    o.CustomerID,
+		Status:        o.Status,
+		Total:         o.Total,
+		PaymentMethod: o.PaymentMethod,
+		Items:         o.Items,
+		CreatedAt:     o.CreatedAt,
+		UpdatedAt:     o.UpdatedAt,
+	}
+}
+
+// OrderEventCreated is the event type of an order placed through the API,
+// its payload is the OrderResponse
+const OrderEventCreated = "order.created"
+
+// OrderSortFields lists the fields orders can be sorted by, the first one is the default
+var OrderSortFields = []string{"created_at", "total", "id"}
+
+// OrderFilter narrows an order listing, empty fields are ignored
+type OrderFilter struct {
+	Status     string
+	CustomerID string
+}
+
+// Validate checks the order and each of its items and reports every invalid field
 func (o *Order) Validate() error {
+	var errs validate.Errors
 	if o.ID == "" {
-		return fmt.Errorf("order ID is required")
+		errs.Add("id", "order ID is required")
 	}
 	if o.CustomerID == "" {
-		return fmt.Errorf("customer ID is required")
+		errs.Add("customer_id", "customer ID is required")
 	}
 	if o.Total < 0 {
-		return fmt.Errorf("total must be non-negative")
+		errs.Add("total", "total must be non-negative")
 	}
 	if len(o.Items) == 0 {
-		return fmt.Errorf("order must contain at least one item")
+		errs.Add("items", "order must contain at least one item")
 	}
 	for i, item := range o.Items {
-		if err := item.Validate(); err != nil {
-			return fmt.Errorf("invalid item at index %d: %w", i, err)
-		}
+		errs.Merge(fmt.Sprintf("items[%d]", i), item.Validate())
 	}
-	return nil
+	return errs.Err()
 }
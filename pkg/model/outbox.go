@@ -0,0 +1,20 @@
+package model
+
+import "time"
+
+// OutboxMessage is an event stored in the same transaction as the change that
+// caused it. The outbox relay publishes it to Kafka afterwards, so the event
+// is sent if and only if the change was committed.
+type OutboxMessage struct {
+	ID            int64
+	AggregateType string
+	AggregateID   string
+	EventType     string
+	Key           string
+	Payload       []byte // JSON encoded message value
+	RequestID     string
+	TraceContext  map[string]string // W3C trace context of the request that wrote it
+	Attempts      int
+	CreatedAt     time.Time
+	NextAttemptAt time.Time
+}
@@ -2,38 +2,160 @@
 package model
 
 import (
-	"errors"
 	"fmt"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
 )
 
 type Product struct {
 	BaseModel
-	Name        string  `json:"name" db:"name"`
-	Description string  `json:"description" db:"description"`
-	Price       float64 `json:"price" db:"price"`
-	SKU         string  `json:"sku" db:"sku"`
-	Stock       int     `json:"stock" db:"stock"`
+	Name        string     `json:"name" db:"name"`
+	Description string     `json:"description" db:"description"`
+	Price       float64    `json:"price" db:"price"`
+	SKU         string     `json:"sku" db:"sku"`
+	Stock       int        `json:"stock" db:"stock"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
+}
+
+// ProductResponse is the public representation of a product
+// @Description Product information
+type ProductResponse struct {
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Price       float64    `json:"price"`
+	SKU         string     `json:"sku"`
+	Stock       int        `json:"stock"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Version     int        `json:"version"`
+}
+
+// ToResponse returns the public representation of the product
+func (p *Product) ToResponse() ProductResponse {
+	return ProductResponse{
+		ID:          p.ID,
+		Name:        p.Name,
+		Description: p.Description,
+		Price:       p.Price,
+		SKU:         p.SKU,
+		Stock:       p.Stock,
+		CreatedAt:   p.CreatedAt,
+		UpdatedAt:   p.UpdatedAt,
+		DeletedAt:   p.DeletedAt,
+		Version:     p.Version,
+	}
+}
+
+// ProductCreate represents a product creation request
+// @Description Product creation request body
+type ProductCreate struct {
+	Name        string  `json:"name" binding:"required"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price" binding:"min=0"`
+	SKU         string  `json:"sku" binding:"required"`
+	Stock       int     `json:"stock" binding:"min=0"`
+}
+
+// Product returns a new product built from the request
+func (c *ProductCreate) Product() *Product {
+	return &Product{
+		Name:        c.Name,
+		Description: c.Description,
+		Price:       c.Price,
+		SKU:         c.SKU,
+		Stock:       c.Stock,
+	}
+}
+
+// Validate applies the product rules to the request
+func (c *ProductCreate) Validate() error {
+	return c.Product().Validate()
+}
+
+// ProductUpdate represents a product update request
+// @Description Product update request body
+type ProductUpdate struct {
+	Name        string  `json:"name" binding:"required"`
+	Description string  `json:"description"`
+	Price       float64 `json:"price" binding:"min=0"`
+	SKU         string  `json:"sku" binding:"required"`
+	Stock       int     `json:"stock" binding:"min=0"`
 }
 
-// Validate performs basic validation on the product
+// Apply copies the updatable fields onto p
+func (u ProductUpdate) Apply(p *Product) {
+	p.Name = u.Name
+	p.Description = u.Description
+	p.Price = u.Price
+	p.SKU = u.SKU
+	p.Stock = u.Stock
+}
+
+// Validate applies the product rules to the request
+func (u *ProductUpdate) Validate() error {
+	var p Product
+	u.Apply(&p)
+	return p.Validate()
+}
+
+// ProductChangedEvent is published whenever a product is created, updated,
+// deleted or restored
+type ProductChangedEvent struct {
+	Type       string           `json:"type"`
+	ProductID  int64            `json:"product_id"`
+	Product    *ProductResponse `json:"product,omitempty"`
+	OccurredAt time.Time        `json:"occurred_at"`
+}
+
+// EventType returns the event type, one of the ProductEvent constants
+func (e ProductChangedEvent) EventType() string {
+	return e.Type
+}
+
+// Product change event types
+const (
+	ProductEventCreated  = "product.created"
+	ProductEventUpdated  = "product.updated"
+	ProductEventDeleted  = "product.deleted"
+	ProductEventRestored = "product.restored"
+)
+
+// ProductSortFields lists the fields products can be sorted by, the first one is the default
+var ProductSortFields = []string{"id", "name", "price", "stock", "created_at"}
+
+// ProductFilter narrows a product listing, nil bounds are ignored. Deleted
+// lists soft-deleted products instead of live ones.
+type ProductFilter struct {
+	MinPrice *float64
+	MaxPrice *float64
+	MinStock *int
+	Deleted  bool
+}
+
+// Validate performs basic validation on the product and reports every invalid field
 func (p *Product) Validate() error {
+	var errs validate.Errors
+
 	if p.Name == "" {
-		return errors.New("product name is required")
+		errs.Add("name", "product name is required")
 	}
 
 	if p.Price < 0 {
-		return fmt.Errorf("invalid price: %.2f", p.Price)
+		errs.Add("price", fmt.Sprintf("invalid price: %.2f", p.Price))
 	}
 
 	if p.Stock < 0 {
-		return fmt.Errorf("invalid stock quantity: %d", p.Stock)
+		errs.Add("stock", fmt.Sprintf("invalid stock quantity: %d", p.Stock))
 	}
 
 	if p.SKU == "" {
-		return errors.New("SKU is required")
+		errs.Add("sku", "SKU is required")
 	}
 
-	return nil
+	return errs.Err()
 }
 
 // CalculateTotal returns the total price for a given quantity
@@ -2,11 +2,14 @@ package model
 
 import (
 	"errors"
+	"fmt"
 	"net/mail"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/pkg/password"
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
 	"github.com/google/uuid"
 )
 
@@ -16,8 +19,28 @@ const (
 	StatusActive    UserStatus = "active"
 	StatusInactive  UserStatus = "inactive"
 	StatusSuspended UserStatus = "suspended"
+	StatusDeleted   UserStatus = "deleted"
 )
 
+// ErrInvalidStatusTransition is returned when a status change is not allowed
+var ErrInvalidStatusTransition = errors.New("invalid user status transition")
+
+// userStatusTransitions lists the statuses each status may move to.
+// Deleted is terminal for status changes; only an administrator restore
+// brings a deleted user back, as inactive.
+var userStatusTransitions = map[UserStatus][]UserStatus{
+	StatusActive:    {StatusInactive, StatusSuspended, StatusDeleted},
+	StatusInactive:  {StatusActive, StatusSuspended, StatusDeleted},
+	StatusSuspended: {StatusActive, StatusInactive, StatusDeleted},
+	StatusDeleted:   {},
+}
+
+// IsValid reports whether s is a known user status
+func (s UserStatus) IsValid() bool {
+	_, ok := userStatusTransitions[s]
+	return ok
+}
+
 // User represents a user in the system
 // @Description User account information
 type User struct {
@@ -26,13 +49,29 @@ type User struct {
 	Username  string     `json:"username" db:"username"`
 	Email     string     `json:"email" db:"email"`
 	FullName  string     `json:"full_name" db:"full_name"`
-	Password  string     `json:"password,omitempty" db:"password"`
+	Password  string     `json:"password,omitempty" db:"password" sensitive:"true"`
 	Status    UserStatus `json:"status" db:"status"`
 	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 	Version   int        `json:"version" db:"version"`
 }
 
+// UserResponse is the public representation of a user. Handlers, the cache
+// and change events use it so the password hash never leaves the service.
+// @Description Public user information
+type UserResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Username  string     `json:"username"`
+	Email     string     `json:"email"`
+	FullName  string     `json:"full_name"`
+	Status    UserStatus `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"`
+}
+
 // UserCreate represents user creation request
 // @Description User creation request body
 type UserCreate struct {
@@ -42,14 +81,82 @@ type UserCreate struct {
 	Password string `json:"password" binding:"required,min=8"`
 }
 
+// Validate checks the username and email rules the binding tags cannot express
+func (c *UserCreate) Validate() error {
+	u := User{Username: c.Username, Email: c.Email, Status: StatusActive}
+	return u.Validate()
+}
+
+// User returns a new active user built from the request with its password
+// hashed. The request password is always hashed, even when it looks like a
+// hash, so a caller cannot choose the parameters its login is verified with.
+func (c *UserCreate) User() (*User, error) {
+	u := NewUser(c.Username, c.Email, "")
+	u.FullName = c.FullName
+	if err := u.SetPassword(c.Password); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
 // UserUpdate represents user update request
 // @Description User update request body
 type UserUpdate struct {
-	Email    string `json:"email,omitempty" binding:"omitempty,email"`
-	FullName string `json:"full_name,omitempty"`
-	Status   string `json:"status,omitempty" binding:"omitempty,oneof=active inactive suspended"`
+	Email    string `json:"email,omitempty" binding:"required,email"`
+	FullName string `json:"full_name,omitempty" binding:"required"`
+	Status   string `json:"status,omitempty" binding:"omitempty,oneof=active inactive suspended deleted"`
 }
 
+// UserPatch represents a partial user update, only fields that are present are changed
+// @Description Partial user update request body
+type UserPatch struct {
+	Email    *string `json:"email,omitempty" binding:"omitempty,email"`
+	FullName *string `json:"full_name,omitempty" binding:"omitempty,min=1"`
+}
+
+// UserStatusChange represents a status transition request
+// @Description User status change request body
+type UserStatusChange struct {
+	Status UserStatus `json:"status" binding:"required,oneof=active inactive suspended deleted"`
+	Reason string     `json:"reason,omitempty"`
+}
+
+// UserChangedEvent is published whenever a user is updated, changes status or is deleted
+type UserChangedEvent struct {
+	Type       string        `json:"type"`
+	UserID     uuid.UUID     `json:"user_id"`
+	Status     UserStatus    `json:"status"`
+	Previous   UserStatus    `json:"previous_status,omitempty"`
+	Reason     string        `json:"reason,omitempty"`
+	User       *UserResponse `json:"user,omitempty"`
+	OccurredAt time.Time     `json:"occurred_at"`
+}
+
+// EventType returns the event type, one of the UserEvent constants
+func (e UserChangedEvent) EventType() string {
+	return e.Type
+}
+
+// UserSortFields lists the fields users can be sorted by, the first one is the default
+var UserSortFields = []string{"id", "username", "email", "created_at"}
+
+// UserFilter narrows a user listing, zero values are ignored. Deleted lists
+// soft-deleted users instead of live ones.
+type UserFilter struct {
+	Status      UserStatus
+	EmailDomain string
+	Deleted     bool
+}
+
+// User change event types
+const (
+	UserEventCreated       = "user.created"
+	UserEventUpdated       = "user.updated"
+	UserEventStatusChanged = "user.status_changed"
+	UserEventDeleted       = "user.deleted"
+	UserEventRestored      = "user.restored"
+)
+
 // NewUser creates a new user with default values
 func NewUser(username, email, password string) *User {
 	now := time.Now()
@@ -65,35 +172,53 @@ func NewUser(username, email, password string) *User {
 	}
 }
 
-// SetPassword sets the user's password directly
-func (u *User) SetPassword(password string) error {
-	if len(password) < 8 {
+// SetPassword hashes the password with the default password manager
+func (u *User) SetPassword(plain string) error {
+	if len(plain) < 8 {
 		return errors.New("password must be at least 8 characters long")
 	}
-	u.Password = password
+
+	hash, err := password.Default().Hash(plain)
+	if err != nil {
+		return err
+	}
+	u.Password = hash
 	return nil
 }
 
-// CheckPassword verifies if the provided password matches the user's password
-func (u *User) CheckPassword(password string) bool {
-	return u.Password == password
+// CheckPassword verifies if the provided password matches the user's password hash
+func (u *User) CheckPassword(plain string) bool {
+	ok, _ := u.VerifyPassword(plain)
+	return ok
 }
 
-// Validate performs validation on user data
+// VerifyPassword verifies the provided password and reports whether the stored
+// hash should be replaced because the hashing parameters have changed
+func (u *User) VerifyPassword(plain string) (ok bool, needsRehash bool) {
+	ok, needsRehash, err := password.Default().Verify(plain, u.Password)
+	if err != nil {
+		return false, false
+	}
+	return ok, needsRehash
+}
+
+// Validate performs validation on user data and reports every invalid field
 func (u *User) Validate() error {
+	var errs validate.Errors
+
 	if err := u.validateUsername(); err != nil {
-		return err
+		errs.Add("username", err.Error())
 	}
 
 	if err := u.validateEmail(); err != nil {
-		return err
+		errs.Add("email", err.Error())
 	}
 
-	if u.Status != StatusActive && u.Status != StatusInactive && u.Status != StatusSuspended {
-		return errors.New("invalid user status")
+	if !u.Status.IsValid() {
+		errs.Add("status", "invalid user status")
 	}
 
-	return nil
+	return errs.Err()
 }
 
 // validateUsername checks if the username is valid
@@ -146,6 +271,39 @@ func (u *User) IsActive() bool {
 	return u.Status == StatusActive
 }
 
+// IsDeleted checks if the user account has been deleted
+func (u *User) IsDeleted() bool {
+	return u.Status == StatusDeleted
+}
+
+// CanTransitionTo reports whether the user may move from its current status to status
+func (u *User) CanTransitionTo(status UserStatus) bool {
+	for _, allowed := range userStatusTransitions[u.Status] {
+		if allowed == status {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionTo changes the user's status if the transition is allowed.
+// Moving to the current status is a no-op.
+func (u *User) TransitionTo(status UserStatus) error {
+	if u.Status == status {
+		return nil
+	}
+	if !u.CanTransitionTo(status) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidStatusTransition, u.Status, status)
+	}
+	u.Status = status
+	u.UpdatedAt = time.Now()
+	if status == StatusDeleted {
+		deletedAt := u.UpdatedAt
+		u.DeletedAt = &deletedAt
+	}
+	return nil
+}
+
 // Deactivate sets the user's status to inactive
 func (u *User) Deactivate() {
 	u.Status = StatusInactive
@@ -164,14 +322,17 @@ func (u *User) Ban() {
 	u.UpdatedAt = time.Now()
 }
 
-// ToPublicUser returns a copy of the user with sensitive information removed
-func (u *User) ToPublicUser() map[string]interface{} {
-	return map[string]interface{}{
-		"id":         u.ID,
-		"username":   u.Username,
-		"email":      u.Email,
-		"full_name":  u.FullName,
-		"status":     u.Status,
-		"created_at": u.CreatedAt,
+// ToResponse returns the public representation of the user, without sensitive information
+func (u *User) ToResponse() UserResponse {
+	return UserResponse{
+		ID:        u.ID,
+		Username:  u.Username,
+		Email:     u.Email,
+		FullName:  u.FullName,
+		Status:    u.Status,
+		CreatedAt: u.CreatedAt,
+		UpdatedAt: u.UpdatedAt,
+		DeletedAt: u.DeletedAt,
+		Version:   u.Version,
 	}
 }
@@ -0,0 +1,205 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/netguard"
+	"github.com/Napat/golang-testcontainers-demo/pkg/validate"
+)
+
+// WebhookEvents lists the event types webhook subscriptions can receive
+var WebhookEvents = ChangeEvents
+
+// WebhookSortFields lists the fields webhook subscriptions and their attempts
+// can be sorted by
+var WebhookSortFields = []string{"id"}
+
+// WebhookSecretMinLength is the shortest secret a subscription accepts
+const WebhookSecretMinLength = 16
+
+// WebhookSubscription is an HTTP endpoint that receives the events matching
+// its filters, signed with its secret. It is disabled after too many failed
+// attempts in a row.
+type WebhookSubscription struct {
+	BaseModel
+	URL                 string     `db:"url"`
+	Secret              string     `json:"secret" db:"secret" sensitive:"true"`
+	Events              []string   `db:"events"` // event types, "user.*" or "*"
+	Description         string     `db:"description"`
+	Active              bool       `db:"active"`
+	ConsecutiveFailures int        `db:"consecutive_failures"`
+	DisabledAt          *time.Time `db:"disabled_at"`
+}
+
+// Matches reports whether eventType passes one of the event filters
+func (s *WebhookSubscription) Matches(eventType string) bool {
+	for _, filter := range s.Events {
+		if MatchEventFilter(filter, eventType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the endpoint and the event filters and reports every
+// invalid field
+func (s *WebhookSubscription) Validate() error {
+	var errs validate.Errors
+
+	if u, err := url.Parse(s.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs.Add("url", "url must be an absolute http or https URL")
+	} else if u.User != nil {
+		errs.Add("url", "url must not carry credentials")
+	} else if netguard.BlockedHost(u.Hostname()) {
+		errs.Add("url", "url must not point to a loopback, private or link-local address")
+	}
+	if len(s.Events) == 0 {
+		errs.Add("events", "at least one event filter is required")
+	}
+	for _, filter := range s.Events {
+		if !ValidEventFilter(filter) {
+			errs.Add("events", "unknown event filter: "+filter)
+		}
+	}
+
+	return errs.Err()
+}
+
+// ToResponse returns the public representation of the subscription, without
+// its secret
+func (s *WebhookSubscription) ToResponse() WebhookResponse {
+	return WebhookResponse{
+		ID:                  s.ID,
+		URL:                 s.URL,
+		Events:              s.Events,
+		Description:         s.Description,
+		Active:              s.Active,
+		ConsecutiveFailures: s.ConsecutiveFailures,
+		DisabledAt:          s.DisabledAt,
+		CreatedAt:           s.CreatedAt,
+		UpdatedAt:           s.UpdatedAt,
+		Version:             s.Version,
+	}
+}
+
+// WebhookResponse is the public representation of a webhook subscription
+// @Description Webhook subscription
+type WebhookResponse struct {
+	ID                  int64      `json:"id"`
+	URL                 string     `json:"url" example:"https://partner.example.com/hooks"`
+	Events              []string   `json:"events" example:"user.*,order.created"`
+	Description         string     `json:"description,omitempty"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	Version             int        `json:"version"`
+}
+
+// WebhookCreated is returned once, when a subscription is created. It is the
+// only response that carries the secret.
+// @Description Created webhook subscription with its signing secret
+type WebhookCreated struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+// WebhookCreate represents a webhook subscription request
+// @Description Webhook subscription request body
+type WebhookCreate struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required"`
+	Description string   `json:"description,omitempty"`
+	Secret      string   `json:"secret,omitempty"` // generated when empty
+}
+
+// Subscription returns a new active subscription built from the request
+func (c *WebhookCreate) Subscription() *WebhookSubscription {
+	return &WebhookSubscription{
+		URL:         c.URL,
+		Secret:      c.Secret,
+		Events:      c.Events,
+		Description: c.Description,
+		Active:      true,
+	}
+}
+
+// Validate applies the subscription rules to the request. An empty secret
+// is accepted, one is generated for it.
+func (c *WebhookCreate) Validate() error {
+	var errs validate.Errors
+	errs.Merge("", c.Subscription().Validate())
+	if c.Secret != "" && len(c.Secret) < WebhookSecretMinLength {
+		errs.Add("secret", fmt.Sprintf("secret must be at least %d characters", WebhookSecretMinLength))
+	}
+	return errs.Err()
+}
+
+// WebhookUpdate replaces the endpoint, filters and state of a subscription.
+// Activating a disabled subscription resets its failure count.
+// @Description Webhook subscription update request body
+type WebhookUpdate struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required"`
+	Description string   `json:"description,omitempty"`
+	Active      bool     `json:"active"`
+}
+
+// Apply copies the updatable fields onto s
+func (u WebhookUpdate) Apply(s *WebhookSubscription) {
+	s.URL = u.URL
+	s.Events = u.Events
+	s.Description = u.Description
+	if u.Active && !s.Active {
+		s.ConsecutiveFailures = 0
+		s.DisabledAt = nil
+	}
+	s.Active = u.Active
+}
+
+// Validate applies the subscription rules to the request
+func (u *WebhookUpdate) Validate() error {
+	var s WebhookSubscription
+	u.Apply(&s)
+	return s.Validate()
+}
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event to send to one subscription. It stays pending
+// until an attempt succeeds or the attempts run out.
+type WebhookDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	EventID        string
+	EventType      string
+	Payload        []byte // the event envelope as JSON, the request body
+	Attempts       int
+	URL            string // endpoint of the subscription
+	Secret         string `json:"secret" sensitive:"true"` // signing secret of the subscription
+}
+
+// WebhookAttempt is the outcome of one request made for a delivery
+// @Description Webhook delivery attempt
+type WebhookAttempt struct {
+	ID             int64      `json:"id"`
+	DeliveryID     int64      `json:"delivery_id"`
+	SubscriptionID int64      `json:"subscription_id"`
+	EventID        string     `json:"event_id"`
+	EventType      string     `json:"event_type" example:"user.updated"`
+	Attempt        int        `json:"attempt" example:"1"`
+	Succeeded      bool       `json:"succeeded"`
+	StatusCode     int        `json:"status_code,omitempty" example:"502"` // 0 when no response arrived
+	Error          string     `json:"error,omitempty"`
+	DurationMs     int64      `json:"duration_ms"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"` // when the delivery is retried
+	CreatedAt      time.Time  `json:"created_at"`
+}
@@ -0,0 +1,81 @@
+// Package netguard keeps outbound requests made on behalf of API callers,
+// such as webhooks, away from the internal network: loopback, private,
+// link-local (which includes cloud metadata at 169.254.169.254) and other
+// addresses that are not reachable on the public internet.
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+// ErrBlockedAddress is returned when a connection to an internal address is
+// refused
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// blockedPrefixes are the ranges not covered by the netip predicates
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),   // reserved, and broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64 of any IPv4 address
+}
+
+// Blocked reports whether addr is not a public unicast address
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() ||
+		addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockedHost reports whether host, the host part of a URL, names an
+// internal address without resolving it: localhost or a blocked IP literal.
+// A name that resolves to an internal address is only caught by Control.
+func BlockedHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(host, "[]")); err == nil {
+		return Blocked(addr)
+	}
+	return false
+}
+
+// Control refuses connections to blocked addresses. It is meant for
+// net.Dialer.Control, which runs after name resolution for every address
+// tried, so a name that resolves to an internal address, or is rebound to
+// one after it was checked, is refused too.
+func Control(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+	if Blocked(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// Dialer returns a dialer that refuses blocked addresses
+func Dialer() *net.Dialer {
+	return &net.Dialer{Control: Control}
+}
@@ -0,0 +1,45 @@
+package netguard
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlocked(t *testing.T) {
+	blocked := []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"0.0.0.0", "100.64.0.1", "224.0.0.1", "255.255.255.255",
+		"::1", "::", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "::ffff:10.0.0.1", "64:ff9b::a9fe:a9fe",
+	}
+	for _, s := range blocked {
+		assert.True(t, Blocked(netip.MustParseAddr(s)), s)
+	}
+
+	public := []string{"93.184.216.34", "8.8.8.8", "2606:4700::1111", "::ffff:8.8.8.8"}
+	for _, s := range public {
+		assert.False(t, Blocked(netip.MustParseAddr(s)), s)
+	}
+}
+
+func TestBlockedHost(t *testing.T) {
+	for _, host := range []string{"localhost", "LOCALHOST.", "api.localhost", "127.0.0.1", "[::1]", "169.254.169.254"} {
+		assert.True(t, BlockedHost(host), host)
+	}
+	for _, host := range []string{"partner.example.com", "8.8.8.8", "[2606:4700::1111]"} {
+		assert.False(t, BlockedHost(host), host)
+	}
+}
+
+func TestDialer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	_, err = Dialer().DialContext(context.Background(), "tcp", listener.Addr().String())
+	assert.ErrorIs(t, err, ErrBlockedAddress)
+}
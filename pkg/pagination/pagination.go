@@ -0,0 +1,158 @@
+package pagination
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultLimit is the page size used when the request does not set one
+	DefaultLimit = 20
+	// MaxLimit caps the page size a client may ask for
+	MaxLimit = 100
+)
+
+var (
+	// ErrInvalidLimit is returned when limit is not a number between 1 and MaxLimit
+	ErrInvalidLimit = fmt.Errorf("limit must be between 1 and %d", MaxLimit)
+	// ErrInvalidSort is returned when sort names a field that cannot be sorted on
+	ErrInvalidSort = errors.New("unsupported sort field")
+	// ErrInvalidCursor is returned when a cursor is malformed or was issued for another sort order
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Request describes one page of a keyset-paginated list
+type Request struct {
+	Limit  int
+	Sort   string
+	Desc   bool
+	Cursor *Cursor
+}
+
+// SortSpec returns the sort as written in the query string, e.g. "-price"
+func (r Request) SortSpec() string {
+	if r.Desc {
+		return "-" + r.Sort
+	}
+	return r.Sort
+}
+
+// Next returns the cursor that continues after a row with the given sort key values
+func (r Request) Next(values ...interface{}) string {
+	return Cursor{Sort: r.SortSpec(), Values: values}.Encode()
+}
+
+// Cursor is the position of the last row of a page. Values holds the sort key
+// of that row followed by its unique id, so rows with equal sort keys are
+// never skipped or repeated. Clients treat the encoded form as opaque.
+type Cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// Encode returns the opaque, URL-safe form of the cursor
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor produced by Encode. Numbers are kept as
+// json.Number so they round-trip to backends without losing precision.
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var c Cursor
+	if err := dec.Decode(&c); err != nil || c.Sort == "" || len(c.Values) == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Strings returns the cursor values as strings, for SQL backends that bind
+// every key as text and let the database cast it to the column type
+func (c Cursor) Strings() []string {
+	out := make([]string, len(c.Values))
+	for i, v := range c.Values {
+		out[i] = fmt.Sprint(v)
+	}
+	return out
+}
+
+// Page is the response envelope shared by every list endpoint
+type Page[T any] struct {
+	Data       []T    `json:"data"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int64  `json:"total"`
+}
+
+// MapPage converts every item of a page with f, keeping its cursor and total
+func MapPage[T, U any](p *Page[T], f func(T) U) *Page[U] {
+	if p == nil {
+		return nil
+	}
+	data := make([]U, len(p.Data))
+	for i, item := range p.Data {
+		data[i] = f(item)
+	}
+	return &Page[U]{Data: data, NextCursor: p.NextCursor, Total: p.Total}
+}
+
+// Parse reads limit, sort and cursor from a query string. sorts lists the
+// fields that may be sorted on; the first one is the default. A leading "-"
+// sorts descending. A cursor is only accepted with the sort it was issued for.
+func Parse(query url.Values, sorts ...string) (Request, error) {
+	req := Request{Limit: DefaultLimit}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return req, ErrInvalidLimit
+		}
+		req.Limit = limit
+	}
+
+	if len(sorts) > 0 {
+		req.Sort = sorts[0]
+	}
+	if raw := query.Get("sort"); raw != "" {
+		req.Desc = strings.HasPrefix(raw, "-")
+		req.Sort = strings.TrimPrefix(raw, "-")
+		if !contains(sorts, req.Sort) {
+			return req, fmt.Errorf("%w: %s", ErrInvalidSort, req.Sort)
+		}
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := DecodeCursor(raw)
+		if err != nil {
+			return req, err
+		}
+		if cursor.Sort != req.SortSpec() {
+			return req, ErrInvalidCursor
+		}
+		req.Cursor = cursor
+	}
+
+	return req, nil
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package pagination
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	sorts := []string{"id", "price"}
+	priceCursor := Cursor{Sort: "-price", Values: []interface{}{"9.5", "42"}}.Encode()
+
+	tests := []struct {
+		name    string
+		query   string
+		want    Request
+		wantErr error
+	}{
+		{"defaults", "", Request{Limit: DefaultLimit, Sort: "id"}, nil},
+		{"limit and descending sort", "limit=5&sort=-price", Request{Limit: 5, Sort: "price", Desc: true}, nil},
+		{"zero limit", "limit=0", Request{}, ErrInvalidLimit},
+		{"limit too large", "limit=101", Request{}, ErrInvalidLimit},
+		{"non numeric limit", "limit=ten", Request{}, ErrInvalidLimit},
+		{"unknown sort", "sort=password", Request{}, ErrInvalidSort},
+		{"garbage cursor", "cursor=%%%", Request{}, ErrInvalidCursor},
+		{"cursor from another sort", "sort=price&cursor=" + priceCursor, Request{}, ErrInvalidCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				query = url.Values{"cursor": {"%%%"}}
+			}
+			got, err := Parse(query, sorts...)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	req := Request{Limit: 2, Sort: "created_at", Desc: true}
+	next := req.Next(json.Number("1704067200000"), "order-7")
+
+	query := url.Values{"sort": {"-created_at"}, "cursor": {next}}
+	got, err := Parse(query, "created_at")
+	require.NoError(t, err)
+	require.NotNil(t, got.Cursor)
+
+	assert.Equal(t, []interface{}{json.Number("1704067200000"), "order-7"}, got.Cursor.Values)
+	assert.Equal(t, []string{"1704067200000", "order-7"}, got.Cursor.Strings())
+}
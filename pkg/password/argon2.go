@@ -0,0 +1,162 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2idID = "argon2id"
+
+// Limits on the parameters of a stored hash, relative to the configured ones
+// where possible. Verifying runs with the parameters of the stored hash, so
+// without them a single hash could exhaust the memory or CPU of the server.
+const (
+	maxArgon2MemoryFactor     = 4
+	maxArgon2IterationsFactor = 10
+	maxArgon2Parallelism      = 16
+)
+
+// Argon2Params holds the argon2id cost parameters
+type Argon2Params struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follows the OWASP recommendation for argon2id
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Argon2idHasher hashes passwords with argon2id and encodes them in the PHC string format:
+// $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>
+type Argon2idHasher struct {
+	params Argon2Params
+}
+
+// NewArgon2id creates an argon2id hasher with the given parameters
+func NewArgon2id(params Argon2Params) *Argon2idHasher {
+	return &Argon2idHasher{params: params}
+}
+
+func (h *Argon2idHasher) ID() string {
+	return argon2idID
+}
+
+func (h *Argon2idHasher) Hash(plain string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("password: generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(plain), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idID,
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(plain, encoded string) (bool, error) {
+	params, version, salt, key, err := h.decode(encoded)
+	if err != nil {
+		return false, err
+	}
+	if version != argon2.Version {
+		return false, fmt.Errorf("%w: unsupported argon2 version %d", ErrInvalidHash, version)
+	}
+
+	other := argon2.IDKey([]byte(plain), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, version, salt, key, err := h.decode(encoded)
+	if err != nil {
+		return true
+	}
+
+	return version != argon2.Version ||
+		params.Memory != h.params.Memory ||
+		params.Iterations != h.params.Iterations ||
+		params.Parallelism != h.params.Parallelism ||
+		uint32(len(salt)) != h.params.SaltLength ||
+		uint32(len(key)) != h.params.KeyLength
+}
+
+func (h *Argon2idHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$"+argon2idID+"$")
+}
+
+func (h *Argon2idHasher) Valid(encoded string) bool {
+	_, version, _, _, err := h.decode(encoded)
+	return err == nil && version == argon2.Version
+}
+
+// decode parses an encoded hash and rejects parameters above the limits of
+// this hasher
+func (h *Argon2idHasher) decode(encoded string) (params Argon2Params, version int, salt, key []byte, err error) {
+	params, version, salt, key, err = decodeArgon2id(encoded)
+	if err != nil {
+		return params, 0, nil, nil, err
+	}
+	if uint64(params.Memory) > maxArgon2MemoryFactor*uint64(h.params.Memory) ||
+		uint64(params.Iterations) > maxArgon2IterationsFactor*uint64(h.params.Iterations) ||
+		params.Parallelism > max(maxArgon2Parallelism, h.params.Parallelism) {
+		return params, 0, nil, nil, fmt.Errorf("%w: argon2 parameters above the limit: m=%d,t=%d,p=%d",
+			ErrInvalidHash, params.Memory, params.Iterations, params.Parallelism)
+	}
+	return params, version, salt, key, nil
+}
+
+// decodeArgon2id parses an encoded hash and rejects parameters argon2.IDKey
+// cannot work with, which would make it panic, and empty salts or keys
+func decodeArgon2id(encoded string) (params Argon2Params, version int, salt, key []byte, err error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != argon2idID {
+		return params, 0, nil, nil, ErrInvalidHash
+	}
+
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, 0, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, 0, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	if params.Iterations < 1 || params.Parallelism < 1 || params.Memory < 8*uint32(params.Parallelism) {
+		return params, 0, nil, nil, fmt.Errorf("%w: argon2 parameters out of range: %s", ErrInvalidHash, parts[3])
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, 0, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil {
+		return params, 0, nil, nil, fmt.Errorf("%w: %v", ErrInvalidHash, err)
+	}
+	if len(salt) == 0 || len(key) == 0 {
+		return params, 0, nil, nil, fmt.Errorf("%w: empty salt or key", ErrInvalidHash)
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, version, salt, key, nil
+}
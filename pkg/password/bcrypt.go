@@ -0,0 +1,88 @@
+package password
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const bcryptID = "bcrypt"
+
+// bcryptLength is the length of an encoded bcrypt hash: the prefix, the
+// cost, and 53 characters of salt and key
+const bcryptLength = 60
+
+// DefaultBcryptCost is the work factor used when none is configured
+const DefaultBcryptCost = 12
+
+// maxBcryptCost is the highest cost a stored hash may have unless the hasher
+// is configured with a higher one. Each step doubles the work of verifying.
+const maxBcryptCost = 14
+
+// BcryptHasher hashes passwords with bcrypt. The cost is part of the encoded hash.
+type BcryptHasher struct {
+	cost int
+}
+
+// NewBcrypt creates a bcrypt hasher with the given cost
+func NewBcrypt(cost int) *BcryptHasher {
+	if cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		cost = DefaultBcryptCost
+	}
+	return &BcryptHasher{cost: cost}
+}
+
+func (h *BcryptHasher) ID() string {
+	return bcryptID
+}
+
+func (h *BcryptHasher) Hash(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (h *BcryptHasher) Verify(plain, encoded string) (bool, error) {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, err
+	}
+	if cost > h.maxCost() {
+		return false, fmt.Errorf("%w: bcrypt cost %d above the limit of %d", ErrInvalidHash, cost, h.maxCost())
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(plain))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.cost
+}
+
+func (h *BcryptHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h *BcryptHasher) Valid(encoded string) bool {
+	// bcrypt hashes have a fixed length, Cost checks the prefix and the cost
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return h.Matches(encoded) && len(encoded) == bcryptLength && err == nil && cost <= h.maxCost()
+}
+
+// maxCost is the highest cost Verify accepts
+func (h *BcryptHasher) maxCost() int {
+	return max(maxBcryptCost, h.cost)
+}
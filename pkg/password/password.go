@@ -0,0 +1,106 @@
+package password
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrUnknownAlgorithm is returned when an encoded hash does not belong to any registered hasher
+	ErrUnknownAlgorithm = errors.New("password: unknown hash algorithm")
+	// ErrInvalidHash is returned when an encoded hash cannot be parsed
+	ErrInvalidHash = errors.New("password: invalid encoded hash")
+)
+
+// Hasher hashes and verifies passwords. Encoded hashes carry the algorithm
+// identifier and its parameters so they can be verified after the
+// configuration changes.
+type Hasher interface {
+	// ID returns the algorithm identifier stored in the encoded hash
+	ID() string
+	// Hash returns the encoded hash of the plaintext password
+	Hash(plain string) (string, error)
+	// Verify reports whether the plaintext password matches the encoded hash
+	Verify(plain, encoded string) (bool, error)
+	// NeedsRehash reports whether the encoded hash was produced with different parameters
+	NeedsRehash(encoded string) bool
+	// Matches reports whether the encoded hash was produced by this algorithm
+	Matches(encoded string) bool
+	// Valid reports whether the encoded hash is a complete hash of this
+	// algorithm that Verify can check
+	Valid(encoded string) bool
+}
+
+// Manager hashes new passwords with the preferred hasher and verifies
+// existing hashes with whichever registered hasher produced them.
+type Manager struct {
+	preferred Hasher
+	hashers   []Hasher
+}
+
+// NewManager creates a Manager that hashes with preferred and can still
+// verify hashes produced by any of the legacy hashers
+func NewManager(preferred Hasher, legacy ...Hasher) *Manager {
+	return &Manager{
+		preferred: preferred,
+		hashers:   append([]Hasher{preferred}, legacy...),
+	}
+}
+
+// Hash returns the encoded hash of plain using the preferred hasher
+func (m *Manager) Hash(plain string) (string, error) {
+	return m.preferred.Hash(plain)
+}
+
+// Verify reports whether plain matches encoded. needsRehash is true when the
+// password matched but the hash should be replaced because it was produced by
+// another algorithm or with outdated parameters.
+func (m *Manager) Verify(plain, encoded string) (ok bool, needsRehash bool, err error) {
+	h := m.lookup(encoded)
+	if h == nil {
+		return false, false, ErrUnknownAlgorithm
+	}
+
+	ok, err = h.Verify(plain, encoded)
+	if err != nil || !ok {
+		return false, false, err
+	}
+
+	return true, h.ID() != m.preferred.ID() || h.NeedsRehash(encoded), nil
+}
+
+// IsHashed reports whether encoded is a complete hash produced by one of the
+// registered hashers. A value that only starts like a hash, such as a
+// plaintext password beginning with "$2a$", is not.
+func (m *Manager) IsHashed(encoded string) bool {
+	h := m.lookup(encoded)
+	return h != nil && h.Valid(encoded)
+}
+
+func (m *Manager) lookup(encoded string) Hasher {
+	for _, h := range m.hashers {
+		if h.Matches(encoded) {
+			return h
+		}
+	}
+	return nil
+}
+
+var (
+	defaultManager = NewManager(NewArgon2id(DefaultArgon2Params), NewBcrypt(DefaultBcryptCost))
+	defaultMu      sync.RWMutex
+)
+
+// Default returns the process-wide password manager
+func Default() *Manager {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultManager
+}
+
+// SetDefault replaces the process-wide password manager
+func SetDefault(m *Manager) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultManager = m
+}
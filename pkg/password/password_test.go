@@ -0,0 +1,207 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fastArgon2Params keeps the tests quick while exercising the same code paths
+var fastArgon2Params = Argon2Params{
+	Memory:      1024,
+	Iterations:  1,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	h := NewArgon2id(fastArgon2Params)
+
+	encoded, err := h.Hash("password123")
+	require.NoError(t, err)
+	assert.True(t, h.Matches(encoded))
+	assert.Contains(t, encoded, "$argon2id$v=19$m=1024,t=1,p=1$")
+
+	ok, err := h.Verify("password123", encoded)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = h.Verify("wrong-password", encoded)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.False(t, h.NeedsRehash(encoded))
+
+	stronger := fastArgon2Params
+	stronger.Iterations = 2
+	assert.True(t, NewArgon2id(stronger).NeedsRehash(encoded))
+
+	_, err = h.Verify("password123", "$argon2id$v=19$garbage")
+	assert.ErrorIs(t, err, ErrInvalidHash)
+}
+
+func TestArgon2idHasher_RejectsInvalidParameters(t *testing.T) {
+	h := NewArgon2id(fastArgon2Params)
+	const saltAndKey = "$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+	// argon2.IDKey panics on each of these, they must be refused first
+	for _, params := range []string{"m=1024,t=0,p=1", "m=1024,t=1,p=0", "m=15,t=1,p=2", "m=0,t=1,p=1"} {
+		t.Run(params, func(t *testing.T) {
+			encoded := "$argon2id$v=19$" + params + saltAndKey
+			assert.NotPanics(t, func() {
+				_, err := h.Verify("password123", encoded)
+				assert.ErrorIs(t, err, ErrInvalidHash)
+			})
+			assert.True(t, h.NeedsRehash(encoded))
+			assert.False(t, h.Valid(encoded))
+		})
+	}
+
+	assert.False(t, h.Valid("$argon2id$v=19$m=1024,t=1,p=1$$"))
+	assert.True(t, h.Valid("$argon2id$v=19$m=1024,t=1,p=1"+saltAndKey))
+}
+
+func TestArgon2idHasher_RejectsParametersAboveLimits(t *testing.T) {
+	h := NewArgon2id(fastArgon2Params)
+	const saltAndKey = "$c2FsdHNhbHRzYWx0c2FsdA$a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+	// Verifying any of these would run with far more memory or time than configured
+	for _, params := range []string{"m=4097,t=1,p=1", "m=1024,t=11,p=1", "m=1024,t=1,p=17", "m=4294967295,t=4294967295,p=255"} {
+		t.Run(params, func(t *testing.T) {
+			encoded := "$argon2id$v=19$" + params + saltAndKey
+			_, err := h.Verify("password123", encoded)
+			assert.ErrorIs(t, err, ErrInvalidHash)
+			assert.True(t, h.NeedsRehash(encoded))
+			assert.False(t, h.Valid(encoded))
+		})
+	}
+
+	assert.True(t, h.Valid("$argon2id$v=19$m=4096,t=10,p=16"+saltAndKey))
+}
+
+func TestBcryptHasher(t *testing.T) {
+	h := NewBcrypt(4)
+
+	encoded, err := h.Hash("password123")
+	require.NoError(t, err)
+	assert.True(t, h.Matches(encoded))
+
+	ok, err := h.Verify("password123", encoded)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = h.Verify("wrong-password", encoded)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.False(t, h.NeedsRehash(encoded))
+	assert.True(t, NewBcrypt(5).NeedsRehash(encoded))
+}
+
+func TestBcryptHasher_RejectsCostAboveLimit(t *testing.T) {
+	h := NewBcrypt(4)
+
+	encoded, err := h.Hash("password123")
+	require.NoError(t, err)
+	expensive := strings.Replace(encoded, "$04$", "$31$", 1)
+
+	_, err = h.Verify("password123", expensive)
+	assert.ErrorIs(t, err, ErrInvalidHash)
+	assert.True(t, h.NeedsRehash(expensive))
+	assert.False(t, h.Valid(expensive))
+
+	assert.True(t, h.Valid(strings.Replace(encoded, "$04$", "$14$", 1)))
+	assert.False(t, h.Valid(strings.Replace(encoded, "$04$", "$16$", 1)))
+
+	// A hasher configured above the limit accepts its own cost
+	assert.True(t, NewBcrypt(16).Valid(strings.Replace(encoded, "$04$", "$16$", 1)))
+}
+
+func TestManager_Verify(t *testing.T) {
+	argon := NewArgon2id(fastArgon2Params)
+	bcryptHasher := NewBcrypt(4)
+	m := NewManager(argon, bcryptHasher)
+
+	tests := []struct {
+		name            string
+		encoded         func() string
+		plain           string
+		wantOK          bool
+		wantNeedsRehash bool
+		wantErr         error
+	}{
+		{
+			name:    "preferred algorithm",
+			encoded: func() string { s, _ := argon.Hash("password123"); return s },
+			plain:   "password123",
+			wantOK:  true,
+		},
+		{
+			name:            "legacy algorithm needs rehash",
+			encoded:         func() string { s, _ := bcryptHasher.Hash("password123"); return s },
+			plain:           "password123",
+			wantOK:          true,
+			wantNeedsRehash: true,
+		},
+		{
+			name: "outdated parameters need rehash",
+			encoded: func() string {
+				weaker := fastArgon2Params
+				weaker.Memory = 512
+				s, _ := NewArgon2id(weaker).Hash("password123")
+				return s
+			},
+			plain:           "password123",
+			wantOK:          true,
+			wantNeedsRehash: true,
+		},
+		{
+			name:    "wrong password",
+			encoded: func() string { s, _ := argon.Hash("password123"); return s },
+			plain:   "password124",
+		},
+		{
+			name:    "plaintext is never accepted",
+			encoded: func() string { return "password123" },
+			plain:   "password123",
+			wantErr: ErrUnknownAlgorithm,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, needsRehash, err := m.Verify(tt.plain, tt.encoded())
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.wantNeedsRehash, needsRehash)
+		})
+	}
+}
+
+func TestManager_IsHashed(t *testing.T) {
+	m := NewManager(NewArgon2id(fastArgon2Params), NewBcrypt(4))
+
+	encoded, err := m.Hash("password123")
+	require.NoError(t, err)
+
+	assert.True(t, m.IsHashed(encoded))
+	assert.False(t, m.IsHashed("password123"))
+	assert.False(t, m.IsHashed(""))
+
+	bcryptEncoded, err := NewBcrypt(4).Hash("password123")
+	require.NoError(t, err)
+	assert.True(t, m.IsHashed(bcryptEncoded))
+
+	// Plaintext passwords that only look like hashes are still plaintext
+	assert.False(t, m.IsHashed("$2a$my-secret-password"))
+	assert.False(t, m.IsHashed("$2b$10$tooshort"))
+	assert.False(t, m.IsHashed("$argon2id$hunter2"))
+	assert.False(t, m.IsHashed("$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5"))
+}
@@ -0,0 +1,157 @@
+// Package projection restricts API responses to a sparse fieldset and lists
+// the model fields that must never be serialized.
+package projection
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+)
+
+// ErrUnknownField is returned when a sparse fieldset names a field the
+// response does not have
+var ErrUnknownField = errors.New("unknown field")
+
+// Fields reads the sparse fieldset from the fields query parameter, e.g.
+// ?fields=id,username. Every name must be a JSON field of dto. It returns
+// nil when the parameter is absent, which selects every field.
+func Fields(query url.Values, dto interface{}) ([]string, error) {
+	raw := query.Get("fields")
+	if raw == "" {
+		return nil, nil
+	}
+
+	known := jsonNames(reflect.TypeOf(dto))
+	var names []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownField, name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Select returns v, an object or a list of objects, restricted to names.
+// v is returned unchanged when names is empty.
+func Select(v interface{}, names []string) interface{} {
+	if len(names) == 0 {
+		return v
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var decoded interface{}
+	if err := dec.Decode(&decoded); err != nil {
+		return v
+	}
+	return pick(decoded, names)
+}
+
+// SelectPage restricts every item of a page to names, keeping its cursor and total
+func SelectPage[T any](p *pagination.Page[T], names []string) interface{} {
+	if len(names) == 0 || p == nil {
+		return p
+	}
+	data, _ := Select(p.Data, names).([]interface{})
+	if data == nil {
+		data = []interface{}{}
+	}
+	return &pagination.Page[interface{}]{
+		Data:       data,
+		NextCursor: p.NextCursor,
+		Total:      p.Total,
+	}
+}
+
+func pick(v interface{}, names []string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(names))
+		for _, name := range names {
+			if value, ok := v[name]; ok {
+				out[name] = value
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = pick(item, names)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// Sensitive returns the JSON names of the fields tagged sensitive:"true" in
+// the given structs. Such fields must never appear in a response.
+func Sensitive(types ...interface{}) []string {
+	var names []string
+	for _, v := range types {
+		t := indirect(reflect.TypeOf(v))
+		if t.Kind() != reflect.Struct {
+			continue
+		}
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.Tag.Get("sensitive") == "true" {
+				names = append(names, jsonName(sf))
+			}
+		}
+	}
+	return names
+}
+
+// jsonNames returns the JSON names of the fields of t, including those of
+// embedded structs
+func jsonNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool)
+	t = indirect(t)
+	if t == nil || t.Kind() != reflect.Struct {
+		return names
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			for name := range jsonNames(sf.Type) {
+				names[name] = true
+			}
+			continue
+		}
+		if name := jsonName(sf); sf.IsExported() && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+func jsonName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" {
+		return name
+	}
+	return sf.Name
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
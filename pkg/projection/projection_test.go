@@ -0,0 +1,85 @@
+package projection
+
+import (
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type base struct {
+	ID int64 `json:"id"`
+}
+
+type account struct {
+	base
+	Name   string `json:"name"`
+	Email  string `json:"email,omitempty"`
+	Secret string `json:"secret" sensitive:"true"`
+	Note   string `json:"-"`
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    []string
+		wantErr error
+	}{
+		{"absent selects everything", "", nil, nil},
+		{"listed fields", "fields=id,name", []string{"id", "name"}, nil},
+		{"spaces and empty entries", "fields=+id+,,email", []string{"id", "email"}, nil},
+		{"unknown field", "fields=id,password", nil, ErrUnknownField},
+		{"ignored field", "fields=Note", nil, ErrUnknownField},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			require.NoError(t, err)
+
+			got, err := Fields(query, account{})
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSelect(t *testing.T) {
+	a := account{base: base{ID: 9007199254740993}, Name: "Ada", Email: "ada@example.com"}
+
+	data, err := json.Marshal(Select(a, []string{"id", "name"}))
+	require.NoError(t, err)
+	// Large ids keep their precision
+	assert.JSONEq(t, `{"id":9007199254740993,"name":"Ada"}`, string(data))
+
+	data, err = json.Marshal(Select([]account{a, a}, []string{"email"}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{"email":"ada@example.com"},{"email":"ada@example.com"}]`, string(data))
+
+	assert.Equal(t, a, Select(a, nil))
+}
+
+func TestSelectPage(t *testing.T) {
+	page := &pagination.Page[account]{Data: []account{{Name: "Ada"}}, NextCursor: "abc", Total: 3}
+
+	data, err := json.Marshal(SelectPage(page, []string{"name"}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":[{"name":"Ada"}],"next_cursor":"abc","total":3}`, string(data))
+
+	empty := &pagination.Page[account]{}
+	data, err = json.Marshal(SelectPage(empty, []string{"name"}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"data":[],"total":0}`, string(data))
+}
+
+func TestSensitive(t *testing.T) {
+	assert.Equal(t, []string{"secret", "secret"}, Sensitive(account{}, &account{}, 42))
+}
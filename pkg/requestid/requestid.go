@@ -0,0 +1,48 @@
+// Package requestid carries the id that correlates one API request across
+// logs, traces, Kafka messages and Elasticsearch calls.
+package requestid
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Header is the HTTP header a client may set to choose the request id. The
+// server echoes it on every response.
+const Header = "X-Request-ID"
+
+// maxLength bounds client supplied ids so they stay cheap to log and index
+const maxLength = 128
+
+type contextKey struct{}
+
+// New returns a fresh request id
+func New() string {
+	return uuid.Must(uuid.NewV7()).String()
+}
+
+// Valid reports whether a client supplied id can be reused: 1 to 128
+// printable ASCII characters without spaces
+func Valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a copy of ctx carrying id
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request id of ctx, or "" outside a request
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
@@ -0,0 +1,38 @@
+package requestid
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{uuid.NewString(), true},
+		{"has space", false},
+		{"line\nbreak", false},
+		{"ümlaut", false},
+		{strings.Repeat("a", 128), true},
+		{strings.Repeat("a", 129), false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Valid(tt.id), "%q", tt.id)
+	}
+}
+
+func TestContext(t *testing.T) {
+	assert.Empty(t, FromContext(context.Background()))
+
+	id := New()
+	assert.True(t, Valid(id))
+	assert.Equal(t, id, FromContext(NewContext(context.Background(), id)))
+}
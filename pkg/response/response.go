@@ -3,22 +3,84 @@ package response
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/logging"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"go.opentelemetry.io/otel/trace"
 )
 
-type ErrorResponse struct {
-	Error  string `json:"error"`
-	Source string `json:"source"`
+// ProblemContentType is the media type of every error response (RFC 7807)
+const ProblemContentType = "application/problem+json"
+
+// RequestIDHeader carries the id that correlates a request with its logs
+const RequestIDHeader = requestid.Header
+
+// Problem is the RFC 7807 error body shared by every endpoint
+type Problem struct {
+	Type      string              `json:"type" example:"about:blank"`
+	Title     string              `json:"title" example:"Conflict"`
+	Status    int                 `json:"status" example:"409"`
+	Detail    string              `json:"detail,omitempty" example:"user with this email already exists"`
+	Instance  string              `json:"instance,omitempty" example:"/api/v1/users"`
+	RequestID string              `json:"request_id,omitempty"`
+	TraceID   string              `json:"trace_id,omitempty"`
+	Errors    []errors.FieldError `json:"errors,omitempty"`
+}
+
+// RespondWithError sends a problem with the specified status code and detail
+func RespondWithError(w http.ResponseWriter, r *http.Request, code int, detail string) {
+	writeProblem(w, r, &errors.Error{Code: code, Message: detail})
 }
 
-// RespondWithError sends an error response with the specified status code and message
-func RespondWithError(w http.ResponseWriter, code int, message string, source string) {
-	response := ErrorResponse{
-		Error:  message,
-		Source: source,
+// WriteError maps err to its HTTP status with errors.From and sends it as a
+// problem. It is the one place where errors become HTTP responses: server
+// side failures are logged and answered with a generic detail, so driver
+// errors never reach the client.
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e := errors.From(err)
+	if e.Code >= http.StatusInternalServerError {
+		attrs := []any{"method", r.Method, "path", r.URL.Path, "status", e.Code, "error", err}
+		if e.Op != "" {
+			attrs = append(attrs, "op", e.Op)
+		}
+		logging.FromContext(r.Context()).Error("request failed", attrs...)
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
-	json.NewEncoder(w).Encode(response)
+	writeProblem(w, r, e)
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, e *errors.Error) {
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(e.Code),
+		Status:    e.Code,
+		Detail:    e.Message,
+		Instance:  r.URL.Path,
+		RequestID: requestID(w, r),
+		Errors:    e.Fields,
+	}
+	if e.Field != "" && len(e.Fields) == 0 {
+		problem.Errors = []errors.FieldError{{Field: e.Field, Message: e.Message}}
+	}
+	if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+		problem.TraceID = sc.TraceID().String()
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(e.Code)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// requestID prefers the id the server assigned to the request over the one
+// the client sent
+func requestID(w http.ResponseWriter, r *http.Request) string {
+	if id := requestid.FromContext(r.Context()); id != "" {
+		return id
+	}
+	if id := w.Header().Get(RequestIDHeader); id != "" {
+		return id
+	}
+	return r.Header.Get(RequestIDHeader)
 }
 
 // RespondWithJSON sends a JSON response with the specified status code and payload
@@ -0,0 +1,89 @@
+package response
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func decodeProblem(t *testing.T, rec *httptest.ResponseRecorder) Problem {
+	t.Helper()
+	assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+
+	var p Problem
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&p))
+	return p
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want Problem
+	}{
+		{
+			name: "duplicate carries the offending field",
+			err:  errors.NewDuplicate("UserRepository.Create", "user", "email", nil),
+			want: Problem{
+				Type:     "about:blank",
+				Title:    "Conflict",
+				Status:   http.StatusConflict,
+				Detail:   "user with this email already exists",
+				Instance: "/api/v1/users",
+				Errors:   []errors.FieldError{{Field: "email", Message: "user with this email already exists"}},
+			},
+		},
+		{
+			name: "unknown errors do not leak",
+			err:  fmt.Errorf("dial tcp 10.0.0.1:3306: connection refused"),
+			want: Problem{
+				Type:     "about:blank",
+				Title:    "Internal Server Error",
+				Status:   http.StatusInternalServerError,
+				Detail:   "internal server error",
+				Instance: "/api/v1/users",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/users", nil)
+			rec := httptest.NewRecorder()
+
+			WriteError(rec, req, tt.err)
+
+			assert.Equal(t, tt.want.Status, rec.Code)
+			assert.Equal(t, tt.want, decodeProblem(t, rec))
+		})
+	}
+}
+
+func TestRespondWithError_Correlation(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/products/7", nil).WithContext(ctx)
+	req.Header.Set(RequestIDHeader, "client-id")
+	rec := httptest.NewRecorder()
+	rec.Header().Set(RequestIDHeader, "server-id")
+
+	RespondWithError(rec, req, http.StatusNotFound, "product not found")
+
+	p := decodeProblem(t, rec)
+	assert.Equal(t, http.StatusNotFound, p.Status)
+	assert.Equal(t, "server-id", p.RequestID)
+	assert.Equal(t, traceID.String(), p.TraceID)
+}
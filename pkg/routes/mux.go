@@ -0,0 +1,129 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/response"
+)
+
+// Register adds every route to mux under its method pattern, e.g.
+// "GET /users/{id}". wrap, when not nil, decorates each handler after the
+// route's own middleware; the router uses it for authentication. A path
+// that is registered for some methods answers the others with 405 and an
+// Allow header. Conflicting patterns are reported as an error.
+func Register(mux *http.ServeMux, rs []Route, wrap func(Route, http.Handler) http.Handler) (err error) {
+	defer func() {
+		// ServeMux panics on conflicting patterns
+		if p := recover(); p != nil {
+			err = fmt.Errorf("register routes: %v", p)
+		}
+	}()
+
+	allowed := make(map[string][]string)
+	var paths []string
+	for _, route := range rs {
+		var h http.Handler = route.Handler
+		for i := len(route.Middleware) - 1; i >= 0; i-- {
+			h = route.Middleware[i](h)
+		}
+		if wrap != nil {
+			h = wrap(route, h)
+		}
+		mux.Handle(route.Method+" "+route.Pattern, h)
+
+		if _, ok := allowed[route.Pattern]; !ok {
+			paths = append(paths, route.Pattern)
+		}
+		allowed[route.Pattern] = append(allowed[route.Pattern], route.Method)
+	}
+
+	for _, path := range paths {
+		allow := strings.Join(allowed[path], ", ")
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Allow", allow)
+			response.RespondWithError(w, r, http.StatusMethodNotAllowed, "Method not allowed")
+		})
+	}
+	return nil
+}
+
+// NotFound answers paths no route matches with a problem response instead of
+// the plain text 404 of ServeMux
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	response.RespondWithError(w, r, http.StatusNotFound, "Not found")
+}
+
+// NewHandler serves rs directly, without authentication or other
+// middleware. Requests are accepted with or without Prefix. Handlers use it
+// to implement http.Handler for tests. It panics on conflicting patterns,
+// like ServeMux.
+func NewHandler(rs []Route) http.Handler {
+	mux := http.NewServeMux()
+	if err := Register(mux, rs, nil); err != nil {
+		panic(err)
+	}
+	mux.HandleFunc("/", NotFound)
+
+	stripped := http.StripPrefix(Prefix, mux)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, Prefix+"/") {
+			stripped.ServeHTTP(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// Info describes a registered route
+type Info struct {
+	Name        string   `json:"name"`
+	Method      string   `json:"method"`
+	Path        string   `json:"path"`
+	Handler     string   `json:"handler"`
+	Public      bool     `json:"public"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+// Describe lists rs sorted by path and method. prefix is prepended to every
+// pattern, e.g. Prefix for routes mounted under the API.
+func Describe(prefix string, rs []Route) []Info {
+	infos := make([]Info, 0, len(rs))
+	for _, route := range rs {
+		infos = append(infos, Info{
+			Name:        route.Name,
+			Method:      route.Method,
+			Path:        prefix + route.Pattern,
+			Handler:     funcName(route.Handler),
+			Public:      route.Public,
+			Permissions: route.Permissions,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].Path != infos[j].Path {
+			return infos[i].Path < infos[j].Path
+		}
+		return infos[i].Method < infos[j].Method
+	})
+	return infos
+}
+
+// funcName returns the short name of a handler, e.g. "handler.(*UserHandler).getUserByID"
+func funcName(h http.HandlerFunc) string {
+	if h == nil {
+		return ""
+	}
+	fn := runtime.FuncForPC(reflect.ValueOf(h).Pointer())
+	if fn == nil {
+		return ""
+	}
+	name := strings.TrimSuffix(fn.Name(), "-fm")
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
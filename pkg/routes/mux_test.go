@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ok(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+
+func TestRegister(t *testing.T) {
+	var order []string
+	trace := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	rs := []Route{
+		{Name: "items.list", Method: http.MethodGet, Pattern: "/items", Handler: ok},
+		{Name: "items.create", Method: http.MethodPost, Pattern: "/items", Handler: ok, Middleware: []func(http.Handler) http.Handler{trace("first"), trace("second")}},
+		{Name: "items.get", Method: http.MethodGet, Pattern: "/items/{id}", Handler: ok},
+	}
+
+	mux := http.NewServeMux()
+	err := Register(mux, rs, func(route Route, h http.Handler) http.Handler {
+		return trace("wrap " + route.Name)(h)
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{"exact path", http.MethodGet, "/items", http.StatusOK, ""},
+		{"path parameter", http.MethodGet, "/items/42", http.StatusOK, ""},
+		{"wrong method", http.MethodDelete, "/items", http.StatusMethodNotAllowed, "GET, POST"},
+		{"wrong method with parameter", http.MethodPut, "/items/42", http.StatusMethodNotAllowed, "GET"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, tt.wantAllow, rec.Header().Get("Allow"))
+		})
+	}
+
+	t.Run("middleware order", func(t *testing.T) {
+		order = nil
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/items", nil))
+		assert.Equal(t, []string{"wrap items.create", "first", "second"}, order)
+	})
+}
+
+func TestRegister_Conflict(t *testing.T) {
+	rs := []Route{
+		{Name: "items.get", Method: http.MethodGet, Pattern: "/items/{id}", Handler: ok},
+		{Name: "items.get_by_key", Method: http.MethodGet, Pattern: "/items/{key}", Handler: ok},
+	}
+
+	assert.Error(t, Register(http.NewServeMux(), rs, nil))
+}
+
+func TestNewHandler(t *testing.T) {
+	h := NewHandler([]Route{{Name: "items.list", Method: http.MethodGet, Pattern: "/items", Handler: ok}})
+
+	for path, want := range map[string]int{
+		"/items":          http.StatusOK,
+		Prefix + "/items": http.StatusOK,
+		"/missing":        http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, want, rec.Code, path)
+	}
+}
+
+func TestParams(t *testing.T) {
+	id := uuid.Must(uuid.NewV7())
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.SetPathValue("id", id.String())
+	req.SetPathValue("n", "42")
+	req.SetPathValue("bad", "forty-two")
+
+	gotID, err := UUIDParam(req, "id")
+	require.NoError(t, err)
+	assert.Equal(t, id, gotID)
+
+	n, err := Int64Param(req, "n")
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+
+	_, err = Int64Param(req, "bad")
+	assert.Equal(t, http.StatusBadRequest, errors.From(err).Code)
+	assert.Equal(t, []errors.FieldError{{Field: "bad", Message: "must be an integer"}}, errors.From(err).Fields)
+
+	_, err = UUIDParam(req, "bad")
+	assert.Equal(t, []errors.FieldError{{Field: "bad", Message: "must be a UUID"}}, errors.From(err).Fields)
+}
+
+func TestDescribe(t *testing.T) {
+	rs := []Route{
+		{Name: "items.create", Method: http.MethodPost, Pattern: "/items", Handler: ok, Permissions: []string{"items:write"}},
+		{Name: "items.get", Method: http.MethodGet, Pattern: "/items/{id}", Handler: ok, Public: true},
+		{Name: "items.list", Method: http.MethodGet, Pattern: "/items", Handler: ok, Permissions: []string{"items:read"}},
+	}
+
+	assert.Equal(t, []Info{
+		{Name: "items.list", Method: http.MethodGet, Path: "/api/v1/items", Handler: "routes.ok", Permissions: []string{"items:read"}},
+		{Name: "items.create", Method: http.MethodPost, Path: "/api/v1/items", Handler: "routes.ok", Permissions: []string{"items:write"}},
+		{Name: "items.get", Method: http.MethodGet, Path: "/api/v1/items/{id}", Handler: "routes.ok", Public: true},
+	}, Describe(Prefix, rs))
+}
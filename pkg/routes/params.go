@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/google/uuid"
+)
+
+// UUIDParam returns the path parameter name parsed as a UUID. A malformed
+// value is a 400 error that names the parameter.
+func UUIDParam(r *http.Request, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		return uuid.Nil, paramError(name, "must be a UUID")
+	}
+	return id, nil
+}
+
+// Int64Param returns the path parameter name parsed as a base 10 integer. A
+// malformed value is a 400 error that names the parameter.
+func Int64Param(r *http.Request, name string) (int64, error) {
+	v, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		return 0, paramError(name, "must be an integer")
+	}
+	return v, nil
+}
+
+func paramError(name, message string) error {
+	e := errors.NewBadRequest("routes", fmt.Sprintf("Invalid path parameter %s", name))
+	e.Fields = []errors.FieldError{{Field: name, Message: message}}
+	return e
+}
@@ -2,11 +2,25 @@ package routes
 
 import "net/http"
 
+// Prefix is the path the API routes are mounted under
+const Prefix = "/api/v1"
+
 // Route represents a single route with its HTTP method, pattern and handler
 type Route struct {
-	Method  string
+	// Name identifies the route in logs and /debug/routes, e.g. "users.get"
+	Name   string
+	Method string
+	// Pattern is a ServeMux path pattern; {name} segments are path
+	// parameters read with UUIDParam, Int64Param or r.PathValue
 	Pattern string
 	Handler http.HandlerFunc
+	// Middleware wraps this route only, after authentication and
+	// authorization have passed. The first entry runs first.
+	Middleware []func(http.Handler) http.Handler
+	// Public routes can be called without a bearer token
+	Public bool
+	// Permissions the caller must hold; required for every non-public route
+	Permissions []string
 }
 
 // Handler interface for all handlers that can provide their routes
@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordSpans installs a tracer provider that records ended spans
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	})
+	return recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestKafkaPropagation(t *testing.T) {
+	recorder := recordSpans(t)
+
+	ctx, request := otel.Tracer("test").Start(context.Background(), "POST /api/v1/users")
+	msg := &sarama.ProducerMessage{
+		Topic:   "events",
+		Key:     sarama.StringEncoder("user:1"),
+		Headers: []sarama.RecordHeader{{Key: []byte("traceparent"), Value: []byte("stale")}},
+	}
+	_, span := StartProducerSpan(ctx, msg, "id-1")
+	EndProducerSpan(span, 1, 42, nil)
+	request.End()
+
+	// The stale header is replaced, not duplicated
+	require.Len(t, msg.Headers, 1)
+	assert.Equal(t, "traceparent", string(msg.Headers[0].Key))
+
+	consumed := &sarama.ConsumerMessage{Topic: "events", Partition: 1, Offset: 42, Key: []byte("user:1")}
+	for _, h := range msg.Headers {
+		consumed.Headers = append(consumed.Headers, &h)
+	}
+	_, span = StartConsumerSpan(context.Background(), "group", consumed, "id-1")
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+	producer, consumer := spans[0], spans[2]
+
+	assert.Equal(t, "publish events", producer.Name())
+	assert.Equal(t, trace.SpanKindProducer, producer.SpanKind())
+	assert.Equal(t, request.SpanContext().SpanID(), producer.Parent().SpanID())
+	assert.Equal(t, "kafka", attributes(producer)["messaging.system"].AsString())
+	assert.Equal(t, "1", attributes(producer)["messaging.destination.partition.id"].AsString())
+	assert.Equal(t, int64(42), attributes(producer)["messaging.kafka.message.offset"].AsInt64())
+	assert.Equal(t, "id-1", attributes(producer)["messaging.message.id"].AsString())
+
+	assert.Equal(t, "process events", consumer.Name())
+	assert.Equal(t, trace.SpanKindConsumer, consumer.SpanKind())
+	assert.Equal(t, producer.SpanContext().TraceID(), consumer.SpanContext().TraceID())
+	assert.Equal(t, producer.SpanContext().SpanID(), consumer.Parent().SpanID())
+	assert.True(t, consumer.Parent().IsRemote())
+	assert.Equal(t, "group", attributes(consumer)["messaging.kafka.consumer.group"].AsString())
+	assert.Equal(t, "user:1", attributes(consumer)["messaging.kafka.message.key"].AsString())
+}
+
+func TestEndProducerSpan_Error(t *testing.T) {
+	recorder := recordSpans(t)
+
+	_, span := StartProducerSpan(context.Background(), &sarama.ProducerMessage{Topic: "events"}, "")
+	EndProducerSpan(span, -1, -1, errors.New("kafka: client has run out of available brokers"))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, codes.Error, spans[0].Status().Code)
+	assert.NotContains(t, attributes(spans[0]), attribute.Key("messaging.kafka.message.offset"))
+}
+
+func TestInjectExtract(t *testing.T) {
+	recordSpans(t)
+
+	assert.Nil(t, Inject(context.Background()))
+
+	ctx, span := otel.Tracer("test").Start(context.Background(), "request")
+	defer span.End()
+	carrier := Inject(ctx)
+	require.Contains(t, carrier, "traceparent")
+
+	extracted := trace.SpanContextFromContext(Extract(context.Background(), carrier))
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+}
@@ -2,10 +2,11 @@ package tracing
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
@@ -59,10 +60,15 @@ func InitTracer(serviceName string, collectorURL string, opts ...Option) (func()
     )
 
     otel.SetTracerProvider(provider)
+    // W3C trace context in HTTP and Kafka headers
+    otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+        propagation.TraceContext{},
+        propagation.Baggage{},
+    ))
 
     return func() {
         if err := provider.Shutdown(context.Background()); err != nil {
-            log.Printf("Error shutting down tracer provider: %v", err)
+            slog.Error("failed to shut down tracer provider", "error", err)
         }
     }, nil
 }
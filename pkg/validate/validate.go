@@ -0,0 +1,292 @@
+// Package validate decodes request bodies and enforces the binding tags on
+// request DTOs, reporting every failed rule at once.
+//
+// Supported rules: required, omitempty, email, min=N, max=N and
+// oneof=a b c. min and max compare the length of strings and slices and
+// the value of numbers. Nested structs and slices of structs are checked
+// too, with their fields reported as "items[0].quantity".
+package validate
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+)
+
+// Validator is implemented by models with rules that tags cannot express
+type Validator interface {
+	Validate() error
+}
+
+// Errors collects field failures so every broken rule is reported at once
+type Errors []errors.FieldError
+
+// Add records a failure for field
+func (e *Errors) Add(field, message string) {
+	*e = append(*e, errors.FieldError{Field: field, Message: message})
+}
+
+// Merge adds the failures of err, prefixing their fields with prefix, e.g.
+// "items[0]". An error without field details is recorded against prefix.
+func (e *Errors) Merge(prefix string, err error) {
+	if err == nil {
+		return
+	}
+	var fe *errors.Error
+	if !stderrors.As(err, &fe) || len(fe.Fields) == 0 {
+		e.Add(prefix, err.Error())
+		return
+	}
+	for _, f := range fe.Fields {
+		e.Add(join(prefix, f.Field), f.Message)
+	}
+}
+
+// Err returns nil when no failure was recorded, otherwise a validation error
+// carrying every failure
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return &errors.Error{
+		Code:    http.StatusUnprocessableEntity,
+		Message: "request validation failed",
+		Op:      "validate",
+		Fields:  e,
+	}
+}
+
+// Decode reads the JSON body of r into dst and validates it with Struct.
+// Malformed JSON, unknown fields and values of the wrong type are rejected
+// with 400; broken rules with 422.
+func Decode(r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err)
+	}
+	if dec.More() {
+		return errors.NewBadRequest("validate", "Request body must contain a single JSON object")
+	}
+	return Struct(dst)
+}
+
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case stderrors.Is(err, io.EOF):
+		return errors.NewBadRequest("validate", "Request body is required")
+	case stderrors.As(err, &typeErr):
+		e := errors.NewBadRequest("validate", "Invalid request payload")
+		e.Fields = []errors.FieldError{{Field: typeErr.Field, Message: "must be of type " + typeErr.Type.String()}}
+		return e
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field, _ := strconv.Unquote(strings.TrimPrefix(err.Error(), "json: unknown field "))
+		e := errors.NewBadRequest("validate", "Invalid request payload")
+		e.Fields = []errors.FieldError{{Field: field, Message: "unknown field"}}
+		return e
+	default:
+		return errors.NewBadRequest("validate", "Invalid request payload")
+	}
+}
+
+// Struct checks the binding tags of v, a struct or a pointer to one, and
+// then calls its Validate method. A field that already failed a tag rule is
+// not reported again by Validate.
+func Struct(v interface{}) error {
+	var errs Errors
+	checkStruct(reflect.ValueOf(v), "", &errs)
+
+	if validator, ok := v.(Validator); ok {
+		var extra Errors
+		extra.Merge("", validator.Validate())
+		for _, f := range extra {
+			if !errs.has(f.Field) {
+				errs = append(errs, f)
+			}
+		}
+	}
+	return errs.Err()
+}
+
+func (e Errors) has(field string) bool {
+	for _, f := range e {
+		if f.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+func checkStruct(v reflect.Value, prefix string, errs *Errors) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+		if sf.Anonymous {
+			checkStruct(fv, prefix, errs)
+			continue
+		}
+
+		name := join(prefix, fieldName(sf))
+		if tag := sf.Tag.Get("binding"); tag != "" && tag != "-" {
+			if msg := checkRules(fv, tag); msg != "" {
+				errs.Add(name, msg)
+				continue
+			}
+		}
+		checkNested(fv, name, errs)
+	}
+}
+
+func checkNested(v reflect.Value, name string, errs *Errors) {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		checkStruct(v, name, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			checkNested(v.Index(i), fmt.Sprintf("%s[%d]", name, i), errs)
+		}
+	}
+}
+
+// checkRules applies a comma separated rule list and returns the message of
+// the first rule that fails
+func checkRules(v reflect.Value, tag string) string {
+	rules := strings.Split(tag, ",")
+	for _, rule := range rules {
+		if rule == "omitempty" && v.IsZero() {
+			return ""
+		}
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			if contains(rules, "required") {
+				return "is required"
+			}
+			return ""
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		var msg string
+		switch name {
+		case "required":
+			if v.IsZero() {
+				msg = "is required"
+			}
+		case "email":
+			if _, err := mail.ParseAddress(v.String()); err != nil || strings.ContainsAny(v.String(), "<> ") {
+				msg = "must be a valid email address"
+			}
+		case "min":
+			msg = checkBound(v, param, true)
+		case "max":
+			msg = checkBound(v, param, false)
+		case "oneof":
+			options := strings.Fields(param)
+			if !contains(options, fmt.Sprint(v.Interface())) {
+				msg = "must be one of: " + strings.Join(options, ", ")
+			}
+		}
+		if msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func checkBound(v reflect.Value, param string, min bool) string {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		panic(fmt.Sprintf("validate: invalid bound %q", param))
+	}
+
+	var got float64
+	var format string
+	switch v.Kind() {
+	case reflect.String:
+		if min && limit == 1 && v.Len() == 0 {
+			return "must not be empty"
+		}
+		got, format = float64(len([]rune(v.String()))), "must be %s %s characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		got, format = float64(v.Len()), "must contain %s %s items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		got, format = float64(v.Int()), "must be %s %s"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		got, format = float64(v.Uint()), "must be %s %s"
+	case reflect.Float32, reflect.Float64:
+		got, format = v.Float(), "must be %s %s"
+	default:
+		return ""
+	}
+
+	if min && got < limit {
+		return fmt.Sprintf(format, "at least", param)
+	}
+	if !min && got > limit {
+		return fmt.Sprintf(format, "at most", param)
+	}
+	return ""
+}
+
+// fieldName returns the JSON name of a struct field, the name clients see
+func fieldName(sf reflect.StructField) string {
+	if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return sf.Name
+}
+
+func join(prefix, field string) string {
+	switch {
+	case prefix == "":
+		return field
+	case field == "":
+		return prefix
+	case strings.HasPrefix(field, "["):
+		return prefix + field
+	default:
+		return prefix + "." + field
+	}
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
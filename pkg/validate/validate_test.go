@@ -0,0 +1,118 @@
+package validate
+
+import (
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type line struct {
+	SKU      string `json:"sku" binding:"required"`
+	Quantity int    `json:"quantity" binding:"min=1,max=10"`
+}
+
+type request struct {
+	Email  string   `json:"email" binding:"required,email"`
+	Name   *string  `json:"name" binding:"omitempty,min=1"`
+	Status string   `json:"status" binding:"omitempty,oneof=open closed"`
+	Tags   []string `json:"tags" binding:"max=2"`
+	Lines  []line   `json:"lines" binding:"required"`
+	Note   string   `json:"note"`
+}
+
+func (r *request) Validate() error {
+	var errs Errors
+	if r.Note == "forbidden" {
+		errs.Add("note", "note is not allowed")
+	}
+	if r.Email == "taken@example.com" {
+		errs.Add("email", "already used")
+	}
+	return errs.Err()
+}
+
+func fields(t *testing.T, err error) []errors.FieldError {
+	t.Helper()
+	var e *errors.Error
+	require.True(t, stderrors.As(err, &e), "expected *errors.Error, got %v", err)
+	return e.Fields
+}
+
+func TestStruct(t *testing.T) {
+	empty := ""
+	valid := request{Email: "a@example.com", Lines: []line{{SKU: "A", Quantity: 1}}}
+
+	t.Run("valid", func(t *testing.T) {
+		assert.NoError(t, Struct(&valid))
+	})
+
+	t.Run("every failure at once", func(t *testing.T) {
+		req := request{
+			Email:  "nope",
+			Name:   &empty,
+			Status: "pending",
+			Tags:   []string{"a", "b", "c"},
+			Lines:  []line{{SKU: "A", Quantity: 1}, {Quantity: 11}},
+			Note:   "forbidden",
+		}
+
+		err := Struct(&req)
+		assert.ErrorIs(t, err, errors.ErrValidation)
+		assert.Equal(t, []errors.FieldError{
+			{Field: "email", Message: "must be a valid email address"},
+			{Field: "name", Message: "must not be empty"},
+			{Field: "status", Message: "must be one of: open, closed"},
+			{Field: "tags", Message: "must contain at most 2 items"},
+			{Field: "lines[1].sku", Message: "is required"},
+			{Field: "lines[1].quantity", Message: "must be at most 10"},
+			{Field: "note", Message: "note is not allowed"},
+		}, fields(t, err))
+	})
+
+	t.Run("tag failure is not repeated by Validate", func(t *testing.T) {
+		req := valid
+		req.Email = "taken@example.com"
+		assert.Equal(t, []errors.FieldError{{Field: "email", Message: "already used"}}, fields(t, Struct(&req)))
+
+		req.Email = ""
+		assert.Equal(t, []errors.FieldError{{Field: "email", Message: "is required"}}, fields(t, Struct(&req)))
+	})
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantFields []errors.FieldError
+	}{
+		{"valid", `{"email":"a@example.com","lines":[{"sku":"A","quantity":2}]}`, 0, nil},
+		{"empty body", ``, http.StatusBadRequest, nil},
+		{"malformed", `{"email":`, http.StatusBadRequest, nil},
+		{"unknown field", `{"email":"a@example.com","id":7}`, http.StatusBadRequest, []errors.FieldError{{Field: "id", Message: "unknown field"}}},
+		{"wrong type", `{"email":"a@example.com","lines":[{"sku":"A","quantity":"two"}]}`, http.StatusBadRequest, []errors.FieldError{{Field: "lines.0.quantity", Message: "must be of type int"}}},
+		{"trailing data", `{"email":"a@example.com","lines":[{"sku":"A","quantity":2}]} {}`, http.StatusBadRequest, nil},
+		{"rule failure", `{"email":"a@example.com"}`, http.StatusUnprocessableEntity, []errors.FieldError{{Field: "lines", Message: "is required"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+
+			var req request
+			err := Decode(r, &req)
+			if tt.wantStatus == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.wantStatus, errors.From(err).Code)
+			assert.Equal(t, tt.wantFields, errors.From(err).Fields)
+		})
+	}
+}
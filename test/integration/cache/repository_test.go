@@ -6,12 +6,18 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus"
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus/eventbustest"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_cache"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_message"
+	"github.com/Napat/golang-testcontainers-demo/pkg/metrics"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
 	"github.com/Napat/golang-testcontainers-demo/pkg/testhelper"
 	"github.com/Napat/golang-testcontainers-demo/test/integration"
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"github.com/stretchr/testify/suite"
 	"github.com/testcontainers/testcontainers-go"
@@ -135,3 +141,92 @@ func (s *CacheRepositoryTestSuite) TestSetAndGet() {
 	s.Equal(user.Username, fetchedUser.Username)
 	s.Equal(user.Email, fetchedUser.Email)
 }
+
+// TestDelete tests the Delete method of the CacheRepository.
+//
+// The test stores a value, deletes it and verifies that a subsequent Get
+// reports a cache miss. Deleting a missing key is not an error.
+func (s *CacheRepositoryTestSuite) TestDelete() {
+	ctx := context.Background()
+
+	s.Require().NoError(s.repo.Set(ctx, "user:2", &model.User{Username: "deleted"}, time.Minute))
+	s.Require().NoError(s.repo.Delete(ctx, "user:2"))
+
+	var fetchedUser model.User
+	err := s.repo.Get(ctx, "user:2", &fetchedUser)
+	s.ErrorIs(err, redis.Nil)
+
+	s.NoError(s.repo.Delete(ctx, "user:2"))
+}
+
+// TestEventBusContract runs the event bus contract against the Redis Streams
+// backend, each bus on a stream of its own.
+func (s *CacheRepositoryTestSuite) TestEventBusContract() {
+	eventbustest.Run(s.T(), func(t *testing.T) eventbus.Bus {
+		stream := "events-" + uuid.NewString()
+		return eventbus.NewRedisBus(s.client, stream, 1000, eventbus.DefaultClaimIdle, eventbus.Retry{MaxAttempts: 3, Backoff: 10 * time.Millisecond})
+	})
+}
+
+// TestEventBusCountsInvalidEntries tests that a stream entry that is not an
+// event is acknowledged and counted as a dead letter of the group.
+func (s *CacheRepositoryTestSuite) TestEventBusCountsInvalidEntries() {
+	ctx := context.Background()
+	stream := "events-" + uuid.NewString()
+	bus := eventbus.NewRedisBus(s.client, stream, 1000, eventbus.DefaultClaimIdle, eventbus.Retry{MaxAttempts: 3, Backoff: 10 * time.Millisecond})
+	defer bus.Close(ctx)
+
+	s.Require().NoError(bus.Subscribe("invalid", consumer.NewRegistry()))
+	s.Require().NoError(s.client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: map[string]interface{}{"event": "not json"}}).Err())
+
+	deadLetters := metrics.NewConsumerMetrics().DeadLettersTotal.WithLabelValues("invalid", stream, "invalid")
+	s.Eventually(func() bool { return testutil.ToFloat64(deadLetters) == 1 }, 5*time.Second, 10*time.Millisecond)
+	s.Eventually(func() bool {
+		pending, err := s.client.XPending(ctx, stream, "invalid").Result()
+		return err == nil && pending.Count == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// TestMessageSchedule tests that scheduled messages are claimed once due,
+// claimed again when their lease ends and released by Ack or Retry.
+func (s *CacheRepositoryTestSuite) TestMessageSchedule() {
+	ctx := context.Background()
+	schedule := repository_message.NewScheduleRepository(s.client)
+	now := time.Now().Truncate(time.Millisecond)
+
+	s.Require().NoError(schedule.Add(ctx, "due", now.Add(-time.Minute)))
+	s.Require().NoError(schedule.Add(ctx, "cancelled", now.Add(-time.Second)))
+	s.Require().NoError(schedule.Add(ctx, "later", now.Add(time.Hour)))
+	s.Require().NoError(schedule.Remove(ctx, "cancelled"))
+
+	backlog, err := schedule.Backlog(ctx, now)
+	s.Require().NoError(err)
+	s.Equal(model.MessageBacklog{Scheduled: 2, Due: 1, Lag: time.Minute}, backlog)
+
+	claims, err := schedule.Claim(ctx, now, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Equal([]model.MessageClaim{{ID: "due", Attempt: 1}}, claims)
+
+	// Held by its lease, then claimed again once it ends
+	claims, err = schedule.Claim(ctx, now, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Empty(claims)
+	claims, err = schedule.Claim(ctx, now.Add(2*time.Minute), 10, time.Minute)
+	s.Require().NoError(err)
+	s.Equal([]model.MessageClaim{{ID: "due", Attempt: 2}}, claims)
+
+	backlog, err = schedule.Backlog(ctx, now)
+	s.Require().NoError(err)
+	s.Equal(int64(1), backlog.InFlight)
+
+	// Retried with its attempts, then released
+	s.Require().NoError(schedule.Retry(ctx, "due", now.Add(5*time.Minute)))
+	claims, err = schedule.Claim(ctx, now.Add(5*time.Minute), 10, time.Minute)
+	s.Require().NoError(err)
+	s.Equal([]model.MessageClaim{{ID: "due", Attempt: 3}}, claims)
+	s.Require().NoError(schedule.Ack(ctx, "due"))
+
+	backlog, err = schedule.Backlog(ctx, now.Add(5*time.Minute))
+	s.Require().NoError(err)
+	s.Equal(model.MessageBacklog{Scheduled: 1}, backlog)
+}
@@ -0,0 +1,293 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/Napat/golang-testcontainers-demo/internal/consumer"
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus"
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus/eventbustest"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
+	"github.com/Napat/golang-testcontainers-demo/pkg/testhelper"
+	"github.com/Napat/golang-testcontainers-demo/test/integration"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/kafka"
+)
+
+const consumerTopic = "consumer-topic"
+
+type ConsumerTestSuite struct {
+	integration.BaseTestSuite
+	container testcontainers.Container
+	ctx       context.Context
+	brokers   []string
+	producer  sarama.SyncProducer
+	repo      *repository_event.ProducerRepository
+}
+
+// TestIntegrationConsumer runs the ConsumerTestSuite.
+//
+// It uses Testcontainers to spin up a Kafka container and tests the
+// consumer group against messages sent through the ProducerRepository.
+func TestIntegrationConsumer(t *testing.T) {
+	testhelper.SkipIfShort(t)
+	t.Parallel()
+	suite.Run(t, new(ConsumerTestSuite))
+}
+
+// SetupSuite starts a Kafka container, creates a topic with two partitions
+// and initializes the producer the tests publish with.
+func (s *ConsumerTestSuite) SetupSuite() {
+	s.BaseTestSuite.SetupSuite()
+
+	s.ctx = context.Background()
+	kafkaContainer, err := kafka.Run(s.ctx,
+		"confluentinc/cp-kafka:7.8.0",
+		kafka.WithClusterID("test-cluster"),
+	)
+	s.Require().NoError(err)
+	s.container = kafkaContainer
+
+	host, err := kafkaContainer.Host(s.ctx)
+	s.Require().NoError(err)
+	port, err := kafkaContainer.MappedPort(s.ctx, "9093")
+	s.Require().NoError(err)
+	s.brokers = []string{fmt.Sprintf("%s:%s", host, port.Port())}
+
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	producer, err := sarama.NewSyncProducer(s.brokers, config)
+	s.Require().NoError(err)
+	s.producer = producer
+
+	admin, err := sarama.NewClusterAdmin(s.brokers, config)
+	s.Require().NoError(err)
+	defer admin.Close()
+
+	err = admin.CreateTopic(consumerTopic, &sarama.TopicDetail{
+		NumPartitions:     2,
+		ReplicationFactor: 1,
+	}, false)
+	s.Require().NoError(err)
+
+	s.repo = repository_event.NewProducerRepository(producer, consumerTopic, event.Structured, repository_event.DefaultPublishTimeout)
+}
+
+// TearDownSuite closes the producer and terminates the Kafka container.
+func (s *ConsumerTestSuite) TearDownSuite() {
+	if s.producer != nil {
+		s.producer.Close()
+	}
+	if s.container != nil {
+		s.CleanupContainer(s.container)
+	}
+}
+
+// startConsumer joins groupID with registry, the caller stops it
+func (s *ConsumerTestSuite) startConsumer(groupID string, registry *consumer.Registry) *consumer.Consumer {
+	return s.startConsumerWithConfig(registry, consumer.Config{GroupID: groupID})
+}
+
+// startConsumerWithConfig joins config.GroupID on consumerTopic, the caller stops it
+func (s *ConsumerTestSuite) startConsumerWithConfig(registry *consumer.Registry, config consumer.Config) *consumer.Consumer {
+	group, err := sarama.NewConsumerGroup(s.brokers, config.GroupID, consumer.SaramaConfig("oldest"))
+	s.Require().NoError(err)
+
+	config.Topics = []string{consumerTopic}
+	config.MaxAttempts = 3
+	config.RetryBackoff = 10 * time.Millisecond
+	c := consumer.New(group, registry, config)
+	c.Start()
+	return c
+}
+
+// TestConsume sends typed events to both partitions and checks each one is
+// handled with its request id, then committed for the group.
+func (s *ConsumerTestSuite) TestConsume() {
+	const groupID = "consumer-test"
+
+	var (
+		mu       sync.Mutex
+		received = map[string]string{}
+		done     = make(chan struct{}, 16)
+	)
+	registry := consumer.NewRegistry()
+	registry.Handle(model.UserEventUpdated, consumer.Typed(func(ctx context.Context, payload model.UserChangedEvent) error {
+		mu.Lock()
+		received[payload.UserID.String()] = requestid.FromContext(ctx)
+		mu.Unlock()
+		done <- struct{}{}
+		return nil
+	}))
+
+	ids := make([]uuid.UUID, 4)
+	for i := range ids {
+		ids[i] = uuid.Must(uuid.NewV7())
+		ctx := requestid.NewContext(s.ctx, fmt.Sprintf("req-%d", i))
+		err := s.repo.SendMessage(ctx, "user:"+ids[i].String(), model.UserChangedEvent{Type: model.UserEventUpdated, UserID: ids[i]})
+		s.Require().NoError(err)
+	}
+	// Messages without an envelope are committed without a handler
+	_, _, err := s.producer.SendMessage(&sarama.ProducerMessage{Topic: consumerTopic, Value: sarama.StringEncoder(`{"content":"hello"}`)})
+	s.Require().NoError(err)
+
+	c := s.startConsumer(groupID, registry)
+	for range ids {
+		select {
+		case <-done:
+		case <-time.After(30 * time.Second):
+			s.FailNow("timed out waiting for messages")
+		}
+	}
+
+	stopCtx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+	defer cancel()
+	s.Require().NoError(c.Stop(stopCtx))
+
+	mu.Lock()
+	for i, id := range ids {
+		s.Equal(fmt.Sprintf("req-%d", i), received[id.String()])
+	}
+	mu.Unlock()
+
+	// The committed offsets of the group cover every message sent
+	admin, err := sarama.NewClusterAdmin(s.brokers, sarama.NewConfig())
+	s.Require().NoError(err)
+	defer admin.Close()
+
+	offsets, err := admin.ListConsumerGroupOffsets(groupID, map[string][]int32{consumerTopic: {0, 1}})
+	s.Require().NoError(err)
+
+	client, err := sarama.NewClient(s.brokers, sarama.NewConfig())
+	s.Require().NoError(err)
+	defer client.Close()
+
+	for _, partition := range []int32{0, 1} {
+		newest, err := client.GetOffset(consumerTopic, partition, sarama.OffsetNewest)
+		s.Require().NoError(err)
+		block := offsets.GetBlock(consumerTopic, partition)
+		s.Require().NotNil(block)
+		if newest > 0 {
+			s.Equal(newest, block.Offset, "partition %d", partition)
+		}
+	}
+}
+
+// TestDeadLetter fails a message on every tier, checks it ends up in the
+// dead-letter topic and is handled again after a replay.
+func (s *ConsumerTestSuite) TestDeadLetter() {
+	const groupID = "dead-letter-test"
+
+	var (
+		mu      sync.Mutex
+		healthy bool
+		calls   int
+		handled = make(chan struct{}, 1)
+	)
+	userID := uuid.Must(uuid.NewV7())
+	registry := consumer.NewRegistry()
+	registry.Handle(model.UserEventDeleted, consumer.Typed(func(ctx context.Context, payload model.UserChangedEvent) error {
+		if payload.UserID != userID {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if !healthy {
+			return fmt.Errorf("cache unavailable")
+		}
+		handled <- struct{}{}
+		return nil
+	}))
+
+	config := consumer.Config{
+		GroupID:     groupID,
+		Forwarder:   repository_event.NewFailureRepository(s.producer),
+		RetryDelays: []time.Duration{100 * time.Millisecond},
+	}
+	admin, err := sarama.NewClusterAdmin(s.brokers, sarama.NewConfig())
+	s.Require().NoError(err)
+	s.Require().NoError(repository_event.CreateTopics(admin, consumerTopic, config.FailureTopics()))
+	admin.Close()
+
+	client, err := sarama.NewClient(s.brokers, sarama.NewConfig())
+	s.Require().NoError(err)
+	defer client.Close()
+	deadLetters := repository_event.NewDeadLetterRepository(client, s.producer, consumerTopic)
+
+	err = s.repo.SendMessage(s.ctx, "user:"+userID.String(), model.UserChangedEvent{Type: model.UserEventDeleted, UserID: userID})
+	s.Require().NoError(err)
+
+	c := s.startConsumerWithConfig(registry, config)
+	defer func() {
+		stopCtx, cancel := context.WithTimeout(s.ctx, 10*time.Second)
+		defer cancel()
+		s.NoError(c.Stop(stopCtx))
+	}()
+
+	// Three attempts on the main topic and three on the retry tier
+	var letter *model.DeadLetter
+	s.Require().Eventually(func() bool {
+		letters, err := deadLetters.List(s.ctx, groupID, 10)
+		if err != nil || len(letters) == 0 {
+			return false
+		}
+		letter = letters[0]
+		return true
+	}, 30*time.Second, 200*time.Millisecond)
+
+	s.Equal(model.UserEventDeleted, letter.EventType)
+	s.Equal(consumerTopic, letter.OriginalTopic)
+	s.Equal(6, letter.Attempts)
+	s.Equal("cache unavailable", letter.Reason)
+	mu.Lock()
+	s.Equal(6, calls)
+	healthy = true
+	mu.Unlock()
+
+	s.Require().NoError(deadLetters.Replay(s.ctx, groupID, letter.ID))
+	select {
+	case <-handled:
+	case <-time.After(30 * time.Second):
+		s.FailNow("timed out waiting for the replayed message")
+	}
+
+	// A replayed message is settled and cannot be replayed twice
+	_, err = deadLetters.Get(s.ctx, groupID, letter.ID)
+	s.ErrorIs(err, errors.ErrNotFound)
+	s.ErrorIs(deadLetters.Replay(s.ctx, groupID, letter.ID), errors.ErrNotFound)
+
+	// Dead letters belong to the group that failed them
+	others, err := deadLetters.List(s.ctx, groupID+"-other", 10)
+	s.Require().NoError(err)
+	s.Empty(others)
+}
+
+// TestEventBusContract runs the event bus contract against the Kafka
+// backend, each bus on a topic of its own.
+func (s *ConsumerTestSuite) TestEventBusContract() {
+	eventbustest.Run(s.T(), func(t *testing.T) eventbus.Bus {
+		topic := "bus-" + uuid.NewString()
+		admin, err := sarama.NewClusterAdmin(s.brokers, sarama.NewConfig())
+		require.NoError(t, err)
+		defer admin.Close()
+		require.NoError(t, admin.CreateTopic(topic, &sarama.TopicDetail{NumPartitions: 2, ReplicationFactor: 1}, false))
+
+		producer := repository_event.NewProducerRepository(s.producer, topic, event.Binary, repository_event.DefaultPublishTimeout)
+		return eventbus.NewKafkaBus(s.brokers, producer, topic, "oldest", consumer.Config{
+			MaxAttempts:  3,
+			RetryBackoff: 10 * time.Millisecond,
+		})
+	})
+}
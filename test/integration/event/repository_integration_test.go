@@ -9,6 +9,7 @@ import (
 
 	"github.com/IBM/sarama"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_event"
+	"github.com/Napat/golang-testcontainers-demo/pkg/event"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
 	"github.com/Napat/golang-testcontainers-demo/pkg/testhelper"
 	"github.com/Napat/golang-testcontainers-demo/test/integration"
@@ -106,7 +107,7 @@ func (s *ProducerTestSuite) SetupSuite() {
 	s.Require().NoError(err)
 
 	// Initialize repository
-	s.repo = repository_event.NewProducerRepository(producer, "test-topic")
+	s.repo = repository_event.NewProducerRepository(producer, "test-topic", event.Binary, repository_event.DefaultPublishTimeout)
 }
 
 // TearDownSuite tears down the test environment for the ProducerTestSuite.
@@ -128,7 +129,9 @@ func (s *ProducerTestSuite) TearDownSuite() {
 
 // TestSendMessage tests the SendMessage method of the ProducerRepository.
 //
-// The test creates a sample User instance and uses SendMessage to send it as a message to the Kafka topic.
+// The test creates a sample User instance and uses SendMessage to send its
+// public representation as a user.created event to the Kafka topic. The
+// stored user itself is refused.
 func (s *ProducerTestSuite) TestSendMessage() {
 	// Test data
 	uuidV7 := uuid.Must(uuid.NewV7())
@@ -139,6 +142,9 @@ func (s *ProducerTestSuite) TestSendMessage() {
 	}
 
 	// Test sending message
-	err := s.repo.SendMessage(uuidV7.String(), user)
+	err := s.repo.SendMessage(s.ctx, "user:"+uuidV7.String(), user.ToResponse())
 	s.Require().NoError(err)
+
+	err = s.repo.SendMessage(s.ctx, "user:"+uuidV7.String(), user)
+	s.ErrorIs(err, event.ErrUnknownType)
 }
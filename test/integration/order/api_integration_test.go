@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus"
 	"github.com/Napat/golang-testcontainers-demo/internal/handler"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_order"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
@@ -40,8 +41,8 @@ func (s *OrderAPITestSuite) SetupSuite() {
 	s.SetupElasticsearch()
 	s.repo = repository_order.NewOrderRepository(s.ESClient)
 
-	// Create a new handler
-	orderHandler := handler.NewOrderHandler(s.repo)
+	// Create a new handler, its order events go to an in-process bus
+	orderHandler := handler.NewOrderHandler(s.repo, eventbus.NewMemoryBus(eventbus.Retry{}))
 
 	// Create a router that forwards all /api/v1/orders* requests to the order handler
 	router := http.NewServeMux()
@@ -10,6 +10,7 @@ import (
 
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_order"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
 	"github.com/Napat/golang-testcontainers-demo/pkg/testhelper"
 	"github.com/Napat/golang-testcontainers-demo/test/integration"
 	"github.com/stretchr/testify/suite"
@@ -112,3 +113,29 @@ func (s *OrderRepositoryTestSuite) TestSearchOrders() {
 	s.Require().Len(orders, 1)
 	s.Equal("order-002", orders[0].ID)
 }
+
+// TestListOrders tests that ListOrders pages through the index with
+// search_after, one order per page, newest first.
+func (s *OrderRepositoryTestSuite) TestListOrders() {
+	ctx := context.Background()
+
+	page := pagination.Request{Limit: 1, Sort: "created_at", Desc: true}
+	filter := model.OrderFilter{CustomerID: "cust-001"}
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		result, err := s.repo.ListOrders(ctx, page, filter)
+		s.Require().NoError(err)
+		s.Equal(int64(2), result.Total)
+		for _, order := range result.Data {
+			ids = append(ids, order.ID)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor, err = pagination.DecodeCursor(result.NextCursor)
+		s.Require().NoError(err)
+	}
+
+	s.Equal([]string{"order-002", "order-001"}, ids)
+}
@@ -8,8 +8,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_product"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
 	"github.com/Napat/golang-testcontainers-demo/pkg/testhelper"
 	"github.com/Napat/golang-testcontainers-demo/test/integration"
 	_ "github.com/lib/pq"
@@ -56,7 +59,10 @@ func (s *ProductRepositoryTestSuite) SetupSuite() {
 
 	postgresContainer, err := postgres.Run(s.ctx,
 		"postgres:14-alpine",
-		postgres.WithInitScripts(filepath.Join("testdata", "000001_create_products_table.up.sql")),
+		postgres.WithInitScripts(
+			filepath.Join("testdata", "000001_create_products_table.up.sql"),
+			filepath.Join("testdata", "000002_add_products_deleted_at.up.sql"),
+		),
 		postgres.WithDatabase("testdb"),
 		postgres.WithUsername("test"),
 		postgres.WithPassword("test"),
@@ -145,3 +151,170 @@ func (s *ProductRepositoryTestSuite) TestCreateAndGetProduct() {
 	s.Equal(testProduct.Price, fetchedProduct.Price)
 	s.Equal(testProduct.Stock, fetchedProduct.Stock)
 }
+
+// TestCreateDuplicateSKU tests that a unique violation is reported as a
+// conflict on the sku field rather than a raw driver error
+func (s *ProductRepositoryTestSuite) TestCreateDuplicateSKU() {
+	ctx := context.Background()
+
+	s.Require().NoError(s.repo.Create(ctx, &model.Product{Name: "Original", Price: 1, SKU: "DUP-001"}))
+
+	err := s.repo.Create(ctx, &model.Product{Name: "Copy", Price: 1, SKU: "DUP-001"})
+	s.Require().ErrorIs(err, errors.ErrConflict)
+	s.Equal("sku", errors.From(err).Field)
+}
+
+// TestOptimisticConcurrency tests the compare-and-swap Update and Delete
+// methods of the ProductRepository.
+//
+// The method:
+// - Updates a product and verifies that its version is incremented
+// - Retries the update with the stale version and expects a VersionConflictError
+// - Deletes with the stale version (conflict) and then with the current version
+func (s *ProductRepositoryTestSuite) TestOptimisticConcurrency() {
+	ctx := context.Background()
+
+	product := &model.Product{
+		Name:  "Versioned Product",
+		Price: 10,
+		SKU:   "VERSION-001",
+		Stock: 5,
+	}
+	s.Require().NoError(s.repo.Create(ctx, product))
+	s.Equal(1, product.Version)
+
+	stale := *product
+
+	product.Stock = 4
+	s.Require().NoError(s.repo.Update(ctx, product))
+	s.Equal(2, product.Version)
+
+	stale.Stock = 3
+	err := s.repo.Update(ctx, &stale)
+	var conflict *repository.VersionConflictError
+	s.Require().ErrorAs(err, &conflict)
+	s.Equal(1, conflict.Expected)
+	s.Equal(2, conflict.Current)
+
+	s.ErrorIs(s.repo.Delete(ctx, product.ID, 1), repository.ErrVersionConflict)
+	s.Require().NoError(s.repo.Delete(ctx, product.ID, product.Version))
+	s.ErrorIs(s.repo.Delete(ctx, product.ID, product.Version), repository_product.ErrProductNotFound)
+}
+
+// TestListPagination tests the keyset pagination of the List method.
+//
+// The method:
+// - Creates products that share a price so the id tie-breaker is exercised
+// - Walks them two at a time sorted by price within a price range
+// - Verifies that no product is skipped or repeated across pages
+func (s *ProductRepositoryTestSuite) TestListPagination() {
+	ctx := context.Background()
+
+	prices := []float64{1000.50, 1000.50, 1000.50, 1001, 1002}
+	for i, price := range prices {
+		s.Require().NoError(s.repo.Create(ctx, &model.Product{
+			Name:  fmt.Sprintf("Paged Product %d", i),
+			Price: price,
+			SKU:   fmt.Sprintf("PAGE-%03d", i),
+			Stock: i,
+		}))
+	}
+
+	minPrice, maxPrice := 1000.0, 1001.5
+	filter := model.ProductFilter{MinPrice: &minPrice, MaxPrice: &maxPrice}
+	page := pagination.Request{Limit: 2, Sort: "price"}
+
+	var skus []string
+	for {
+		result, err := s.repo.List(ctx, page, filter)
+		s.Require().NoError(err)
+		s.Equal(int64(4), result.Total)
+		for _, product := range result.Data {
+			skus = append(skus, product.SKU)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor, err = pagination.DecodeCursor(result.NextCursor)
+		s.Require().NoError(err)
+	}
+
+	s.Equal([]string{"PAGE-000", "PAGE-001", "PAGE-002", "PAGE-003"}, skus)
+}
+
+// TestSoftDeleteRestoreAndPurge tests the soft delete lifecycle.
+//
+// The method:
+// - Deletes a product and verifies it is hidden from lookups and listed as deleted
+// - Restores it with a new version and refuses to purge it while it is live
+// - Deletes it again and purges it permanently
+func (s *ProductRepositoryTestSuite) TestSoftDeleteRestoreAndPurge() {
+	ctx := context.Background()
+
+	product := &model.Product{
+		Name:  "Restorable Product",
+		Price: 15,
+		SKU:   "RESTORE-001",
+		Stock: 2,
+	}
+	s.Require().NoError(s.repo.Create(ctx, product))
+	s.Require().NoError(s.repo.Delete(ctx, product.ID, product.Version))
+
+	_, err := s.repo.GetByID(ctx, product.ID)
+	s.ErrorIs(err, repository_product.ErrProductNotFound)
+
+	deleted, err := s.repo.List(ctx, pagination.Request{Limit: 10, Sort: "id"}, model.ProductFilter{Deleted: true})
+	s.Require().NoError(err)
+	s.Require().NotEmpty(deleted.Data)
+	for _, p := range deleted.Data {
+		s.NotNil(p.DeletedAt)
+	}
+
+	s.Require().NoError(s.repo.Restore(ctx, product.ID))
+	restored, err := s.repo.GetByID(ctx, product.ID)
+	s.Require().NoError(err)
+	s.Nil(restored.DeletedAt)
+	s.Equal(3, restored.Version)
+
+	s.ErrorIs(s.repo.Restore(ctx, product.ID), repository.ErrNotDeleted)
+	s.ErrorIs(s.repo.Purge(ctx, product.ID), repository.ErrNotDeleted)
+
+	s.Require().NoError(s.repo.Delete(ctx, restored.ID, restored.Version))
+	s.Require().NoError(s.repo.Purge(ctx, product.ID))
+	s.ErrorIs(s.repo.Purge(ctx, product.ID), repository_product.ErrProductNotFound)
+}
+
+// TestPurgeDeletedOlderThan tests that the retention purge only removes
+// products deleted before the cutoff and honours the batch limit
+func (s *ProductRepositoryTestSuite) TestPurgeDeletedOlderThan() {
+	ctx := context.Background()
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		product := &model.Product{
+			Name:  fmt.Sprintf("Expiring Product %d", i),
+			Price: 5,
+			SKU:   fmt.Sprintf("EXPIRE-%03d", i),
+			Stock: 1,
+		}
+		s.Require().NoError(s.repo.Create(ctx, product))
+		s.Require().NoError(s.repo.Delete(ctx, product.ID, product.Version))
+		ids = append(ids, product.ID)
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE products SET deleted_at = NOW() - INTERVAL '40 days' WHERE id IN ($1, $2)", ids[0], ids[1])
+	s.Require().NoError(err)
+
+	age := 30 * 24 * time.Hour
+	purged, err := s.repo.PurgeDeletedOlderThan(ctx, age, 1)
+	s.Require().NoError(err)
+	s.Equal(int64(1), purged)
+
+	purged, err = s.repo.PurgeDeletedOlderThan(ctx, age, 10)
+	s.Require().NoError(err)
+	s.Equal(int64(1), purged)
+
+	s.NoError(s.repo.Restore(ctx, ids[2]))
+	s.ErrorIs(s.repo.Restore(ctx, ids[0]), repository_product.ErrProductNotFound)
+}
@@ -0,0 +1,5 @@
+-- เพิ่มคอลัมน์ deleted_at สำหรับ soft delete (NULL = ยังไม่ถูกลบ)
+ALTER TABLE products ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL;
+
+-- index เฉพาะแถวที่ถูกลบ ใช้กับ admin listing และ retention purge
+CREATE INDEX idx_products_deleted_at ON products(deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,160 @@
+package user
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/Napat/golang-testcontainers-demo/internal/config"
+	"github.com/Napat/golang-testcontainers-demo/internal/eventbus"
+	"github.com/Napat/golang-testcontainers-demo/internal/handler"
+	"github.com/Napat/golang-testcontainers-demo/internal/handler/health"
+	"github.com/Napat/golang-testcontainers-demo/internal/router"
+	"github.com/Napat/golang-testcontainers-demo/pkg/auth"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/routes"
+	"github.com/go-redis/redis/v8"
+)
+
+// noRoutes stands in for the handlers an API test does not exercise
+type noRoutes struct{}
+
+func (noRoutes) GetRoutes() []routes.Route { return nil }
+
+// memoryCache is a CacheRepository that misses until a value is set
+type memoryCache struct {
+	mu     sync.Mutex
+	values map[string][]byte
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string, value interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.values[key]
+	if !ok {
+		return redis.Nil
+	}
+	return json.Unmarshal(data, value)
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = map[string][]byte{}
+	}
+	c.values[key] = data
+	return nil
+}
+
+func (c *memoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.values, key)
+	return nil
+}
+
+// memoryRevocations is a RevocationStore kept in memory
+type memoryRevocations struct {
+	mu      sync.Mutex
+	revoked map[string]bool
+}
+
+func (m *memoryRevocations) Revoke(ctx context.Context, tokenID string, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.revoked == nil {
+		m.revoked = map[string]bool{}
+	}
+	if m.revoked[tokenID] {
+		return false, nil
+	}
+	m.revoked[tokenID] = true
+	return true, nil
+}
+
+func (m *memoryRevocations) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.revoked[tokenID], nil
+}
+
+// apiRouter builds the API router over the suite database with the default
+// role of the migrations
+func (s *UserRepositoryTestSuite) apiRouter() http.Handler {
+	tokens := auth.NewTokenManager(auth.Config{
+		Secret:     []byte("integration-test-secret-0123456789"),
+		Issuer:     "integration-test",
+		AccessTTL:  time.Minute,
+		RefreshTTL: time.Hour,
+	}, &memoryRevocations{})
+
+	api, err := router.Setup(
+		handler.NewUserHandler(s.repo, &memoryCache{}, eventbus.NewMemoryBus(eventbus.Retry{})),
+		noRoutes{}, noRoutes{}, noRoutes{},
+		handler.NewAuthHandler(s.repo, tokens),
+		noRoutes{}, noRoutes{}, noRoutes{},
+		health.NewHealthHandler(nil, nil, nil, nil, nil),
+		tokens,
+		authz.NewEngine(s.roleRepo, "user"),
+		&config.Config{},
+	)
+	s.Require().NoError(err)
+	return api
+}
+
+func (s *UserRepositoryTestSuite) do(api http.Handler, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		s.Require().NoError(json.NewEncoder(&payload).Encode(body))
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestRegisteredUserCannotReadUsers tests that a user who registered through
+// the public POST /users, and so only has the default role, cannot list or
+// read other accounts.
+func (s *UserRepositoryTestSuite) TestRegisteredUserCannotReadUsers() {
+	api := s.apiRouter()
+
+	rec := s.do(api, http.MethodPost, "/api/v1/users", "", map[string]string{
+		"username":  "newcomer",
+		"email":     "newcomer@example.com",
+		"full_name": "New Comer",
+		"password":  "password123",
+	})
+	s.Require().Equal(http.StatusCreated, rec.Code, rec.Body.String())
+	var created struct {
+		ID string `json:"id"`
+	}
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &created))
+
+	rec = s.do(api, http.MethodPost, "/api/v1/auth/login", "", map[string]string{
+		"username": "newcomer",
+		"password": "password123",
+	})
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	var tokens auth.TokenPair
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &tokens))
+
+	rec = s.do(api, http.MethodGet, "/api/v1/users", tokens.AccessToken, nil)
+	s.Equal(http.StatusForbidden, rec.Code, rec.Body.String())
+
+	rec = s.do(api, http.MethodGet, "/api/v1/users/"+created.ID, tokens.AccessToken, nil)
+	s.Equal(http.StatusForbidden, rec.Code, rec.Body.String())
+}
@@ -3,27 +3,52 @@ package user
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/Napat/golang-testcontainers-demo/internal/repository"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_message"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_outbox"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_role"
 	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_user"
+	"github.com/Napat/golang-testcontainers-demo/internal/repository/repository_webhook"
+	"github.com/Napat/golang-testcontainers-demo/pkg/authz"
+	"github.com/Napat/golang-testcontainers-demo/pkg/errors"
 	"github.com/Napat/golang-testcontainers-demo/pkg/model"
+	"github.com/Napat/golang-testcontainers-demo/pkg/pagination"
+	"github.com/Napat/golang-testcontainers-demo/pkg/password"
+	"github.com/Napat/golang-testcontainers-demo/pkg/requestid"
 	"github.com/Napat/golang-testcontainers-demo/pkg/testhelper"
 	"github.com/Napat/golang-testcontainers-demo/test/integration"
 	_ "github.com/go-sql-driver/mysql" // Add MySQL driver
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/suite"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/mysql"
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
+// testPasswordHash is the stored form of "password123", Create refuses plaintext
+var testPasswordHash = func() string {
+	hash, err := password.Default().Hash("password123")
+	if err != nil {
+		panic(err)
+	}
+	return hash
+}()
+
 type UserRepositoryTestSuite struct {
 	integration.BaseTestSuite
-	container testcontainers.Container
-	db        *sql.DB
-	repo      *repository_user.UserRepository
+	container   testcontainers.Container
+	db          *sql.DB
+	repo        *repository_user.UserRepository
+	roleRepo    *repository_role.RoleRepository
+	outboxRepo  *repository_outbox.OutboxRepository
+	webhookRepo *repository_webhook.WebhookRepository
+	messageRepo *repository_message.MessageRepository
 }
 
 // TestIntegrationUserRepository runs the UserRepositoryTestSuite.
@@ -45,7 +70,20 @@ func (s *UserRepositoryTestSuite) SetupSuite() {
 
 	mysqlContainer, err := mysql.Run(ctx,
 		"mysql:8",
-		mysql.WithScripts(filepath.Join("testdata", "000002_alter_users_uuid.up.sql")),
+		mysql.WithScripts(
+			filepath.Join("testdata", "000002_alter_users_uuid.up.sql"),
+			filepath.Join("testdata", "000005_create_rbac_tables.up.sql"),
+			filepath.Join("testdata", "000006_add_deleted_user_status.up.sql"),
+			filepath.Join("testdata", "000007_add_users_deleted_at.up.sql"),
+			filepath.Join("testdata", "000008_create_outbox_table.up.sql"),
+			filepath.Join("testdata", "000009_add_outbox_trace_context.up.sql"),
+			filepath.Join("testdata", "000010_create_webhook_tables.up.sql"),
+			filepath.Join("testdata", "000011_create_messages_table.up.sql"),
+			filepath.Join("testdata", "000012_add_message_schedule.up.sql"),
+			filepath.Join("testdata", "000013_revoke_default_users_read.up.sql"),
+			filepath.Join("testdata", "000014_add_message_sender.up.sql"),
+			filepath.Join("testdata", "000015_add_outbox_parked.up.sql"),
+		),
 		mysql.WithDatabase("testdb"),
 		mysql.WithUsername("test"),
 		mysql.WithPassword("test"),
@@ -88,6 +126,10 @@ func (s *UserRepositoryTestSuite) SetupSuite() {
 
 	// Initialize repository
 	s.repo = repository_user.NewUserRepository(db)
+	s.roleRepo = repository_role.NewRoleRepository(db)
+	s.outboxRepo = repository_outbox.NewOutboxRepository(db)
+	s.webhookRepo = repository_webhook.NewWebhookRepository(db)
+	s.messageRepo = repository_message.NewMessageRepository(db)
 }
 
 // TearDownSuite tears down the test environment for the UserRepositoryTestSuite.
@@ -120,8 +162,8 @@ func (s *UserRepositoryTestSuite) TestCreateAndGetUser() {
 	testUser := &model.User{
 		Username: "testuser",
 		Email:    "test@example.com",
-		FullName: "Test User",   // Make sure this is set
-		Password: "password123", // Set password directly
+		FullName: "Test User", // Make sure this is set
+		Password: testPasswordHash,
 		Status:   model.StatusActive,
 	}
 
@@ -134,9 +176,589 @@ func (s *UserRepositoryTestSuite) TestCreateAndGetUser() {
 	s.Equal(testUser.Username, fetchedUser.Username)
 	s.Equal(testUser.Email, fetchedUser.Email)
 	s.Equal(testUser.FullName, fetchedUser.FullName)
-	s.Equal(testUser.Password, fetchedUser.Password) // Changed from PasswordHash to Password
+	s.Equal(testUser.Password, fetchedUser.Password)
+	s.True(fetchedUser.CheckPassword("password123"))
 	s.Equal(testUser.Status, fetchedUser.Status)
 	s.NotZero(fetchedUser.CreatedAt)
 	s.NotZero(fetchedUser.UpdatedAt)
 	s.Equal(1, fetchedUser.Version)
+
+	// Plaintext is refused rather than stored
+	s.Error(s.repo.Create(ctx, &model.User{Username: "plainuser", Email: "plain@example.com", FullName: "Plain", Password: "password123"}))
+}
+
+// TestCreateDuplicate tests that creating a user with a taken username or
+// email fails with a conflict naming the field
+func (s *UserRepositoryTestSuite) TestCreateDuplicate() {
+	ctx := context.Background()
+
+	s.Require().NoError(s.repo.Create(ctx, &model.User{
+		Username: "dupuser",
+		Email:    "dup@example.com",
+		FullName: "Dup User",
+		Password: testPasswordHash,
+	}))
+
+	err := s.repo.Create(ctx, &model.User{Username: "dupuser", Email: "other@example.com", FullName: "Other", Password: testPasswordHash})
+	s.Require().ErrorIs(err, errors.ErrConflict)
+	s.Equal("username", errors.From(err).Field)
+
+	err = s.repo.Create(ctx, &model.User{Username: "otheruser", Email: "dup@example.com", FullName: "Other", Password: testPasswordHash})
+	s.Require().ErrorIs(err, errors.ErrConflict)
+	s.Equal("email", errors.From(err).Field)
+}
+
+// TestCreateWritesOutbox tests that Create records a user.created event in
+// the outbox, that a failed create records nothing, and the lifecycle of the
+// message through the relay's store methods.
+func (s *UserRepositoryTestSuite) TestCreateWritesOutbox() {
+	ctx := requestid.NewContext(context.Background(), "req-outbox")
+
+	user := &model.User{
+		Username: "outboxuser",
+		Email:    "outbox@example.com",
+		FullName: "Outbox User",
+		Password: testPasswordHash,
+	}
+	s.Require().NoError(s.repo.Create(ctx, user))
+	s.Require().Error(s.repo.Create(ctx, &model.User{Username: "outboxuser", Email: "other@example.com", FullName: "Other", Password: testPasswordHash}))
+
+	findPending := func() []*model.OutboxMessage {
+		pending, err := s.outboxRepo.Pending(ctx, 1000)
+		s.Require().NoError(err)
+		var found []*model.OutboxMessage
+		for _, msg := range pending {
+			if msg.AggregateID == user.ID.String() {
+				found = append(found, msg)
+			}
+		}
+		return found
+	}
+
+	found := findPending()
+	s.Require().Len(found, 1)
+	msg := found[0]
+	s.Equal("user", msg.AggregateType)
+	s.Equal(model.UserEventCreated, msg.EventType)
+	s.Equal("user:"+user.ID.String(), msg.Key)
+	s.Equal("req-outbox", msg.RequestID)
+	var payload model.UserResponse
+	s.Require().NoError(json.Unmarshal(msg.Payload, &payload))
+	s.Equal(user.ID, payload.ID)
+	s.Equal("outboxuser", payload.Username)
+	s.NotContains(string(msg.Payload), "password")
+
+	// A later message of the same aggregate waits behind the first one
+	tx, err := s.db.BeginTx(ctx, nil)
+	s.Require().NoError(err)
+	later := &model.OutboxMessage{AggregateType: "user", AggregateID: user.ID.String(), EventType: model.UserEventUpdated, Key: msg.Key, Payload: []byte(`{}`)}
+	s.Require().NoError(repository_outbox.Insert(ctx, tx, later))
+	s.Require().NoError(tx.Commit())
+	s.Require().Len(findPending(), 1)
+
+	// A message waiting for its retry is not due and holds back its aggregate
+	s.Require().NoError(s.outboxRepo.MarkFailed(ctx, msg.ID, fmt.Errorf("broker unavailable"), time.Minute))
+	s.Empty(findPending())
+	var attempts int
+	s.Require().NoError(s.db.QueryRowContext(ctx, "SELECT attempts FROM outbox WHERE id = ?", msg.ID).Scan(&attempts))
+	s.Equal(1, attempts)
+
+	pending, lag, err := s.outboxRepo.Stats(ctx)
+	s.Require().NoError(err)
+	s.Positive(pending)
+	s.GreaterOrEqual(lag, time.Duration(0))
+
+	// A parked message no longer holds back the later one
+	s.Require().NoError(s.outboxRepo.Park(ctx, msg.ID, fmt.Errorf("broker unavailable")))
+	found = findPending()
+	s.Require().Len(found, 1)
+	s.Equal(later.ID, found[0].ID)
+
+	s.Require().NoError(s.outboxRepo.MarkPublished(ctx, later.ID))
+	s.Empty(findPending())
+}
+
+// TestRoleAssignment tests the RoleRepository against the seeded roles.
+//
+// The test verifies that a new user has no role permissions, that assigning
+// the seeded admin role grants the wildcard permission, and that assigning an
+// unknown role fails with ErrRoleNotFound.
+func (s *UserRepositoryTestSuite) TestRoleAssignment() {
+	ctx := context.Background()
+
+	testUser := &model.User{
+		Username: "roleuser",
+		Email:    "role@example.com",
+		FullName: "Role User",
+		Password: testPasswordHash,
+	}
+	s.Require().NoError(s.repo.Create(ctx, testUser))
+
+	permissions, err := s.roleRepo.PermissionsForUser(ctx, testUser.ID)
+	s.Require().NoError(err)
+	s.Empty(permissions)
+
+	defaults, err := s.roleRepo.PermissionsForRole(ctx, "user")
+	s.Require().NoError(err)
+	s.NotContains(defaults, authz.UsersRead, "anyone can register, so the default role cannot read other users")
+	s.NotContains(defaults, authz.UsersWrite)
+
+	s.Require().NoError(s.roleRepo.AssignRole(ctx, testUser.ID, "admin"))
+	s.Require().NoError(s.roleRepo.AssignRole(ctx, testUser.ID, "admin")) // idempotent
+
+	permissions, err = s.roleRepo.PermissionsForUser(ctx, testUser.ID)
+	s.Require().NoError(err)
+	s.Equal([]string{"*"}, permissions)
+
+	s.ErrorIs(s.roleRepo.AssignRole(ctx, testUser.ID, "no-such-role"), repository_role.ErrRoleNotFound)
+
+	s.Require().NoError(s.roleRepo.RevokeRole(ctx, testUser.ID, "admin"))
+	permissions, err = s.roleRepo.PermissionsForUser(ctx, testUser.ID)
+	s.Require().NoError(err)
+	s.Empty(permissions)
+}
+
+// TestUpdateStatusToDeleted tests that storing the deleted status through
+// Update soft deletes the user: it disappears from lookups and only shows up
+// in the deleted listing, with deleted_at set.
+func (s *UserRepositoryTestSuite) TestUpdateStatusToDeleted() {
+	ctx := context.Background()
+
+	testUser := &model.User{
+		Username: "deleteduser",
+		Email:    "deleted@example.com",
+		FullName: "Deleted User",
+		Password: testPasswordHash,
+	}
+	s.Require().NoError(s.repo.Create(ctx, testUser))
+
+	s.Require().NoError(testUser.TransitionTo(model.StatusDeleted))
+	s.Require().NoError(s.repo.Update(ctx, testUser))
+
+	_, err := s.repo.GetByID(ctx, testUser.ID)
+	s.ErrorIs(err, repository_user.ErrUserNotFound)
+
+	deleted, err := s.repo.List(ctx, pagination.Request{Limit: 10, Sort: "id"},
+		model.UserFilter{EmailDomain: "example.com", Deleted: true})
+	s.Require().NoError(err)
+	s.Require().NotEmpty(deleted.Data)
+	for _, user := range deleted.Data {
+		s.Equal(model.StatusDeleted, user.Status)
+		s.NotNil(user.DeletedAt)
+	}
+}
+
+// TestSoftDeleteRestoreAndPurge tests the soft delete lifecycle.
+//
+// The test:
+// - Deletes a user and verifies it is hidden but can still be restored, as inactive
+// - Refuses to purge a live user
+// - Deletes the user again and purges it permanently
+func (s *UserRepositoryTestSuite) TestSoftDeleteRestoreAndPurge() {
+	ctx := context.Background()
+
+	testUser := &model.User{
+		Username: "restoreuser",
+		Email:    "restore@example.com",
+		FullName: "Restore User",
+		Password: testPasswordHash,
+	}
+	s.Require().NoError(s.repo.Create(ctx, testUser))
+
+	s.Require().NoError(s.repo.Delete(ctx, testUser.ID))
+	_, err := s.repo.GetByUsername(ctx, "restoreuser")
+	s.ErrorIs(err, repository_user.ErrUserNotFound)
+
+	s.Require().NoError(s.repo.Restore(ctx, testUser.ID))
+	restored, err := s.repo.GetByID(ctx, testUser.ID)
+	s.Require().NoError(err)
+	s.Equal(model.StatusInactive, restored.Status)
+	s.Nil(restored.DeletedAt)
+	s.Greater(restored.Version, testUser.Version)
+
+	s.ErrorIs(s.repo.Restore(ctx, testUser.ID), repository.ErrNotDeleted)
+	s.ErrorIs(s.repo.Purge(ctx, testUser.ID), repository.ErrNotDeleted)
+
+	s.Require().NoError(s.repo.Delete(ctx, testUser.ID))
+	s.Require().NoError(s.repo.Purge(ctx, testUser.ID))
+	s.ErrorIs(s.repo.Restore(ctx, testUser.ID), repository_user.ErrUserNotFound)
+}
+
+// TestPurgeDeletedOlderThan tests that the retention purge only removes users
+// deleted before the cutoff and honours the batch limit.
+func (s *UserRepositoryTestSuite) TestPurgeDeletedOlderThan() {
+	ctx := context.Background()
+
+	var ids []uuid.UUID
+	for _, name := range []string{"expireduser1", "expireduser2", "recentuser"} {
+		user := &model.User{
+			Username: name,
+			Email:    name + "@retention.example",
+			FullName: "Retention User",
+			Password: testPasswordHash,
+		}
+		s.Require().NoError(s.repo.Create(ctx, user))
+		s.Require().NoError(s.repo.Delete(ctx, user.ID))
+		ids = append(ids, user.ID)
+	}
+
+	_, err := s.db.ExecContext(ctx,
+		"UPDATE users SET deleted_at = NOW() - INTERVAL 40 DAY WHERE id IN (?, ?)", ids[0], ids[1])
+	s.Require().NoError(err)
+
+	age := 30 * 24 * time.Hour
+	purged, err := s.repo.PurgeDeletedOlderThan(ctx, age, 1)
+	s.Require().NoError(err)
+	s.Equal(int64(1), purged)
+
+	purged, err = s.repo.PurgeDeletedOlderThan(ctx, age, 10)
+	s.Require().NoError(err)
+	s.Equal(int64(1), purged)
+
+	s.NoError(s.repo.Restore(ctx, ids[2]))
+	s.ErrorIs(s.repo.Restore(ctx, ids[0]), repository_user.ErrUserNotFound)
+}
+
+// TestUpdateVersionConflict tests that Update is a compare-and-swap on the
+// version column. An update carrying a stale version must fail with a
+// VersionConflictError and leave the stored row untouched.
+func (s *UserRepositoryTestSuite) TestUpdateVersionConflict() {
+	ctx := context.Background()
+
+	testUser := &model.User{
+		Username: "versionuser",
+		Email:    "version@example.com",
+		FullName: "Version User",
+		Password: testPasswordHash,
+	}
+	s.Require().NoError(s.repo.Create(ctx, testUser))
+	s.Equal(1, testUser.Version)
+
+	stale := *testUser
+
+	testUser.FullName = "First Writer"
+	s.Require().NoError(s.repo.Update(ctx, testUser))
+	s.Equal(2, testUser.Version)
+
+	stale.FullName = "Second Writer"
+	err := s.repo.Update(ctx, &stale)
+	s.ErrorIs(err, repository.ErrVersionConflict)
+
+	fetchedUser, err := s.repo.GetByID(ctx, testUser.ID)
+	s.Require().NoError(err)
+	s.Equal("First Writer", fetchedUser.FullName)
+	s.Equal(2, fetchedUser.Version)
+}
+
+// TestListPagination tests the keyset pagination of the List method.
+//
+// The test creates five users in their own email domain and walks them two
+// at a time sorted by username descending, verifying that every page reports
+// the filtered total and that the last page has no next cursor.
+func (s *UserRepositoryTestSuite) TestListPagination() {
+	ctx := context.Background()
+
+	for _, name := range []string{"pageuser1", "pageuser2", "pageuser3", "pageuser4", "pageuser5"} {
+		s.Require().NoError(s.repo.Create(ctx, &model.User{
+			Username: name,
+			Email:    name + "@paging.example",
+			FullName: "Paging User",
+			Password: testPasswordHash,
+		}))
+	}
+
+	filter := model.UserFilter{EmailDomain: "paging.example"}
+	page := pagination.Request{Limit: 2, Sort: "username", Desc: true}
+
+	var usernames []string
+	for i := 0; i < 3; i++ {
+		result, err := s.repo.List(ctx, page, filter)
+		s.Require().NoError(err)
+		s.Equal(int64(5), result.Total)
+		for _, user := range result.Data {
+			usernames = append(usernames, user.Username)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		page.Cursor, err = pagination.DecodeCursor(result.NextCursor)
+		s.Require().NoError(err)
+	}
+
+	s.Equal([]string{"pageuser5", "pageuser4", "pageuser3", "pageuser2", "pageuser1"}, usernames)
+
+	// The domain matches exactly, LIKE wildcards match nothing
+	for _, domain := range []string{"%", "paging_example", "%.example", "example"} {
+		result, err := s.repo.List(ctx, pagination.Request{Limit: 10, Sort: "id"}, model.UserFilter{EmailDomain: domain})
+		s.Require().NoError(err)
+		s.Zero(result.Total, domain)
+	}
+}
+
+// TestWebhookSubscriptions tests storing webhook subscriptions: the event
+// filters survive the JSON column, Update is a compare-and-swap on the
+// version, and Delete removes the subscription for good.
+func (s *UserRepositoryTestSuite) TestWebhookSubscriptions() {
+	ctx := context.Background()
+
+	subscription := &model.WebhookSubscription{
+		URL:    "https://partner.example.com/hooks",
+		Secret: "0123456789abcdef",
+		Events: []string{"user.*", model.OrderEventCreated},
+		Active: true,
+	}
+	s.Require().NoError(s.webhookRepo.Create(ctx, subscription))
+	s.NotZero(subscription.ID)
+	s.Equal(1, subscription.Version)
+
+	fetched, err := s.webhookRepo.GetByID(ctx, subscription.ID)
+	s.Require().NoError(err)
+	s.Equal(subscription.Events, fetched.Events)
+	s.Equal(subscription.Secret, fetched.Secret)
+	s.True(fetched.Active)
+
+	stale := *fetched
+	fetched.Description = "First Writer"
+	s.Require().NoError(s.webhookRepo.Update(ctx, fetched))
+	s.Equal(2, fetched.Version)
+
+	stale.Description = "Second Writer"
+	s.ErrorIs(s.webhookRepo.Update(ctx, &stale), repository.ErrVersionConflict)
+
+	page, err := s.webhookRepo.List(ctx, pagination.Request{Limit: 10, Sort: "id"})
+	s.Require().NoError(err)
+	s.NotEmpty(page.Data)
+
+	s.Require().NoError(s.webhookRepo.Delete(ctx, subscription.ID))
+	_, err = s.webhookRepo.GetByID(ctx, subscription.ID)
+	s.ErrorIs(err, repository_webhook.ErrWebhookNotFound)
+	s.ErrorIs(s.webhookRepo.Delete(ctx, subscription.ID), repository_webhook.ErrWebhookNotFound)
+}
+
+// TestWebhookDeliveries tests the delivery queue: an event is queued once per
+// subscription however often it is enqueued, a claimed delivery is held back
+// for the lease, and recorded attempts retry, settle and eventually disable
+// the subscription.
+func (s *UserRepositoryTestSuite) TestWebhookDeliveries() {
+	ctx := context.Background()
+
+	subscription := &model.WebhookSubscription{
+		URL:    "https://partner.example.com/deliveries",
+		Secret: "0123456789abcdef",
+		Events: []string{"*"},
+		Active: true,
+	}
+	s.Require().NoError(s.webhookRepo.Create(ctx, subscription))
+
+	queue := func(eventID string) {
+		s.Require().NoError(s.webhookRepo.Enqueue(ctx, []*model.WebhookDelivery{{
+			SubscriptionID: subscription.ID,
+			EventID:        eventID,
+			EventType:      model.UserEventCreated,
+			Payload:        []byte(`{"id":"` + eventID + `"}`),
+		}}))
+	}
+	queue("event-1")
+	queue("event-1")
+
+	claimed, err := s.webhookRepo.Claim(ctx, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 1)
+	delivery := claimed[0]
+	s.Equal("event-1", delivery.EventID)
+	s.Equal(subscription.URL, delivery.URL)
+	s.Equal(subscription.Secret, delivery.Secret)
+	s.JSONEq(`{"id":"event-1"}`, string(delivery.Payload))
+
+	again, err := s.webhookRepo.Claim(ctx, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Empty(again, "a claimed delivery is leased")
+
+	// A failed attempt schedules a retry
+	disabled, err := s.webhookRepo.RecordAttempt(ctx, &model.WebhookAttempt{
+		DeliveryID:     delivery.ID,
+		SubscriptionID: subscription.ID,
+		Attempt:        1,
+		StatusCode:     502,
+		Error:          "endpoint returned 502 Bad Gateway",
+	}, time.Hour, 2)
+	s.Require().NoError(err)
+	s.False(disabled)
+
+	attempts, err := s.webhookRepo.Attempts(ctx, subscription.ID, pagination.Request{Limit: 10, Sort: "id"})
+	s.Require().NoError(err)
+	s.Require().Len(attempts.Data, 1)
+	s.Equal("event-1", attempts.Data[0].EventID)
+	s.Equal(502, attempts.Data[0].StatusCode)
+	s.NotNil(attempts.Data[0].NextAttemptAt)
+
+	// A success settles the delivery and resets the failure count
+	_, err = s.db.ExecContext(ctx, "UPDATE webhook_deliveries SET next_attempt_at = NOW(6) WHERE id = ?", delivery.ID)
+	s.Require().NoError(err)
+	claimed, err = s.webhookRepo.Claim(ctx, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 1)
+	s.Equal(1, claimed[0].Attempts)
+
+	_, err = s.webhookRepo.RecordAttempt(ctx, &model.WebhookAttempt{
+		DeliveryID:     delivery.ID,
+		SubscriptionID: subscription.ID,
+		Attempt:        2,
+		Succeeded:      true,
+		StatusCode:     200,
+	}, 0, 2)
+	s.Require().NoError(err)
+
+	fetched, err := s.webhookRepo.GetByID(ctx, subscription.ID)
+	s.Require().NoError(err)
+	s.Zero(fetched.ConsecutiveFailures)
+
+	// Failures in a row disable the subscription, whose deliveries then wait
+	queue("event-2")
+	queue("event-3")
+	claimed, err = s.webhookRepo.Claim(ctx, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 2)
+	for i, d := range claimed {
+		disabled, err = s.webhookRepo.RecordAttempt(ctx, &model.WebhookAttempt{
+			DeliveryID:     d.ID,
+			SubscriptionID: subscription.ID,
+			Attempt:        1,
+			Error:          "connection refused",
+		}, 0, 2)
+		s.Require().NoError(err)
+		s.Equal(i == 1, disabled)
+	}
+
+	fetched, err = s.webhookRepo.GetByID(ctx, subscription.ID)
+	s.Require().NoError(err)
+	s.False(fetched.Active)
+	s.NotNil(fetched.DisabledAt)
+	s.Equal(2, fetched.ConsecutiveFailures)
+
+	queue("event-4")
+	claimed, err = s.webhookRepo.Claim(ctx, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Empty(claimed, "deliveries of a disabled subscription are not sent")
+
+	// Re-enabling resumes the waiting deliveries
+	model.WebhookUpdate{URL: fetched.URL, Events: fetched.Events, Active: true}.Apply(fetched)
+	s.Require().NoError(s.webhookRepo.Update(ctx, fetched))
+	claimed, err = s.webhookRepo.Claim(ctx, 10, time.Minute)
+	s.Require().NoError(err)
+	s.Require().Len(claimed, 1)
+	s.Equal("event-4", claimed[0].EventID)
+
+	attempts, err = s.webhookRepo.Attempts(ctx, subscription.ID, pagination.Request{Limit: 2, Sort: "id", Desc: true})
+	s.Require().NoError(err)
+	s.Equal(int64(4), attempts.Total)
+	s.Len(attempts.Data, 2)
+	s.NotEmpty(attempts.NextCursor)
+}
+
+// TestMessageSchedule tests that a scheduled message keeps its delivery
+// time, is queued once and can only be cancelled while still scheduled.
+func (s *UserRepositoryTestSuite) TestMessageSchedule() {
+	ctx := context.Background()
+
+	at := time.Now().Add(time.Hour)
+	due := model.MessageRequest{Content: "due", DeliverAt: &at}.Message()
+	cancelled := model.MessageRequest{Content: "cancelled", DeliverAt: &at}.Message()
+	for _, m := range []*model.Message{due, cancelled} {
+		s.Require().NoError(s.messageRepo.Create(ctx, m))
+	}
+	defer s.db.ExecContext(ctx, "DELETE FROM messages WHERE id IN (?, ?)", due.ID, cancelled.ID)
+
+	fetched, err := s.messageRepo.GetByID(ctx, due.ID)
+	s.Require().NoError(err)
+	s.Equal(model.MessageScheduled, fetched.Status)
+	s.Require().NotNil(fetched.DeliverAt)
+	s.True(due.DeliverAt.Equal(*fetched.DeliverAt))
+
+	ok, err := s.messageRepo.Cancel(ctx, cancelled.ID)
+	s.Require().NoError(err)
+	s.True(ok)
+	ok, err = s.messageRepo.Cancel(ctx, cancelled.ID)
+	s.Require().NoError(err)
+	s.False(ok, "only scheduled messages can be cancelled")
+
+	queued, err := s.messageRepo.Queue(ctx, due.ID)
+	s.Require().NoError(err)
+	s.Equal(model.MessageQueued, queued.Status)
+	// Queued again after an interrupted publish
+	queued, err = s.messageRepo.Queue(ctx, due.ID)
+	s.Require().NoError(err)
+	s.Equal(model.MessageQueued, queued.Status)
+	ok, err = s.messageRepo.Cancel(ctx, due.ID)
+	s.Require().NoError(err)
+	s.False(ok, "a queued message is on its way")
+
+	skipped, err := s.messageRepo.Queue(ctx, cancelled.ID)
+	s.Require().NoError(err)
+	s.Equal(model.MessageCancelled, skipped.Status)
+
+	missing, err := s.messageRepo.Queue(ctx, uuid.Must(uuid.NewV7()).String())
+	s.Require().NoError(err)
+	s.Nil(missing)
+}
+
+// TestMessageStatus tests that a message settles once, that it can be listed
+// by status and that only settled messages are purged.
+func (s *UserRepositoryTestSuite) TestMessageStatus() {
+	ctx := context.Background()
+
+	published := model.MessageRequest{Content: "published"}.Message()
+	failed := model.MessageRequest{Content: "failed"}.Message()
+	queued := model.MessageRequest{Content: "queued"}.Message()
+	sender := uuid.Must(uuid.NewV7()).String()
+	published.SenderID = sender
+	for _, m := range []*model.Message{published, failed, queued} {
+		s.Require().NoError(s.messageRepo.Create(ctx, m))
+	}
+
+	s.Require().NoError(s.messageRepo.MarkPublished(ctx, published.ID))
+	s.Require().NoError(s.messageRepo.MarkFailed(ctx, failed.ID, "kafka: client has run out of available brokers"))
+	// A late outcome does not overwrite a settled status
+	s.Require().NoError(s.messageRepo.MarkFailed(ctx, published.ID, "late"))
+
+	fetched, err := s.messageRepo.GetByID(ctx, published.ID)
+	s.Require().NoError(err)
+	s.Equal(model.MessagePublished, fetched.Status)
+	s.Equal("published", fetched.Content)
+	s.Empty(fetched.Error)
+	s.NotNil(fetched.PublishedAt)
+
+	fetched, err = s.messageRepo.GetByID(ctx, failed.ID)
+	s.Require().NoError(err)
+	s.Equal(model.MessageFailed, fetched.Status)
+	s.Equal("kafka: client has run out of available brokers", fetched.Error)
+	s.Nil(fetched.PublishedAt)
+
+	_, err = s.messageRepo.GetByID(ctx, uuid.Must(uuid.NewV7()).String())
+	s.ErrorIs(err, repository_message.ErrMessageNotFound)
+
+	page, err := s.messageRepo.List(ctx, pagination.Request{Limit: 10, Sort: "id"}, model.MessageFilter{Status: model.MessageQueued})
+	s.Require().NoError(err)
+	s.Equal(int64(1), page.Total)
+	s.Require().Len(page.Data, 1)
+	s.Equal(queued.ID, page.Data[0].ID)
+
+	page, err = s.messageRepo.List(ctx, pagination.Request{Limit: 2, Sort: "id"}, model.MessageFilter{})
+	s.Require().NoError(err)
+	s.Equal([]string{published.ID, failed.ID}, []string{page.Data[0].ID, page.Data[1].ID}, "oldest first")
+	s.NotEmpty(page.NextCursor)
+
+	page, err = s.messageRepo.List(ctx, pagination.Request{Limit: 10, Sort: "id"}, model.MessageFilter{SenderID: sender})
+	s.Require().NoError(err)
+	s.Equal(int64(1), page.Total)
+	s.Require().Len(page.Data, 1)
+	s.Equal(sender, page.Data[0].SenderID)
+
+	_, err = s.db.ExecContext(ctx, "UPDATE messages SET updated_at = NOW() - INTERVAL 2 DAY")
+	s.Require().NoError(err)
+	purged, err := s.messageRepo.PurgeDeletedOlderThan(ctx, 24*time.Hour, 100)
+	s.Require().NoError(err)
+	s.Equal(int64(2), purged)
+	_, err = s.messageRepo.GetByID(ctx, queued.ID)
+	s.NoError(err, "queued messages are kept")
 }
@@ -0,0 +1,4 @@
+-- ลบตาราง role-based access control
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS roles;
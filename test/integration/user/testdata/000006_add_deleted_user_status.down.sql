@@ -0,0 +1,5 @@
+-- ผู้ใช้ที่ถูกลบต้องเปลี่ยนสถานะก่อนลบค่า deleted ออกจาก enum
+UPDATE users SET status = 'inactive' WHERE status = 'deleted';
+
+ALTER TABLE users
+    MODIFY status ENUM('active', 'inactive', 'suspended') NOT NULL DEFAULT 'active';
@@ -0,0 +1,3 @@
+-- เพิ่มสถานะ deleted สำหรับผู้ใช้ที่ถูกลบ (ไม่สามารถกลับมา active ได้อีก)
+ALTER TABLE users
+    MODIFY status ENUM('active', 'inactive', 'suspended', 'deleted') NOT NULL DEFAULT 'active';
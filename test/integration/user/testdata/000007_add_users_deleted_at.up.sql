@@ -0,0 +1,7 @@
+-- เพิ่มคอลัมน์ deleted_at สำหรับ soft delete (NULL = ยังไม่ถูกลบ)
+ALTER TABLE users
+    ADD COLUMN deleted_at TIMESTAMP NULL DEFAULT NULL,
+    ADD INDEX idx_users_deleted_at (deleted_at);
+
+-- ผู้ใช้ที่มีสถานะ deleted อยู่แล้วให้ถือว่าถูกลบ ณ เวลาที่แก้ไขล่าสุด
+UPDATE users SET deleted_at = updated_at WHERE status = 'deleted';
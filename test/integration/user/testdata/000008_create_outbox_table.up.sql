@@ -0,0 +1,17 @@
+-- ตาราง outbox เก็บ event ใน transaction เดียวกับข้อมูล แล้วให้ relay ส่งเข้า Kafka ภายหลัง
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id VARCHAR(36) NOT NULL,
+    event_type VARCHAR(100) NOT NULL,
+    message_key VARCHAR(255) NOT NULL,
+    payload JSON NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    next_attempt_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    -- NULL = ยังไม่ได้ส่ง
+    published_at TIMESTAMP(6) NULL DEFAULT NULL,
+    INDEX idx_outbox_pending (published_at, id)
+);
@@ -0,0 +1,3 @@
+-- เก็บ trace context ของ request ที่เขียน event เพื่อให้ relay ต่อ trace เดิมตอนส่งเข้า Kafka
+ALTER TABLE outbox
+    ADD COLUMN trace_context JSON NULL AFTER request_id;
@@ -0,0 +1,2 @@
+DELETE FROM role_permissions WHERE permission = 'messages:read';
+DROP TABLE IF EXISTS messages;
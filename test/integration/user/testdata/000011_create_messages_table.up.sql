@@ -0,0 +1,18 @@
+-- ตาราง messages เก็บข้อความที่ส่งผ่าน message API พร้อมสถานะการส่งเข้า broker
+CREATE TABLE IF NOT EXISTS messages (
+    -- UUIDv7 เรียงตามเวลาที่สร้าง
+    id VARCHAR(36) PRIMARY KEY,
+    content TEXT NOT NULL,
+    -- queued, published หรือ failed
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',
+    error TEXT NULL,
+    created_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at TIMESTAMP(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6) ON UPDATE CURRENT_TIMESTAMP(6),
+    published_at TIMESTAMP(6) NULL DEFAULT NULL,
+    INDEX idx_messages_status (status, id),
+    INDEX idx_messages_updated (updated_at)
+);
+
+-- ผู้ที่ส่งข้อความได้ก็ดูสถานะของข้อความได้
+INSERT INTO role_permissions (role_id, permission)
+SELECT id, 'messages:read' FROM roles WHERE name = 'user';
@@ -0,0 +1,2 @@
+DELETE FROM messages WHERE status IN ('scheduled', 'cancelled');
+ALTER TABLE messages DROP COLUMN deliver_at;
@@ -0,0 +1,3 @@
+-- ข้อความที่ตั้งเวลาส่ง: สถานะ scheduled จนถึง deliver_at หรือ cancelled ถ้าถูกยกเลิกก่อน
+ALTER TABLE messages
+    ADD COLUMN deliver_at TIMESTAMP(6) NULL DEFAULT NULL AFTER error;
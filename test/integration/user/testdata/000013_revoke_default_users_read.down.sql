@@ -0,0 +1,2 @@
+INSERT IGNORE INTO role_permissions (role_id, permission)
+SELECT id, 'users:read' FROM roles WHERE name = 'user';
@@ -0,0 +1,4 @@
+-- ผู้ใช้สมัครเองได้ผ่าน POST /users จึงไม่ให้ role เริ่มต้นอ่านข้อมูลผู้ใช้คนอื่น
+DELETE rp FROM role_permissions rp
+JOIN roles r ON r.id = rp.role_id
+WHERE r.name = 'user' AND rp.permission = 'users:read';
@@ -0,0 +1,3 @@
+ALTER TABLE messages
+    DROP INDEX idx_messages_sender,
+    DROP COLUMN sender_id;
@@ -0,0 +1,4 @@
+-- ผู้ส่งข้อความ: ผู้ใช้ทั่วไปเห็นเฉพาะข้อความที่ตัวเองส่ง ข้อความเก่าที่ไม่มีผู้ส่งเห็นได้เฉพาะผู้ที่มี messages:*
+ALTER TABLE messages
+    ADD COLUMN sender_id VARCHAR(36) NULL DEFAULT NULL AFTER id,
+    ADD INDEX idx_messages_sender (sender_id, id);
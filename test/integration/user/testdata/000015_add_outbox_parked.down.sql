@@ -0,0 +1,3 @@
+ALTER TABLE outbox
+    DROP INDEX idx_outbox_aggregate,
+    DROP COLUMN parked_at;
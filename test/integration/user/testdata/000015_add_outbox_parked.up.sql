@@ -0,0 +1,4 @@
+-- ข้อความที่ส่งไม่สำเร็จครบจำนวนครั้งจะถูกพักไว้ (parked_at) ไม่ถูกส่งซ้ำและไม่ขวางข้อความถัดไปของ aggregate เดียวกัน
+ALTER TABLE outbox
+    ADD COLUMN parked_at TIMESTAMP(6) NULL DEFAULT NULL AFTER published_at,
+    ADD INDEX idx_outbox_aggregate (aggregate_type, aggregate_id, id);